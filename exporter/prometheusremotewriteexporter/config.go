@@ -5,12 +5,17 @@ package prometheusremotewriteexporter // import "github.com/open-telemetry/opent
 
 import (
 	"fmt"
+	"regexp"
+	"time"
+	"unicode/utf8"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config/confighttp"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/pdata/pmetric"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/resourcetotelemetry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/prometheusremotewrite"
 )
 
 // Config defines configuration for Remote Write exporter.
@@ -29,6 +34,25 @@ type Config struct {
 	// ExternalLabels defines a map of label keys and values that are allowed to start with reserved prefix "__"
 	ExternalLabels map[string]string `mapstructure:"external_labels"`
 
+	// ExternalLabelsPrecedence decides which value is kept when an external label has the same name as a
+	// label of a series: "series" (the default) keeps the label of the series, "external" the external label.
+	ExternalLabelsPrecedence string `mapstructure:"external_labels_precedence"`
+
+	// LabelSanitization decides how label names are written: "legacy-underscore" (the default) replaces the
+	// characters that are invalid in Prometheus 2.x with underscores, "utf8-passthrough" leaves them untouched.
+	LabelSanitization string `mapstructure:"label_sanitization"`
+
+	// WireCompression decides how the body of requests is encoded: "snappy-block" (the default) with the snappy
+	// block format that remote write requires, "snappy-stream" with the snappy framing format, "none" not at all.
+	WireCompression string `mapstructure:"wire_compression"`
+
+	// MaxLabelValueBytes truncates the label values longer than this number of bytes, other than metric names,
+	// ending them with LabelValueTruncationSuffix. Zero disables the truncation.
+	MaxLabelValueBytes int `mapstructure:"max_label_value_bytes"`
+
+	// LabelValueTruncationSuffix marks the label values that were truncated, within MaxLabelValueBytes.
+	LabelValueTruncationSuffix string `mapstructure:"label_value_truncation_suffix"`
+
 	HTTPClientSettings confighttp.HTTPClientSettings `mapstructure:",squash"` // squash ensures fields are correctly decoded in embedded struct.
 
 	// ResourceToTelemetrySettings is the option for converting resource attributes to telemetry attributes.
@@ -45,6 +69,134 @@ type Config struct {
 
 	// AddMetricSuffixes controls whether unit and type suffixes are added to metrics on export
 	AddMetricSuffixes bool `mapstructure:"add_metric_suffixes"`
+
+	// MetricTypes allows enabling or disabling the conversion of individual OTLP metric types
+	MetricTypes *MetricTypes `mapstructure:"metric_types,omitempty"`
+
+	// Downsampling limits how often samples of selected series are forwarded
+	Downsampling *DownsamplingConfig `mapstructure:"downsampling,omitempty"`
+
+	// MetricNameRules rename metrics on export. The first matching rule applies.
+	MetricNameRules []MetricNameRule `mapstructure:"metric_name_rules"`
+
+	// SendMetadata controls whether the type, help and unit of metrics are sent along with their samples
+	SendMetadata bool `mapstructure:"send_metadata"`
+
+	// Tenants routes the series of each tenant to its own endpoint
+	Tenants *TenantsConfig `mapstructure:"tenants,omitempty"`
+
+	// RequestSigner is the extension that signs every request before it is sent. The extension
+	// must implement RequestSigner.
+	RequestSigner *component.ID `mapstructure:"request_signer,omitempty"`
+}
+
+// MetricNameRule maps OTLP metric names to the exported Prometheus metric name.
+type MetricNameRule struct {
+	// Match is the exact OTLP metric name to rename.
+	Match string `mapstructure:"match"`
+	// MatchRegex is a regular expression matched against the whole OTLP metric name.
+	MatchRegex string `mapstructure:"match_regex"`
+	// Name is the exported metric name. With MatchRegex, it may reference capture groups as ${1}.
+	Name string `mapstructure:"name"`
+}
+
+func (r MetricNameRule) validate() error {
+	if (r.Match == "") == (r.MatchRegex == "") {
+		return fmt.Errorf("exactly one of match or match_regex must be set")
+	}
+	if r.Name == "" {
+		return fmt.Errorf("name can't be empty")
+	}
+	if r.MatchRegex != "" {
+		if _, err := regexp.Compile(r.MatchRegex); err != nil {
+			return fmt.Errorf("invalid match_regex: %w", err)
+		}
+	}
+	return nil
+}
+
+// translatorRule converts the rule to its translator representation. The rule must be valid.
+func (r MetricNameRule) translatorRule() prometheusremotewrite.MetricNameRule {
+	rule := prometheusremotewrite.MetricNameRule{Name: r.Match, Target: r.Name}
+	if r.MatchRegex != "" {
+		rule.Regex = regexp.MustCompile("^(?:" + r.MatchRegex + ")$")
+	}
+	return rule
+}
+
+// DownsamplingConfig configures the forwarding of at most one sample per series and interval.
+type DownsamplingConfig struct {
+	// Rules select the metrics to downsample. The first rule whose pattern
+	// matches the metric name applies; metrics matching no rule are forwarded untouched.
+	Rules []DownsamplingRule `mapstructure:"rules"`
+
+	// SeriesTTL is the time after which a series that received no sample is forgotten.
+	// Defaults to twice the largest rule interval.
+	SeriesTTL time.Duration `mapstructure:"series_ttl"`
+}
+
+// DownsamplingRule forwards at most one sample per Interval for each series
+// whose metric name matches MetricNamePattern.
+type DownsamplingRule struct {
+	// MetricNamePattern is a regular expression matched against the whole exported metric name.
+	MetricNamePattern string `mapstructure:"metric_name_pattern"`
+
+	// Interval is the minimum time between two forwarded samples of the same series.
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// Validate checks if the downsampling configuration is valid.
+func (cfg *DownsamplingConfig) Validate() error {
+	var maxInterval time.Duration
+	for i, rule := range cfg.Rules {
+		if _, err := regexp.Compile(rule.MetricNamePattern); err != nil {
+			return fmt.Errorf("downsampling rule %d: invalid metric_name_pattern: %w", i, err)
+		}
+		if rule.Interval <= 0 {
+			return fmt.Errorf("downsampling rule %d: interval must be positive", i)
+		}
+		if rule.Interval > maxInterval {
+			maxInterval = rule.Interval
+		}
+	}
+	if cfg.SeriesTTL < 0 {
+		return fmt.Errorf("downsampling series_ttl can't be negative")
+	}
+	if cfg.SeriesTTL != 0 && cfg.SeriesTTL < maxInterval {
+		return fmt.Errorf("downsampling series_ttl must not be shorter than the largest interval %v", maxInterval)
+	}
+	return nil
+}
+
+// MetricTypes toggles conversion per OTLP metric type.
+// Data points of a disabled type are dropped before any label is computed.
+type MetricTypes struct {
+	Gauge                bool `mapstructure:"gauge"`
+	Sum                  bool `mapstructure:"sum"`
+	Histogram            bool `mapstructure:"histogram"`
+	ExponentialHistogram bool `mapstructure:"exponential_histogram"`
+	Summary              bool `mapstructure:"summary"`
+}
+
+// skipped returns the set of metric types whose conversion is disabled.
+// A nil MetricTypes enables the conversion of every type.
+func (mt *MetricTypes) skipped() map[pmetric.MetricType]bool {
+	if mt == nil {
+		return nil
+	}
+	skipped := make(map[pmetric.MetricType]bool)
+	for metricType, enabled := range map[pmetric.MetricType]bool{
+		pmetric.MetricTypeGauge:                mt.Gauge,
+		pmetric.MetricTypeSum:                  mt.Sum,
+		pmetric.MetricTypeHistogram:            mt.Histogram,
+		pmetric.MetricTypeExponentialHistogram: mt.ExponentialHistogram,
+		pmetric.MetricTypeSummary:              mt.Summary,
+	} {
+		if !enabled {
+			skipped[metricType] = true
+		}
+	}
+	return skipped
 }
 
 type CreatedMetric struct {
@@ -72,6 +224,14 @@ type RemoteWriteQueue struct {
 	NumConsumers int `mapstructure:"num_consumers"`
 }
 
+const (
+	externalLabelsPrecedenceSeries   = "series"
+	externalLabelsPrecedenceExternal = "external"
+
+	labelSanitizationLegacyUnderscore = "legacy-underscore"
+	labelSanitizationUTF8Passthrough  = "utf8-passthrough"
+)
+
 // TODO(jbd): Add capacity, max_samples_per_send to QueueConfig.
 
 var _ component.Config = (*Config)(nil)
@@ -90,6 +250,46 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("remote write consumer number can't be negative")
 	}
 
+	switch cfg.ExternalLabelsPrecedence {
+	case "", externalLabelsPrecedenceSeries, externalLabelsPrecedenceExternal:
+	default:
+		return fmt.Errorf("invalid external labels precedence %q, must be %q or %q",
+			cfg.ExternalLabelsPrecedence, externalLabelsPrecedenceSeries, externalLabelsPrecedenceExternal)
+	}
+
+	switch cfg.LabelSanitization {
+	case "", labelSanitizationLegacyUnderscore, labelSanitizationUTF8Passthrough:
+	default:
+		return fmt.Errorf("invalid label sanitization %q, must be %q or %q",
+			cfg.LabelSanitization, labelSanitizationLegacyUnderscore, labelSanitizationUTF8Passthrough)
+	}
+
+	if err := validateWireCompression(cfg.WireCompression); err != nil {
+		return err
+	}
+
+	if cfg.MaxLabelValueBytes < 0 {
+		return fmt.Errorf("max label value bytes can't be negative")
+	}
+	if !utf8.ValidString(cfg.LabelValueTruncationSuffix) {
+		return fmt.Errorf("label value truncation suffix must be valid UTF-8")
+	}
+	if cfg.MaxLabelValueBytes > 0 && len(cfg.LabelValueTruncationSuffix) >= cfg.MaxLabelValueBytes {
+		return fmt.Errorf("label value truncation suffix must be shorter than max label value bytes")
+	}
+
+	if cfg.Tenants != nil {
+		if err := cfg.Tenants.validate(cfg.HTTPClientSettings.Headers); err != nil {
+			return err
+		}
+	}
+
+	for i, rule := range cfg.MetricNameRules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("metric name rule %d: %w", i, err)
+		}
+	}
+
 	if cfg.TargetInfo == nil {
 		cfg.TargetInfo = &TargetInfo{
 			Enabled: true,
@@ -100,5 +300,8 @@ func (cfg *Config) Validate() error {
 			Enabled: false,
 		}
 	}
+	if cfg.MetricTypes == nil {
+		cfg.MetricTypes = defaultMetricTypes()
+	}
 	return nil
 }
@@ -54,9 +54,13 @@ func TestLoadConfig(t *testing.T) {
 					QueueSize:    2000,
 					NumConsumers: 10,
 				},
-				AddMetricSuffixes: false,
-				Namespace:         "test-space",
-				ExternalLabels:    map[string]string{"key1": "value1", "key2": "value2"},
+				AddMetricSuffixes:          false,
+				Namespace:                  "test-space",
+				ExternalLabels:             map[string]string{"key1": "value1", "key2": "value2"},
+				ExternalLabelsPrecedence:   externalLabelsPrecedenceExternal,
+				LabelSanitization:          labelSanitizationUTF8Passthrough,
+				MaxLabelValueBytes:         2048,
+				LabelValueTruncationSuffix: "...",
 				HTTPClientSettings: confighttp.HTTPClientSettings{
 					Endpoint: "localhost:8888",
 					TLSSetting: configtls.TLSClientSetting{
@@ -77,6 +81,23 @@ func TestLoadConfig(t *testing.T) {
 					Enabled: true,
 				},
 				CreatedMetric: &CreatedMetric{Enabled: true},
+				MetricTypes: &MetricTypes{
+					Gauge:                true,
+					Sum:                  true,
+					Histogram:            false,
+					ExponentialHistogram: true,
+					Summary:              true,
+				},
+				Downsampling: &DownsamplingConfig{
+					SeriesTTL: 10 * time.Minute,
+					Rules: []DownsamplingRule{
+						{MetricNamePattern: "container_.*", Interval: time.Minute},
+					},
+				},
+				MetricNameRules: []MetricNameRule{
+					{Match: "http.server.duration", Name: "http_request_duration_seconds"},
+					{MatchRegex: `rpc\.(\w+)\.duration`, Name: "rpc_${1}_duration_seconds"},
+				},
 			},
 		},
 		{
@@ -87,6 +108,110 @@ func TestLoadConfig(t *testing.T) {
 			id:           component.NewIDWithName(metadata.Type, "negative_num_consumers"),
 			errorMessage: "remote write consumer number can't be negative",
 		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "invalid_external_labels_precedence"),
+			errorMessage: `invalid external labels precedence "metric", must be "series" or "external"`,
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "invalid_label_sanitization"),
+			errorMessage: `invalid label sanitization "utf8", must be "legacy-underscore" or "utf8-passthrough"`,
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "invalid_wire_compression"),
+			errorMessage: `invalid wire compression "zstd", must be "snappy-block", "snappy-stream" or "none"`,
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "negative_max_label_value_bytes"),
+			errorMessage: "max label value bytes can't be negative",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "long_label_value_truncation_suffix"),
+			errorMessage: "label value truncation suffix must be shorter than max label value bytes",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "empty_tenants_label"),
+			errorMessage: "tenants label can't be empty",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "invalid_downsampling_interval"),
+			errorMessage: "downsampling rule 0: interval must be positive",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "negative_max_retained_segments"),
+			errorMessage: "WAL max retained segments can't be negative",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "invalid_wal_retry_randomization_factor"),
+			errorMessage: "WAL retry randomization factor must be between 0 and 1",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "negative_wal_circuit_breaker_failure_threshold"),
+			errorMessage: "WAL circuit breaker failure threshold can't be negative",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "negative_wal_sink_concurrency"),
+			errorMessage: "WAL sink concurrency can't be negative",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "negative_wal_buffer_size_bytes"),
+			errorMessage: "WAL buffer size bytes can't be negative",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "wal_buffer_size_and_bytes"),
+			errorMessage: "WAL buffer size and buffer size bytes can't both be set",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "negative_wal_read_buffer_size"),
+			errorMessage: "WAL read buffer size can't be negative",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "wal_drop_out_of_order_samples_without_sort"),
+			errorMessage: "WAL out of order samples can only be dropped when samples are sorted by timestamp",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "small_wal_segment_size"),
+			errorMessage: "WAL segment size can't be less than 4096 bytes",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "negative_wal_max_bytes"),
+			errorMessage: "WAL max bytes can't be negative",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "invalid_wal_sync"),
+			errorMessage: `WAL sync "sometimes" must be one of always, interval:<duration> or none`,
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "negative_wal_flush_count"),
+			errorMessage: "WAL flush interval and flush count can't be negative",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "wal_flush_count_with_sync_always"),
+			errorMessage: "WAL flush interval and flush count only apply with a sync of none or interval:<duration>",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "invalid_wal_compression"),
+			errorMessage: `WAL compression "gzip" must be one of none, snappy or zstd`,
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "invalid_wal_future_sample_action"),
+			errorMessage: `WAL future sample action "reject" must be one of drop or clamp`,
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "wal_future_sample_action_without_tolerance"),
+			errorMessage: "WAL future sample action only applies with a future tolerance",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "negative_wal_shards"),
+			errorMessage: "WAL shards can't be negative",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "wal_shards_with_sink_concurrency"),
+			errorMessage: "WAL shards and sink concurrency can't both be set",
+		},
+		{
+			id:           component.NewIDWithName(metadata.Type, "wal_max_bytes_less_than_segment_size"),
+			errorMessage: "WAL max bytes can't be less than the segment size of 1048576 bytes",
+		},
 	}
 
 	for _, tt := range tests {
@@ -133,3 +258,57 @@ func TestDisabledTargetInfo(t *testing.T) {
 
 	assert.False(t, cfg.(*Config).TargetInfo.Enabled)
 }
+
+func TestMetricNameRuleValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   MetricNameRule
+		errMsg string
+	}{
+		{
+			name: "exact",
+			rule: MetricNameRule{Match: "http.server.duration", Name: "http_request_duration_seconds"},
+		},
+		{
+			name: "regex",
+			rule: MetricNameRule{MatchRegex: `http\.(.*)`, Name: "http_${1}"},
+		},
+		{
+			name:   "no_match",
+			rule:   MetricNameRule{Name: "http_request_duration_seconds"},
+			errMsg: "exactly one of match or match_regex must be set",
+		},
+		{
+			name:   "both_matches",
+			rule:   MetricNameRule{Match: "a", MatchRegex: "a", Name: "b"},
+			errMsg: "exactly one of match or match_regex must be set",
+		},
+		{
+			name:   "no_name",
+			rule:   MetricNameRule{Match: "a"},
+			errMsg: "name can't be empty",
+		},
+		{
+			name:   "bad_regex",
+			rule:   MetricNameRule{MatchRegex: "(", Name: "b"},
+			errMsg: "invalid match_regex",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestMetricNameRuleMatchesWholeName(t *testing.T) {
+	rule := MetricNameRule{MatchRegex: `rpc\.(\w+)`, Name: "rpc_${1}"}.translatorRule()
+	assert.True(t, rule.Regex.MatchString("rpc.duration"))
+	assert.False(t, rule.Regex.MatchString("grpc.duration"))
+	assert.False(t, rule.Regex.MatchString("rpc.duration.total"))
+}
@@ -0,0 +1,164 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+type downsamplingRule struct {
+	pattern  *regexp.Regexp
+	interval int64 // milliseconds, the unit of prompb sample timestamps
+}
+
+type downsampledSeries struct {
+	sent     bool
+	lastSent int64     // timestamp of the last forwarded sample
+	lastSeen time.Time // wall clock time the series was last received
+}
+
+// downsampler drops the samples of selected series that arrive less than
+// the configured interval after the previously forwarded sample.
+type downsampler struct {
+	rules []downsamplingRule
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	series    map[string]*downsampledSeries
+	lastSweep time.Time
+}
+
+// newDownsampler returns nil if cfg does not select any metric.
+func newDownsampler(cfg *DownsamplingConfig) *downsampler {
+	if cfg == nil || len(cfg.Rules) == 0 {
+		return nil
+	}
+
+	d := &downsampler{
+		ttl:    cfg.SeriesTTL,
+		now:    time.Now,
+		series: make(map[string]*downsampledSeries),
+	}
+	var maxInterval time.Duration
+	for _, rule := range cfg.Rules {
+		d.rules = append(d.rules, downsamplingRule{
+			pattern:  regexp.MustCompile("^(?:" + rule.MetricNamePattern + ")$"),
+			interval: rule.Interval.Milliseconds(),
+		})
+		if rule.Interval > maxInterval {
+			maxInterval = rule.Interval
+		}
+	}
+	if d.ttl == 0 {
+		d.ttl = 2 * maxInterval
+	}
+	d.lastSweep = d.now()
+	return d
+}
+
+// downsampledSends holds the timestamp of the last sample that apply kept for every series, until
+// commit records them as forwarded.
+type downsampledSends map[string]int64
+
+// apply removes throttled samples from tsMap in place, dropping series left without samples.
+// The kept samples are only recorded as forwarded by commit, once they were exported, so that
+// applying the same samples again, as a retry of a failed export does, keeps them again.
+func (d *downsampler) apply(tsMap map[string]*prompb.TimeSeries) downsampledSends {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	sends := make(downsampledSends)
+	for key, ts := range tsMap {
+		rule, ok := d.match(ts)
+		if !ok {
+			continue
+		}
+
+		state, ok := d.series[key]
+		if !ok {
+			state = &downsampledSeries{}
+			d.series[key] = state
+		}
+		state.lastSeen = now
+
+		sort.Slice(ts.Samples, func(i, j int) bool {
+			return ts.Samples[i].Timestamp < ts.Samples[j].Timestamp
+		})
+		sent, lastSent := state.sent, state.lastSent
+		kept := ts.Samples[:0]
+		for _, sample := range ts.Samples {
+			if !sent || sample.Timestamp-lastSent >= rule.interval {
+				kept = append(kept, sample)
+				sent = true
+				lastSent = sample.Timestamp
+			}
+		}
+		ts.Samples = kept
+		if len(kept) > 0 {
+			sends[key] = lastSent
+		}
+
+		if len(ts.Samples) == 0 && len(ts.Histograms) == 0 {
+			delete(tsMap, key)
+		}
+	}
+
+	if now.Sub(d.lastSweep) >= d.ttl {
+		d.evict(now)
+	}
+	return sends
+}
+
+// commit records the samples that apply kept as forwarded, once they were exported.
+func (d *downsampler) commit(sends downsampledSends) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, lastSent := range sends {
+		state, ok := d.series[key]
+		if !ok {
+			// Evicted while the samples were exported
+			state = &downsampledSeries{lastSeen: d.now()}
+			d.series[key] = state
+		}
+		if !state.sent || lastSent > state.lastSent {
+			state.sent = true
+			state.lastSent = lastSent
+		}
+	}
+}
+
+func (d *downsampler) match(ts *prompb.TimeSeries) (downsamplingRule, bool) {
+	var name string
+	for _, label := range ts.Labels {
+		if label.Name == model.MetricNameLabel {
+			name = label.Value
+			break
+		}
+	}
+	for _, rule := range d.rules {
+		if rule.pattern.MatchString(name) {
+			return rule, true
+		}
+	}
+	return downsamplingRule{}, false
+}
+
+// evict forgets the series that did not receive any sample within the TTL.
+func (d *downsampler) evict(now time.Time) {
+	for key, state := range d.series {
+		if now.Sub(state.lastSeen) >= d.ttl {
+			delete(d.series, key)
+		}
+	}
+	d.lastSweep = now
+}
@@ -0,0 +1,203 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDownsampler(t *testing.T, cfg *DownsamplingConfig, clock *time.Time) *downsampler {
+	require.NoError(t, cfg.Validate())
+	d := newDownsampler(cfg)
+	require.NotNil(t, d)
+	d.now = func() time.Time { return *clock }
+	d.lastSweep = *clock
+	return d
+}
+
+func sampleTimestamps(ts *prompb.TimeSeries) []int64 {
+	var timestamps []int64
+	for _, sample := range ts.Samples {
+		timestamps = append(timestamps, sample.Timestamp)
+	}
+	return timestamps
+}
+
+func Test_downsamplerDisabled(t *testing.T) {
+	assert.Nil(t, newDownsampler(nil))
+	assert.Nil(t, newDownsampler(&DownsamplingConfig{}))
+}
+
+func Test_downsamplerThrottlesWithinInterval(t *testing.T) {
+	clock := time.Now()
+	d := newTestDownsampler(t, &DownsamplingConfig{
+		Rules: []DownsamplingRule{{MetricNamePattern: "throttled_.*", Interval: 10 * time.Second}},
+	}, &clock)
+
+	throttled := getTimeSeries(getPromLabels("__name__", "throttled_total"),
+		getSample(1, 25000), getSample(1, 0), getSample(1, 5000), getSample(1, 10000), getSample(1, 19999))
+	untouched := getTimeSeries(getPromLabels("__name__", "other_total"),
+		getSample(1, 0), getSample(1, 5000))
+	tsMap := map[string]*prompb.TimeSeries{"throttled": throttled, "other": untouched}
+
+	d.apply(tsMap)
+
+	require.Len(t, tsMap, 2)
+	assert.Equal(t, []int64{0, 10000, 25000}, sampleTimestamps(tsMap["throttled"]))
+	assert.Equal(t, []int64{0, 5000}, sampleTimestamps(tsMap["other"]))
+}
+
+func Test_downsamplerMatchesWholeName(t *testing.T) {
+	clock := time.Now()
+	d := newTestDownsampler(t, &DownsamplingConfig{
+		Rules: []DownsamplingRule{{MetricNamePattern: "cpu", Interval: 10 * time.Second}},
+	}, &clock)
+
+	tsMap := map[string]*prompb.TimeSeries{
+		"cpu":     getTimeSeries(getPromLabels("__name__", "cpu"), getSample(1, 0), getSample(1, 5000)),
+		"process": getTimeSeries(getPromLabels("__name__", "process_cpu_seconds_total"), getSample(1, 0), getSample(1, 5000)),
+	}
+	d.apply(tsMap)
+
+	assert.Equal(t, []int64{0}, sampleTimestamps(tsMap["cpu"]))
+	// The pattern only appears in the name, which isn't downsampled
+	assert.Equal(t, []int64{0, 5000}, sampleTimestamps(tsMap["process"]))
+}
+
+func Test_downsamplerThrottlesAcrossCalls(t *testing.T) {
+	clock := time.Now()
+	d := newTestDownsampler(t, &DownsamplingConfig{
+		Rules: []DownsamplingRule{{MetricNamePattern: ".*", Interval: 10 * time.Second}},
+	}, &clock)
+	labels := getPromLabels("__name__", "metric")
+
+	tsMap := map[string]*prompb.TimeSeries{"metric": getTimeSeries(labels, getSample(1, 1000))}
+	d.commit(d.apply(tsMap))
+	assert.Equal(t, []int64{1000}, sampleTimestamps(tsMap["metric"]))
+
+	// Within the interval of the last forwarded sample: the series is dropped entirely.
+	tsMap = map[string]*prompb.TimeSeries{"metric": getTimeSeries(labels, getSample(1, 6000))}
+	d.commit(d.apply(tsMap))
+	assert.Empty(t, tsMap)
+
+	// A full interval after the last forwarded sample.
+	tsMap = map[string]*prompb.TimeSeries{"metric": getTimeSeries(labels, getSample(1, 11000), getSample(1, 12000))}
+	d.commit(d.apply(tsMap))
+	assert.Equal(t, []int64{11000}, sampleTimestamps(tsMap["metric"]))
+}
+
+func Test_downsamplerRetryAfterFailedExport(t *testing.T) {
+	clock := time.Now()
+	d := newTestDownsampler(t, &DownsamplingConfig{
+		Rules: []DownsamplingRule{{MetricNamePattern: ".*", Interval: 10 * time.Second}},
+	}, &clock)
+	labels := getPromLabels("__name__", "metric")
+	samples := func() map[string]*prompb.TimeSeries {
+		return map[string]*prompb.TimeSeries{"metric": getTimeSeries(labels, getSample(1, 0), getSample(1, 5000), getSample(1, 10000))}
+	}
+
+	// The export failed, so the samples are not committed and a retry keeps the same ones.
+	tsMap := samples()
+	d.apply(tsMap)
+	assert.Equal(t, []int64{0, 10000}, sampleTimestamps(tsMap["metric"]))
+	tsMap = samples()
+	d.commit(d.apply(tsMap))
+	assert.Equal(t, []int64{0, 10000}, sampleTimestamps(tsMap["metric"]))
+
+	// Once exported, the samples are throttled.
+	tsMap = samples()
+	d.apply(tsMap)
+	assert.Empty(t, tsMap)
+}
+
+func Test_downsamplerFirstMatchingRuleApplies(t *testing.T) {
+	clock := time.Now()
+	d := newTestDownsampler(t, &DownsamplingConfig{
+		Rules: []DownsamplingRule{
+			{MetricNamePattern: "fast_.*", Interval: time.Second},
+			{MetricNamePattern: ".*", Interval: time.Minute},
+		},
+	}, &clock)
+
+	tsMap := map[string]*prompb.TimeSeries{
+		"fast": getTimeSeries(getPromLabels("__name__", "fast_metric"), getSample(1, 0), getSample(1, 1000), getSample(1, 2000)),
+		"slow": getTimeSeries(getPromLabels("__name__", "slow_metric"), getSample(1, 0), getSample(1, 1000), getSample(1, 2000)),
+	}
+	d.apply(tsMap)
+
+	assert.Equal(t, []int64{0, 1000, 2000}, sampleTimestamps(tsMap["fast"]))
+	assert.Equal(t, []int64{0}, sampleTimestamps(tsMap["slow"]))
+}
+
+func Test_downsamplerEvictsStaleSeries(t *testing.T) {
+	clock := time.Now()
+	d := newTestDownsampler(t, &DownsamplingConfig{
+		Rules:     []DownsamplingRule{{MetricNamePattern: ".*", Interval: time.Second}},
+		SeriesTTL: time.Minute,
+	}, &clock)
+
+	// Every round sends a fresh set of series, as happens with high churn labels.
+	for round := 0; round < 10; round++ {
+		tsMap := make(map[string]*prompb.TimeSeries)
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("round%d_series%d", round, i)
+			tsMap[key] = getTimeSeries(getPromLabels("__name__", "metric", "id", key), getSample(1, clock.UnixMilli()))
+		}
+		d.apply(tsMap)
+		assert.Len(t, tsMap, 100)
+		// Series live up to one TTL past their last sample, plus up to one TTL until the next sweep.
+		assert.LessOrEqual(t, len(d.series), 300)
+
+		clock = clock.Add(30 * time.Second)
+	}
+}
+
+func Test_downsamplerDefaultSeriesTTL(t *testing.T) {
+	d := newDownsampler(&DownsamplingConfig{
+		Rules: []DownsamplingRule{
+			{MetricNamePattern: "a", Interval: time.Second},
+			{MetricNamePattern: "b", Interval: time.Minute},
+		},
+	})
+	assert.Equal(t, 2*time.Minute, d.ttl)
+}
+
+func TestDownsamplingConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    DownsamplingConfig
+		errMsg string
+	}{
+		{
+			name: "valid",
+			cfg:  DownsamplingConfig{Rules: []DownsamplingRule{{MetricNamePattern: ".*", Interval: time.Second}}},
+		},
+		{
+			name:   "bad_pattern",
+			cfg:    DownsamplingConfig{Rules: []DownsamplingRule{{MetricNamePattern: "(", Interval: time.Second}}},
+			errMsg: "downsampling rule 0: invalid metric_name_pattern",
+		},
+		{
+			name:   "ttl_shorter_than_interval",
+			cfg:    DownsamplingConfig{Rules: []DownsamplingRule{{MetricNamePattern: ".*", Interval: time.Minute}}, SeriesTTL: time.Second},
+			errMsg: "downsampling series_ttl must not be shorter than the largest interval 1m0s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
@@ -16,10 +16,12 @@ import (
 	"sync"
 
 	"github.com/gogo/protobuf/proto"
-	"github.com/golang/snappy"
 	"github.com/prometheus/prometheus/prompb"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configopaque"
 	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/pdata/pmetric"
@@ -40,10 +42,23 @@ type prwExporter struct {
 	concurrency     int
 	userAgentHeader string
 	clientSettings  *confighttp.HTTPClientSettings
+	signerID        *component.ID
+	signer          RequestSigner
+	wireCompression string
 	settings        component.TelemetrySettings
+	// host is the host the exporter was started with, that permanent failures of the WAL sink
+	// are reported to.
+	host component.Host
 
 	wal              *prweWAL
 	exporterSettings prometheusremotewrite.Settings
+	downsampler      *downsampler
+	truncator        *labelTruncator
+	sendMetadata     bool
+
+	// tenantLabel is the label whose value selects the destination of a series among tenants.
+	tenantLabel string
+	tenants     map[string]*tenant
 }
 
 // newPRWExporter initializes a new prwExporter instance and sets fields accordingly.
@@ -58,6 +73,11 @@ func newPRWExporter(cfg *Config, set exporter.CreateSettings) (*prwExporter, err
 		return nil, errors.New("invalid endpoint")
 	}
 
+	nameRules := make([]prometheusremotewrite.MetricNameRule, 0, len(cfg.MetricNameRules))
+	for _, rule := range cfg.MetricNameRules {
+		nameRules = append(nameRules, rule.translatorRule())
+	}
+
 	userAgentHeader := fmt.Sprintf("%s/%s", strings.ReplaceAll(strings.ToLower(set.BuildInfo.Description), " ", "-"), set.BuildInfo.Version)
 
 	prwe := &prwExporter{
@@ -67,32 +87,52 @@ func newPRWExporter(cfg *Config, set exporter.CreateSettings) (*prwExporter, err
 		userAgentHeader: userAgentHeader,
 		concurrency:     cfg.RemoteWriteQueue.NumConsumers,
 		clientSettings:  &cfg.HTTPClientSettings,
+		signerID:        cfg.RequestSigner,
+		wireCompression: cfg.WireCompression,
 		settings:        set.TelemetrySettings,
 		exporterSettings: prometheusremotewrite.Settings{
-			Namespace:           cfg.Namespace,
-			ExternalLabels:      sanitizedLabels,
-			DisableTargetInfo:   !cfg.TargetInfo.Enabled,
-			ExportCreatedMetric: cfg.CreatedMetric.Enabled,
-			AddMetricSuffixes:   cfg.AddMetricSuffixes,
+			Namespace:              cfg.Namespace,
+			ExternalLabels:         sanitizedLabels,
+			ExternalLabelsOverride: cfg.ExternalLabelsPrecedence == externalLabelsPrecedenceExternal,
+			PassthroughLabelNames:  cfg.LabelSanitization == labelSanitizationUTF8Passthrough,
+			DisableTargetInfo:      !cfg.TargetInfo.Enabled,
+			ExportCreatedMetric:    cfg.CreatedMetric.Enabled,
+			AddMetricSuffixes:      cfg.AddMetricSuffixes,
+			SkipMetricTypes:        cfg.MetricTypes.skipped(),
+			MetricNameRules:        nameRules,
 		},
+		downsampler:  newDownsampler(cfg.Downsampling),
+		truncator:    newLabelTruncator(cfg.MaxLabelValueBytes, cfg.LabelValueTruncationSuffix),
+		sendMetadata: cfg.SendMetadata,
 	}
-	if cfg.WAL == nil {
-		return prwe, nil
+	if cfg.WAL != nil {
+		prwe.wal, err = newWAL(cfg.WAL, prwe.export)
+		if err != nil {
+			return nil, err
+		}
+		prwe.wal.onSinkStatus = prwe.reportSinkStatus(set.Logger.Named("prw.wal"))
 	}
-
-	prwe.wal, err = newWAL(cfg.WAL, prwe.export)
-	if err != nil {
-		return nil, err
+	if cfg.Tenants != nil {
+		prwe.tenantLabel = cfg.Tenants.Label
+		if prwe.tenants, err = prwe.newTenants(cfg.Tenants); err != nil {
+			return nil, err
+		}
 	}
 	return prwe, nil
 }
 
 // Start creates the prometheus client
 func (prwe *prwExporter) Start(ctx context.Context, host component.Host) (err error) {
+	prwe.host = host
 	prwe.client, err = prwe.clientSettings.ToClient(host, prwe.settings)
 	if err != nil {
 		return err
 	}
+	if prwe.signerID != nil {
+		if prwe.signer, err = requestSignerFromHost(host, *prwe.signerID); err != nil {
+			return err
+		}
+	}
 	return prwe.turnOnWALIfEnabled(contextWithLogger(ctx, prwe.settings.Logger.Named("prw.wal")))
 }
 
@@ -127,46 +167,133 @@ func (prwe *prwExporter) PushMetrics(ctx context.Context, md pmetric.Metrics) er
 	case <-prwe.closeChan:
 		return errors.New("shutdown has been called")
 	default:
+		recordSkippedDataPoints(ctx, md, prwe.exporterSettings.SkipMetricTypes)
 		tsMap, err := prometheusremotewrite.FromMetrics(md, prwe.exporterSettings)
 		if err != nil {
 			err = consumererror.NewPermanent(err)
 		}
+		var sends downsampledSends
+		if prwe.downsampler != nil {
+			sends = prwe.downsampler.apply(tsMap)
+		}
+		var metadata []prompb.MetricMetadata
+		if prwe.sendMetadata {
+			metadata = prometheusremotewrite.OtelMetricsToMetadata(md, prwe.exporterSettings)
+		}
 		// Call export even if a conversion error, since there may be points that were successfully converted.
-		return multierr.Combine(err, prwe.handleExport(ctx, tsMap))
+		exportErr := prwe.handleExport(ctx, tsMap, metadata)
+		if exportErr == nil && prwe.downsampler != nil {
+			prwe.downsampler.commit(sends)
+		}
+		return multierr.Combine(err, exportErr)
 	}
 }
 
+// recordSkippedDataPoints counts, per metric type, the data points that are dropped
+// because the conversion of their metric type is disabled.
+func recordSkippedDataPoints(ctx context.Context, md pmetric.Metrics, skipped map[pmetric.MetricType]bool) {
+	if len(skipped) == 0 {
+		return
+	}
+
+	counts := make(map[pmetric.MetricType]int64)
+	resourceMetricsSlice := md.ResourceMetrics()
+	for i := 0; i < resourceMetricsSlice.Len(); i++ {
+		scopeMetricsSlice := resourceMetricsSlice.At(i).ScopeMetrics()
+		for j := 0; j < scopeMetricsSlice.Len(); j++ {
+			metricSlice := scopeMetricsSlice.At(j).Metrics()
+			for k := 0; k < metricSlice.Len(); k++ {
+				metric := metricSlice.At(k)
+				if skipped[metric.Type()] {
+					counts[metric.Type()] += int64(dataPointCount(metric))
+				}
+			}
+		}
+	}
+
+	for metricType, count := range counts {
+		_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(tagMetricType, metricType.String())}, mSkippedDataPoints.M(count))
+	}
+}
+
+func dataPointCount(metric pmetric.Metric) int {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		return metric.Gauge().DataPoints().Len()
+	case pmetric.MetricTypeSum:
+		return metric.Sum().DataPoints().Len()
+	case pmetric.MetricTypeHistogram:
+		return metric.Histogram().DataPoints().Len()
+	case pmetric.MetricTypeExponentialHistogram:
+		return metric.ExponentialHistogram().DataPoints().Len()
+	case pmetric.MetricTypeSummary:
+		return metric.Summary().DataPoints().Len()
+	}
+	return 0
+}
+
 func validateAndSanitizeExternalLabels(cfg *Config) (map[string]string, error) {
 	sanitizedLabels := make(map[string]string)
 	for key, value := range cfg.ExternalLabels {
 		if key == "" || value == "" {
 			return nil, fmt.Errorf("prometheus remote write: external labels configuration contains an empty key or value")
 		}
+		if cfg.LabelSanitization == labelSanitizationUTF8Passthrough {
+			sanitizedLabels[key] = value
+			continue
+		}
 		sanitizedLabels[prometheustranslator.NormalizeLabel(key)] = value
 	}
 
 	return sanitizedLabels, nil
 }
 
-func (prwe *prwExporter) handleExport(ctx context.Context, tsMap map[string]*prompb.TimeSeries) error {
+func (prwe *prwExporter) handleExport(ctx context.Context, tsMap map[string]*prompb.TimeSeries, metadata []prompb.MetricMetadata) error {
 	// There are no metrics to export, so return.
 	if len(tsMap) == 0 {
 		return nil
 	}
+	if len(prwe.tenants) == 0 {
+		return prwe.handleTenantExport(ctx, nil, tsMap, metadata)
+	}
 
+	var errs error
+	for name, tenantMap := range splitByTenant(tsMap, prwe.tenantLabel, prwe.tenants) {
+		// Series of unknown tenants are handled with a nil tenant, sent to the endpoint of the exporter.
+		errs = multierr.Append(errs, prwe.handleTenantExport(ctx, prwe.tenants[name], tenantMap, metadataOf(metadata, tenantMap)))
+	}
+	return errs
+}
+
+// handleTenantExport exports the series of tenant t, or of no tenant if t is nil.
+func (prwe *prwExporter) handleTenantExport(ctx context.Context, t *tenant, tsMap map[string]*prompb.TimeSeries, metadata []prompb.MetricMetadata) error {
+	// The label values are truncated before the series are written to the WAL, so that their
+	// size is accounted for and the WAL holds what is sent.
+	prwe.truncator.applyToSeries(ctx, tsMap)
 	// Calls the helper function to convert and batch the TsMap to the desired format
 	requests, err := batchTimeSeries(tsMap, maxBatchByteSize)
 	if err != nil {
 		return err
 	}
+	if len(metadata) > 0 {
+		// The metadata is sent in its own request, after the samples it describes.
+		requests = append(requests, &prompb.WriteRequest{Metadata: metadata})
+	}
+	endpointURL, headers, wal := prwe.endpointURL, map[string]configopaque.String(nil), prwe.wal
+	if t != nil {
+		endpointURL, headers, wal = t.endpointURL, t.headers, t.wal
+	}
 	if !prwe.walEnabled() {
 		// Perform a direct export otherwise.
-		return prwe.export(ctx, requests)
+		if err = prwe.exportTo(ctx, endpointURL, headers, requests); err != nil {
+			return consumererror.NewPermanent(err)
+		}
+		return nil
 	}
 
 	// Otherwise the WAL is enabled, and just persist the requests to the WAL
 	// and they'll be exported in another goroutine to the RemoteWrite endpoint.
-	if err = prwe.wal.persistToWAL(requests); err != nil {
+	if err = wal.persistToWAL(requests); err != nil {
 		return consumererror.NewPermanent(err)
 	}
 	return nil
@@ -174,6 +301,13 @@ func (prwe *prwExporter) handleExport(ctx context.Context, tsMap map[string]*pro
 
 // export sends a Snappy-compressed WriteRequest containing TimeSeries to a remote write endpoint in order
 func (prwe *prwExporter) export(ctx context.Context, requests []*prompb.WriteRequest) error {
+	// The requests replayed from the WAL may have been written before the label values were limited.
+	prwe.truncator.applyToRequests(ctx, requests)
+	return prwe.exportTo(ctx, prwe.endpointURL, nil, requests)
+}
+
+// exportTo sends the requests to endpointURL, with the headers added to the headers of the exporter.
+func (prwe *prwExporter) exportTo(ctx context.Context, endpointURL *url.URL, headers map[string]configopaque.String, requests []*prompb.WriteRequest) error {
 	input := make(chan *prompb.WriteRequest, len(requests))
 	for _, request := range requests {
 		input <- request
@@ -201,9 +335,9 @@ func (prwe *prwExporter) export(ctx context.Context, requests []*prompb.WriteReq
 					if !ok {
 						return
 					}
-					if errExecute := prwe.execute(ctx, request); errExecute != nil {
+					if errExecute := prwe.execute(ctx, endpointURL, headers, request); errExecute != nil {
 						mu.Lock()
-						errs = multierr.Append(errs, consumererror.NewPermanent(errExecute))
+						errs = multierr.Append(errs, errExecute)
 						mu.Unlock()
 					}
 				}
@@ -215,36 +349,27 @@ func (prwe *prwExporter) export(ctx context.Context, requests []*prompb.WriteReq
 	return errs
 }
 
-func (prwe *prwExporter) execute(ctx context.Context, writeReq *prompb.WriteRequest) error {
-	// Uses proto.Marshal to convert the WriteRequest into bytes array
-	data, err := proto.Marshal(writeReq)
+func (prwe *prwExporter) execute(ctx context.Context, endpointURL *url.URL, headers map[string]configopaque.String, writeReq *prompb.WriteRequest) error {
+	req, err := prwe.prepareRequest(ctx, endpointURL, headers, writeReq)
 	if err != nil {
 		return consumererror.NewPermanent(err)
 	}
-	buf := make([]byte, len(data), cap(data))
-	compressedData := snappy.Encode(buf, data)
-
-	// Create the HTTP POST request to send to the endpoint
-	req, err := http.NewRequestWithContext(ctx, "POST", prwe.endpointURL.String(), bytes.NewReader(compressedData))
-	if err != nil {
-		return consumererror.NewPermanent(err)
+	if prwe.signer != nil {
+		// A request that can't be signed, such as while credentials can't be retrieved, may be signed when it is retried
+		if err = prwe.signer.SignRequest(req); err != nil {
+			return fmt.Errorf("failed to sign request: %w", err)
+		}
 	}
 
-	// Add necessary headers specified by:
-	// https://cortexmetrics.io/docs/apis/#remote-api
-	req.Header.Add("Content-Encoding", "snappy")
-	req.Header.Set("Content-Type", "application/x-protobuf")
-	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
-	req.Header.Set("User-Agent", prwe.userAgentHeader)
-
 	resp, err := prwe.client.Do(req)
 	if err != nil {
-		return consumererror.NewPermanent(err)
+		// Transport errors, such as a refused connection or a timeout, are recoverable
+		return err
 	}
 	defer resp.Body.Close()
 
 	// 2xx status code is considered a success
-	// 5xx errors are recoverable and the exporter should retry
+	// 5xx and 429 errors are recoverable and the exporter should retry
 	// Reference for different behavior according to status code:
 	// https://github.com/prometheus/prometheus/pull/2552/files#diff-ae8db9d16d8057358e49d694522e7186
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
@@ -252,12 +377,44 @@ func (prwe *prwExporter) execute(ctx context.Context, writeReq *prompb.WriteRequ
 	}
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
 	rerr := fmt.Errorf("remote write returned HTTP status %v; err = %w: %s", resp.Status, err, body)
-	if resp.StatusCode >= 500 && resp.StatusCode < 600 {
+	if resp.StatusCode >= 500 && resp.StatusCode < 600 || resp.StatusCode == http.StatusTooManyRequests {
 		return rerr
 	}
 	return consumererror.NewPermanent(rerr)
 }
 
+// prepareRequest builds the HTTP request that writeReq is sent to endpointURL with.
+func (prwe *prwExporter) prepareRequest(ctx context.Context, endpointURL *url.URL, headers map[string]configopaque.String, writeReq *prompb.WriteRequest) (*http.Request, error) {
+	// Uses proto.Marshal to convert the WriteRequest into bytes array
+	data, err := proto.Marshal(writeReq)
+	if err != nil {
+		return nil, err
+	}
+	body, contentEncoding, err := encodeWireBody(prwe.wireCompression, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the HTTP POST request to send to the endpoint
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, string(value))
+	}
+
+	// Add necessary headers specified by:
+	// https://cortexmetrics.io/docs/apis/#remote-api
+	if contentEncoding != "" {
+		req.Header.Add("Content-Encoding", contentEncoding)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", prwe.userAgentHeader)
+	return req, nil
+}
+
 func (prwe *prwExporter) walEnabled() bool { return prwe.wal != nil }
 
 func (prwe *prwExporter) turnOnWALIfEnabled(ctx context.Context) error {
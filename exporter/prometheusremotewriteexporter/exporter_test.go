@@ -9,8 +9,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
@@ -18,10 +21,13 @@ import (
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/config/confighttp"
 	"go.opentelemetry.io/collector/config/configtls"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
 	"go.opentelemetry.io/collector/exporter/exportertest"
@@ -322,6 +328,40 @@ func Test_export(t *testing.T) {
 	}
 }
 
+// Test_executeRetryableStatus checks that only the responses that reject the payload are permanent errors.
+func Test_executeRetryableStatus(t *testing.T) {
+	for _, tt := range []struct {
+		code      int
+		permanent bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusForbidden, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	} {
+		t.Run(http.StatusText(tt.code), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+			}))
+			defer server.Close()
+
+			cfg := createDefaultConfig().(*Config)
+			cfg.HTTPClientSettings.Endpoint = server.URL
+			prwe, err := newPRWExporter(cfg, exportertest.NewNopCreateSettings())
+			require.NoError(t, err)
+			require.NoError(t, prwe.Start(context.Background(), componenttest.NewNopHost()))
+			defer func() {
+				require.NoError(t, prwe.Shutdown(context.Background()))
+			}()
+
+			err = prwe.execute(context.Background(), prwe.endpointURL, nil, &prompb.WriteRequest{})
+			require.Error(t, err)
+			assert.Equal(t, tt.permanent, consumererror.IsPermanent(err))
+		})
+	}
+}
+
 func TestNoMetricsNoError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusAccepted)
@@ -359,7 +399,7 @@ func runExportPipeline(ts *prompb.TimeSeries, endpoint *url.URL) error {
 		return err
 	}
 
-	return prwe.handleExport(context.Background(), testmap)
+	return prwe.handleExport(context.Background(), testmap, nil)
 }
 
 // Test_PushMetrics checks the number of TimeSeries received by server and the number of metrics dropped is the same as
@@ -717,6 +757,312 @@ func Test_PushMetrics(t *testing.T) {
 	}
 }
 
+func Test_PushMetricsSkipsDisabledMetricTypes(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	received := make(chan *prompb.WriteRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		dest, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		wr := &prompb.WriteRequest{}
+		require.NoError(t, proto.Unmarshal(dest, wr))
+		received <- wr
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		HTTPClientSettings: confighttp.HTTPClientSettings{
+			Endpoint: server.URL,
+		},
+		RemoteWriteQueue: RemoteWriteQueue{NumConsumers: 1},
+		TargetInfo:       &TargetInfo{Enabled: false},
+		CreatedMetric:    &CreatedMetric{Enabled: false},
+		MetricTypes: &MetricTypes{
+			Gauge:                true,
+			Sum:                  true,
+			Histogram:            false,
+			ExponentialHistogram: true,
+			Summary:              true,
+		},
+	}
+	set := exportertest.NewNopCreateSettings()
+	set.BuildInfo = component.BuildInfo{Description: "OpenTelemetry Collector", Version: "1.0"}
+	prwe, err := newPRWExporter(cfg, set)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, prwe.Start(ctx, componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, prwe.Shutdown(ctx))
+	}()
+
+	md := getMetricsFromMetricList(validMetrics1[validIntGauge], validMetrics1[validHistogram], validMetrics2[validHistogram])
+	require.NoError(t, prwe.PushMetrics(ctx, md))
+
+	wr := <-received
+	require.Len(t, wr.Timeseries, 1)
+	assert.Contains(t, wr.Timeseries[0].Labels, prompb.Label{Name: "__name__", Value: validIntGauge})
+
+	rows, err := view.RetrieveData(mSkippedDataPoints.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, []tag.Tag{{Key: tagMetricType, Value: pmetric.MetricTypeHistogram.String()}}, rows[0].Tags)
+	assert.Equal(t, float64(2), rows[0].Data.(*view.SumData).Value)
+}
+
+func Test_PushMetricsSendsMetadata(t *testing.T) {
+	received := make(chan *prompb.WriteRequest, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		dest, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		wr := &prompb.WriteRequest{}
+		require.NoError(t, proto.Unmarshal(dest, wr))
+		received <- wr
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		HTTPClientSettings: confighttp.HTTPClientSettings{
+			Endpoint: server.URL,
+		},
+		RemoteWriteQueue: RemoteWriteQueue{NumConsumers: 1},
+		TargetInfo:       &TargetInfo{Enabled: false},
+		CreatedMetric:    &CreatedMetric{Enabled: false},
+		SendMetadata:     true,
+	}
+	set := exportertest.NewNopCreateSettings()
+	set.BuildInfo = component.BuildInfo{Description: "OpenTelemetry Collector", Version: "1.0"}
+	prwe, err := newPRWExporter(cfg, set)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, prwe.Start(ctx, componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, prwe.Shutdown(ctx))
+	}()
+
+	md := getMetricsFromMetricList(validMetrics1[validIntGauge])
+	require.NoError(t, prwe.PushMetrics(ctx, md))
+
+	var metadata []prompb.MetricMetadata
+	for i := 0; i < 2; i++ {
+		metadata = append(metadata, (<-received).Metadata...)
+	}
+	require.Len(t, metadata, 1)
+	assert.Equal(t, prompb.MetricMetadata_GAUGE, metadata[0].Type)
+	assert.Equal(t, validIntGauge, metadata[0].MetricFamilyName)
+}
+
+// Test_PushMetricsDownsamplingRetry checks that the samples of an export that failed are kept by
+// the downsampler when the same metrics are pushed again.
+func Test_PushMetricsDownsamplingRetry(t *testing.T) {
+	received := make(chan *prompb.WriteRequest, 1)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		dest, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		wr := &prompb.WriteRequest{}
+		require.NoError(t, proto.Unmarshal(dest, wr))
+		received <- wr
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		HTTPClientSettings: confighttp.HTTPClientSettings{
+			Endpoint: server.URL,
+		},
+		RemoteWriteQueue: RemoteWriteQueue{NumConsumers: 1},
+		TargetInfo:       &TargetInfo{Enabled: false},
+		CreatedMetric:    &CreatedMetric{Enabled: false},
+		Downsampling: &DownsamplingConfig{
+			Rules: []DownsamplingRule{{MetricNamePattern: ".*", Interval: time.Hour}},
+		},
+	}
+	set := exportertest.NewNopCreateSettings()
+	set.BuildInfo = component.BuildInfo{Description: "OpenTelemetry Collector", Version: "1.0"}
+	prwe, err := newPRWExporter(cfg, set)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, prwe.Start(ctx, componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, prwe.Shutdown(ctx))
+	}()
+
+	md := getMetricsFromMetricList(validMetrics1[validIntGauge])
+	require.Error(t, prwe.PushMetrics(ctx, md))
+	require.NoError(t, prwe.PushMetrics(ctx, md))
+
+	wr := <-received
+	require.Len(t, wr.Timeseries, 1)
+	assert.Len(t, wr.Timeseries[0].Samples, 1)
+
+	// Once exported, the samples are throttled.
+	require.NoError(t, prwe.PushMetrics(ctx, md))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+// Test_PushMetricsExternalLabelsWAL checks external labels are added, sorted, to the requests written
+// to the WAL, so that they are replayed with them.
+func Test_PushMetricsExternalLabelsWAL(t *testing.T) {
+	tests := []struct {
+		name       string
+		precedence string
+		collector  string
+	}{
+		{name: "series_wins", precedence: externalLabelsPrecedenceSeries, collector: "series"},
+		{name: "external_wins", precedence: externalLabelsPrecedenceExternal, collector: "external"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				HTTPClientSettings: confighttp.HTTPClientSettings{
+					Endpoint: "http://localhost:9009/api/v1/push",
+				},
+				ExternalLabels:           map[string]string{"collector": "external", "cluster": "c1"},
+				ExternalLabelsPrecedence: tt.precedence,
+				RemoteWriteQueue:         RemoteWriteQueue{NumConsumers: 1},
+				TargetInfo:               &TargetInfo{Enabled: false},
+				CreatedMetric:            &CreatedMetric{Enabled: false},
+				WAL:                      &WALConfig{Directory: t.TempDir()},
+			}
+			req := pushToWALAndReplay(t, cfg, gaugeWithAttributes("mem_used", map[string]string{
+				"zone":      "eu",
+				"collector": "series",
+			}))
+			require.Len(t, req.Timeseries, 1)
+			assert.Equal(t, []prompb.Label{
+				{Name: "__name__", Value: "mem_used"},
+				{Name: "cluster", Value: "c1"},
+				{Name: "collector", Value: tt.collector},
+				{Name: "zone", Value: "eu"},
+			}, req.Timeseries[0].Labels)
+		})
+	}
+}
+
+// Test_PushMetricsLabelSanitizationWAL checks dotted attribute names are written to the WAL, and
+// replayed from it, as the label sanitization mode decides.
+func Test_PushMetricsLabelSanitizationWAL(t *testing.T) {
+	tests := []struct {
+		name         string
+		sanitization string
+		want         []prompb.Label
+	}{
+		{
+			name:         "legacy_underscore",
+			sanitization: labelSanitizationLegacyUnderscore,
+			want: []prompb.Label{
+				{Name: "__name__", Value: "http_requests"},
+				{Name: "deployment_environment", Value: "prod"},
+				{Name: "http_method", Value: "GET"},
+			},
+		},
+		{
+			name:         "utf8_passthrough",
+			sanitization: labelSanitizationUTF8Passthrough,
+			want: []prompb.Label{
+				{Name: "__name__", Value: "http_requests"},
+				{Name: "deployment.environment", Value: "prod"},
+				{Name: "http.method", Value: "GET"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				HTTPClientSettings: confighttp.HTTPClientSettings{
+					Endpoint: "http://localhost:9009/api/v1/push",
+				},
+				ExternalLabels:    map[string]string{"deployment.environment": "prod"},
+				LabelSanitization: tt.sanitization,
+				RemoteWriteQueue:  RemoteWriteQueue{NumConsumers: 1},
+				TargetInfo:        &TargetInfo{Enabled: false},
+				CreatedMetric:     &CreatedMetric{Enabled: false},
+				WAL:               &WALConfig{Directory: t.TempDir()},
+			}
+			req := pushToWALAndReplay(t, cfg, gaugeWithAttributes("http_requests", map[string]string{
+				"http.method": "GET",
+			}))
+			require.Len(t, req.Timeseries, 1)
+			assert.Equal(t, tt.want, req.Timeseries[0].Labels)
+		})
+	}
+}
+
+// Test_PushMetricsTruncatesLabelValuesWAL checks over-long label values are truncated before they
+// are written to the WAL.
+func Test_PushMetricsTruncatesLabelValuesWAL(t *testing.T) {
+	cfg := &Config{
+		HTTPClientSettings: confighttp.HTTPClientSettings{
+			Endpoint: "http://localhost:9009/api/v1/push",
+		},
+		MaxLabelValueBytes:         32,
+		LabelValueTruncationSuffix: "…",
+		RemoteWriteQueue:           RemoteWriteQueue{NumConsumers: 1},
+		TargetInfo:                 &TargetInfo{Enabled: false},
+		CreatedMetric:              &CreatedMetric{Enabled: false},
+		WAL:                        &WALConfig{Directory: t.TempDir()},
+	}
+	req := pushToWALAndReplay(t, cfg, gaugeWithAttributes("exceptions", map[string]string{
+		"exception.stacktrace": strings.Repeat("panic: runtime error\n", 50),
+		"zone":                 "eu",
+	}))
+	require.Len(t, req.Timeseries, 1)
+	assert.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: "exceptions"},
+		{Name: "exception_stacktrace", Value: "panic: runtime error\npanic: r…"},
+		{Name: "zone", Value: "eu"},
+	}, req.Timeseries[0].Labels)
+}
+
+func gaugeWithAttributes(name string, attributes map[string]string) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName(name)
+	dp := m.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetDoubleValue(42)
+	for k, v := range attributes {
+		dp.Attributes().PutStr(k, v)
+	}
+	return md
+}
+
+// pushToWALAndReplay pushes md to an exporter with the WAL of cfg, and returns the first request read
+// from the WAL after a restart.
+func pushToWALAndReplay(t *testing.T, cfg *Config, md pmetric.Metrics) *prompb.WriteRequest {
+	prwe, err := newPRWExporter(cfg, exportertest.NewNopCreateSettings())
+	require.NoError(t, err)
+	require.NoError(t, prwe.wal.retrieveWALIndices())
+	require.NoError(t, prwe.PushMetrics(context.Background(), md))
+	require.NoError(t, prwe.wal.stop())
+
+	pwal, err := newWAL(cfg.WAL, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	req, err := pwal.readPrompbFromWAL(context.Background(), pwal.rWALIndex.Load())
+	require.NoError(t, err)
+	return req
+}
+
 func Test_validateAndSanitizeExternalLabels(t *testing.T) {
 	tests := []struct {
 		name                string
@@ -901,7 +1247,7 @@ func TestWALOnExporterRoundTrip(t *testing.T) {
 		"timeseries1": ts1,
 		"timeseries2": ts2,
 	}
-	errs := prwe.handleExport(ctx, tsMap)
+	errs := prwe.handleExport(ctx, tsMap, nil)
 	assert.NoError(t, errs)
 	// Shutdown after we've written to the WAL. This ensures that our
 	// exported data in-flight will flushed flushed to the WAL before exiting.
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"go.opencensus.io/stats/view"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/config/confighttp"
 	"go.opentelemetry.io/collector/config/configopaque"
@@ -21,6 +22,8 @@ import (
 
 // NewFactory creates a new Prometheus Remote Write exporter.
 func NewFactory() exporter.Factory {
+	_ = view.Register(MetricViews()...)
+
 	return exporter.NewFactory(
 		metadata.Type,
 		createDefaultConfig,
@@ -69,9 +72,12 @@ func createMetricsExporter(ctx context.Context, set exporter.CreateSettings,
 
 func createDefaultConfig() component.Config {
 	return &Config{
-		Namespace:       "",
-		ExternalLabels:  map[string]string{},
-		TimeoutSettings: exporterhelper.NewDefaultTimeoutSettings(),
+		Namespace:                  "",
+		ExternalLabels:             map[string]string{},
+		ExternalLabelsPrecedence:   externalLabelsPrecedenceSeries,
+		LabelSanitization:          labelSanitizationLegacyUnderscore,
+		LabelValueTruncationSuffix: defaultLabelValueTruncationSuffix,
+		TimeoutSettings:            exporterhelper.NewDefaultTimeoutSettings(),
 		RetrySettings: exporterhelper.RetrySettings{
 			Enabled:             true,
 			InitialInterval:     50 * time.Millisecond,
@@ -101,5 +107,16 @@ func createDefaultConfig() component.Config {
 		CreatedMetric: &CreatedMetric{
 			Enabled: false,
 		},
+		MetricTypes: defaultMetricTypes(),
+	}
+}
+
+func defaultMetricTypes() *MetricTypes {
+	return &MetricTypes{
+		Gauge:                true,
+		Sum:                  true,
+		Histogram:            true,
+		ExponentialHistogram: true,
+		Summary:              true,
 	}
 }
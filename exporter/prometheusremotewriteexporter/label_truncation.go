@@ -0,0 +1,91 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"go.opencensus.io/stats"
+)
+
+const defaultLabelValueTruncationSuffix = "…"
+
+// labelTruncator truncates the label values that are longer than maxBytes, other than the
+// metric names, and ends them with suffix.
+type labelTruncator struct {
+	maxBytes int
+	suffix   string
+}
+
+// newLabelTruncator returns nil if maxBytes doesn't limit the label values. The suffix must
+// be valid UTF-8, and shorter than maxBytes.
+func newLabelTruncator(maxBytes int, suffix string) *labelTruncator {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &labelTruncator{maxBytes: maxBytes, suffix: suffix}
+}
+
+// truncate returns value truncated to maxBytes, and whether it was truncated. The value is
+// cut at the start of a character, so that a valid UTF-8 value stays valid.
+func (lt *labelTruncator) truncate(value string) (string, bool) {
+	if len(value) <= lt.maxBytes {
+		return value, false
+	}
+	end := lt.maxBytes - len(lt.suffix)
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+	return value[:end] + lt.suffix, true
+}
+
+// applyToSeries truncates the label values of the series in place.
+func (lt *labelTruncator) applyToSeries(ctx context.Context, tsMap map[string]*prompb.TimeSeries) {
+	if lt == nil {
+		return
+	}
+	var truncated int64
+	for _, ts := range tsMap {
+		truncated += lt.truncateLabels(ts.Labels)
+	}
+	recordTruncatedLabels(ctx, truncated)
+}
+
+// applyToRequests truncates the label values of the series of the requests in place, so that
+// requests written to the WAL before the limit was set or lowered are sent within it.
+func (lt *labelTruncator) applyToRequests(ctx context.Context, requests []*prompb.WriteRequest) {
+	if lt == nil {
+		return
+	}
+	var truncated int64
+	for _, req := range requests {
+		for i := range req.Timeseries {
+			truncated += lt.truncateLabels(req.Timeseries[i].Labels)
+		}
+	}
+	recordTruncatedLabels(ctx, truncated)
+}
+
+func (lt *labelTruncator) truncateLabels(labels []prompb.Label) int64 {
+	var truncated int64
+	for i := range labels {
+		if labels[i].Name == model.MetricNameLabel {
+			continue
+		}
+		var ok bool
+		if labels[i].Value, ok = lt.truncate(labels[i].Value); ok {
+			truncated++
+		}
+	}
+	return truncated
+}
+
+func recordTruncatedLabels(ctx context.Context, truncated int64) {
+	if truncated > 0 {
+		stats.Record(ctx, mTruncatedLabels.M(truncated))
+	}
+}
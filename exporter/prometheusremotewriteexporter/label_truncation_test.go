@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+func TestLabelTruncatorTruncate(t *testing.T) {
+	tests := []struct {
+		name      string
+		suffix    string
+		value     string
+		want      string
+		truncated bool
+	}{
+		{name: "short", suffix: "…", value: "abc", want: "abc"},
+		{name: "at_limit", suffix: "…", value: "abcdefghij", want: "abcdefghij"},
+		{name: "over_limit", suffix: "…", value: "abcdefghijk", want: "abcdefg…", truncated: true},
+		{name: "no_suffix", suffix: "", value: "abcdefghijk", want: "abcdefghij", truncated: true},
+		// The cut falls in the middle of the 3 bytes of the third "€", which is dropped whole
+		{name: "multibyte", suffix: "…", value: "€€€€", want: "€€…", truncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lt := newLabelTruncator(10, tt.suffix)
+			got, truncated := lt.truncate(tt.value)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.truncated, truncated)
+			assert.LessOrEqual(t, len(got), 10)
+			assert.True(t, utf8.ValidString(got))
+		})
+	}
+}
+
+func TestLabelTruncatorApply(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	assert.Nil(t, newLabelTruncator(0, "…"))
+
+	lt := newLabelTruncator(16, "...")
+	stack := strings.Repeat("at main.go:42\n", 100)
+	name := strings.Repeat("m", 20)
+	tsMap := map[string]*prompb.TimeSeries{
+		"a": {Labels: []prompb.Label{
+			{Name: "__name__", Value: name},
+			{Name: "exception_stacktrace", Value: stack},
+			{Name: "zone", Value: "eu"},
+		}},
+	}
+	lt.applyToSeries(context.Background(), tsMap)
+	assert.Equal(t, []prompb.Label{
+		{Name: "__name__", Value: name},
+		{Name: "exception_stacktrace", Value: "at main.go:42..."},
+		{Name: "zone", Value: "eu"},
+	}, tsMap["a"].Labels)
+
+	// Requests replayed from the WAL are truncated the same way
+	requests := []*prompb.WriteRequest{{Timeseries: []prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: "exception_stacktrace", Value: stack}}},
+		{Labels: []prompb.Label{{Name: "exception_stacktrace", Value: "at main.go:42..."}}},
+	}}}
+	lt.applyToRequests(context.Background(), requests)
+	assert.Equal(t, "at main.go:42...", requests[0].Timeseries[0].Labels[0].Value)
+	assert.Equal(t, "at main.go:42...", requests[0].Timeseries[1].Labels[0].Value)
+
+	rows, err := view.RetrieveData(mTruncatedLabels.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(2), rows[0].Data.(*view.SumData).Value)
+}
@@ -0,0 +1,112 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+var (
+	tagMetricType, _ = tag.NewKey("metric_type")
+	tagTenant, _     = tag.NewKey("tenant")
+
+	// aggLastValue is shared by the views, so that registering them again is not a conflict.
+	aggLastValue = view.LastValue()
+
+	mSkippedDataPoints     = stats.Int64("prometheusremotewrite_skipped_datapoints", "Number of data points skipped because the conversion of their metric type is disabled", stats.UnitDimensionless)
+	mTruncatedLabels       = stats.Int64("prometheusremotewrite_truncated_labels", "Number of label values truncated to max_label_value_bytes", stats.UnitDimensionless)
+	mWALDroppedRequests    = stats.Int64("prometheusremotewrite_wal_dropped_requests", "Number of write requests removed from the WAL before they were exported, to stay within its max bytes", stats.UnitDimensionless)
+	mWALDroppedEmptySeries = stats.Int64("prometheusremotewrite_dropped_empty_series", "Number of series without samples, exemplars or histograms removed before they were written to the WAL", stats.UnitDimensionless)
+	mWALFutureSamples      = stats.Int64("prometheusremotewrite_wal_future_samples", "Number of samples dropped or clamped because their timestamp was more than future_tolerance ahead of the time they were written to or exported from the WAL", stats.UnitDimensionless)
+	mWALBacklog            = stats.Int64("prometheusremotewrite_wal_backlog", "Number of write requests in the WAL that were not exported yet", stats.UnitDimensionless)
+	mWALOldestUnsent       = stats.Float64("prometheusremotewrite_wal_oldest_unsent_seconds", "Age of the oldest sample in the WAL that was not exported yet, zero if every sample was exported", stats.UnitSeconds)
+	mWALDeadLettered       = stats.Int64("prometheusremotewrite_wal_deadlettered", "Number of write requests moved from the WAL to the dead-letter directory because they were rejected permanently", stats.UnitDimensionless)
+	mWALReplayedRequests   = stats.Int64("prometheusremotewrite_wal_replayed_requests", "Number of write requests read from the WAL and exported", stats.UnitDimensionless)
+	mWALCircuitState       = stats.Int64("prometheusremotewrite_wal_circuit_state", "State of the circuit breaker of the WAL sink: 0 closed, 1 open, 2 half-open", stats.UnitDimensionless)
+	mWALSinkStatus         = stats.Int64("prometheusremotewrite_wal_sink_status", "Status of the WAL sink as of its last export: 0 ok, 1 transient error, 2 permanent error", stats.UnitDimensionless)
+)
+
+// MetricViews returns the metric views for the Prometheus Remote Write exporter.
+func MetricViews() []*view.View {
+	return []*view.View{
+		{
+			Name:        mSkippedDataPoints.Name(),
+			Measure:     mSkippedDataPoints,
+			Description: mSkippedDataPoints.Description(),
+			TagKeys:     []tag.Key{tagMetricType},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mTruncatedLabels.Name(),
+			Measure:     mTruncatedLabels,
+			Description: mTruncatedLabels.Description(),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mWALDroppedRequests.Name(),
+			Measure:     mWALDroppedRequests,
+			Description: mWALDroppedRequests.Description(),
+			TagKeys:     []tag.Key{tagTenant},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mWALDroppedEmptySeries.Name(),
+			Measure:     mWALDroppedEmptySeries,
+			Description: mWALDroppedEmptySeries.Description(),
+			TagKeys:     []tag.Key{tagTenant},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mWALFutureSamples.Name(),
+			Measure:     mWALFutureSamples,
+			Description: mWALFutureSamples.Description(),
+			TagKeys:     []tag.Key{tagTenant},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mWALBacklog.Name(),
+			Measure:     mWALBacklog,
+			Description: mWALBacklog.Description(),
+			TagKeys:     []tag.Key{tagTenant},
+			Aggregation: aggLastValue,
+		},
+		{
+			Name:        mWALOldestUnsent.Name(),
+			Measure:     mWALOldestUnsent,
+			Description: mWALOldestUnsent.Description(),
+			TagKeys:     []tag.Key{tagTenant},
+			Aggregation: aggLastValue,
+		},
+		{
+			Name:        mWALDeadLettered.Name(),
+			Measure:     mWALDeadLettered,
+			Description: mWALDeadLettered.Description(),
+			TagKeys:     []tag.Key{tagTenant},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mWALReplayedRequests.Name(),
+			Measure:     mWALReplayedRequests,
+			Description: mWALReplayedRequests.Description(),
+			TagKeys:     []tag.Key{tagTenant},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mWALCircuitState.Name(),
+			Measure:     mWALCircuitState,
+			Description: mWALCircuitState.Description(),
+			TagKeys:     []tag.Key{tagTenant},
+			Aggregation: aggLastValue,
+		},
+		{
+			Name:        mWALSinkStatus.Name(),
+			Measure:     mWALSinkStatus,
+			Description: mWALSinkStatus.Description(),
+			TagKeys:     []tag.Key{tagTenant},
+			Aggregation: aggLastValue,
+		},
+	}
+}
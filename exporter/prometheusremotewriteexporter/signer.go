@@ -0,0 +1,32 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// RequestSigner is implemented by the extensions that sign the requests sent to the remote
+// write endpoint, such as with AWS SigV4. A request is signed right before it is sent, once its
+// body and headers are set, so that the requests exported from the WAL are signed when they are
+// sent rather than when they were written. The body of the request can be read with GetBody.
+type RequestSigner interface {
+	SignRequest(req *http.Request) error
+}
+
+// requestSignerFromHost returns the extension id of host as a RequestSigner.
+func requestSignerFromHost(host component.Host, id component.ID) (RequestSigner, error) {
+	ext, ok := host.GetExtensions()[id]
+	if !ok {
+		return nil, fmt.Errorf("request signer %q not found", id)
+	}
+	signer, ok := ext.(RequestSigner)
+	if !ok {
+		return nil, fmt.Errorf("extension %q is not a request signer", id)
+	}
+	return signer, nil
+}
@@ -0,0 +1,150 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+var signerID = component.NewID("signer")
+
+// hmacSigner signs the body of a request with an HMAC in the X-Signature header.
+type hmacSigner struct {
+	component.StartFunc
+	component.ShutdownFunc
+	key []byte
+}
+
+func (s *hmacSigner) sign(body []byte) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *hmacSigner) SignRequest(req *http.Request) error {
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Signature", s.sign(b))
+	return nil
+}
+
+type extensionsHost struct {
+	component.Host
+	extensions map[component.ID]component.Component
+}
+
+func (h *extensionsHost) GetExtensions() map[component.ID]component.Component {
+	return h.extensions
+}
+
+func TestRequestSigner_SignsWALExports(t *testing.T) {
+	signer := &hmacSigner{key: []byte("secret")}
+	accepted := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		if r.Header.Get("X-Signature") != signer.sign(body) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		accepted <- body
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		HTTPClientSettings: confighttp.HTTPClientSettings{Endpoint: server.URL},
+		RemoteWriteQueue:   RemoteWriteQueue{NumConsumers: 1},
+		TargetInfo:         &TargetInfo{Enabled: false},
+		CreatedMetric:      &CreatedMetric{Enabled: false},
+		WAL:                &WALConfig{Directory: t.TempDir(), BufferSize: 1},
+		RequestSigner:      &signerID,
+	}
+	prwe, err := newPRWExporter(cfg, exportertest.NewNopCreateSettings())
+	require.NoError(t, err)
+	host := &extensionsHost{Host: componenttest.NewNopHost(), extensions: map[component.ID]component.Component{signerID: signer}}
+	require.NoError(t, prwe.Start(context.Background(), host))
+	defer func() {
+		assert.NoError(t, prwe.Shutdown(context.Background()))
+	}()
+
+	tsMap := map[string]*prompb.TimeSeries{
+		"ts": {
+			Labels:  []prompb.Label{{Name: "__name__", Value: "ts"}},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 100}},
+		},
+	}
+	require.NoError(t, prwe.handleExport(context.Background(), tsMap, nil))
+	select {
+	case body := <-accepted:
+		assert.NotEmpty(t, body)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Timed out waiting for the signed request")
+	}
+}
+
+func TestRequestSigner_Start(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.HTTPClientSettings.Endpoint = "http://localhost:9009/api/v1/push"
+	cfg.RequestSigner = &signerID
+
+	tests := []struct {
+		name       string
+		extensions map[component.ID]component.Component
+		err        string
+	}{
+		{
+			name:       "signer",
+			extensions: map[component.ID]component.Component{signerID: &hmacSigner{}},
+		},
+		{
+			name: "missing",
+			err:  `request signer "signer" not found`,
+		},
+		{
+			name: "not a signer",
+			extensions: map[component.ID]component.Component{signerID: struct {
+				component.StartFunc
+				component.ShutdownFunc
+			}{}},
+			err: `extension "signer" is not a request signer`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prwe, err := newPRWExporter(cfg, exportertest.NewNopCreateSettings())
+			require.NoError(t, err)
+			err = prwe.Start(context.Background(), &extensionsHost{Host: componenttest.NewNopHost(), extensions: tt.extensions})
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.extensions[signerID], prwe.signer)
+			assert.NoError(t, prwe.Shutdown(context.Background()))
+		})
+	}
+}
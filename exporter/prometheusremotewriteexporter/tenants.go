@@ -0,0 +1,142 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+// TenantsConfig routes the series of each tenant to the endpoint of the tenant.
+type TenantsConfig struct {
+	// Label is the name of the exported label whose value is the tenant of a series.
+	Label string `mapstructure:"label"`
+
+	// Endpoints maps tenants to the endpoint their series are sent to. Series of other
+	// tenants, or without Label, are sent to the endpoint of the exporter.
+	Endpoints map[string]TenantEndpoint `mapstructure:"endpoints"`
+}
+
+// TenantEndpoint is the remote write endpoint of a tenant.
+type TenantEndpoint struct {
+	// Endpoint is the remote write URL of the tenant.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Headers are added to the requests of the tenant, such as X-Scope-OrgID.
+	Headers map[string]configopaque.String `mapstructure:"headers"`
+}
+
+// validate checks if the tenants configuration is valid. headers are the headers
+// added to every request, which can't be set per tenant.
+func (cfg *TenantsConfig) validate(headers map[string]configopaque.String) error {
+	if cfg.Label == "" {
+		return fmt.Errorf("tenants label can't be empty")
+	}
+	shared := make(map[string]bool, len(headers))
+	for name := range headers {
+		shared[http.CanonicalHeaderKey(name)] = true
+	}
+	for name, endpoint := range cfg.Endpoints {
+		// The name of a tenant is the name of the directory of its WAL.
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("invalid tenant name %q", name)
+		}
+		if _, err := url.ParseRequestURI(endpoint.Endpoint); err != nil {
+			return fmt.Errorf("tenant %q: invalid endpoint", name)
+		}
+		for header := range endpoint.Headers {
+			if shared[http.CanonicalHeaderKey(header)] {
+				return fmt.Errorf("tenant %q: header %q is already set in headers", name, header)
+			}
+		}
+	}
+	return nil
+}
+
+// tenant is the destination of the series of a tenant.
+type tenant struct {
+	endpointURL *url.URL
+	headers     map[string]configopaque.String
+	wal         *prweWAL
+}
+
+// newTenants creates the tenants of cfg, with a child of the WAL of the exporter when it
+// is enabled. cfg must be valid.
+func (prwe *prwExporter) newTenants(cfg *TenantsConfig) (map[string]*tenant, error) {
+	tenants := make(map[string]*tenant, len(cfg.Endpoints))
+	for name, endpoint := range cfg.Endpoints {
+		endpointURL, err := url.ParseRequestURI(endpoint.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q: invalid endpoint", name)
+		}
+		t := &tenant{endpointURL: endpointURL, headers: endpoint.Headers}
+		if prwe.walEnabled() {
+			sink := func(ctx context.Context, reqL []*prompb.WriteRequest) error {
+				prwe.truncator.applyToRequests(ctx, reqL)
+				return prwe.exportTo(ctx, t.endpointURL, t.headers, reqL)
+			}
+			if t.wal, err = prwe.wal.newChild(name, sink); err != nil {
+				return nil, err
+			}
+		}
+		tenants[name] = t
+	}
+	return tenants, nil
+}
+
+// splitByTenant splits tsMap by the value of label. Series of unknown tenants, or without
+// label, are returned under the empty tenant.
+func splitByTenant(tsMap map[string]*prompb.TimeSeries, label string, tenants map[string]*tenant) map[string]map[string]*prompb.TimeSeries {
+	split := make(map[string]map[string]*prompb.TimeSeries)
+	for key, ts := range tsMap {
+		name := labelValue(ts.Labels, label)
+		if _, ok := tenants[name]; !ok {
+			name = ""
+		}
+		if split[name] == nil {
+			split[name] = make(map[string]*prompb.TimeSeries)
+		}
+		split[name][key] = ts
+	}
+	return split
+}
+
+func labelValue(labels []prompb.Label, name string) string {
+	for _, l := range labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// metadataOf returns the metadata of the metric families of the series in tsMap, so that
+// tenants aren't sent the metadata of the metrics of other tenants.
+func metadataOf(metadata []prompb.MetricMetadata, tsMap map[string]*prompb.TimeSeries) []prompb.MetricMetadata {
+	if len(metadata) == 0 {
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, ts := range tsMap {
+		name := labelValue(ts.Labels, model.MetricNameLabel)
+		names[name] = true
+		for _, suffix := range []string{"_bucket", "_sum", "_count", "_created"} {
+			names[strings.TrimSuffix(name, suffix)] = true
+		}
+	}
+	var filtered []prompb.MetricMetadata
+	for _, m := range metadata {
+		if names[m.MetricFamilyName] {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,193 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// tenantServer records the tenant label values and X-Scope-OrgID headers it receives.
+type tenantServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	tenants map[string]bool
+	orgIDs  map[string]bool
+}
+
+func newTenantServer(t *testing.T) *tenantServer {
+	s := &tenantServer{tenants: map[string]bool{}, orgIDs: map[string]bool{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		dest, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		wr := &prompb.WriteRequest{}
+		require.NoError(t, proto.Unmarshal(dest, wr))
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.orgIDs[r.Header.Get("X-Scope-OrgID")] = true
+		for _, ts := range wr.Timeseries {
+			s.tenants[labelValue(ts.Labels, "tenant")] = true
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *tenantServer) received() (tenants, orgIDs map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tenants, orgIDs = map[string]bool{}, map[string]bool{}
+	for k := range s.tenants {
+		tenants[k] = true
+	}
+	for k := range s.orgIDs {
+		orgIDs[k] = true
+	}
+	return tenants, orgIDs
+}
+
+func TestTenants(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		wal  bool
+	}{
+		{name: "direct"},
+		{name: "wal", wal: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultServer, serverA, serverB := newTenantServer(t), newTenantServer(t), newTenantServer(t)
+			cfg := &Config{
+				HTTPClientSettings: confighttp.HTTPClientSettings{
+					Endpoint: defaultServer.URL,
+				},
+				RemoteWriteQueue: RemoteWriteQueue{NumConsumers: 2},
+				TargetInfo:       &TargetInfo{Enabled: false},
+				CreatedMetric:    &CreatedMetric{Enabled: false},
+				Tenants: &TenantsConfig{
+					Label: "tenant",
+					Endpoints: map[string]TenantEndpoint{
+						"a": {Endpoint: serverA.URL, Headers: map[string]configopaque.String{"X-Scope-OrgID": "org-a"}},
+						"b": {Endpoint: serverB.URL, Headers: map[string]configopaque.String{"X-Scope-OrgID": "org-b"}},
+					},
+				},
+			}
+			if tt.wal {
+				cfg.WAL = &WALConfig{Directory: t.TempDir(), BufferSize: 1}
+			}
+			require.NoError(t, cfg.Validate())
+			prwe, err := newPRWExporter(cfg, exportertest.NewNopCreateSettings())
+			require.NoError(t, err)
+			ctx := context.Background()
+			require.NoError(t, prwe.Start(ctx, componenttest.NewNopHost()))
+			t.Cleanup(func() {
+				assert.NoError(t, prwe.Shutdown(ctx))
+			})
+
+			md := pmetric.NewMetrics()
+			metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+			for i, tenant := range []string{"a", "b", "c", ""} {
+				m := metrics.AppendEmpty()
+				m.SetName("requests")
+				dp := m.SetEmptyGauge().DataPoints().AppendEmpty()
+				dp.SetDoubleValue(float64(i))
+				if tenant != "" {
+					dp.Attributes().PutStr("tenant", tenant)
+				}
+			}
+			require.NoError(t, prwe.PushMetrics(ctx, md))
+
+			for _, want := range []struct {
+				server  *tenantServer
+				tenants map[string]bool
+				orgIDs  map[string]bool
+			}{
+				{server: serverA, tenants: map[string]bool{"a": true}, orgIDs: map[string]bool{"org-a": true}},
+				{server: serverB, tenants: map[string]bool{"b": true}, orgIDs: map[string]bool{"org-b": true}},
+				// Series of unknown tenants or without tenant are sent to the endpoint of the exporter.
+				{server: defaultServer, tenants: map[string]bool{"c": true, "": true}, orgIDs: map[string]bool{"": true}},
+			} {
+				assert.Eventually(t, func() bool {
+					tenants, _ := want.server.received()
+					return len(tenants) == len(want.tenants)
+				}, 5*time.Second, 10*time.Millisecond)
+				tenants, orgIDs := want.server.received()
+				assert.Equal(t, want.tenants, tenants)
+				assert.Equal(t, want.orgIDs, orgIDs)
+			}
+		})
+	}
+}
+
+func TestTenantsConfigValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		cfg     TenantsConfig
+		headers map[string]configopaque.String
+		err     string
+	}{
+		{
+			name: "valid",
+			cfg: TenantsConfig{Label: "tenant", Endpoints: map[string]TenantEndpoint{
+				"a": {Endpoint: "http://localhost:9009/api/v1/push", Headers: map[string]configopaque.String{"X-Scope-OrgID": "a"}},
+			}},
+			headers: map[string]configopaque.String{"Authorization": "token"},
+		},
+		{
+			name: "empty_label",
+			cfg:  TenantsConfig{},
+			err:  "tenants label can't be empty",
+		},
+		{
+			name: "path_in_name",
+			cfg: TenantsConfig{Label: "tenant", Endpoints: map[string]TenantEndpoint{
+				"../a": {Endpoint: "http://localhost:9009/api/v1/push"},
+			}},
+			err: `invalid tenant name "../a"`,
+		},
+		{
+			name: "invalid_endpoint",
+			cfg: TenantsConfig{Label: "tenant", Endpoints: map[string]TenantEndpoint{
+				"a": {Endpoint: "localhost"},
+			}},
+			err: `tenant "a": invalid endpoint`,
+		},
+		{
+			name: "shared_header",
+			cfg: TenantsConfig{Label: "tenant", Endpoints: map[string]TenantEndpoint{
+				"a": {Endpoint: "http://localhost:9009/api/v1/push", Headers: map[string]configopaque.String{"x-scope-orgid": "a"}},
+			}},
+			headers: map[string]configopaque.String{"X-Scope-OrgID": "default"},
+			err:     `tenant "a": header "x-scope-orgid" is already set in headers`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate(tt.headers)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
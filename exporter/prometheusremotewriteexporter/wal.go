@@ -7,7 +7,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -27,24 +30,148 @@ type prweWAL struct {
 
 	exportSink func(ctx context.Context, reqL []*prompb.WriteRequest) error
 
-	stopOnce  sync.Once
-	stopChan  chan struct{}
-	rNotify   chan struct{}
+	stopOnce sync.Once
+	stopChan chan struct{}
+	rNotify  chan struct{}
+	// unsynced is the number of requests written since the WAL was last synced, and flushNotify
+	// is signaled once it reaches FlushCount. flushWG waits for the routine flushing the WAL.
+	unsynced    int
+	flushNotify chan struct{}
+	flushWG     sync.WaitGroup
+	// runWG waits for the routine exporting the WAL.
+	runWG     sync.WaitGroup
 	rWALIndex *atomic.Uint64
 	wWALIndex *atomic.Uint64
+	// sWALIndex is the last index that was exported.
+	sWALIndex *atomic.Uint64
 
-	log *zap.Logger
+	checkpoint *sentCheckpoint
+	deadLetter deadLetterWAL
+	breaker    *circuitBreaker
+	sorter     *sampleSorter
+	active     *activeSeries
+	future     *futureSamples
+	shards     *walShards
+	oldest     *oldestUnsent
+
+	// tenant is the tenant of a child WAL, and children are the child WALs of a parent
+	// WAL, by tenant. They are started and stopped along with their parent.
+	tenant   string
+	children map[string]*prweWAL
+
+	// status is the status of the sink as of the last export and sinkErr its error, and
+	// onSinkStatus is called whenever the status changes. It is copied to the child WALs.
+	statusMu     sync.Mutex
+	status       sinkStatus
+	sinkErr      error
+	onSinkStatus func(tenant string, status sinkStatus, err error)
+
+	// clock drives the truncation and the flushes of the WAL, and is advanced by tests.
+	clock clock
+	log   *zap.Logger
 }
 
 const (
 	defaultWALBufferSize        = 300
+	defaultWALReadBufferSize    = 100
+	minWALSegmentSize           = 4 * 1024
 	defaultWALTruncateFrequency = 1 * time.Minute
 )
 
 type WALConfig struct {
 	Directory         string        `mapstructure:"directory"`
 	BufferSize        int           `mapstructure:"buffer_size"`
+	ReadBufferSize    int           `mapstructure:"read_buffer_size"`
 	TruncateFrequency time.Duration `mapstructure:"truncate_frequency"`
+	// BufferSizeBytes bounds the requests read from the WAL before they are exported by their
+	// marshalled size instead of their number, and can't be set together with BufferSize. The
+	// buffer is exported as soon as the request that crosses it was read.
+	BufferSizeBytes int `mapstructure:"buffer_size_bytes"`
+	// CompressBuffer holds the requests read from the WAL in memory, until BufferSize of them, a
+	// number of requests regardless of their size, or BufferSizeBytes of them are exported,
+	// compressed with snappy. They are decoded again when they are exported, which trades CPU
+	// for memory.
+	CompressBuffer bool `mapstructure:"compress_buffer"`
+	// MaxRetainedSegments bounds the number of segment files kept on disk once their
+	// entries have been delivered. Zero keeps every segment that truncation leaves behind.
+	MaxRetainedSegments int `mapstructure:"max_retained_segments"`
+	// MaxBytes bounds the size of the segment files on disk. Once it is exceeded, the oldest
+	// segments are removed every TruncateFrequency, including entries that were not exported
+	// yet. It can't be less than SegmentSize, since the newest segment is never removed. Zero
+	// means no limit.
+	MaxBytes int64 `mapstructure:"max_bytes"`
+	// Compression is the codec that entries are compressed with before they are written:
+	// none, snappy or zstd. Entries are read regardless of the codec they were written with.
+	Compression string `mapstructure:"compression"`
+	// RepairOnCorruption truncates a torn tail segment back to its last decodable entry when
+	// the WAL is opened, instead of failing to start. The discarded entries are logged.
+	RepairOnCorruption bool `mapstructure:"repair_on_corruption"`
+	// Sync is when writes are flushed to disk: always, after every write, interval:<duration>,
+	// periodically, or none, leaving it to the OS. Unsynced writes are faster but may be lost
+	// if the host crashes; they survive a crash of the collector alone. Defaults to always.
+	Sync string `mapstructure:"sync"`
+	// FlushInterval and FlushCount bound how long unsynced writes, with a Sync of none or
+	// interval:<duration>, wait before they are flushed to disk: once FlushInterval elapsed or
+	// FlushCount requests were written since the last flush, whichever comes first.
+	// FlushInterval replaces the interval of the Sync policy.
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	FlushCount    int           `mapstructure:"flush_count"`
+	// SinkConcurrency is the number of workers exporting consecutive ranges of entries in
+	// parallel. Entries are only truncated once every entry before them was exported.
+	SinkConcurrency int `mapstructure:"sink_concurrency"`
+	// Retry configures the retries of exports that fail with a transient error.
+	Retry WALRetrySettings `mapstructure:"retry_on_failure"`
+	// CircuitBreaker stops exporting for a while once the sink failed repeatedly.
+	CircuitBreaker WALCircuitBreakerSettings `mapstructure:"circuit_breaker"`
+	// DeadLetterDir is the directory that requests rejected with a permanent error are moved
+	// to, along with their index and error, so that the requests after them are exported.
+	// Without it, the export of a rejected request is attempted again until it succeeds.
+	DeadLetterDir string `mapstructure:"dead_letter_dir"`
+	// KeepEmptySeries writes the series without samples, exemplars or histograms, which are
+	// otherwise removed before a batch of requests is written, along with the requests that are
+	// left empty.
+	KeepEmptySeries bool `mapstructure:"keep_empty_series"`
+	// DedupeSamples removes the samples of a batch of requests that have the same labels, in
+	// any order, and timestamp as a later sample of the batch before it is written.
+	DedupeSamples bool `mapstructure:"dedupe_samples"`
+	// MergeSeries merges the series of a batch of requests that have the same labels, in any
+	// order, into a single series with the samples of all of them, sorted by timestamp, before
+	// the batch is written. It reduces the size of the requests exported from the WAL.
+	MergeSeries bool `mapstructure:"merge_series"`
+	// SortSamplesByTimestamp sorts the samples of every series by timestamp before they are written.
+	SortSamplesByTimestamp bool `mapstructure:"sort_samples_by_timestamp"`
+	// DropOutOfOrderSamples, along with SortSamplesByTimestamp, drops the samples that aren't newer
+	// than the last sample written for their series.
+	DropOutOfOrderSamples bool `mapstructure:"drop_out_of_order_samples"`
+	// StaleOnShutdown exports a staleness marker for every series written since the start when the
+	// WAL is stopped. They are written to the WAL instead, to be exported after the entries that
+	// weren't exported yet, if there are any or if the markers can't be exported.
+	StaleOnShutdown bool `mapstructure:"stale_on_shutdown"`
+	// MaxTrackedSeries bounds the number of series whose last timestamp is kept to drop out of
+	// order samples, and that are marked as stale on shutdown. The least recently written series
+	// are forgotten first. Defaults to 100000.
+	MaxTrackedSeries int `mapstructure:"max_tracked_series"`
+	// SegmentSize is the size in bytes that a segment file is rolled over at. Entries are only
+	// removed from disk a segment at a time, every TruncateFrequency or by MaxBytes, so smaller
+	// segments free disk space sooner and larger ones create fewer files. Defaults to 20MiB,
+	// and can't be less than 4KiB.
+	SegmentSize int `mapstructure:"segment_size"`
+	// FutureTolerance is how far ahead of now the timestamp of a sample may be, when it is
+	// written to the WAL and when it is exported from it. The samples further in the future
+	// are dropped, or clamped to now with a FutureSampleAction of clamp. Samples in the past
+	// are left untouched, so that they can be backfilled. Zero disables the check.
+	FutureTolerance    time.Duration `mapstructure:"future_tolerance"`
+	FutureSampleAction string        `mapstructure:"future_sample_action"`
+	// Shards partitions the entries by a hash of the metric name of their series, and exports
+	// every shard in order with its own routine, so that a shard that is slow to export doesn't
+	// hold back the others. Entries are only truncated once every shard exported them. Zero or
+	// one doesn't partition the entries, and it can't be set together with SinkConcurrency.
+	Shards int `mapstructure:"shards"`
+	// MaxSamplesPerSend is the maximum number of samples, and histograms, of a request exported
+	// from the WAL. The requests with more samples are split along their series, and a series with
+	// more samples than the limit is itself split across requests, each part keeping its labels.
+	// Zero doesn't split the requests.
+	MaxSamplesPerSend int `mapstructure:"max_samples_per_send"`
 }
 
 func (wc *WALConfig) bufferSize() int {
@@ -54,6 +181,37 @@ func (wc *WALConfig) bufferSize() int {
 	return defaultWALBufferSize
 }
 
+// batchCount is the maximum number of requests read from the WAL before they are exported.
+func (wc *WALConfig) batchCount() int {
+	if wc.BufferSizeBytes > 0 && wc.BufferSize == 0 {
+		return math.MaxInt
+	}
+	return wc.bufferSize()
+}
+
+// flushInterval is the interval at which unsynced writes are flushed to disk, if any.
+func (wc *WALConfig) flushInterval() time.Duration {
+	if wc.FlushInterval > 0 {
+		return wc.FlushInterval
+	}
+	_, interval, _ := parseWALSync(wc.Sync)
+	return interval
+}
+
+func (wc *WALConfig) readBufferSize() int {
+	if wc.ReadBufferSize > 0 {
+		return wc.ReadBufferSize
+	}
+	return defaultWALReadBufferSize
+}
+
+func (wc *WALConfig) sinkConcurrency() int {
+	if wc.SinkConcurrency > 0 {
+		return wc.SinkConcurrency
+	}
+	return 1
+}
+
 func (wc *WALConfig) truncateFrequency() time.Duration {
 	if wc.TruncateFrequency > 0 {
 		return wc.TruncateFrequency
@@ -67,25 +225,48 @@ func newWAL(walConfig *WALConfig, exportSink func(context.Context, []*prompb.Wri
 		// TODO: Perhaps log that the WAL wasn't enabled.
 		return nil, errNilConfig
 	}
+	if err := checkWritable(walConfig.path()); err != nil {
+		return nil, err
+	}
 
 	wal := prweWAL{
-		exportSink: exportSink,
-		walConfig:  walConfig,
-		stopChan:   make(chan struct{}),
-		rNotify:    make(chan struct{}),
-		rWALIndex:  &atomic.Uint64{},
-		wWALIndex:  &atomic.Uint64{},
-		log:        zap.NewNop(),
+		exportSink:  exportSink,
+		walConfig:   walConfig,
+		stopChan:    make(chan struct{}),
+		rNotify:     make(chan struct{}),
+		flushNotify: make(chan struct{}, 1),
+		rWALIndex:   &atomic.Uint64{},
+		wWALIndex:   &atomic.Uint64{},
+		sWALIndex:   &atomic.Uint64{},
+		checkpoint:  &sentCheckpoint{path: filepath.Join(walConfig.path(), walCheckpointFile)},
+		sorter:      newSampleSorter(walConfig),
+		active:      newActiveSeries(walConfig),
+		future:      newFutureSamples(walConfig),
+		shards:      newWALShards(walConfig),
+		oldest:      &oldestUnsent{},
+		log:         zap.NewNop(),
+		clock:       wallClock{},
 	}
+	wal.breaker = newCircuitBreaker(walConfig.CircuitBreaker, func(state circuitState) {
+		wal.record(context.Background(), mWALCircuitState.M(int64(state)))
+	})
 
 	return &wal, nil
 }
 
+func (wc *WALConfig) path() string {
+	return filepath.Join(wc.Directory, "prom_remotewrite")
+}
+
 func (wc *WALConfig) createWAL() (*wal.Log, string, error) {
-	walPath := filepath.Join(wc.Directory, "prom_remotewrite")
+	walPath := wc.path()
+	// The sync policy is validated with the config.
+	noSync, _, _ := parseWALSync(wc.Sync)
 	log, err := wal.Open(walPath, &wal.Options{
 		SegmentCacheSize: wc.bufferSize(),
+		SegmentSize:      wc.segmentSize(),
 		NoCopy:           true,
+		NoSync:           noSync,
 	})
 	if err != nil {
 		return nil, "", fmt.Errorf("prometheusremotewriteexporter: failed to open WAL: %w", err)
@@ -93,10 +274,64 @@ func (wc *WALConfig) createWAL() (*wal.Log, string, error) {
 	return log, walPath, nil
 }
 
+// ErrWALNotWritable is matched by the error of newWAL when the WAL directory can't be written to.
+var ErrWALNotWritable = errors.New("WAL directory is not writable")
+
+// WALNotWritableError is returned by newWAL when the WAL directory can't be written to. It
+// matches ErrWALNotWritable, and unwraps to the error of the filesystem.
+type WALNotWritableError struct {
+	Path string
+	Err  error
+}
+
+func (e *WALNotWritableError) Error() string {
+	return fmt.Sprintf("prometheusremotewriteexporter: %v: %s: %v", ErrWALNotWritable, e.Path, e.Err)
+}
+
+func (e *WALNotWritableError) Is(target error) bool {
+	return target == ErrWALNotWritable
+}
+
+func (e *WALNotWritableError) Unwrap() error {
+	return e.Err
+}
+
+// checkWritable creates and removes a file in dir, so that a WAL that can't be written to
+// fails when it is created rather than once it is written to. A dir that doesn't exist yet is
+// created when the WAL is opened, so its closest existing parent is checked instead.
+func checkWritable(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil && !info.IsDir() {
+			err = fmt.Errorf("%s is not a directory", dir)
+		}
+		if err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if !errors.Is(err, os.ErrNotExist) || parent == dir {
+			return &WALNotWritableError{Path: dir, Err: err}
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return &WALNotWritableError{Path: dir, Err: err}
+	}
+	if err = multierr.Append(f.Close(), os.Remove(f.Name())); err != nil {
+		return &WALNotWritableError{Path: dir, Err: err}
+	}
+	return nil
+}
+
 var (
 	errAlreadyClosed = errors.New("already closed")
 	errNilWAL        = errors.New("wal is nil")
-	errNilConfig     = errors.New("expecting a non-nil configuration")
+	errWALStopped    = errors.New("attempt to read from WAL after stopped")
+	// errWALEntriesRemoved is returned when reading entries that truncateOverMaxBytes removed.
+	errWALEntriesRemoved = errors.New("WAL entries were removed to stay within max bytes")
+	errNilConfig         = errors.New("expecting a non-nil configuration")
 )
 
 // retrieveWALIndices queries the WriteAheadLog for its current first and last indices.
@@ -104,12 +339,27 @@ func (prwe *prweWAL) retrieveWALIndices() (err error) {
 	prwe.mu.Lock()
 	defer prwe.mu.Unlock()
 
+	select {
+	case <-prwe.stopChan:
+		// The exporting goroutine may still be running once the WAL was stopped, and must not reopen it.
+		return errAlreadyClosed
+	default:
+	}
+
 	err = prwe.closeWAL()
 	if err != nil {
 		return err
 	}
 
-	log, walPath, err := prwe.walConfig.createWAL()
+	if err = prwe.removeDeliveredSegments(); err != nil {
+		return err
+	}
+
+	if err = prwe.removeSegmentsOverMaxBytes(); err != nil {
+		return err
+	}
+
+	log, walPath, err := prwe.openWAL()
 	if err != nil {
 		return err
 	}
@@ -122,26 +372,52 @@ func (prwe *prweWAL) retrieveWALIndices() (err error) {
 		return fmt.Errorf("prometheusremotewriteexporter: failed to retrieve the first WAL index: %w", err)
 	}
 
-	// NOTE: github.com/tidwall/wal reading starts at 1, but FirstIndex() may return 0 if the wal is empty
-	prwe.rWALIndex.Store(max(rIndex, 1))
-
 	wIndex, err := prwe.wal.LastIndex()
 	if err != nil {
 		return fmt.Errorf("prometheusremotewriteexporter: failed to retrieve the last WAL index: %w", err)
 	}
 	prwe.wWALIndex.Store(wIndex)
+
+	sIndex, err := prwe.checkpoint.read()
+	if err != nil {
+		return err
+	}
+	if sIndex > wIndex {
+		// The checkpoint outlived the entries it refers to, e.g. when they were repaired away.
+		prwe.log.Warn("ignoring WAL checkpoint past the last entry", zap.Uint64("checkpoint", sIndex), zap.Uint64("last", wIndex))
+		sIndex = 0
+	}
+
+	// NOTE: github.com/tidwall/wal reading starts at 1, but FirstIndex() may return 0 if the wal is empty.
+	// Entries up to the checkpoint were exported before a restart, and aren't read again.
+	rIndex = max(rIndex, sIndex+1)
+	prwe.rWALIndex.Store(rIndex)
+	// Entries before the first index were exported before they were truncated.
+	prwe.sWALIndex.Store(max(prwe.sWALIndex.Load(), rIndex-1))
+	if prwe.shards != nil {
+		return prwe.shards.loadWatermarks(prwe.sWALIndex.Load(), wIndex)
+	}
 	return nil
 }
 
 func (prwe *prweWAL) stop() error {
 	err := errAlreadyClosed
 	prwe.stopOnce.Do(func() {
+		prwe.mu.Lock()
+		close(prwe.stopChan)
+		prwe.mu.Unlock()
+
+		// The staleness markers are exported once the exporting stopped, before the WAL is closed.
+		staleErr := prwe.flushStaleMarkers()
+
 		prwe.mu.Lock()
 		defer prwe.mu.Unlock()
 
-		close(prwe.stopChan)
-		err = prwe.closeWAL()
+		err = multierr.Append(staleErr, prwe.closeWAL())
+		err = multierr.Append(err, prwe.closeDeadLetter())
+		err = multierr.Append(err, prwe.stopChildren())
 	})
+	prwe.flushWG.Wait()
 	return err
 }
 
@@ -161,11 +437,23 @@ func (prwe *prweWAL) run(ctx context.Context) (err error) {
 
 	runCtx, cancel := context.WithCancel(ctx)
 
+	if interval := prwe.walConfig.flushInterval(); interval > 0 || prwe.walConfig.FlushCount > 0 {
+		prwe.flushWG.Add(1)
+		go func() {
+			defer prwe.flushWG.Done()
+			prwe.syncPeriodically(runCtx, interval)
+		}()
+	}
+	go prwe.recordBacklogPeriodically(runCtx)
+
 	// Start the process of exporting but wait until the exporting has started.
 	waitUntilStartedCh := make(chan bool)
+	prwe.runWG.Add(1)
 	go func() {
+		defer prwe.runWG.Done()
 		signalStart := func() { close(waitUntilStartedCh) }
 		defer cancel()
+		replay := prwe.walConfig.Retry.newBackOff(prwe.clock)
 		for {
 			select {
 			case <-runCtx.Done():
@@ -173,11 +461,34 @@ func (prwe *prweWAL) run(ctx context.Context) (err error) {
 			case <-prwe.stopChan:
 				return
 			default:
-				err := prwe.continuallyPopWALThenExport(runCtx, signalStart)
+				sent := prwe.sWALIndex.Load()
+				var err error
+				switch {
+				case prwe.shards != nil:
+					err = prwe.runShards(runCtx, signalStart)
+				case prwe.walConfig.sinkConcurrency() > 1:
+					err = prwe.runSinkWorkers(runCtx, signalStart)
+				default:
+					err = prwe.continuallyPopWALThenExport(runCtx, signalStart)
+				}
 				signalStart = func() {}
+				if errors.Is(err, errWALStopped) {
+					return
+				}
+				if errors.Is(err, ErrUnsupportedWALVersion) {
+					// The entry can't be read until the collector is upgraded, it would be read again forever
+					logger.Error("WAL entry was written by a newer version, the WAL is no longer exported", zap.Error(err))
+					return
+				}
 				if err != nil {
 					// log err
 					logger.Error("error processing WAL entries", zap.Error(err))
+					if prwe.sWALIndex.Load() > sent {
+						replay.Reset()
+					}
+					if !prwe.waitBeforeReplay(runCtx, replay) {
+						return
+					}
 					// Restart WAL
 					if errS := prwe.retrieveWALIndices(); errS != nil {
 						logger.Error("unable to re-start write-ahead log after error", zap.Error(errS))
@@ -188,7 +499,7 @@ func (prwe *prweWAL) run(ctx context.Context) (err error) {
 		}
 	}()
 	<-waitUntilStartedCh
-	return nil
+	return prwe.runChildren(ctx)
 }
 
 // continuallyPopWALThenExport reads a prompb.WriteRequest proto encoded blob from the WAL, and moves
@@ -197,17 +508,28 @@ func (prwe *prweWAL) run(ctx context.Context) (err error) {
 // the requests to the Remote-Write endpoint, and then truncates the head of the WAL to where
 // it last read from.
 func (prwe *prweWAL) continuallyPopWALThenExport(ctx context.Context, signalStart func()) (err error) {
-	var reqL []*prompb.WriteRequest
+	buf := &walBuffer{
+		compress: prwe.walConfig.CompressBuffer,
+		maxCount: prwe.walConfig.batchCount(),
+		maxBytes: prwe.walConfig.BufferSizeBytes,
+	}
 	defer func() {
 		// Keeping it within a closure to ensure that the later
-		// updated value of reqL is always flushed to disk.
-		if errL := prwe.exportSink(ctx, reqL); errL != nil {
+		// updated content of buf is always flushed to disk.
+		reqL, errB := buf.requests()
+		if errB != nil {
+			err = multierr.Append(err, errB)
+			return
+		}
+		if errL := prwe.exportWithRetry(ctx, prwe.rWALIndex.Load()-uint64(len(reqL)), reqL); errL != nil {
 			err = multierr.Append(err, errL)
+		} else {
+			prwe.markSent(ctx, len(reqL))
 		}
 	}()
 
-	freshTimer := func() *time.Timer {
-		return time.NewTimer(prwe.walConfig.truncateFrequency())
+	freshTimer := func() clockTimer {
+		return prwe.clock.NewTimer(prwe.walConfig.truncateFrequency())
 	}
 
 	timer := freshTimer()
@@ -219,7 +541,6 @@ func (prwe *prweWAL) continuallyPopWALThenExport(ctx context.Context, signalStar
 
 	signalStart()
 
-	maxCountPerUpload := prwe.walConfig.bufferSize()
 	for {
 		select {
 		case <-ctx.Done():
@@ -229,19 +550,18 @@ func (prwe *prweWAL) continuallyPopWALThenExport(ctx context.Context, signalStar
 		default:
 		}
 
-		var req *prompb.WriteRequest
-		req, err = prwe.readPrompbFromWAL(ctx, prwe.rWALIndex.Load())
-		if err != nil {
+		count := min(prwe.walConfig.readBufferSize(), buf.maxCount-buf.len())
+		// The entries decoded before an error are still exported when returning.
+		if err = prwe.readBatchFromWAL(ctx, prwe.rWALIndex.Load(), count, buf.visit); err != nil {
 			return err
 		}
-		reqL = append(reqL, req)
 
 		shouldExport := false
 		select {
-		case <-timer.C:
+		case <-timer.Chan():
 			shouldExport = true
 		default:
-			shouldExport = len(reqL) >= maxCountPerUpload
+			shouldExport = buf.full()
 		}
 
 		if !shouldExport {
@@ -252,11 +572,69 @@ func (prwe *prweWAL) continuallyPopWALThenExport(ctx context.Context, signalStar
 		timer.Stop()
 		timer = freshTimer()
 
+		var reqL []*prompb.WriteRequest
+		if reqL, err = buf.requests(); err != nil {
+			return err
+		}
 		if err = prwe.exportThenFrontTruncateWAL(ctx, reqL); err != nil {
 			return err
 		}
-		// Reset but reuse the write requests slice.
-		reqL = reqL[:0]
+		// Reset but reuse the buffer.
+		buf.reset()
+	}
+}
+
+// markSent records that the count entries before the read index were exported.
+func (prwe *prweWAL) markSent(ctx context.Context, count int) {
+	if count == 0 {
+		return
+	}
+	prwe.sWALIndex.Store(prwe.rWALIndex.Load() - 1)
+	prwe.writeCheckpoint()
+	prwe.record(ctx, mWALReplayedRequests.M(int64(count)))
+	prwe.recordBacklog(ctx)
+}
+
+// writeCheckpoint persists the sent index, so that the entries up to it aren't exported
+// again after a restart. A failure only causes them to be exported again, and is logged.
+// Nothing is written once the WAL is closed.
+func (prwe *prweWAL) writeCheckpoint() {
+	prwe.mu.Lock()
+	defer prwe.mu.Unlock()
+
+	if prwe.wal == nil {
+		return
+	}
+	if err := prwe.checkpoint.write(prwe.sWALIndex.Load()); err != nil {
+		prwe.log.Warn("failed to checkpoint the exported WAL entries", zap.Error(err))
+	}
+}
+
+// recordBacklog records the number of entries written to the WAL that were not exported yet,
+// and the age of the oldest sample among them.
+func (prwe *prweWAL) recordBacklog(ctx context.Context) {
+	var backlog int64
+	if wIndex, sIndex := prwe.wWALIndex.Load(), prwe.sWALIndex.Load(); wIndex > sIndex {
+		backlog = int64(wIndex - sIndex)
+	}
+	prwe.record(ctx, mWALBacklog.M(backlog), mWALOldestUnsent.M(prwe.oldestUnsentAge().Seconds()))
+}
+
+// recordBacklogPeriodically records the backlog every truncate frequency, so that it is
+// up to date while nothing is exported, until ctx is done or the WAL is stopped.
+func (prwe *prweWAL) recordBacklogPeriodically(ctx context.Context) {
+	prwe.recordBacklog(ctx)
+	ticker := prwe.clock.NewTicker(prwe.walConfig.truncateFrequency())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-prwe.stopChan:
+			return
+		case <-ticker.Chan():
+			prwe.recordBacklog(ctx)
+		}
 	}
 }
 
@@ -269,7 +647,197 @@ func (prwe *prweWAL) closeWAL() error {
 	return nil
 }
 
-func (prwe *prweWAL) syncAndTruncateFront() error {
+// removeDeliveredSegments deletes the oldest segment files beyond MaxRetainedSegments,
+// as long as every entry they hold was already read and exported.
+// It must be called with prwe.mu held and the WAL closed.
+func (prwe *prweWAL) removeDeliveredSegments() error {
+	maxSegments := prwe.walConfig.MaxRetainedSegments
+	if maxSegments <= 0 || prwe.walPath == "" {
+		return nil
+	}
+
+	segments, err := listWALSegments(prwe.walPath)
+	if err != nil {
+		return err
+	}
+
+	rIndex := prwe.rWALIndex.Load()
+	// The newest segment is never removed, it is still being written to.
+	for i := 0; i < len(segments)-1 && len(segments)-i > maxSegments; i++ {
+		// Entries of segment i end right before the first index of segment i+1.
+		if segments[i+1].firstIndex > rIndex {
+			break
+		}
+		if err = os.Remove(segments[i].path); err != nil {
+			return fmt.Errorf("prometheusremotewriteexporter: failed to remove WAL segment: %w", err)
+		}
+		prwe.log.Debug("removed delivered WAL segment", zap.String("path", segments[i].path))
+	}
+	return nil
+}
+
+// removeSegmentsOverMaxBytes deletes the oldest segment files until the WAL fits in MaxBytes.
+// Delivered segments are the oldest, so they are removed first. Entries of removed segments
+// that were not read yet are lost, and counted as dropped requests.
+// It must be called with prwe.mu held and the WAL closed.
+func (prwe *prweWAL) removeSegmentsOverMaxBytes() error {
+	maxBytes := prwe.walConfig.MaxBytes
+	if maxBytes <= 0 || prwe.walPath == "" {
+		return nil
+	}
+
+	segments, remove, err := segmentsOverMaxBytes(prwe.walPath, maxBytes)
+	if err != nil {
+		return err
+	}
+
+	rIndex := prwe.rWALIndex.Load()
+	var dropped uint64
+	for i := 0; i < remove; i++ {
+		if err = os.Remove(segments[i].path); err != nil {
+			return fmt.Errorf("prometheusremotewriteexporter: failed to remove WAL segment: %w", err)
+		}
+		// Entries of segment i end right before the first index of segment i+1.
+		if next := segments[i+1].firstIndex; next > rIndex {
+			dropped += next - max(segments[i].firstIndex, rIndex)
+		}
+	}
+	prwe.recordDropped(dropped)
+	return nil
+}
+
+// truncateOverMaxBytes truncates the front of the open WAL until it fits in MaxBytes. Shards and
+// sink workers call it every truncate frequency, since they don't reopen the WAL, which removes
+// the segments over MaxBytes otherwise. The read index, the sent index and the watermarks of the
+// shards skip the removed entries. Those that were not read yet are counted as dropped requests,
+// and, with shards, every entry that was not exported, since the entries that shards read aren't
+// tracked.
+func (prwe *prweWAL) truncateOverMaxBytes() error {
+	maxBytes := prwe.walConfig.MaxBytes
+	if maxBytes <= 0 {
+		return nil
+	}
+	prwe.mu.Lock()
+	defer prwe.mu.Unlock()
+
+	if prwe.wal == nil {
+		return errNilWAL
+	}
+	if err := prwe.wal.Sync(); err != nil {
+		return err
+	}
+	segments, remove, err := segmentsOverMaxBytes(prwe.walPath, maxBytes)
+	if err != nil || remove == 0 {
+		return err
+	}
+	first := segments[remove].firstIndex
+	if err = prwe.wal.TruncateFront(first); err != nil {
+		return err
+	}
+
+	read := prwe.rWALIndex.Load()
+	if prwe.shards != nil {
+		read = prwe.sWALIndex.Load() + 1
+	}
+	if read < first {
+		prwe.recordDropped(first - max(segments[0].firstIndex, read))
+	}
+	storeMax(prwe.rWALIndex, first)
+	storeMax(prwe.sWALIndex, first-1)
+	if err = prwe.checkpoint.write(prwe.sWALIndex.Load()); err != nil {
+		prwe.log.Warn("failed to checkpoint the exported WAL entries", zap.Error(err))
+	}
+	if prwe.shards != nil {
+		var watermarks string
+		for shard := 0; shard < prwe.shards.count; shard++ {
+			_, watermarks = prwe.shards.advance(shard, first-1)
+		}
+		if err = writeFileAtomic(prwe.shards.path, watermarks); err != nil {
+			prwe.log.Warn("failed to checkpoint the exported WAL entries of the shards", zap.Error(err))
+		}
+		// Shards waiting for entries that were removed read from their watermark again.
+		prwe.shards.notify()
+	}
+	select {
+	case prwe.rNotify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// recordDropped records the entries that were removed to stay within MaxBytes before they were
+// exported.
+func (prwe *prweWAL) recordDropped(dropped uint64) {
+	if dropped == 0 {
+		return
+	}
+	prwe.record(context.Background(), mWALDroppedRequests.M(int64(dropped)))
+	prwe.log.Warn("dropped WAL entries that were not exported to stay within the WAL max bytes",
+		zap.Uint64("dropped", dropped), zap.Int64("max_bytes", prwe.walConfig.MaxBytes))
+}
+
+// segmentsOverMaxBytes returns the segment files of the WAL in dir, and how many of the oldest
+// must be removed for the WAL to fit in maxBytes. The newest segment is never removed, it is
+// still being written to, which is why maxBytes can't be less than the segment size.
+func segmentsOverMaxBytes(dir string, maxBytes int64) ([]walSegment, int, error) {
+	segments, err := listWALSegments(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	sizes := make([]int64, len(segments))
+	var total int64
+	for i, segment := range segments {
+		info, err := os.Stat(segment.path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("prometheusremotewriteexporter: failed to stat WAL segment: %w", err)
+		}
+		sizes[i] = info.Size()
+		total += sizes[i]
+	}
+	remove := 0
+	for ; remove < len(segments)-1 && total > maxBytes; remove++ {
+		total -= sizes[remove]
+	}
+	return segments, remove, nil
+}
+
+// storeMax stores index in v, unless v holds a later index.
+func storeMax(v *atomic.Uint64, index uint64) {
+	for current := v.Load(); index > current && !v.CompareAndSwap(current, index); current = v.Load() {
+	}
+}
+
+type walSegment struct {
+	firstIndex uint64
+	path       string
+}
+
+// listWALSegments returns the segment files of the WAL in dir, ordered by their first index.
+// Segment files are named after the index of their first entry, as 20 zero padded digits.
+func listWALSegments(dir string) ([]walSegment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("prometheusremotewriteexporter: failed to list WAL segments: %w", err)
+	}
+
+	var segments []walSegment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) != 20 {
+			continue
+		}
+		index, err := strconv.ParseUint(name, 10, 64)
+		if err != nil || index == 0 {
+			continue
+		}
+		segments = append(segments, walSegment{firstIndex: index, path: filepath.Join(dir, name)})
+	}
+	// os.ReadDir sorts by file name, which orders the zero padded indices.
+	return segments, nil
+}
+
+// syncAndTruncateFront syncs the WAL and removes the entries before index.
+func (prwe *prweWAL) syncAndTruncateFront(index uint64) error {
 	prwe.mu.Lock()
 	defer prwe.mu.Unlock()
 
@@ -283,7 +851,7 @@ func (prwe *prweWAL) syncAndTruncateFront() error {
 	}
 	// Truncate the WAL from the front for the entries that we already
 	// read from the WAL and had already exported.
-	if err := prwe.wal.TruncateFront(prwe.rWALIndex.Load()); err != nil && !errors.Is(err, wal.ErrOutOfRange) {
+	if err := prwe.wal.TruncateFront(index); err != nil && !errors.Is(err, wal.ErrOutOfRange) {
 		return err
 	}
 	return nil
@@ -297,33 +865,74 @@ func (prwe *prweWAL) exportThenFrontTruncateWAL(ctx context.Context, reqL []*pro
 		return nil
 	}
 
-	if errL := prwe.exportSink(ctx, reqL); errL != nil {
+	if errL := prwe.exportWithRetry(ctx, prwe.rWALIndex.Load()-uint64(len(reqL)), reqL); errL != nil {
 		return errL
 	}
-	if err := prwe.syncAndTruncateFront(); err != nil {
+	prwe.markSent(ctx, len(reqL))
+	if err := prwe.syncAndTruncateFront(prwe.rWALIndex.Load()); err != nil {
 		return err
 	}
+	// The front can't be truncated past the last entry, so once every entry was
+	// exported the first index of the WAL lags behind the read index.
+	rIndex := prwe.rWALIndex.Load()
 	// Reset by retrieving the respective read and write WAL indices.
-	return prwe.retrieveWALIndices()
+	if err := prwe.retrieveWALIndices(); err != nil {
+		return err
+	}
+	prwe.rWALIndex.Store(max(prwe.rWALIndex.Load(), rIndex))
+	return nil
 }
 
 // persistToWAL is the routine that'll be hooked into the exporter's receiving side and it'll
 // write them to the Write-Ahead-Log so that shutdowns won't lose data, and that the routine that
 // reads from the WAL can then process the previously serialized requests.
 func (prwe *prweWAL) persistToWAL(requests []*prompb.WriteRequest) error {
+	requests = prwe.applyFutureSamples(context.Background(), requests)
+	if !prwe.walConfig.KeepEmptySeries {
+		var dropped int
+		if requests, dropped = dropEmptySeries(requests); dropped > 0 {
+			prwe.record(context.Background(), mWALDroppedEmptySeries.M(int64(dropped)))
+		}
+	}
+	requests = normalizeLabels(requests)
+	if prwe.walConfig.DedupeSamples {
+		requests = dedupeSamples(requests)
+	}
+	if prwe.walConfig.MergeSeries {
+		requests = mergeSeries(requests)
+	}
+	if prwe.sorter != nil {
+		requests = prwe.sorter.apply(requests)
+	}
+	if prwe.active != nil {
+		prwe.active.observe(requests)
+	}
+	var shards []int
+	if prwe.shards != nil {
+		requests, shards = prwe.shards.split(requests)
+	}
+
 	prwe.mu.Lock()
 	defer prwe.mu.Unlock()
 
+	if prwe.wal == nil {
+		return errNilWAL
+	}
+
 	// Write all the requests to the WAL in a batch.
 	batch := new(wal.Batch)
-	for _, req := range requests {
+	for i, req := range requests {
 		protoBlob, err := proto.Marshal(req)
 		if err != nil {
 			return err
 		}
 		wIndex := prwe.wWALIndex.Add(1)
 		prwe.log.Debug("write", zap.Uint64("index", wIndex))
-		batch.Write(wIndex, protoBlob)
+		entry := versionWALEntry(walEntryVersion, compressWALEntry(prwe.walConfig.Compression, protoBlob))
+		if shards != nil {
+			entry = tagWALEntry(shards[i], entry)
+		}
+		batch.Write(wIndex, entry)
 	}
 
 	// notify possibly waiting tailing routine of write
@@ -334,7 +943,21 @@ func (prwe *prweWAL) persistToWAL(requests []*prompb.WriteRequest) error {
 		// no receiver, ignore
 	}
 
-	return prwe.wal.WriteBatch(batch)
+	if err := prwe.wal.WriteBatch(batch); err != nil {
+		return err
+	}
+	if prwe.shards != nil {
+		prwe.shards.notify()
+	}
+	prwe.unsynced += len(requests)
+	if flushCount := prwe.walConfig.FlushCount; flushCount > 0 && prwe.unsynced >= flushCount {
+		select {
+		case prwe.flushNotify <- struct{}{}:
+		default:
+			// a flush is already pending
+		}
+	}
+	return nil
 }
 
 // read repeatedly attempts to fetch a *prompb.WriteRequest from the WAL,
@@ -352,18 +975,17 @@ func (prwe *prweWAL) readPrompbFromWAL(ctx context.Context, index uint64) (*prom
 		defer prwe.mu.Unlock()
 
 		prwe.log.Debug("read", zap.Uint64("index", index))
-		protoBlob, err := prwe.wal.Read(index)
+		entry, err := prwe.wal.Read(index)
 		if err != nil {
 			return nil, err
 		}
-
-		var req prompb.WriteRequest
-		if err := proto.Unmarshal(protoBlob, &req); err != nil {
-			return nil, err
+		req, err := decodeWALEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("decode WAL entry %d: %w", index, err)
 		}
 
 		prwe.rWALIndex.Add(1)
-		return &req, nil
+		return req, nil
 	}
 
 	for {
@@ -371,7 +993,7 @@ func (prwe *prweWAL) readPrompbFromWAL(ctx context.Context, index uint64) (*prom
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		case <-prwe.stopChan:
-			return nil, fmt.Errorf("attempt to read from WAL after stopped")
+			return nil, errWALStopped
 		default:
 		}
 
@@ -394,6 +1016,118 @@ func (prwe *prweWAL) readPrompbFromWAL(ctx context.Context, index uint64) (*prom
 	}
 }
 
+// decodeWALEntry decompresses and unmarshals an entry that persistToWAL wrote, regardless of
+// its shard. Entries of a version more recent than walEntryVersion fail with an
+// *UnsupportedWALVersionError rather than being misread.
+func decodeWALEntry(entry []byte) (*prompb.WriteRequest, error) {
+	_, entry, err := untagWALEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+	if entry, err = unversionWALEntry(entry); err != nil {
+		return nil, err
+	}
+	protoBlob, err := decompressWALEntry(entry)
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+
+	var req prompb.WriteRequest
+	if err := proto.Unmarshal(protoBlob, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// readPrompbBatchFromWAL reads up to count consecutive entries starting at index, waiting
+// until at least the first one is written. Fewer entries are returned if the WAL ends
+// before count entries. If an entry can't be read, the entries before it are returned
+// along with the error, so that the read progress can be checkpointed.
+func (prwe *prweWAL) readPrompbBatchFromWAL(ctx context.Context, index uint64, count int) ([]*prompb.WriteRequest, error) {
+	reqL := make([]*prompb.WriteRequest, 0, count)
+	err := prwe.readBatchFromWAL(ctx, index, count, func(_ []byte, req *prompb.WriteRequest) bool {
+		reqL = append(reqL, req)
+		return true
+	})
+	return reqL, err
+}
+
+// readBatchFromWAL is readPrompbBatchFromWAL, calling visit with every entry that was read
+// and its decoded request instead of returning them. Reading stops early once visit returns false.
+func (prwe *prweWAL) readBatchFromWAL(ctx context.Context, index uint64, count int, visit func(entry []byte, req *prompb.WriteRequest) bool) error {
+	if prwe == nil {
+		return fmt.Errorf("attempt to read from closed WAL")
+	}
+
+	try := func() error {
+		prwe.mu.Lock()
+		defer prwe.mu.Unlock()
+
+		if prwe.wal == nil {
+			return errNilWAL
+		}
+		if first, err := prwe.wal.FirstIndex(); err == nil && index < first {
+			return errWALEntriesRemoved
+		}
+		prwe.log.Debug("read batch", zap.Uint64("index", index), zap.Int("count", count))
+		read := 0
+		// Advance the read index by the number of entries that were decoded, even on error.
+		defer func() { prwe.rWALIndex.Add(uint64(read)) }()
+		for i := index; read < count; i++ {
+			entry, err := prwe.wal.Read(i)
+			if errors.Is(err, wal.ErrNotFound) && read > 0 {
+				// A partial batch at the end of the WAL
+				return nil
+			} else if err != nil {
+				return err
+			}
+			req, err := decodeWALEntry(entry)
+			if err != nil {
+				return fmt.Errorf("decode WAL entry %d: %w", i, err)
+			}
+			read++
+			if !visit(entry, req) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-prwe.stopChan:
+			return errWALStopped
+		default:
+		}
+
+		err := try()
+		if errors.Is(err, wal.ErrNotFound) {
+			prwe.log.Debug("wal empty - waiting for write")
+
+			select {
+			case <-prwe.rNotify:
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-prwe.stopChan:
+				return errWALStopped
+			}
+
+			continue
+		}
+
+		return err
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 func max(a, b uint64) uint64 {
 	if a > b {
 		return a
@@ -0,0 +1,85 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// walBuffer holds the requests read from the WAL until they are exported. When compressed, it
+// holds their WAL entries compressed, as written or with snappy if they were written without
+// compression, and only decodes them when they are exported. It is full once it holds maxCount
+// requests or, when maxBytes is set, maxBytes of marshalled requests.
+type walBuffer struct {
+	compress bool
+	maxCount int
+	maxBytes int
+	reqL     []*prompb.WriteRequest
+	entries  [][]byte
+	bytes    int
+}
+
+// add adds a WAL entry and its decoded request to the buffer.
+func (b *walBuffer) add(entry []byte, req *prompb.WriteRequest) {
+	b.bytes += req.Size()
+	if !b.compress {
+		b.reqL = append(b.reqL, req)
+		return
+	}
+	// Entries of a sharded WAL are held without their shard and version, which aren't needed
+	// to export them: the request was already decoded from the entry.
+	if _, untagged, err := untagWALEntry(entry); err == nil {
+		entry = untagged
+	}
+	if unversioned, err := unversionWALEntry(entry); err == nil {
+		entry = unversioned
+	}
+	if len(entry) > 0 && (entry[0] == walHeaderSnappy || entry[0] == walHeaderZstd) {
+		b.entries = append(b.entries, entry)
+		return
+	}
+	b.entries = append(b.entries, compressWALEntry(walCompressionSnappy, entry))
+}
+
+// visit adds a WAL entry and its decoded request to the buffer, and returns whether it isn't full.
+func (b *walBuffer) visit(entry []byte, req *prompb.WriteRequest) bool {
+	b.add(entry, req)
+	return !b.full()
+}
+
+// full returns whether the buffer should be exported.
+func (b *walBuffer) full() bool {
+	return b.len() >= b.maxCount || (b.maxBytes > 0 && b.bytes >= b.maxBytes)
+}
+
+// len returns the number of requests in the buffer.
+func (b *walBuffer) len() int {
+	if b.compress {
+		return len(b.entries)
+	}
+	return len(b.reqL)
+}
+
+// requests returns the requests in the buffer, decoding them if they are compressed.
+func (b *walBuffer) requests() ([]*prompb.WriteRequest, error) {
+	if !b.compress {
+		return b.reqL, nil
+	}
+	reqL := make([]*prompb.WriteRequest, 0, len(b.entries))
+	for _, entry := range b.entries {
+		req, err := decodeWALEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		reqL = append(reqL, req)
+	}
+	return reqL, nil
+}
+
+// reset empties the buffer, reusing its memory.
+func (b *walBuffer) reset() {
+	b.reqL = b.reqL[:0]
+	b.entries = b.entries[:0]
+	b.bytes = 0
+}
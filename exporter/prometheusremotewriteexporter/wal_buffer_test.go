@@ -0,0 +1,153 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWAL_CompressBuffer(t *testing.T) {
+	for _, compression := range []string{walCompressionNone, walCompressionSnappy, walCompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			in := []*prompb.WriteRequest{
+				series("mem_used_percent", 0, 0),
+				series("mem_used_percent", 15, 34),
+				series("mem_used_percent", 30, 99),
+			}
+			out := make([]*prompb.WriteRequest, 0, len(in))
+
+			done := make(chan struct{})
+			sink := func(ctx context.Context, reqs []*prompb.WriteRequest) error {
+				out = append(out, reqs...)
+				if len(out) >= len(in) {
+					close(done)
+				}
+				return nil
+			}
+
+			pwal, err := newWAL(&WALConfig{
+				Directory:      t.TempDir(),
+				Compression:    compression,
+				CompressBuffer: true,
+			}, sink)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			require.NoError(t, pwal.run(contextWithLogger(ctx, zap.NewNop())))
+			t.Cleanup(func() {
+				require.NoError(t, pwal.stop())
+			})
+			require.NoError(t, pwal.persistToWAL(in))
+
+			// wait until the tail routine is no longer busy
+			pwal.rNotify <- struct{}{}
+			cancel()
+
+			// wait until we received all series
+			<-done
+			require.Equal(t, in, out)
+		})
+	}
+}
+
+func TestWAL_BufferSizeBytes(t *testing.T) {
+	small := series("mem_used_percent", 0, 0)
+	large := scaledE2ESeries(1, 50)[0]
+	in := []*prompb.WriteRequest{small, large, small, small, large, small}
+
+	batches := make(chan int, len(in))
+	sink := func(ctx context.Context, reqs []*prompb.WriteRequest) error {
+		batches <- len(reqs)
+		return nil
+	}
+
+	pwal, err := newWAL(&WALConfig{
+		Directory:         t.TempDir(),
+		BufferSizeBytes:   large.Size(),
+		TruncateFrequency: time.Hour,
+	}, sink)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, pwal.run(contextWithLogger(ctx, zap.NewNop())))
+	t.Cleanup(func() {
+		require.NoError(t, pwal.stop())
+	})
+	require.NoError(t, pwal.persistToWAL(in))
+
+	// The buffer is exported with the request that crosses its size, the last small request
+	// is left in it.
+	assert.Equal(t, 2, <-batches)
+	assert.Equal(t, 3, <-batches)
+	select {
+	case n := <-batches:
+		t.Fatalf("unexpected export of %d requests", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// scaledE2ESeries returns the requests of TestWAL_E2E scaled up to count requests of width series each.
+func scaledE2ESeries(count, width int) []*prompb.WriteRequest {
+	reqL := make([]*prompb.WriteRequest, 0, count)
+	for i := 0; i < count; i++ {
+		req := &prompb.WriteRequest{}
+		for j := 0; j < width; j++ {
+			ts := series("mem_used_percent", int64(i*15), float64(i)).Timeseries[0]
+			ts.Labels = append(ts.Labels,
+				prompb.Label{Name: "instance", Value: fmt.Sprintf("host-%d.example.com:9100", j)},
+				prompb.Label{Name: "job", Value: "node"},
+			)
+			req.Timeseries = append(req.Timeseries, ts)
+		}
+		reqL = append(reqL, req)
+	}
+	return reqL
+}
+
+// BenchmarkWALBuffer reports the heap held by a full buffer of BufferSize requests, in the
+// buffer-bytes metric, with and without compression.
+func BenchmarkWALBuffer(b *testing.B) {
+	in := scaledE2ESeries(defaultWALBufferSize, 500)
+	entries := make([][]byte, 0, len(in))
+	for _, req := range in {
+		entry, err := proto.Marshal(req)
+		require.NoError(b, err)
+		entries = append(entries, entry)
+	}
+	in = nil
+
+	for _, compress := range []bool{false, true} {
+		b.Run(fmt.Sprintf("compress=%t", compress), func(b *testing.B) {
+			var held uint64
+			var before, after runtime.MemStats
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				buf := &walBuffer{compress: compress}
+				for _, entry := range entries {
+					req, err := decodeWALEntry(entry)
+					require.NoError(b, err)
+					buf.add(entry, req)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				held += after.HeapAlloc - before.HeapAlloc
+				runtime.KeepAlive(buf)
+			}
+			b.ReportMetric(float64(held)/float64(b.N), "buffer-bytes")
+		})
+	}
+}
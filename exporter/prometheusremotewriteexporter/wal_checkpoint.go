@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// walCheckpointFile is the file in the WAL directory that holds the last index that was
+// exported, so that the entries up to it aren't exported again after a restart. Its name is
+// shorter than segment file names, which are ignored by the WAL.
+const walCheckpointFile = "checkpoint"
+
+// sentCheckpoint persists the last index that was exported.
+type sentCheckpoint struct {
+	path string
+
+	mu      sync.Mutex
+	written uint64
+}
+
+// read returns the last index that was exported, or 0 if none was checkpointed.
+func (c *sentCheckpoint) read() (uint64, error) {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("prometheusremotewriteexporter: failed to read the WAL checkpoint: %w", err)
+	}
+	index, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("prometheusremotewriteexporter: invalid WAL checkpoint %q: %w", data, err)
+	}
+	return index, nil
+}
+
+// write persists index, unless a later index was already written. The checkpoint is replaced
+// atomically, so that a crash leaves either the previous or the new checkpoint.
+func (c *sentCheckpoint) write(index uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if index <= c.written {
+		return nil
+	}
+	if err := writeFileAtomic(c.path, strconv.FormatUint(index, 10)); err != nil {
+		return fmt.Errorf("prometheusremotewriteexporter: failed to write the WAL checkpoint: %w", err)
+	}
+	c.written = index
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file that is synced and then renamed over the
+// file at path, so that a crash leaves either its previous or its new content.
+func writeFileAtomic(path, data string) error {
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteString(data); err == nil {
+		err = f.Sync()
+	}
+	if errC := f.Close(); err == nil {
+		err = errC
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(path))
+	}
+	return err
+}
+
+// syncDir syncs the directory at path, so that a rename in it survives a crash.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if errC := dir.Close(); err == nil {
+		err = errC
+	}
+	return err
+}
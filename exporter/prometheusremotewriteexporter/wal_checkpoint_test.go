@@ -0,0 +1,76 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWAL_CheckpointSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	in := []*prompb.WriteRequest{
+		series("mem_used_percent", 0, 0),
+		series("mem_used_percent", 15, 34),
+		series("mem_used_percent", 30, 99),
+	}
+
+	pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	require.NoError(t, pwal.persistToWAL(in))
+
+	// The first two requests are exported, but the WAL is stopped before it is truncated.
+	ctx := context.Background()
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 2)
+	require.NoError(t, err)
+	require.Equal(t, in[:2], reqL)
+	pwal.markSent(ctx, len(reqL))
+	require.NoError(t, pwal.stop())
+
+	var out []*prompb.WriteRequest
+	done := make(chan struct{})
+	sink := func(_ context.Context, reqL []*prompb.WriteRequest) error {
+		out = append(out, reqL...)
+		close(done)
+		return nil
+	}
+	pwal, err = newWAL(&WALConfig{Directory: dir, TruncateFrequency: 10 * defaultWALTruncateFrequency}, sink)
+	require.NoError(t, err)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	require.NoError(t, pwal.run(contextWithLogger(runCtx, zap.NewNop())))
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	assert.Equal(t, uint64(2), pwal.sWALIndex.Load())
+
+	// Only the third request is replayed after the restart.
+	pwal.rNotify <- struct{}{}
+	cancel()
+	<-done
+	assert.Equal(t, in[2:], out)
+}
+
+func TestWAL_CheckpointPastLastEntry(t *testing.T) {
+	dir := t.TempDir()
+	pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 0, 0)}))
+
+	// A checkpoint of entries that are gone doesn't skip the entries written since.
+	require.NoError(t, pwal.checkpoint.write(10))
+	require.NoError(t, pwal.retrieveWALIndices())
+	assert.Equal(t, uint64(1), pwal.rWALIndex.Load())
+	assert.Equal(t, uint64(0), pwal.sWALIndex.Load())
+}
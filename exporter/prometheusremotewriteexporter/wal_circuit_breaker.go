@@ -0,0 +1,149 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultWALCircuitBreakerCooldown = 30 * time.Second
+
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// WALCircuitBreakerSettings configures the circuit breaker that stops exporting the entries of the
+// WAL for a while once the sink failed repeatedly.
+type WALCircuitBreakerSettings struct {
+	// FailureThreshold is the number of consecutive failed exports after which the circuit opens.
+	// Zero disables the circuit breaker.
+	FailureThreshold int `mapstructure:"failure_threshold"`
+	// Cooldown is the time the circuit stays open, before a single export is attempted again.
+	// Defaults to 30s.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+func (cs *WALCircuitBreakerSettings) validate() error {
+	if cs.FailureThreshold < 0 {
+		return fmt.Errorf("WAL circuit breaker failure threshold can't be negative")
+	}
+	if cs.Cooldown < 0 {
+		return fmt.Errorf("WAL circuit breaker cooldown can't be negative")
+	}
+	return nil
+}
+
+type circuitState int64
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker counts the consecutive failures of the sink. Once they reach the threshold,
+// the circuit opens and no export is allowed until the cooldown elapsed. A single probe export
+// is then allowed, and the circuit closes if it succeeds or opens again if it fails.
+type circuitBreaker struct {
+	threshold     int
+	cooldown      time.Duration
+	onStateChange func(circuitState)
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(settings WALCircuitBreakerSettings, onStateChange func(circuitState)) *circuitBreaker {
+	if settings.FailureThreshold <= 0 {
+		return nil
+	}
+	cooldown := settings.Cooldown
+	if cooldown <= 0 {
+		cooldown = defaultWALCircuitBreakerCooldown
+	}
+	return &circuitBreaker{
+		threshold:     settings.FailureThreshold,
+		cooldown:      cooldown,
+		onStateChange: onStateChange,
+	}
+}
+
+// allow returns how long to wait after now before an export may be attempted, zero if it may be
+// attempted now. A nil circuit breaker always allows exports.
+func (cb *circuitBreaker) allow(now time.Time) time.Duration {
+	if cb == nil {
+		return 0
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if remaining := cb.openedAt.Add(cb.cooldown).Sub(now); remaining > 0 {
+			return remaining
+		}
+		// The caller sends the probe.
+		cb.setState(circuitHalfOpen)
+		return 0
+	case circuitHalfOpen:
+		// Wait for the outcome of the probe.
+		return cb.cooldown
+	default:
+		return 0
+	}
+}
+
+// record records the outcome of an export that was allowed, which completed at now.
+func (cb *circuitBreaker) record(err error, now time.Time) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if err == nil {
+		cb.failures = 0
+		cb.setState(circuitClosed)
+		return
+	}
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.failures >= cb.threshold {
+		cb.openedAt = now
+		cb.setState(circuitOpen)
+	}
+}
+
+func (cb *circuitBreaker) setState(state circuitState) {
+	if cb.state == state {
+		return
+	}
+	cb.state = state
+	if cb.onStateChange != nil {
+		cb.onStateChange(state)
+	}
+}
+
+// waitForCircuit waits until the circuit breaker allows an export, ctx is done or the WAL is stopped.
+func (prwe *prweWAL) waitForCircuit(ctx context.Context) error {
+	for {
+		wait := prwe.breaker.allow(prwe.clock.Now())
+		if wait == 0 {
+			return nil
+		}
+		timer := prwe.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-prwe.stopChan:
+			timer.Stop()
+			return errCircuitOpen
+		case <-timer.Chan():
+		}
+	}
+}
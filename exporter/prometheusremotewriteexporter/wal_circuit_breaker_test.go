@@ -0,0 +1,109 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var states []circuitState
+	cb := newCircuitBreaker(WALCircuitBreakerSettings{FailureThreshold: 2, Cooldown: time.Minute}, func(state circuitState) {
+		states = append(states, state)
+	})
+	now := time.Unix(0, 0)
+	errSink := errors.New("unavailable")
+
+	assert.Zero(t, cb.allow(now))
+	cb.record(errSink, now)
+	assert.Zero(t, cb.allow(now), "the circuit must stay closed below the threshold")
+	cb.record(errSink, now)
+	assert.Equal(t, time.Minute, cb.allow(now), "the circuit must open at the threshold")
+
+	now = now.Add(40 * time.Second)
+	assert.Equal(t, 20*time.Second, cb.allow(now))
+
+	// A single probe is allowed once the cooldown elapsed
+	now = now.Add(20 * time.Second)
+	assert.Zero(t, cb.allow(now))
+	assert.Equal(t, time.Minute, cb.allow(now))
+	cb.record(errSink, now)
+	assert.Equal(t, time.Minute, cb.allow(now), "a failed probe must open the circuit again")
+
+	now = now.Add(time.Minute)
+	assert.Zero(t, cb.allow(now))
+	cb.record(nil, now)
+	assert.Zero(t, cb.allow(now))
+	cb.record(errSink, now)
+	assert.Zero(t, cb.allow(now), "a successful probe must reset the failures")
+
+	assert.Equal(t, []circuitState{circuitOpen, circuitHalfOpen, circuitOpen, circuitHalfOpen, circuitClosed}, states)
+}
+
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	cb := newCircuitBreaker(WALCircuitBreakerSettings{}, nil)
+	require.Nil(t, cb)
+	cb.record(errors.New("unavailable"), time.Unix(0, 0))
+	assert.Zero(t, cb.allow(time.Unix(0, 0)))
+}
+
+func TestWAL_CircuitBreaker(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	const (
+		failures = 3
+		cooldown = 100 * time.Millisecond
+	)
+	var calls []time.Time
+	sink := func(_ context.Context, reqL []*prompb.WriteRequest) error {
+		calls = append(calls, time.Now())
+		if len(calls) <= failures {
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+
+	pwal, err := newWAL(&WALConfig{
+		Directory: t.TempDir(),
+		Retry: WALRetrySettings{
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  time.Minute,
+		},
+		CircuitBreaker: WALCircuitBreakerSettings{FailureThreshold: 2, Cooldown: cooldown},
+	}, sink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	in := []*prompb.WriteRequest{series("mem_used_percent", 0, 0)}
+	require.NoError(t, pwal.persistToWAL(in))
+	ctx := context.Background()
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 1)
+	require.NoError(t, err)
+	require.NoError(t, pwal.exportWithRetry(ctx, 1, reqL))
+
+	// Two failures open the circuit, then a probe is sent after each cooldown until one succeeds.
+	require.Len(t, calls, failures+1)
+	assert.Less(t, calls[1].Sub(calls[0]), cooldown)
+	assert.GreaterOrEqual(t, calls[2].Sub(calls[1]), cooldown)
+	assert.GreaterOrEqual(t, calls[3].Sub(calls[2]), cooldown)
+
+	rows, err := view.RetrieveData(mWALCircuitState.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(circuitClosed), rows[0].Data.(*view.LastValueData).Value)
+}
@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import "time"
+
+// clock is the source of the time of the WAL, and of the timers that drive its truncation,
+// flushes, retries and circuit breaker. It is the wall clock outside of tests, which advance a
+// fake clock instead of sleeping.
+type clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) clockTimer
+	NewTicker(d time.Duration) clockTicker
+}
+
+type clockTimer interface {
+	Chan() <-chan time.Time
+	Stop() bool
+}
+
+type clockTicker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+func (wallClock) NewTimer(d time.Duration) clockTimer {
+	return wallTimer{time.NewTimer(d)}
+}
+
+func (wallClock) NewTicker(d time.Duration) clockTicker {
+	return wallTicker{time.NewTicker(d)}
+}
+
+type wallTimer struct {
+	*time.Timer
+}
+
+func (t wallTimer) Chan() <-chan time.Time {
+	return t.C
+}
+
+type wallTicker struct {
+	*time.Ticker
+}
+
+func (t wallTicker) Chan() <-chan time.Time {
+	return t.C
+}
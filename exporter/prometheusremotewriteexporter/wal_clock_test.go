@@ -0,0 +1,170 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeClock is a clock whose timers and tickers only fire when it is advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a timer, or a ticker if its period is positive.
+type fakeWaiter struct {
+	c       chan time.Time
+	at      time.Time
+	period  time.Duration
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return newFakeClockAt(time.Unix(0, 0))
+}
+
+func newFakeClockAt(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) add(d, period time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{c: make(chan time.Time, 1), at: c.now.Add(d), period: period}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) clockTimer {
+	return &fakeTimer{clock: c, w: c.add(d, 0)}
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) clockTicker {
+	return &fakeTicker{clock: c, w: c.add(d, d)}
+}
+
+// Advance moves the clock forward by d, and fires the timers and tickers that are due. Like
+// those of the time package, a ticker drops the ticks its receiver is not ready for.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, w := range c.waiters {
+		for !w.stopped && !w.at.After(c.now) {
+			select {
+			case w.c <- w.at:
+			default:
+			}
+			if w.period <= 0 {
+				w.stopped = true
+				break
+			}
+			w.at = w.at.Add(w.period)
+		}
+	}
+}
+
+func (c *fakeClock) stop(w *fakeWaiter) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	active := !w.stopped
+	w.stopped = true
+	return active
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	w     *fakeWaiter
+}
+
+func (t *fakeTimer) Chan() <-chan time.Time {
+	return t.w.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	return t.clock.stop(t.w)
+}
+
+type fakeTicker struct {
+	clock *fakeClock
+	w     *fakeWaiter
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time {
+	return t.w.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.stop(t.w)
+}
+
+func TestWAL_TruncateFrequencyClock(t *testing.T) {
+	var exports atomic.Int64
+	var exported atomic.Int64
+	sink := func(_ context.Context, reqs []*prompb.WriteRequest) error {
+		exports.Add(1)
+		exported.Add(int64(len(reqs)))
+		return nil
+	}
+
+	wal, err := newWAL(&WALConfig{
+		Directory:         t.TempDir(),
+		TruncateFrequency: time.Minute,
+	}, sink)
+	require.NoError(t, err)
+	clock := newFakeClock()
+	wal.clock = clock
+
+	ctx := contextWithLogger(context.Background(), zap.NewNop())
+	require.NoError(t, wal.run(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, wal.stop())
+	})
+
+	in := []*prompb.WriteRequest{
+		series("mem_used_percent", 0, 0),
+		series("mem_used_percent", 15, 34),
+		series("mem_used_percent", 30, 99),
+	}
+	require.NoError(t, wal.persistToWAL(in))
+
+	// The requests are read, but they are held until the truncate frequency elapsed
+	require.Eventually(t, func() bool {
+		return wal.rWALIndex.Load() == uint64(len(in)+1)
+	}, 5*time.Second, 5*time.Millisecond)
+	clock.Advance(time.Minute - time.Second)
+	require.NoError(t, wal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 45, 12)}))
+	require.Never(t, func() bool {
+		return exports.Load() > 0
+	}, 100*time.Millisecond, 5*time.Millisecond)
+
+	// Once it elapsed, the next read exports and truncates the WAL exactly once
+	clock.Advance(time.Second)
+	require.NoError(t, wal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 60, 7)}))
+	require.Eventually(t, func() bool {
+		return exports.Load() == 1
+	}, 5*time.Second, 5*time.Millisecond)
+	require.Never(t, func() bool {
+		return exports.Load() > 1
+	}, 100*time.Millisecond, 5*time.Millisecond)
+	assert.Equal(t, int64(len(in)+2), exported.Load())
+	assert.Equal(t, uint64(len(in)+2), wal.sWALIndex.Load())
+}
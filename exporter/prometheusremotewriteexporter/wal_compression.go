@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	walCompressionNone   = "none"
+	walCompressionSnappy = "snappy"
+	walCompressionZstd   = "zstd"
+)
+
+// Compressed WAL entries start with a header byte naming their codec. A marshalled
+// WriteRequest never starts with one of these bytes, since they encode field number 0,
+// so entries without a header, such as those written before compression was supported,
+// are read as uncompressed.
+const (
+	walHeaderSnappy byte = 0x01
+	walHeaderZstd   byte = 0x02
+)
+
+var (
+	zstdEncoderOnce sync.Once
+	zstdEncoder     *zstd.Encoder
+	zstdDecoderOnce sync.Once
+	zstdDecoder     *zstd.Decoder
+)
+
+func validateWALCompression(compression string) error {
+	switch compression {
+	case "", walCompressionNone, walCompressionSnappy, walCompressionZstd:
+		return nil
+	default:
+		return fmt.Errorf("WAL compression %q must be one of %s, %s or %s",
+			compression, walCompressionNone, walCompressionSnappy, walCompressionZstd)
+	}
+}
+
+// compressWALEntry compresses a marshalled WriteRequest and prepends the header of the codec
+func compressWALEntry(compression string, protoBlob []byte) []byte {
+	switch compression {
+	case walCompressionSnappy:
+		return append([]byte{walHeaderSnappy}, snappy.Encode(nil, protoBlob)...)
+	case walCompressionZstd:
+		zstdEncoderOnce.Do(func() {
+			// The encoder is only used with EncodeAll, which does not fail with nil options
+			zstdEncoder, _ = zstd.NewWriter(nil)
+		})
+		return zstdEncoder.EncodeAll(protoBlob, []byte{walHeaderZstd})
+	default:
+		return protoBlob
+	}
+}
+
+// decompressWALEntry returns the marshalled WriteRequest of a WAL entry,
+// regardless of the compression that is currently configured
+func decompressWALEntry(entry []byte) ([]byte, error) {
+	if len(entry) == 0 {
+		return entry, nil
+	}
+	switch entry[0] {
+	case walHeaderSnappy:
+		return snappy.Decode(nil, entry[1:])
+	case walHeaderZstd:
+		zstdDecoderOnce.Do(func() {
+			// The decoder is only used with DecodeAll, which does not fail with nil options
+			zstdDecoder, _ = zstd.NewReader(nil)
+		})
+		return zstdDecoder.DecodeAll(entry[1:], nil)
+	default:
+		return entry, nil
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAL_CompressionRoundTrip(t *testing.T) {
+	for _, compression := range []string{"", walCompressionNone, walCompressionSnappy, walCompressionZstd} {
+		t.Run(fmt.Sprintf("compression=%q", compression), func(t *testing.T) {
+			// Empty series are kept, so that an empty entry is written
+			pwal, err := newWAL(&WALConfig{Directory: t.TempDir(), Compression: compression, KeepEmptySeries: true}, doNothingExportSink)
+			require.NoError(t, err)
+			require.NoError(t, pwal.retrieveWALIndices())
+			t.Cleanup(func() {
+				assert.NoError(t, pwal.stop())
+			})
+
+			in := []*prompb.WriteRequest{series("mem_used_percent", 100, 1), {}}
+			require.NoError(t, pwal.persistToWAL(in))
+
+			ctx := context.Background()
+			for i, want := range in {
+				got, err := pwal.readPrompbFromWAL(ctx, uint64(i+1))
+				require.NoError(t, err)
+				require.Equal(t, want, got)
+			}
+		})
+	}
+}
+
+func TestWAL_CompressionChanged(t *testing.T) {
+	// Entries written before the compression changed, including those written before
+	// compression was supported, are read after a restart with a different codec.
+	dir := t.TempDir()
+	var in []*prompb.WriteRequest
+	for i, compression := range []string{walCompressionNone, walCompressionSnappy, walCompressionZstd} {
+		pwal, err := newWAL(&WALConfig{Directory: dir, Compression: compression}, doNothingExportSink)
+		require.NoError(t, err)
+		require.NoError(t, pwal.retrieveWALIndices())
+		req := series("mem_used_percent", int64(i), float64(i))
+		require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{req}))
+		require.NoError(t, pwal.stop())
+		in = append(in, req)
+	}
+
+	pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	for i, want := range in {
+		got, err := pwal.readPrompbFromWAL(context.Background(), uint64(i+1))
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+}
+
+func TestWAL_CompressionHeaderIsNotProto(t *testing.T) {
+	protoBlob, err := proto.Marshal(series("mem_used_percent", 100, 1))
+	require.NoError(t, err)
+	require.NotContains(t, []byte{walHeaderSnappy, walHeaderZstd}, protoBlob[0])
+
+	decoded, err := decompressWALEntry(protoBlob)
+	require.NoError(t, err)
+	require.Equal(t, protoBlob, decoded)
+}
+
+func BenchmarkWAL_DecodeEntry(b *testing.B) {
+	req := &prompb.WriteRequest{}
+	for i := 0; i < 500; i++ {
+		req.Timeseries = append(req.Timeseries, series(fmt.Sprintf("metric_%d", i), int64(i), float64(i)).Timeseries...)
+	}
+	protoBlob, err := proto.Marshal(req)
+	require.NoError(b, err)
+
+	for _, compression := range []string{walCompressionNone, walCompressionSnappy, walCompressionZstd} {
+		entry := compressWALEntry(compression, protoBlob)
+		b.Run(compression, func(b *testing.B) {
+			b.SetBytes(int64(len(protoBlob)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				decompressed, err := decompressWALEntry(entry)
+				if err != nil {
+					b.Fatal(err)
+				}
+				var decoded prompb.WriteRequest
+				if err := proto.Unmarshal(decompressed, &decoded); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
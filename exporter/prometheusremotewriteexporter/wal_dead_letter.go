@@ -0,0 +1,80 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/tidwall/wal"
+	"go.uber.org/zap"
+)
+
+// deadLetterWAL holds the requests that the export sink rejected with a permanent error.
+type deadLetterWAL struct {
+	mu  sync.Mutex
+	wal *wal.Log
+}
+
+// deadLetterEntry is an entry of the dead-letter WAL.
+type deadLetterEntry struct {
+	// Index is the index of the request in the WAL.
+	Index uint64 `json:"index"`
+	// Error is the error the request was rejected with.
+	Error string `json:"error"`
+	// Request is the marshalled prompb.WriteRequest.
+	Request []byte `json:"request"`
+}
+
+// moveToDeadLetter appends the request read from the WAL at index to the dead-letter WAL.
+func (prwe *prweWAL) moveToDeadLetter(ctx context.Context, index uint64, req *prompb.WriteRequest, cause error) error {
+	prwe.deadLetter.mu.Lock()
+	defer prwe.deadLetter.mu.Unlock()
+
+	dir := prwe.walConfig.DeadLetterDir
+	if prwe.deadLetter.wal == nil {
+		log, err := wal.Open(dir, &wal.Options{NoCopy: true})
+		if err != nil {
+			return fmt.Errorf("prometheusremotewriteexporter: failed to open dead-letter WAL: %w", err)
+		}
+		prwe.deadLetter.wal = log
+	}
+
+	protoBlob, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	entry, err := json.Marshal(deadLetterEntry{Index: index, Error: cause.Error(), Request: protoBlob})
+	if err != nil {
+		return err
+	}
+	last, err := prwe.deadLetter.wal.LastIndex()
+	if err != nil {
+		return fmt.Errorf("prometheusremotewriteexporter: failed to retrieve the last dead-letter WAL index: %w", err)
+	}
+	if err = prwe.deadLetter.wal.Write(last+1, entry); err != nil {
+		return fmt.Errorf("prometheusremotewriteexporter: failed to write to dead-letter WAL: %w", err)
+	}
+
+	prwe.record(ctx, mWALDeadLettered.M(1))
+	prwe.log.Warn("moved WAL entry that can't be exported to the dead-letter WAL",
+		zap.Uint64("index", index), zap.String("path", dir), zap.Error(cause))
+	return nil
+}
+
+func (prwe *prweWAL) closeDeadLetter() error {
+	prwe.deadLetter.mu.Lock()
+	defer prwe.deadLetter.mu.Unlock()
+
+	if prwe.deadLetter.wal == nil {
+		return nil
+	}
+	err := prwe.deadLetter.wal.Close()
+	prwe.deadLetter.wal = nil
+	return err
+}
@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/wal"
+	"go.opencensus.io/stats/view"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap"
+)
+
+func TestWAL_DeadLetter(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	poison := series("mem_used_percent", 30, -1)
+	in := []*prompb.WriteRequest{
+		series("mem_used_percent", 0, 0),
+		poison,
+		series("mem_used_percent", 15, 34),
+	}
+
+	// The sink rejects every batch that holds the poison request
+	var mu sync.Mutex
+	var out []*prompb.WriteRequest
+	sink := func(_ context.Context, reqs []*prompb.WriteRequest) error {
+		for _, req := range reqs {
+			if req.Timeseries[0].Samples[0].Value < 0 {
+				return consumererror.NewPermanent(errors.New("remote write returned HTTP status 400 Bad Request"))
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		out = append(out, reqs...)
+		return nil
+	}
+
+	dir := t.TempDir()
+	deadLetterDir := filepath.Join(dir, "dead_letter")
+	pwal, err := newWAL(&WALConfig{
+		Directory:     dir,
+		DeadLetterDir: deadLetterDir,
+	}, sink)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, pwal.run(contextWithLogger(ctx, zap.NewNop())))
+	require.NoError(t, pwal.persistToWAL(in))
+	// wait until the tail routine is no longer busy, then flush what it read
+	pwal.rNotify <- struct{}{}
+	cancel()
+
+	// The requests after the poison one still reach the sink
+	require.Eventually(t, func() bool {
+		return pwal.sWALIndex.Load() == uint64(len(in))
+	}, 10*time.Second, 10*time.Millisecond)
+	require.NoError(t, pwal.stop())
+	mu.Lock()
+	assert.Equal(t, []*prompb.WriteRequest{in[0], in[2]}, out)
+	mu.Unlock()
+
+	deadLetter, err := wal.Open(deadLetterDir, nil)
+	require.NoError(t, err)
+	defer deadLetter.Close()
+	last, err := deadLetter.LastIndex()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), last)
+	data, err := deadLetter.Read(1)
+	require.NoError(t, err)
+	var entry deadLetterEntry
+	require.NoError(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, uint64(2), entry.Index)
+	assert.Contains(t, entry.Error, "400 Bad Request")
+	var req prompb.WriteRequest
+	require.NoError(t, proto.Unmarshal(entry.Request, &req))
+	assert.Equal(t, poison, &req)
+
+	rows, err := view.RetrieveData(mWALDeadLettered.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(1), rows[0].Data.(*view.SumData).Value)
+}
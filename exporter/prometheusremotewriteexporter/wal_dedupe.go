@@ -0,0 +1,79 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// sampleKey identifies a sample by the labels of its series and its timestamp.
+type sampleKey struct {
+	labels    string
+	timestamp int64
+}
+
+// labelsKey returns a key of the labels that doesn't depend on their order.
+func labelsKey(labels []prompb.Label) string {
+	sorted := make([]prompb.Label, len(labels))
+	copy(sorted, labels)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+
+	var b strings.Builder
+	for _, label := range sorted {
+		b.WriteString(strconv.Quote(label.Name))
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(label.Value))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// dedupeSamples returns the requests without the samples that have the same labels and
+// timestamp as a later sample of the requests, so that the last value is kept. Series and
+// requests that are left empty are removed. The requests themselves are not modified.
+func dedupeSamples(requests []*prompb.WriteRequest) []*prompb.WriteRequest {
+	type position struct{ request, series, sample int }
+	last := map[sampleKey]position{}
+	keys := make([][]string, len(requests))
+	for i, req := range requests {
+		keys[i] = make([]string, len(req.Timeseries))
+		for j, ts := range req.Timeseries {
+			keys[i][j] = labelsKey(ts.Labels)
+			for k, sample := range ts.Samples {
+				last[sampleKey{labels: keys[i][j], timestamp: sample.Timestamp}] = position{i, j, k}
+			}
+		}
+	}
+
+	deduped := make([]*prompb.WriteRequest, 0, len(requests))
+	for i, req := range requests {
+		out := &prompb.WriteRequest{Metadata: req.Metadata}
+		for j, ts := range req.Timeseries {
+			samples := make([]prompb.Sample, 0, len(ts.Samples))
+			for k, sample := range ts.Samples {
+				if last[sampleKey{labels: keys[i][j], timestamp: sample.Timestamp}] == (position{i, j, k}) {
+					samples = append(samples, sample)
+				}
+			}
+			if len(samples) == 0 && len(ts.Exemplars) == 0 && len(ts.Histograms) == 0 {
+				continue
+			}
+			ts.Samples = samples
+			out.Timeseries = append(out.Timeseries, ts)
+		}
+		if len(out.Timeseries) > 0 || len(out.Metadata) > 0 {
+			deduped = append(deduped, out)
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,70 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAL_DedupeSamples(t *testing.T) {
+	hostA := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "host", Value: "a"}}
+	// The same series, with its labels in another order
+	hostAReordered := []prompb.Label{{Name: "host", Value: "a"}, {Name: "__name__", Value: "mem_used_percent"}}
+	hostB := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "host", Value: "b"}}
+
+	in := []*prompb.WriteRequest{
+		{
+			Timeseries: []prompb.TimeSeries{
+				{Labels: hostA, Samples: []prompb.Sample{{Value: 1, Timestamp: 100}, {Value: 2, Timestamp: 200}}},
+				{Labels: hostB, Samples: []prompb.Sample{{Value: 3, Timestamp: 100}}},
+			},
+		},
+		{
+			Timeseries: []prompb.TimeSeries{
+				{Labels: hostAReordered, Samples: []prompb.Sample{{Value: 10, Timestamp: 100}}},
+				{Labels: hostB, Samples: []prompb.Sample{{Value: 3, Timestamp: 100}}},
+			},
+		},
+	}
+
+	pwal, err := newWAL(&WALConfig{Directory: t.TempDir(), DedupeSamples: true}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	require.NoError(t, pwal.persistToWAL(in))
+
+	var out []*prompb.WriteRequest
+	for i := uint64(1); i <= pwal.wWALIndex.Load(); i++ {
+		req, err := pwal.readPrompbFromWAL(context.Background(), i)
+		require.NoError(t, err)
+		out = append(out, req)
+	}
+
+	// The last sample of every series and timestamp is kept, with the labels of the series sorted
+	want := []*prompb.WriteRequest{
+		{
+			Timeseries: []prompb.TimeSeries{
+				{Labels: hostA, Samples: []prompb.Sample{{Value: 2, Timestamp: 200}}},
+			},
+		},
+		{
+			Timeseries: []prompb.TimeSeries{
+				{Labels: hostA, Samples: []prompb.Sample{{Value: 10, Timestamp: 100}}},
+				{Labels: hostB, Samples: []prompb.Sample{{Value: 3, Timestamp: 100}}},
+			},
+		},
+	}
+	assert.Equal(t, want, out)
+
+	// The requests that were persisted are left untouched
+	assert.Len(t, in[0].Timeseries, 2)
+	assert.Len(t, in[0].Timeseries[0].Samples, 2)
+}
@@ -0,0 +1,35 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import "github.com/prometheus/prometheus/prompb"
+
+// dropEmptySeries returns the requests without the series that have no samples, exemplars
+// or histograms, along with the number of series it removed. Requests that are left without
+// series or metadata are removed. The requests themselves are not modified.
+func dropEmptySeries(requests []*prompb.WriteRequest) ([]*prompb.WriteRequest, int) {
+	dropped := 0
+	kept := make([]*prompb.WriteRequest, 0, len(requests))
+	for _, req := range requests {
+		out := req
+		for i, ts := range req.Timeseries {
+			if len(ts.Samples) > 0 || len(ts.Exemplars) > 0 || len(ts.Histograms) > 0 {
+				if out != req {
+					out.Timeseries = append(out.Timeseries, ts)
+				}
+				continue
+			}
+			dropped++
+			if out == req {
+				// Copy the request on its first empty series, with the series before it.
+				out = &prompb.WriteRequest{Metadata: req.Metadata}
+				out.Timeseries = append(out.Timeseries, req.Timeseries[:i]...)
+			}
+		}
+		if len(out.Timeseries) > 0 || len(out.Metadata) > 0 {
+			kept = append(kept, out)
+		}
+	}
+	return kept, dropped
+}
@@ -0,0 +1,92 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+func TestWAL_DropEmptySeries(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	hostA := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "host", Value: "a"}}
+	hostB := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "host", Value: "b"}}
+	hostC := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "host", Value: "c"}}
+	metadata := []prompb.MetricMetadata{{MetricFamilyName: "mem_used_percent", Type: prompb.MetricMetadata_GAUGE}}
+
+	in := []*prompb.WriteRequest{
+		{
+			Timeseries: []prompb.TimeSeries{
+				{Labels: hostA, Samples: []prompb.Sample{{Value: 1, Timestamp: 100}}},
+				{Labels: hostB},
+				{Labels: hostC, Exemplars: []prompb.Exemplar{{Value: 2, Timestamp: 100}}},
+			},
+		},
+		// A request left without series is dropped, unless it has metadata
+		{Timeseries: []prompb.TimeSeries{{Labels: hostA}, {Labels: hostB}}},
+		{Timeseries: []prompb.TimeSeries{{Labels: hostC}}, Metadata: metadata},
+		{Timeseries: []prompb.TimeSeries{{Labels: hostB, Histograms: []prompb.Histogram{{Timestamp: 100}}}}},
+	}
+
+	pwal, err := newWAL(&WALConfig{Directory: t.TempDir()}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	require.NoError(t, pwal.persistToWAL(in))
+
+	var out []*prompb.WriteRequest
+	for i := uint64(1); i <= pwal.wWALIndex.Load(); i++ {
+		req, err := pwal.readPrompbFromWAL(context.Background(), i)
+		require.NoError(t, err)
+		out = append(out, req)
+	}
+
+	want := []*prompb.WriteRequest{
+		{
+			Timeseries: []prompb.TimeSeries{
+				{Labels: hostA, Samples: []prompb.Sample{{Value: 1, Timestamp: 100}}},
+				{Labels: hostC, Exemplars: []prompb.Exemplar{{Value: 2, Timestamp: 100}}},
+			},
+		},
+		{Metadata: metadata},
+		{Timeseries: []prompb.TimeSeries{{Labels: hostB, Histograms: []prompb.Histogram{{Timestamp: 100}}}}},
+	}
+	assert.Equal(t, want, out)
+
+	// The requests that were persisted are left untouched
+	assert.Len(t, in[0].Timeseries, 3)
+
+	rows, err := view.RetrieveData(mWALDroppedEmptySeries.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(4), rows[0].Data.(*view.SumData).Value)
+}
+
+func TestWAL_KeepEmptySeries(t *testing.T) {
+	in := []*prompb.WriteRequest{
+		{Timeseries: []prompb.TimeSeries{{Labels: []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}}}}},
+	}
+
+	pwal, err := newWAL(&WALConfig{Directory: t.TempDir(), KeepEmptySeries: true}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	require.NoError(t, pwal.persistToWAL(in))
+
+	req, err := pwal.readPrompbFromWAL(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, in[0], req)
+}
@@ -0,0 +1,153 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const (
+	walFutureSampleDrop  = "drop"
+	walFutureSampleClamp = "clamp"
+)
+
+func validateWALFutureSamples(tolerance time.Duration, action string) error {
+	if tolerance < 0 {
+		return fmt.Errorf("WAL future tolerance can't be negative")
+	}
+	switch action {
+	case "", walFutureSampleDrop, walFutureSampleClamp:
+	default:
+		return fmt.Errorf("WAL future sample action %q must be one of %s or %s",
+			action, walFutureSampleDrop, walFutureSampleClamp)
+	}
+	if action != "" && tolerance == 0 {
+		return fmt.Errorf("WAL future sample action only applies with a future tolerance")
+	}
+	return nil
+}
+
+// futureSamples drops the samples and histograms whose timestamp is more than tolerance
+// ahead of now, or clamps their timestamp to now. Samples in the past are left untouched.
+type futureSamples struct {
+	tolerance time.Duration
+	clamp     bool
+}
+
+// newFutureSamples returns nil if no tolerance is set.
+func newFutureSamples(wc *WALConfig) *futureSamples {
+	if wc.FutureTolerance <= 0 {
+		return nil
+	}
+	return &futureSamples{
+		tolerance: wc.FutureTolerance,
+		clamp:     wc.FutureSampleAction == walFutureSampleClamp,
+	}
+}
+
+// apply returns the requests with the samples and histograms that are too far in the future
+// dropped or clamped, along with their number. A series whose every sample was dropped is
+// removed, but the requests are all returned, so that they keep their WAL index when they are
+// exported. The requests themselves are not modified.
+func (f *futureSamples) apply(requests []*prompb.WriteRequest, now time.Time) ([]*prompb.WriteRequest, int) {
+	limit := now.Add(f.tolerance).UnixMilli()
+	affected := 0
+	out := make([]*prompb.WriteRequest, 0, len(requests))
+	for _, req := range requests {
+		var fixed *prompb.WriteRequest
+		for i, ts := range req.Timeseries {
+			samples, n := f.samples(ts.Samples, limit, now.UnixMilli())
+			histograms, m := f.histograms(ts.Histograms, limit, now.UnixMilli())
+			if n+m == 0 {
+				if fixed != nil {
+					fixed.Timeseries = append(fixed.Timeseries, ts)
+				}
+				continue
+			}
+			affected += n + m
+			if fixed == nil {
+				// Copy the request on its first affected series, with the series before it.
+				fixed = &prompb.WriteRequest{Metadata: req.Metadata}
+				fixed.Timeseries = append(fixed.Timeseries, req.Timeseries[:i]...)
+			}
+			if len(samples) == 0 && len(histograms) == 0 && len(ts.Exemplars) == 0 {
+				continue
+			}
+			ts.Samples, ts.Histograms = samples, histograms
+			fixed.Timeseries = append(fixed.Timeseries, ts)
+		}
+		if fixed == nil {
+			fixed = req
+		}
+		out = append(out, fixed)
+	}
+	return out, affected
+}
+
+// samples returns the samples whose timestamp isn't after limit, or the samples with their
+// timestamp clamped to now, and how many were too far in the future. Samples that are all
+// within limit are returned as they are.
+func (f *futureSamples) samples(samples []prompb.Sample, limit, now int64) ([]prompb.Sample, int) {
+	affected := 0
+	for _, s := range samples {
+		if s.Timestamp > limit {
+			affected++
+		}
+	}
+	if affected == 0 {
+		return samples, 0
+	}
+	out := make([]prompb.Sample, 0, len(samples))
+	for _, s := range samples {
+		if s.Timestamp > limit {
+			if !f.clamp {
+				continue
+			}
+			s.Timestamp = now
+		}
+		out = append(out, s)
+	}
+	return out, affected
+}
+
+// histograms is samples for the histograms of a series.
+func (f *futureSamples) histograms(histograms []prompb.Histogram, limit, now int64) ([]prompb.Histogram, int) {
+	affected := 0
+	for _, h := range histograms {
+		if h.Timestamp > limit {
+			affected++
+		}
+	}
+	if affected == 0 {
+		return histograms, 0
+	}
+	out := make([]prompb.Histogram, 0, len(histograms))
+	for _, h := range histograms {
+		if h.Timestamp > limit {
+			if !f.clamp {
+				continue
+			}
+			h.Timestamp = now
+		}
+		out = append(out, h)
+	}
+	return out, affected
+}
+
+// applyFutureSamples applies the future tolerance of the WAL to the requests, if any, and
+// counts the samples that were too far in the future.
+func (prwe *prweWAL) applyFutureSamples(ctx context.Context, requests []*prompb.WriteRequest) []*prompb.WriteRequest {
+	if prwe.future == nil {
+		return requests
+	}
+	requests, affected := prwe.future.apply(requests, prwe.clock.Now())
+	if affected > 0 {
+		prwe.record(ctx, mWALFutureSamples.M(int64(affected)))
+	}
+	return requests
+}
@@ -0,0 +1,123 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+func TestWAL_FutureSamples(t *testing.T) {
+	now := time.UnixMilli(1_000_000_000)
+	future := now.Add(time.Hour).UnixMilli()
+	soon := now.Add(time.Minute).UnixMilli()
+	past := now.Add(-365 * 24 * time.Hour).UnixMilli()
+
+	hostA := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "host", Value: "a"}}
+	hostB := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "host", Value: "b"}}
+	in := func() []*prompb.WriteRequest {
+		return []*prompb.WriteRequest{{
+			Timeseries: []prompb.TimeSeries{
+				{Labels: hostA, Samples: []prompb.Sample{{Value: 1, Timestamp: past}, {Value: 2, Timestamp: soon}, {Value: 3, Timestamp: future}}},
+				{Labels: hostB, Samples: []prompb.Sample{{Value: 4, Timestamp: future}}},
+			},
+		}}
+	}
+
+	tests := []struct {
+		action string
+		want   []prompb.TimeSeries
+	}{
+		{
+			// Samples beyond the tolerance are dropped, along with a series left without samples
+			action: walFutureSampleDrop,
+			want: []prompb.TimeSeries{
+				{Labels: hostA, Samples: []prompb.Sample{{Value: 1, Timestamp: past}, {Value: 2, Timestamp: soon}}},
+			},
+		},
+		{
+			action: walFutureSampleClamp,
+			want: []prompb.TimeSeries{
+				{Labels: hostA, Samples: []prompb.Sample{{Value: 1, Timestamp: past}, {Value: 2, Timestamp: soon}, {Value: 3, Timestamp: now.UnixMilli()}}},
+				{Labels: hostB, Samples: []prompb.Sample{{Value: 4, Timestamp: now.UnixMilli()}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			views := MetricViews()
+			require.NoError(t, view.Register(views...))
+			t.Cleanup(func() { view.Unregister(views...) })
+
+			dir := t.TempDir()
+			pwal, err := newWAL(&WALConfig{
+				Directory:          dir,
+				FutureTolerance:    10 * time.Minute,
+				FutureSampleAction: tt.action,
+			}, doNothingExportSink)
+			require.NoError(t, err)
+			pwal.clock = newFakeClockAt(now)
+			require.NoError(t, pwal.retrieveWALIndices())
+			reqs := in()
+			require.NoError(t, pwal.persistToWAL(reqs))
+			require.NoError(t, pwal.stop())
+
+			assert.Equal(t, []*prompb.WriteRequest{{Timeseries: tt.want}}, readAllFromWAL(t, dir))
+			// The requests themselves are not modified
+			assert.Equal(t, in(), reqs)
+
+			rows, err := view.RetrieveData(mWALFutureSamples.Name())
+			require.NoError(t, err)
+			require.Len(t, rows, 1)
+			assert.Equal(t, float64(2), rows[0].Data.(*view.SumData).Value)
+		})
+	}
+}
+
+func TestWAL_FutureSamplesReplayed(t *testing.T) {
+	now := time.UnixMilli(1_000_000_000)
+	hostA := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "host", Value: "a"}}
+	past := prompb.Sample{Value: 1, Timestamp: now.Add(-time.Hour).UnixMilli()}
+	future := prompb.Sample{Value: 2, Timestamp: now.Add(time.Hour).UnixMilli()}
+
+	// The requests were written before the future tolerance was set
+	dir := t.TempDir()
+	pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{
+		{Timeseries: []prompb.TimeSeries{{Labels: hostA, Samples: []prompb.Sample{past, future}}}},
+		{Timeseries: []prompb.TimeSeries{{Labels: hostA, Samples: []prompb.Sample{future}}}},
+	}))
+	require.NoError(t, pwal.stop())
+
+	var exported []*prompb.WriteRequest
+	sink := func(_ context.Context, reqL []*prompb.WriteRequest) error {
+		exported = append(exported, reqL...)
+		return nil
+	}
+	pwal, err = newWAL(&WALConfig{Directory: dir, FutureTolerance: time.Minute}, sink)
+	require.NoError(t, err)
+	pwal.clock = newFakeClockAt(now)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	ctx := context.Background()
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 2)
+	require.NoError(t, err)
+	require.NoError(t, pwal.exportWithRetry(ctx, 1, reqL))
+
+	// Every request is exported, without the samples too far in the future
+	assert.Equal(t, []*prompb.WriteRequest{
+		{Timeseries: []prompb.TimeSeries{{Labels: hostA, Samples: []prompb.Sample{past}}}},
+		{},
+	}, exported)
+}
@@ -0,0 +1,137 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/tidwall/wal"
+)
+
+// WALReader iterates over the requests persisted in a WAL without modifying it. It is
+// returned by OpenWALReadOnly.
+type WALReader struct {
+	segments []walSegment
+	// data is the content of the segment that is read, pos the position of its next entry
+	// and index the index of that entry.
+	data  []byte
+	pos   int
+	index uint64
+}
+
+// OpenWALReadOnly opens the WAL of the exporter whose WAL directory is dir, to inspect the
+// requests that it holds, such as those that were not exported yet. The segment files are
+// only read: unlike opening the WAL to export it, which may create, rename or truncate them,
+// nothing is written and no index or checkpoint is changed, so the WAL of a running exporter
+// may be opened, and the same WAL may be opened any number of times.
+func OpenWALReadOnly(dir string) (*WALReader, error) {
+	walPath := (&WALConfig{Directory: dir}).path()
+	if _, err := os.Stat(walPath); err != nil {
+		return nil, fmt.Errorf("prometheusremotewriteexporter: failed to open WAL: %w", err)
+	}
+	segments, err := listReadOnlyWALSegments(walPath)
+	if err != nil {
+		return nil, err
+	}
+	return &WALReader{segments: segments}, nil
+}
+
+// Next returns the next request of the WAL along with its index, and io.EOF after the last
+// request. An entry that is still being written at the end of the WAL is not returned.
+func (r *WALReader) Next() (uint64, *prompb.WriteRequest, error) {
+	for r.pos >= len(r.data) {
+		if len(r.segments) == 0 {
+			return 0, nil, io.EOF
+		}
+		segment := r.segments[0]
+		r.segments = r.segments[1:]
+		data, err := os.ReadFile(segment.path)
+		if err != nil {
+			return 0, nil, fmt.Errorf("prometheusremotewriteexporter: failed to read WAL segment: %w", err)
+		}
+		r.data, r.pos, r.index = data, 0, segment.firstIndex
+	}
+
+	// Entries are written by github.com/tidwall/wal as their uvarint size followed by their data.
+	size, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 || uint64(len(r.data)-r.pos-n) < size {
+		if len(r.segments) == 0 {
+			r.pos = len(r.data)
+			return 0, nil, io.EOF
+		}
+		return 0, nil, fmt.Errorf("prometheusremotewriteexporter: WAL entry %d is torn: %w", r.index, wal.ErrCorrupt)
+	}
+	entry := r.data[r.pos+n : r.pos+n+int(size)]
+	index := r.index
+	r.pos += n + int(size)
+	r.index++
+
+	req, err := decodeWALEntry(entry)
+	if err != nil {
+		return index, nil, fmt.Errorf("decode WAL entry %d: %w", index, err)
+	}
+	return index, req, nil
+}
+
+// Close releases the WAL. It never fails, since no file is held open between calls to Next.
+func (r *WALReader) Close() error {
+	r.segments, r.data = nil, nil
+	return nil
+}
+
+// listReadOnlyWALSegments returns the segment files of the WAL in dir, ordered by their first
+// index, as they are once the WAL is opened. A front or back truncation that was interrupted
+// leaves a .START or .END segment, which opening the WAL completes; they are resolved here
+// without touching the files instead.
+func listReadOnlyWALSegments(dir string) ([]walSegment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("prometheusremotewriteexporter: failed to list WAL segments: %w", err)
+	}
+
+	var segments []walSegment
+	start, end := -1, -1
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) < 20 {
+			continue
+		}
+		index, err := strconv.ParseUint(name[:20], 10, 64)
+		if err != nil || index == 0 {
+			continue
+		}
+		isStart := len(name) == 26 && strings.HasSuffix(name, ".START")
+		isEnd := len(name) == 24 && strings.HasSuffix(name, ".END")
+		if len(name) != 20 && !isStart && !isEnd {
+			continue
+		}
+		if isStart {
+			start = len(segments)
+		} else if isEnd && end == -1 {
+			end = len(segments)
+		}
+		segments = append(segments, walSegment{firstIndex: index, path: filepath.Join(dir, name)})
+	}
+
+	if start != -1 && end != -1 {
+		return nil, fmt.Errorf("prometheusremotewriteexporter: WAL has both a START and an END segment: %w", wal.ErrCorrupt)
+	}
+	if start != -1 {
+		segments = segments[start:]
+	}
+	if end != -1 {
+		segments = segments[:end+1]
+		if n := len(segments); n > 1 && segments[n-2].firstIndex == segments[n-1].firstIndex {
+			segments = append(segments[:n-2], segments[n-1])
+		}
+	}
+	return segments, nil
+}
@@ -0,0 +1,88 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readWALFiles returns the content of the files of the WAL in dir, by name.
+func readWALFiles(t *testing.T, dir string) map[string][]byte {
+	files := map[string][]byte{}
+	walPath := (&WALConfig{Directory: dir}).path()
+	entries, err := os.ReadDir(walPath)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(walPath, entry.Name()))
+		require.NoError(t, err)
+		files[entry.Name()] = data
+	}
+	return files
+}
+
+func TestOpenWALReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	in := []*prompb.WriteRequest{
+		series("mem_used_percent", 0, 0),
+		series("mem_used_percent", 15, 34),
+		series("mem_used_percent", 30, 99),
+	}
+	require.NoError(t, pwal.persistToWAL(in))
+	files := readWALFiles(t, dir)
+
+	// The WAL is read while it is open, by two readers at the same time
+	first, err := OpenWALReadOnly(dir)
+	require.NoError(t, err)
+	second, err := OpenWALReadOnly(dir)
+	require.NoError(t, err)
+	for i, want := range in {
+		for _, r := range []*WALReader{first, second} {
+			index, req, err := r.Next()
+			require.NoError(t, err)
+			assert.Equal(t, uint64(i+1), index)
+			assert.Equal(t, want, req)
+		}
+	}
+	for _, r := range []*WALReader{first, second} {
+		_, _, err = r.Next()
+		assert.ErrorIs(t, err, io.EOF)
+		assert.NoError(t, r.Close())
+	}
+
+	// Neither the files nor the indices of the WAL changed
+	assert.Equal(t, files, readWALFiles(t, dir))
+	assert.Equal(t, uint64(1), pwal.rWALIndex.Load())
+	assert.Equal(t, uint64(3), pwal.wWALIndex.Load())
+
+	// Once the front of the WAL is truncated, the requests start at its first index
+	require.NoError(t, pwal.syncAndTruncateFront(2))
+	r, err := OpenWALReadOnly(dir)
+	require.NoError(t, err)
+	index, req, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), index)
+	assert.Equal(t, in[1], req)
+}
+
+func TestOpenWALReadOnlyMissing(t *testing.T) {
+	dir := t.TempDir()
+	_, err := OpenWALReadOnly(dir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	// The WAL directory is not created
+	_, err = os.Stat((&WALConfig{Directory: dir}).path())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,62 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"sort"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// normalizeLabels returns the requests with the labels of every series sorted by name, as
+// the remote write specification requires, and without the labels whose name is repeated
+// later in the series, so that the last value is kept. The requests themselves are not
+// modified, and the requests whose labels are already normalized are returned as they are.
+func normalizeLabels(requests []*prompb.WriteRequest) []*prompb.WriteRequest {
+	normalized := make([]*prompb.WriteRequest, 0, len(requests))
+	for _, req := range requests {
+		out := req
+		for i, ts := range req.Timeseries {
+			if labelsNormalized(ts.Labels) {
+				continue
+			}
+			if out == req {
+				out = &prompb.WriteRequest{Metadata: req.Metadata}
+				out.Timeseries = append([]prompb.TimeSeries(nil), req.Timeseries...)
+			}
+			out.Timeseries[i].Labels = sortedLabels(ts.Labels)
+		}
+		normalized = append(normalized, out)
+	}
+	return normalized
+}
+
+// labelsNormalized reports whether the labels are sorted by name, without a repeated name.
+func labelsNormalized(labels []prompb.Label) bool {
+	for i := 1; i < len(labels); i++ {
+		if labels[i-1].Name >= labels[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedLabels returns a copy of the labels sorted by name, with the last value of every name.
+func sortedLabels(labels []prompb.Label) []prompb.Label {
+	sorted := make([]prompb.Label, len(labels))
+	copy(sorted, labels)
+	// The sort is stable, so that the labels of a name stay in their order and the last is kept.
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	deduped := sorted[:0]
+	for _, label := range sorted {
+		if n := len(deduped); n > 0 && deduped[n-1].Name == label.Name {
+			deduped[n-1] = label
+			continue
+		}
+		deduped = append(deduped, label)
+	}
+	return deduped
+}
@@ -0,0 +1,62 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAL_NormalizeLabels(t *testing.T) {
+	sorted := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "host", Value: "a"}}
+	in := []*prompb.WriteRequest{
+		{
+			Timeseries: []prompb.TimeSeries{
+				// Unsorted, with a repeated name whose last value is kept
+				{
+					Labels: []prompb.Label{
+						{Name: "zone", Value: "eu"},
+						{Name: "host", Value: "a"},
+						{Name: "__name__", Value: "mem_used_percent"},
+						{Name: "host", Value: "b"},
+					},
+					Samples: []prompb.Sample{{Value: 1, Timestamp: 100}},
+				},
+				{Labels: sorted, Samples: []prompb.Sample{{Value: 2, Timestamp: 100}}},
+			},
+		},
+		{Timeseries: []prompb.TimeSeries{{Labels: sorted, Samples: []prompb.Sample{{Value: 3, Timestamp: 200}}}}},
+	}
+
+	dir := t.TempDir()
+	pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	require.NoError(t, pwal.persistToWAL(in))
+	require.NoError(t, pwal.stop())
+
+	assert.Equal(t, []*prompb.WriteRequest{
+		{
+			Timeseries: []prompb.TimeSeries{
+				{
+					Labels: []prompb.Label{
+						{Name: "__name__", Value: "mem_used_percent"},
+						{Name: "host", Value: "b"},
+						{Name: "zone", Value: "eu"},
+					},
+					Samples: []prompb.Sample{{Value: 1, Timestamp: 100}},
+				},
+				{Labels: sorted, Samples: []prompb.Sample{{Value: 2, Timestamp: 100}}},
+			},
+		},
+		{Timeseries: []prompb.TimeSeries{{Labels: sorted, Samples: []prompb.Sample{{Value: 3, Timestamp: 200}}}}},
+	}, readAllFromWAL(t, dir))
+
+	// The requests themselves are not modified
+	assert.Equal(t, "zone", in[0].Timeseries[0].Labels[0].Name)
+	assert.Len(t, in[0].Timeseries[0].Labels, 4)
+}
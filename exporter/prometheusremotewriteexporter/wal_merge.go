@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"sort"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// mergeSeries returns the requests with the series that have the same labels, in any order,
+// merged into their first occurrence, with their samples sorted by timestamp and their
+// exemplars and histograms concatenated. Requests that are left empty are removed. The
+// requests themselves are not modified.
+func mergeSeries(requests []*prompb.WriteRequest) []*prompb.WriteRequest {
+	first := map[string]*prompb.TimeSeries{}
+	seriesL := make([][]*prompb.TimeSeries, len(requests))
+	for i, req := range requests {
+		for _, ts := range req.Timeseries {
+			key := labelsKey(ts.Labels)
+			if series, ok := first[key]; ok {
+				series.Samples = append(series.Samples, ts.Samples...)
+				series.Exemplars = append(series.Exemplars, ts.Exemplars...)
+				series.Histograms = append(series.Histograms, ts.Histograms...)
+				continue
+			}
+			series := &prompb.TimeSeries{
+				Labels:     ts.Labels,
+				Samples:    append([]prompb.Sample(nil), ts.Samples...),
+				Exemplars:  append([]prompb.Exemplar(nil), ts.Exemplars...),
+				Histograms: append([]prompb.Histogram(nil), ts.Histograms...),
+			}
+			first[key] = series
+			seriesL[i] = append(seriesL[i], series)
+		}
+	}
+
+	merged := make([]*prompb.WriteRequest, 0, len(requests))
+	for i, req := range requests {
+		if len(seriesL[i]) == 0 && len(req.Metadata) == 0 {
+			continue
+		}
+		out := &prompb.WriteRequest{Metadata: req.Metadata}
+		for _, series := range seriesL[i] {
+			sort.SliceStable(series.Samples, func(i, j int) bool {
+				return series.Samples[i].Timestamp < series.Samples[j].Timestamp
+			})
+			out.Timeseries = append(out.Timeseries, *series)
+		}
+		merged = append(merged, out)
+	}
+	return merged
+}
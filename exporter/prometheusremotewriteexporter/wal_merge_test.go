@@ -0,0 +1,47 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAL_MergeSeries(t *testing.T) {
+	hostA := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "host", Value: "a"}}
+	// The same series, with its labels in another order
+	hostAReordered := []prompb.Label{{Name: "host", Value: "a"}, {Name: "__name__", Value: "mem_used_percent"}}
+
+	in := []*prompb.WriteRequest{
+		{Timeseries: []prompb.TimeSeries{{Labels: hostA, Samples: []prompb.Sample{{Value: 2, Timestamp: 200}}}}},
+		{Timeseries: []prompb.TimeSeries{{Labels: hostAReordered, Samples: []prompb.Sample{{Value: 3, Timestamp: 300}}}}},
+		{Timeseries: []prompb.TimeSeries{{Labels: hostA, Samples: []prompb.Sample{{Value: 1, Timestamp: 100}}}}},
+	}
+
+	dir := t.TempDir()
+	pwal, err := newWAL(&WALConfig{Directory: dir, MergeSeries: true}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	require.NoError(t, pwal.persistToWAL(in))
+	require.NoError(t, pwal.stop())
+
+	// The series are merged into the first one, with their samples sorted by timestamp
+	assert.Equal(t, []*prompb.WriteRequest{
+		{
+			Timeseries: []prompb.TimeSeries{
+				{Labels: hostA, Samples: []prompb.Sample{
+					{Value: 1, Timestamp: 100},
+					{Value: 2, Timestamp: 200},
+					{Value: 3, Timestamp: 300},
+				}},
+			},
+		},
+	}, readAllFromWAL(t, dir))
+
+	// The requests themselves are not modified
+	assert.Len(t, in[0].Timeseries[0].Samples, 1)
+}
@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"go.uber.org/zap"
+)
+
+// oldestUnsent caches the timestamp of the oldest sample of the first entry of the WAL that
+// was not exported yet, so that the entry is only decoded again once it was exported.
+type oldestUnsent struct {
+	mu sync.Mutex
+	// index is the first entry that was not exported when timestamp was found, zero if none was
+	index     uint64
+	timestamp time.Time
+}
+
+// oldestUnsentTimestamp returns the timestamp of the oldest sample or histogram of the first
+// entry that was not exported yet, or of the first entry after it with one, and false if no
+// entry that was not exported has a sample.
+func (prwe *prweWAL) oldestUnsentTimestamp() (time.Time, bool) {
+	first := prwe.sWALIndex.Load() + 1
+	if first > prwe.wWALIndex.Load() {
+		return time.Time{}, false
+	}
+
+	prwe.oldest.mu.Lock()
+	defer prwe.oldest.mu.Unlock()
+	if prwe.oldest.index == first {
+		return prwe.oldest.timestamp, true
+	}
+	timestamp, ok, err := prwe.oldestTimestampFrom(first)
+	if err != nil {
+		prwe.log.Debug("failed to read the oldest unsent WAL entry", zap.Error(err))
+		return time.Time{}, false
+	}
+	if ok {
+		// An entry without samples is read again, the entries written after it may have some
+		prwe.oldest.index, prwe.oldest.timestamp = first, timestamp
+	}
+	return timestamp, ok
+}
+
+// oldestTimestampFrom returns the oldest timestamp of the first entry from index on that has
+// samples or histograms. Entries removed from the WAL are skipped.
+func (prwe *prweWAL) oldestTimestampFrom(index uint64) (time.Time, bool, error) {
+	prwe.mu.Lock()
+	defer prwe.mu.Unlock()
+
+	if prwe.wal == nil {
+		return time.Time{}, false, nil
+	}
+	firstIndex, err := prwe.wal.FirstIndex()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	lastIndex, err := prwe.wal.LastIndex()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for i := max(index, firstIndex); i <= lastIndex; i++ {
+		entry, err := prwe.wal.Read(i)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		req, err := decodeWALEntry(entry)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("decode WAL entry %d: %w", i, err)
+		}
+		if timestamp, ok := oldestTimestamp(req); ok {
+			return time.UnixMilli(timestamp), true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// oldestTimestamp returns the lowest timestamp of the samples and histograms of req.
+func oldestTimestamp(req *prompb.WriteRequest) (int64, bool) {
+	var oldest int64
+	found := false
+	for _, ts := range req.Timeseries {
+		for _, s := range ts.Samples {
+			if !found || s.Timestamp < oldest {
+				oldest, found = s.Timestamp, true
+			}
+		}
+		for _, h := range ts.Histograms {
+			if !found || h.Timestamp < oldest {
+				oldest, found = h.Timestamp, true
+			}
+		}
+	}
+	return oldest, found
+}
+
+// oldestUnsentAge returns how long ago the oldest sample that was not exported yet was taken,
+// or zero if every sample was exported.
+func (prwe *prweWAL) oldestUnsentAge() time.Duration {
+	timestamp, ok := prwe.oldestUnsentTimestamp()
+	if !ok {
+		return 0
+	}
+	if age := prwe.clock.Now().Sub(timestamp); age > 0 {
+		return age
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+func TestWAL_OldestUnsentTimestamp(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	labels := []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}}
+	metadata := []prompb.MetricMetadata{{MetricFamilyName: "mem_used_percent", Type: prompb.MetricMetadata_GAUGE}}
+	in := []*prompb.WriteRequest{
+		{Timeseries: []prompb.TimeSeries{{Labels: labels, Samples: []prompb.Sample{{Value: 1, Timestamp: 3000}, {Value: 2, Timestamp: 1000}}}}},
+		// An entry without samples is skipped for the entries after it
+		{Metadata: metadata},
+		{Timeseries: []prompb.TimeSeries{{Labels: labels, Histograms: []prompb.Histogram{{Timestamp: 5000}}}}},
+	}
+
+	pwal, err := newWAL(&WALConfig{Directory: t.TempDir()}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	pwal.clock = newFakeClockAt(time.UnixMilli(61000))
+	require.NoError(t, pwal.persistToWAL(in))
+
+	oldestAge := func() float64 {
+		pwal.recordBacklog(context.Background())
+		rows, err := view.RetrieveData(mWALOldestUnsent.Name())
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		return rows[0].Data.(*view.LastValueData).Value
+	}
+
+	timestamp, ok := pwal.oldestUnsentTimestamp()
+	require.True(t, ok)
+	assert.Equal(t, time.UnixMilli(1000), timestamp)
+	assert.Equal(t, float64(60), oldestAge())
+
+	// The timestamp is cached until the entry is exported
+	assert.Equal(t, uint64(1), pwal.oldest.index)
+	pwal.oldest.timestamp = time.UnixMilli(2000)
+	assert.Equal(t, float64(59), oldestAge())
+
+	pwal.sWALIndex.Store(1)
+	timestamp, ok = pwal.oldestUnsentTimestamp()
+	require.True(t, ok)
+	assert.Equal(t, time.UnixMilli(5000), timestamp)
+	assert.Equal(t, float64(56), oldestAge())
+
+	// Nothing is left to export
+	pwal.sWALIndex.Store(3)
+	_, ok = pwal.oldestUnsentTimestamp()
+	assert.False(t, ok)
+	assert.Equal(t, float64(0), oldestAge())
+}
@@ -0,0 +1,101 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tidwall/wal"
+	"go.uber.org/zap"
+)
+
+// openWAL opens the WAL and checks that its last entry can be decoded. With RepairOnCorruption,
+// a WAL whose tail segment was torn by an unclean shutdown is repaired and opened again.
+// It must be called with prwe.mu held.
+func (prwe *prweWAL) openWAL() (*wal.Log, string, error) {
+	log, walPath, err := prwe.walConfig.createWAL()
+	if err == nil {
+		if err = verifyLastWALEntry(log); err != nil {
+			_ = log.Close()
+		}
+	}
+	if err == nil || !prwe.walConfig.RepairOnCorruption || !errors.Is(err, wal.ErrCorrupt) {
+		return log, walPath, err
+	}
+
+	prwe.log.Warn("write-ahead log is corrupt, repairing it", zap.Error(err))
+	walPath = prwe.walConfig.path()
+	discarded, err := repairWALTail(walPath)
+	if err != nil {
+		return nil, "", err
+	}
+	prwe.log.Warn("repaired write-ahead log", zap.Int("discarded", discarded))
+	return prwe.walConfig.createWAL()
+}
+
+// verifyLastWALEntry returns an error wrapping wal.ErrCorrupt if the last entry of the log
+// can't be decoded. An entry written by a newer version is not corrupt, and its error is
+// returned as is, so that it isn't repaired.
+func verifyLastWALEntry(log *wal.Log) error {
+	last, err := log.LastIndex()
+	if err != nil || last == 0 {
+		return err
+	}
+	entry, err := log.Read(last)
+	if err != nil {
+		return fmt.Errorf("prometheusremotewriteexporter: failed to read the last WAL entry: %w", err)
+	}
+	if _, err = decodeWALEntry(entry); errors.Is(err, ErrUnsupportedWALVersion) {
+		return fmt.Errorf("prometheusremotewriteexporter: failed to decode the last WAL entry %d: %w", last, err)
+	} else if err != nil {
+		return fmt.Errorf("prometheusremotewriteexporter: failed to decode the last WAL entry %d: %v: %w", last, err, wal.ErrCorrupt)
+	}
+	return nil
+}
+
+// repairWALTail truncates the tail segment of the WAL in dir right after its last entry that
+// can be decoded, and returns how many entries were discarded, counting a torn one.
+// Only the tail segment is scanned: it is the only one written to when the collector stops.
+// Nothing is truncated if an entry was written by a newer version, which can't be decoded.
+func repairWALTail(dir string) (int, error) {
+	segments, err := listWALSegments(dir)
+	if err != nil || len(segments) == 0 {
+		return 0, err
+	}
+	tail := segments[len(segments)-1].path
+	data, err := os.ReadFile(tail)
+	if err != nil {
+		return 0, fmt.Errorf("prometheusremotewriteexporter: failed to read WAL segment: %w", err)
+	}
+
+	// Entries are written by github.com/tidwall/wal as their uvarint size followed by their data.
+	valid, discarded := 0, 0
+	for pos, end := 0, 0; pos < len(data); pos = end {
+		size, n := binary.Uvarint(data[pos:])
+		if n <= 0 || uint64(len(data)-pos-n) < size {
+			discarded++
+			break
+		}
+		end = pos + n + int(size)
+		if discarded == 0 {
+			_, err := decodeWALEntry(data[pos+n : end])
+			if err == nil {
+				valid = end
+				continue
+			}
+			if errors.Is(err, ErrUnsupportedWALVersion) {
+				return 0, fmt.Errorf("prometheusremotewriteexporter: can't repair WAL segment: %w", err)
+			}
+		}
+		discarded++
+	}
+
+	if err = os.Truncate(tail, int64(valid)); err != nil {
+		return 0, fmt.Errorf("prometheusremotewriteexporter: failed to truncate WAL segment: %w", err)
+	}
+	return discarded, nil
+}
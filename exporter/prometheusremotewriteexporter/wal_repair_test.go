@@ -0,0 +1,72 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/wal"
+)
+
+func TestWAL_RepairOnCorruption(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		garbage []byte
+	}{
+		// The size of an entry was written, but not its data
+		{name: "torn_entry", garbage: binary.AppendUvarint(nil, 64)},
+		// The data of an entry was written, but it was not fully flushed
+		{name: "undecodable_entry", garbage: append(binary.AppendUvarint(nil, 3), "abc"...)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+			require.NoError(t, err)
+			require.NoError(t, pwal.retrieveWALIndices())
+			in := []*prompb.WriteRequest{
+				series("mem_used_percent", 1, 10),
+				series("mem_used_percent", 2, 20),
+				series("mem_used_percent", 3, 30),
+			}
+			require.NoError(t, pwal.persistToWAL(in))
+			require.NoError(t, pwal.stop())
+
+			segments, err := listWALSegments(pwal.walPath)
+			require.NoError(t, err)
+			f, err := os.OpenFile(segments[len(segments)-1].path, os.O_APPEND|os.O_WRONLY, 0600)
+			require.NoError(t, err)
+			_, err = f.Write(tt.garbage)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			// Without repair, the WAL refuses to start
+			pwal, err = newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+			require.NoError(t, err)
+			require.ErrorIs(t, pwal.retrieveWALIndices(), wal.ErrCorrupt)
+
+			pwal, err = newWAL(&WALConfig{Directory: dir, RepairOnCorruption: true}, doNothingExportSink)
+			require.NoError(t, err)
+			require.NoError(t, pwal.retrieveWALIndices())
+			t.Cleanup(func() {
+				assert.NoError(t, pwal.stop())
+			})
+
+			// The valid prefix is read back, and new entries follow it
+			require.Equal(t, uint64(len(in)), pwal.wWALIndex.Load())
+			next := series("mem_used_percent", 4, 40)
+			require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{next}))
+			for i, want := range append(in, next) {
+				req, err := pwal.readPrompbFromWAL(context.Background(), uint64(i+1))
+				require.NoError(t, err)
+				assert.Equal(t, want, req)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/prometheus/prometheus/prompb"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWALRetryInitialInterval = 50 * time.Millisecond
+	defaultWALRetryMaxInterval     = 200 * time.Millisecond
+	defaultWALRetryMaxElapsedTime  = 1 * time.Minute
+)
+
+// WALRetrySettings configures the retries of an export of WAL entries that failed with
+// a transient error. Zero values are replaced by their defaults.
+type WALRetrySettings struct {
+	// InitialInterval is the time to wait after the first failure. Defaults to 50ms.
+	InitialInterval time.Duration `mapstructure:"initial_interval"`
+	// MaxInterval is the upper bound of the time between two retries. Defaults to 200ms.
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+	// MaxElapsedTime is the time after which the export is given up on, and is retried
+	// when the WAL is read again. Defaults to 1m.
+	MaxElapsedTime time.Duration `mapstructure:"max_elapsed_time"`
+	// RandomizationFactor is the jitter applied to every interval, between 0 and 1.
+	// Defaults to 0.5.
+	RandomizationFactor float64 `mapstructure:"randomization_factor"`
+}
+
+func (rs *WALRetrySettings) validate() error {
+	if rs.InitialInterval < 0 || rs.MaxInterval < 0 || rs.MaxElapsedTime < 0 {
+		return fmt.Errorf("WAL retry intervals can't be negative")
+	}
+	if rs.RandomizationFactor < 0 || rs.RandomizationFactor > 1 {
+		return fmt.Errorf("WAL retry randomization factor must be between 0 and 1")
+	}
+	return nil
+}
+
+// newBackOff returns a backoff whose max elapsed time is measured by c.
+func (rs *WALRetrySettings) newBackOff(c clock) *backoff.ExponentialBackOff {
+	b := backoff.NewExponentialBackOff()
+	b.Clock = c
+	b.InitialInterval = defaultWALRetryInitialInterval
+	if rs.InitialInterval > 0 {
+		b.InitialInterval = rs.InitialInterval
+	}
+	b.MaxInterval = defaultWALRetryMaxInterval
+	if rs.MaxInterval > 0 {
+		b.MaxInterval = rs.MaxInterval
+	}
+	b.MaxElapsedTime = defaultWALRetryMaxElapsedTime
+	if rs.MaxElapsedTime > 0 {
+		b.MaxElapsedTime = rs.MaxElapsedTime
+	}
+	if rs.RandomizationFactor > 0 {
+		b.RandomizationFactor = rs.RandomizationFactor
+	}
+	b.Reset()
+	return b
+}
+
+// exportWithRetry exports the requests read from the WAL starting at index first, retrying
+// transient errors with an exponential backoff. With a dead-letter directory, requests that
+// fail with a permanent error are moved there, so that the entries after them can still be
+// exported, and are considered as exported.
+func (prwe *prweWAL) exportWithRetry(ctx context.Context, first uint64, reqL []*prompb.WriteRequest) error {
+	indices := make([]uint64, len(reqL))
+	for i := range indices {
+		indices[i] = first + uint64(i)
+	}
+	return prwe.exportIndexedWithRetry(ctx, indices, reqL)
+}
+
+// exportIndexedWithRetry is exportWithRetry for requests read from the WAL at the indices,
+// which aren't consecutive when they were read by a shard.
+func (prwe *prweWAL) exportIndexedWithRetry(ctx context.Context, indices []uint64, reqL []*prompb.WriteRequest) error {
+	if len(reqL) == 0 {
+		return nil
+	}
+	// The requests may have been written before the future tolerance was set, or lowered.
+	reqL = prwe.applyFutureSamples(ctx, reqL)
+
+	b := prwe.walConfig.Retry.newBackOff(prwe.clock)
+	for {
+		if err := prwe.waitForCircuit(ctx); err != nil {
+			return err
+		}
+		err := prwe.exportSplit(ctx, reqL)
+		prwe.breaker.record(err, prwe.clock.Now())
+		prwe.reportSinkResult(ctx, err)
+		if err == nil {
+			return nil
+		}
+		if consumererror.IsPermanent(err) {
+			if prwe.walConfig.DeadLetterDir == "" {
+				return err
+			}
+			if len(reqL) == 1 {
+				return prwe.moveToDeadLetter(ctx, indices[0], reqL[0], err)
+			}
+			// Export the requests one by one, so that only the rejected ones are moved. The
+			// requests that the sink accepted along with the rejected ones are sent again.
+			for i, req := range reqL {
+				if err = prwe.exportIndexedWithRetry(ctx, indices[i:i+1], []*prompb.WriteRequest{req}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		wait := b.NextBackOff()
+		if wait == backoff.Stop {
+			return err
+		}
+		prwe.log.Warn("failed to export WAL entries, retrying", zap.Error(err), zap.Duration("interval", wait))
+		timer := prwe.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-prwe.stopChan:
+			timer.Stop()
+			return err
+		case <-timer.Chan():
+		}
+	}
+}
+
+// waitBeforeReplay waits for the next interval of b before the WAL is read again after an
+// error, so that entries that keep failing, such as requests rejected with a permanent error
+// without a dead-letter directory, are not exported again in a tight loop. It returns false
+// if the WAL was stopped meanwhile.
+func (prwe *prweWAL) waitBeforeReplay(ctx context.Context, b *backoff.ExponentialBackOff) bool {
+	wait := b.NextBackOff()
+	if wait == backoff.Stop {
+		wait = b.MaxInterval
+	}
+	timer := prwe.clock.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-prwe.stopChan:
+		return false
+	case <-timer.Chan():
+		return true
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+func TestWAL_RetryTransientErrors(t *testing.T) {
+	var calls int
+	sink := func(_ context.Context, _ []*prompb.WriteRequest) error {
+		if calls++; calls <= 2 {
+			return errors.New("remote write returned HTTP status 503 Service Unavailable")
+		}
+		return nil
+	}
+
+	pwal, err := newWAL(&WALConfig{
+		Directory: t.TempDir(),
+		Retry:     WALRetrySettings{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond},
+	}, sink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	in := []*prompb.WriteRequest{series("mem_used_percent", 1, 10), series("mem_used_percent", 2, 20)}
+	require.NoError(t, pwal.persistToWAL(in))
+	ctx := context.Background()
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), len(in))
+	require.NoError(t, err)
+
+	require.NoError(t, pwal.exportThenFrontTruncateWAL(ctx, reqL))
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, uint64(len(in)), pwal.sWALIndex.Load())
+}
+
+func TestWAL_RetryGivesUp(t *testing.T) {
+	sinkErr := errors.New("remote write returned HTTP status 503 Service Unavailable")
+	sink := func(_ context.Context, _ []*prompb.WriteRequest) error {
+		return sinkErr
+	}
+
+	pwal, err := newWAL(&WALConfig{
+		Directory: t.TempDir(),
+		Retry: WALRetrySettings{
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  10 * time.Millisecond,
+		},
+	}, sink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 1, 10)}))
+	ctx := context.Background()
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 1)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, pwal.exportThenFrontTruncateWAL(ctx, reqL), sinkErr)
+	assert.Zero(t, pwal.sWALIndex.Load())
+}
+
+// TestWAL_RetryClock checks that the backoff between retries waits on the clock of the WAL.
+func TestWAL_RetryClock(t *testing.T) {
+	var calls atomic.Int64
+	sink := func(_ context.Context, _ []*prompb.WriteRequest) error {
+		if calls.Add(1) == 1 {
+			return errors.New("remote write returned HTTP status 503 Service Unavailable")
+		}
+		return nil
+	}
+
+	pwal, err := newWAL(&WALConfig{
+		Directory: t.TempDir(),
+		Retry:     WALRetrySettings{InitialInterval: time.Hour, MaxInterval: time.Hour, MaxElapsedTime: 2 * time.Hour},
+	}, sink)
+	require.NoError(t, err)
+	clock := newFakeClock()
+	pwal.clock = clock
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 1, 10)}))
+	ctx := context.Background()
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 1)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- pwal.exportThenFrontTruncateWAL(ctx, reqL)
+	}()
+	// Wait for the timer of the backoff
+	require.Eventually(t, func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.waiters) > 0
+	}, 5*time.Second, time.Millisecond)
+	select {
+	case err = <-done:
+		t.Fatalf("the export returned before the backoff elapsed: %v", err)
+	default:
+	}
+	assert.Equal(t, int64(1), calls.Load())
+
+	// The randomized backoff is at most 1.5 times the interval.
+	for i := 0; i < 4; i++ {
+		clock.Advance(30 * time.Minute)
+	}
+	require.NoError(t, <-done)
+	assert.Equal(t, int64(2), calls.Load())
+}
+
+// failingTransport fails its first requests like a refused connection, and sends the others to next.
+type failingTransport struct {
+	failures atomic.Int32
+	next     http.RoundTripper
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if f.failures.Add(-1) >= 0 {
+		return nil, errors.New("connect: connection refused")
+	}
+	return f.next.RoundTrip(req)
+}
+
+// TestWAL_RetryTransportErrors checks that requests that can't reach the endpoint, as during an
+// outage, are retried instead of being dead-lettered.
+func TestWAL_RetryTransportErrors(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.HTTPClientSettings.Endpoint = server.URL
+	prwe, err := newPRWExporter(cfg, exportertest.NewNopCreateSettings())
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, prwe.Start(ctx, componenttest.NewNopHost()))
+	t.Cleanup(func() {
+		assert.NoError(t, prwe.Shutdown(ctx))
+	})
+	transport := &failingTransport{next: prwe.client.Transport}
+	prwe.client.Transport = transport
+
+	transport.failures.Store(1)
+	err = prwe.export(ctx, []*prompb.WriteRequest{series("mem_used_percent", 1, 10)})
+	var urlErr *url.Error
+	require.True(t, errors.As(err, &urlErr), err)
+	assert.False(t, consumererror.IsPermanent(err))
+
+	deadLetterDir := t.TempDir()
+	pwal, err := newWAL(&WALConfig{
+		Directory:     t.TempDir(),
+		DeadLetterDir: deadLetterDir,
+		Retry:         WALRetrySettings{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond},
+	}, prwe.export)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	transport.failures.Store(2)
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 1, 10)}))
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 1)
+	require.NoError(t, err)
+	require.NoError(t, pwal.exportThenFrontTruncateWAL(ctx, reqL))
+	assert.Equal(t, int32(1), received.Load())
+	assert.Equal(t, uint64(1), pwal.sWALIndex.Load())
+	deadLettered, err := os.ReadDir(deadLetterDir)
+	require.NoError(t, err)
+	assert.Empty(t, deadLettered)
+}
+
+func TestWAL_PermanentErrorWithoutDeadLetter(t *testing.T) {
+	sinkErr := consumererror.NewPermanent(errors.New("remote write returned HTTP status 400 Bad Request"))
+	var calls int
+	sink := func(_ context.Context, _ []*prompb.WriteRequest) error {
+		calls++
+		return sinkErr
+	}
+
+	pwal, err := newWAL(&WALConfig{Directory: t.TempDir()}, sink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 1, 10)}))
+	ctx := context.Background()
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 1)
+	require.NoError(t, err)
+
+	// Permanent errors are not retried
+	require.ErrorIs(t, pwal.exportThenFrontTruncateWAL(ctx, reqL), sinkErr)
+	assert.Equal(t, 1, calls)
+	assert.Zero(t, pwal.sWALIndex.Load())
+}
+
+// TestWAL_ReplayBackoff checks that entries that keep failing without a dead-letter directory
+// are only read and exported again after a backoff.
+func TestWAL_ReplayBackoff(t *testing.T) {
+	var calls atomic.Int64
+	sink := func(_ context.Context, _ []*prompb.WriteRequest) error {
+		calls.Add(1)
+		return consumererror.NewPermanent(errors.New("remote write returned HTTP status 400 Bad Request"))
+	}
+
+	pwal, err := newWAL(&WALConfig{
+		Directory:  t.TempDir(),
+		BufferSize: 1,
+		Retry:      WALRetrySettings{InitialInterval: time.Second, MaxInterval: time.Second},
+	}, sink)
+	require.NoError(t, err)
+	clock := newFakeClock()
+	pwal.clock = clock
+	require.NoError(t, pwal.run(contextWithLogger(context.Background(), zap.NewNop())))
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 1, 10)}))
+	// The entry is exported once more by the run that failed, as it returns, then not again
+	require.Eventually(t, func() bool { return calls.Load() > 0 }, 5*time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	failed := calls.Load()
+	assert.LessOrEqual(t, failed, int64(2))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, failed, calls.Load())
+
+	// The randomized backoff is at most 1.5 times the interval.
+	clock.Advance(2 * time.Second)
+	require.Eventually(t, func() bool { return calls.Load() > failed }, 5*time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 2*failed, calls.Load())
+}
@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"container/list"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const defaultWALMaxTrackedSeries = 100000
+
+// trackedSeries is a series written to the WAL, and the timestamp of its last sample.
+type trackedSeries struct {
+	key       string
+	labels    []prompb.Label
+	timestamp int64
+}
+
+// seriesLRU holds at most maxSeries series, forgetting the least recently written ones first.
+// It isn't safe for concurrent use.
+type seriesLRU struct {
+	maxSeries int
+	series    map[string]*list.Element
+	// recent holds the *trackedSeries values, the most recently written series first.
+	recent *list.List
+}
+
+func newSeriesLRU(maxSeries int) *seriesLRU {
+	if maxSeries <= 0 {
+		maxSeries = defaultWALMaxTrackedSeries
+	}
+	return &seriesLRU{
+		maxSeries: maxSeries,
+		series:    make(map[string]*list.Element),
+		recent:    list.New(),
+	}
+}
+
+// get returns the series of key, and marks it as the most recently written series.
+func (l *seriesLRU) get(key string) (*trackedSeries, bool) {
+	elem, ok := l.series[key]
+	if !ok {
+		return nil, false
+	}
+	l.recent.MoveToFront(elem)
+	return elem.Value.(*trackedSeries), true
+}
+
+// add adds the series of key, that must not be held yet, and forgets the least recently
+// written series beyond maxSeries.
+func (l *seriesLRU) add(key string, labels []prompb.Label, timestamp int64) *trackedSeries {
+	s := &trackedSeries{key: key, labels: labels, timestamp: timestamp}
+	l.series[key] = l.recent.PushFront(s)
+	for l.recent.Len() > l.maxSeries {
+		l.remove(l.recent.Back().Value.(*trackedSeries).key)
+	}
+	return s
+}
+
+func (l *seriesLRU) remove(key string) {
+	if elem, ok := l.series[key]; ok {
+		l.recent.Remove(elem)
+		delete(l.series, key)
+	}
+}
+
+func (l *seriesLRU) len() int {
+	return l.recent.Len()
+}
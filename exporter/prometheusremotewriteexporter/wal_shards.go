@@ -0,0 +1,379 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/tidwall/wal"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// walShardsCheckpointFile is the file in the WAL directory that holds the watermark of every
+// shard. Like the checkpoint, its name is shorter than segment file names.
+const walShardsCheckpointFile = "shard-checkpoint"
+
+// Entries of a sharded WAL start with a header byte followed by their shard as a uvarint, and
+// then the entry itself, which may be compressed. Like the compression headers, the header
+// encodes field number 0, which a marshalled WriteRequest never starts with.
+const walHeaderShard byte = 0x03
+
+// walShards tracks the export of the entries of a WAL whose entries are partitioned in shards,
+// by a hash of the metric name of their series. The entries of every shard are read and
+// exported in order by their own routine, so that a shard whose series are slow to export
+// doesn't hold back the others.
+//
+// The watermark of a shard is an index such that every entry of the shard up to it was
+// exported. Entries of other shards are skipped by a shard, so its watermark moves past them.
+// An entry may only be removed from the WAL once every shard exported it, or skipped it, so:
+//
+//	the WAL is only truncated up to the lowest watermark, which is its sent index.
+//
+// The watermarks are persisted along with the checkpoint of the sent index, which is written
+// after them, so that a shard resumes after its own watermark after a restart and never after
+// an entry of its own that was not exported.
+type walShards struct {
+	count int
+	// path is the file that the watermarks are checkpointed to.
+	path string
+
+	mu         sync.Mutex
+	watermarks []uint64
+	// written is closed and replaced whenever entries are written, to wake up every shard
+	// that is waiting for entries, unlike rNotify which wakes up a single reader.
+	written chan struct{}
+}
+
+// newWALShards returns nil if the entries of the WAL aren't partitioned.
+func newWALShards(wc *WALConfig) *walShards {
+	if wc.Shards <= 1 {
+		return nil
+	}
+	return &walShards{
+		count:      wc.Shards,
+		path:       filepath.Join(wc.path(), walShardsCheckpointFile),
+		watermarks: make([]uint64, wc.Shards),
+		written:    make(chan struct{}),
+	}
+}
+
+// shardOf returns the shard of the series or metadata with the metric name.
+func (s *walShards) shardOf(name string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return int(h.Sum32() % uint32(s.count))
+}
+
+// split returns the requests split by the shard of their series and metadata, and the shard
+// of every request. The requests keep their order, and the requests themselves are not
+// modified.
+func (s *walShards) split(requests []*prompb.WriteRequest) ([]*prompb.WriteRequest, []int) {
+	var out []*prompb.WriteRequest
+	var shards []int
+	for _, req := range requests {
+		byShard := make([]*prompb.WriteRequest, s.count)
+		get := func(shard int) *prompb.WriteRequest {
+			if byShard[shard] == nil {
+				byShard[shard] = &prompb.WriteRequest{}
+			}
+			return byShard[shard]
+		}
+		for _, ts := range req.Timeseries {
+			r := get(s.shardOf(labelValue(ts.Labels, model.MetricNameLabel)))
+			r.Timeseries = append(r.Timeseries, ts)
+		}
+		for _, md := range req.Metadata {
+			r := get(s.shardOf(md.MetricFamilyName))
+			r.Metadata = append(r.Metadata, md)
+		}
+		for shard, r := range byShard {
+			if r != nil {
+				out = append(out, r)
+				shards = append(shards, shard)
+			}
+		}
+	}
+	return out, shards
+}
+
+// tagWALEntry prefixes the entry with its shard.
+func tagWALEntry(shard int, entry []byte) []byte {
+	tagged := make([]byte, 0, 1+binary.MaxVarintLen64+len(entry))
+	tagged = append(tagged, walHeaderShard)
+	tagged = binary.AppendUvarint(tagged, uint64(shard))
+	return append(tagged, entry...)
+}
+
+// untagWALEntry returns the shard of the entry and the entry without its shard. Entries
+// without a shard, such as those written before the WAL was sharded, are in shard 0.
+func untagWALEntry(entry []byte) (uint64, []byte, error) {
+	if len(entry) == 0 || entry[0] != walHeaderShard {
+		return 0, entry, nil
+	}
+	shard, n := binary.Uvarint(entry[1:])
+	if n <= 0 {
+		return 0, nil, errors.New("invalid shard header")
+	}
+	return shard, entry[1+n:], nil
+}
+
+// loadWatermarks sets the watermarks of the shards from their checkpoint. A watermark is never
+// behind sIndex, the sent index of the WAL, since every entry up to it was exported, nor past
+// wIndex, the last entry. The watermarks are all sIndex if the number of shards changed, since
+// the entries of a shard may have moved to another.
+func (s *walShards) loadWatermarks(sIndex, wIndex uint64) error {
+	data, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("prometheusremotewriteexporter: failed to read the WAL shard checkpoint: %w", err)
+	}
+	saved := strings.Fields(string(data))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for shard := range s.watermarks {
+		s.watermarks[shard] = sIndex
+		if len(saved) != s.count {
+			continue
+		}
+		watermark, err := strconv.ParseUint(saved[shard], 10, 64)
+		if err != nil {
+			return fmt.Errorf("prometheusremotewriteexporter: invalid WAL shard checkpoint %q: %w", data, err)
+		}
+		if watermark > sIndex && watermark <= wIndex {
+			s.watermarks[shard] = watermark
+		}
+	}
+	return nil
+}
+
+// watermark returns the watermark of shard.
+func (s *walShards) watermark(shard int) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.watermarks[shard]
+}
+
+// wait returns a channel that is closed once entries are written.
+func (s *walShards) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.written
+}
+
+// notify wakes up the shards that wait for entries.
+func (s *walShards) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.written)
+	s.written = make(chan struct{})
+}
+
+// advance moves the watermark of shard to index, and returns the lowest watermark along with
+// the watermarks, one per line, to checkpoint.
+func (s *walShards) advance(shard int, index uint64) (uint64, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watermarks[shard] = max(s.watermarks[shard], index)
+	lowest := s.watermarks[0]
+	lines := make([]string, len(s.watermarks))
+	for i, watermark := range s.watermarks {
+		if watermark < lowest {
+			lowest = watermark
+		}
+		lines[i] = strconv.FormatUint(watermark, 10)
+	}
+	return lowest, strings.Join(lines, "\n")
+}
+
+// readShardBatch reads the entries of shard from index on into buf, skipping the entries of
+// other shards, and returns the indices of the entries of shard and the last index that was
+// read. It waits for entries if there is none to read, and otherwise returns once buf is
+// full, scanLimit entries were read or every entry was read.
+func (prwe *prweWAL) readShardBatch(ctx context.Context, shard int, index uint64, scanLimit int, buf *walBuffer) ([]uint64, uint64, error) {
+	try := func() ([]uint64, uint64, error) {
+		prwe.mu.Lock()
+		defer prwe.mu.Unlock()
+
+		if prwe.wal == nil {
+			return nil, 0, errNilWAL
+		}
+		if first, err := prwe.wal.FirstIndex(); err == nil && index < first {
+			return nil, 0, errWALEntriesRemoved
+		}
+		var indices []uint64
+		last := index - 1
+		for i := index; i < index+uint64(scanLimit) && !buf.full(); i++ {
+			entry, err := prwe.wal.Read(i)
+			if errors.Is(err, wal.ErrNotFound) && i > index {
+				break
+			} else if err != nil {
+				return nil, 0, err
+			}
+			last = i
+			entryShard, untagged, err := untagWALEntry(entry)
+			if err != nil {
+				return nil, 0, fmt.Errorf("decode WAL entry %d: %w", i, err)
+			}
+			// Entries written with more shards belong to the shard they wrap around to.
+			if int(entryShard%uint64(prwe.shards.count)) != shard {
+				continue
+			}
+			req, err := decodeWALEntry(untagged)
+			if err != nil {
+				return nil, 0, fmt.Errorf("decode WAL entry %d: %w", i, err)
+			}
+			buf.add(untagged, req)
+			indices = append(indices, i)
+		}
+		return indices, last, nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-prwe.stopChan:
+			return nil, 0, errWALStopped
+		default:
+		}
+
+		// The channel is taken before reading, so that a write after the read wakes it up.
+		written := prwe.shards.wait()
+		indices, last, err := try()
+		if !errors.Is(err, wal.ErrNotFound) {
+			return indices, last, err
+		}
+		select {
+		case <-written:
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-prwe.stopChan:
+			return nil, 0, errWALStopped
+		}
+	}
+}
+
+// runShard exports the entries of shard in order, from its watermark on, until ctx is done,
+// the WAL is stopped or the export fails.
+func (prwe *prweWAL) runShard(ctx context.Context, shard int) error {
+	count := prwe.walConfig.batchCount()
+	scanLimit := prwe.walConfig.readBufferSize() * prwe.shards.count
+	for {
+		buf := &walBuffer{compress: prwe.walConfig.CompressBuffer, maxCount: count, maxBytes: prwe.walConfig.BufferSizeBytes}
+		next := prwe.shards.watermark(shard) + 1
+		indices, last, err := prwe.readShardBatch(ctx, shard, next, scanLimit, buf)
+		if errors.Is(err, errWALEntriesRemoved) {
+			// The watermark was moved past them
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(indices) > 0 {
+			reqL, err := buf.requests()
+			if err != nil {
+				return err
+			}
+			if err = prwe.exportIndexedWithRetry(ctx, indices, reqL); err != nil {
+				return err
+			}
+			prwe.record(ctx, mWALReplayedRequests.M(int64(len(reqL))))
+		}
+		prwe.advanceShard(ctx, shard, last)
+	}
+}
+
+// advanceShard moves the watermark of shard to index, and the sent index of the WAL to the
+// lowest watermark, and checkpoints them.
+func (prwe *prweWAL) advanceShard(ctx context.Context, shard int, index uint64) {
+	// The watermarks are advanced and written under the lock, so that they are written in order,
+	// and before the sent index, so that it is never ahead of them.
+	prwe.mu.Lock()
+	lowest, watermarks := prwe.shards.advance(shard, index)
+	if prwe.wal != nil {
+		if err := writeFileAtomic(prwe.shards.path, watermarks); err != nil {
+			prwe.log.Warn("failed to checkpoint the exported WAL entries of the shards", zap.Error(err))
+		}
+	}
+	prwe.mu.Unlock()
+	if lowest > prwe.sWALIndex.Load() {
+		storeMax(prwe.sWALIndex, lowest)
+		prwe.writeCheckpoint()
+	}
+	prwe.recordBacklog(ctx)
+}
+
+// runShards exports the entries of every shard with its own routine, until ctx is done, the WAL
+// is stopped or a shard fails. The WAL is truncated up to the sent index, the lowest watermark
+// of the shards, every truncate frequency.
+func (prwe *prweWAL) runShards(ctx context.Context, signalStart func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		errMu sync.Mutex
+		errs  error
+	)
+	fail := func(err error) {
+		select {
+		case <-prwe.stopChan:
+			// Reads and truncation fail once the WAL is closed, which isn't an error.
+			cancel()
+			return
+		default:
+		}
+		errMu.Lock()
+		errs = multierr.Append(errs, err)
+		errMu.Unlock()
+		cancel()
+	}
+
+	for shard := 0; shard < prwe.shards.count; shard++ {
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			if err := prwe.runShard(ctx, shard); err != nil && ctx.Err() == nil {
+				fail(fmt.Errorf("shard %d: %w", shard, err))
+			}
+		}(shard)
+	}
+
+	signalStart()
+
+	ticker := prwe.clock.NewTicker(prwe.walConfig.truncateFrequency())
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-prwe.stopChan:
+			done = true
+		case <-ticker.Chan():
+			if err := prwe.syncAndTruncateFront(prwe.sWALIndex.Load() + 1); err != nil {
+				fail(err)
+			} else if err = prwe.truncateOverMaxBytes(); err != nil {
+				fail(err)
+			}
+		}
+	}
+	cancel()
+	wg.Wait()
+
+	errMu.Lock()
+	defer errMu.Unlock()
+	return errs
+}
@@ -0,0 +1,175 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// metricsOfShards returns a metric name in shard 0 and one in shard 1 of s.
+func metricsOfShards(t *testing.T, s *walShards) (string, string) {
+	names := make([]string, 2)
+	for i := 0; names[0] == "" || names[1] == ""; i++ {
+		require.Less(t, i, 1000)
+		name := fmt.Sprintf("metric_%d", i)
+		if shard := s.shardOf(name); shard < 2 && names[shard] == "" {
+			names[shard] = name
+		}
+	}
+	return names[0], names[1]
+}
+
+func TestWALShards_SplitAndTag(t *testing.T) {
+	s := newWALShards(&WALConfig{Shards: 2})
+	require.NotNil(t, s)
+	assert.Nil(t, newWALShards(&WALConfig{Shards: 1}))
+	a, b := metricsOfShards(t, s)
+
+	in := []*prompb.WriteRequest{{
+		Timeseries: []prompb.TimeSeries{
+			series(b, 0, 1).Timeseries[0],
+			series(a, 0, 2).Timeseries[0],
+			series(b, 0, 3).Timeseries[0],
+		},
+		Metadata: []prompb.MetricMetadata{{MetricFamilyName: a}},
+	}, series(b, 15, 4)}
+	out, shards := s.split(in)
+	assert.Equal(t, []int{0, 1, 1}, shards)
+	assert.Equal(t, []*prompb.WriteRequest{
+		{Timeseries: []prompb.TimeSeries{series(a, 0, 2).Timeseries[0]}, Metadata: []prompb.MetricMetadata{{MetricFamilyName: a}}},
+		{Timeseries: []prompb.TimeSeries{series(b, 0, 1).Timeseries[0], series(b, 0, 3).Timeseries[0]}},
+		series(b, 15, 4),
+	}, out)
+	// The requests that were split are left untouched.
+	assert.Len(t, in[0].Timeseries, 3)
+
+	shard, entry, err := untagWALEntry(tagWALEntry(300, []byte{walHeaderSnappy, 1}))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(300), shard)
+	assert.Equal(t, []byte{walHeaderSnappy, 1}, entry)
+
+	// Entries written without shards are in shard 0.
+	shard, entry, err = untagWALEntry([]byte{walHeaderSnappy, 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), shard)
+	assert.Equal(t, []byte{walHeaderSnappy, 1}, entry)
+
+	_, _, err = untagWALEntry([]byte{walHeaderShard})
+	assert.Error(t, err)
+}
+
+// TestWAL_ShardsTruncateLowestWatermark checks that while a shard exports its entries, the
+// entries of a lagging shard are neither truncated nor, after a restart, skipped.
+func TestWAL_ShardsTruncateLowestWatermark(t *testing.T) {
+	dir := t.TempDir()
+	walConfig := &WALConfig{Directory: dir, Shards: 2, TruncateFrequency: time.Minute}
+	a, b := metricsOfShards(t, newWALShards(walConfig))
+
+	// The requests of shard B block until the gate of their value is closed.
+	gates := map[float64]chan struct{}{1: make(chan struct{}), 2: make(chan struct{})}
+	t.Cleanup(func() { close(gates[2]) })
+	var mu sync.Mutex
+	var exported []string
+	record := func(ts prompb.TimeSeries) {
+		mu.Lock()
+		defer mu.Unlock()
+		exported = append(exported, fmt.Sprintf("%s/%v", labelValue(ts.Labels, "__name__"), ts.Samples[0].Value))
+	}
+	sink := func(ctx context.Context, reqL []*prompb.WriteRequest) error {
+		for _, req := range reqL {
+			ts := req.Timeseries[0]
+			if labelValue(ts.Labels, "__name__") == b {
+				select {
+				case <-gates[ts.Samples[0].Value]:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			record(ts)
+		}
+		return nil
+	}
+	exportedNames := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		names := exported
+		exported = nil
+		return names
+	}
+
+	pwal, err := newWAL(walConfig, sink)
+	require.NoError(t, err)
+	clock := newFakeClock()
+	pwal.clock = clock
+	ctx := contextWithLogger(context.Background(), zap.NewNop())
+	require.NoError(t, pwal.run(ctx))
+	firstIndex := func() uint64 {
+		pwal.mu.Lock()
+		defer pwal.mu.Unlock()
+		index, err := pwal.wal.FirstIndex()
+		assert.NoError(t, err)
+		return index
+	}
+
+	// Shard A drains its entries 1, 3 and 4 while shard B is stuck on entry 2.
+	for _, req := range []*prompb.WriteRequest{series(a, 0, 1), series(b, 0, 1), series(a, 15, 2), series(a, 30, 3)} {
+		require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{req}))
+	}
+	require.Eventually(t, func() bool {
+		return pwal.shards.watermark(0) == 4
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{a + "/1", a + "/2", a + "/3"}, exportedNames())
+	assert.Equal(t, uint64(0), pwal.sWALIndex.Load())
+	clock.Advance(time.Minute)
+	require.Never(t, func() bool {
+		return firstIndex() > 1
+	}, 100*time.Millisecond, 5*time.Millisecond)
+
+	// Once shard B exported entry 2, every entry was exported, and while shard B is stuck on
+	// entry 5, the WAL is truncated up to it.
+	close(gates[1])
+	require.Eventually(t, func() bool {
+		return pwal.sWALIndex.Load() == 4
+	}, 5*time.Second, 5*time.Millisecond)
+	for _, req := range []*prompb.WriteRequest{series(b, 45, 2), series(a, 45, 4)} {
+		require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{req}))
+	}
+	require.Eventually(t, func() bool {
+		return pwal.shards.watermark(0) == 6
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{b + "/1", a + "/4"}, exportedNames())
+	assert.Equal(t, uint64(4), pwal.sWALIndex.Load())
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		return firstIndex() == 5
+	}, 5*time.Second, 5*time.Millisecond)
+	require.NoError(t, pwal.stop())
+
+	// After a restart, shard B exports entry 5, which it was stuck on, but shard A doesn't export
+	// entry 6 again.
+	pwal, err = newWAL(walConfig, func(_ context.Context, reqL []*prompb.WriteRequest) error {
+		for _, req := range reqL {
+			record(req.Timeseries[0])
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, pwal.run(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	require.Eventually(t, func() bool {
+		return pwal.sWALIndex.Load() == 6
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{b + "/2"}, exportedNames())
+}
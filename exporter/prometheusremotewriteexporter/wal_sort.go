@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// sampleSorter sorts the samples of every series by timestamp and, when dropOutOfOrder is set,
+// drops the samples that aren't newer than the last sample written for their series. The last
+// timestamps of at most MaxTrackedSeries series are kept.
+type sampleSorter struct {
+	dropOutOfOrder bool
+
+	mu     sync.Mutex
+	series *seriesLRU
+}
+
+// newSampleSorter returns nil if the samples aren't sorted.
+func newSampleSorter(wc *WALConfig) *sampleSorter {
+	if !wc.SortSamplesByTimestamp {
+		return nil
+	}
+	return &sampleSorter{
+		dropOutOfOrder: wc.DropOutOfOrderSamples,
+		series:         newSeriesLRU(wc.MaxTrackedSeries),
+	}
+}
+
+// apply returns the requests with the samples of every series sorted by timestamp, without the
+// samples that are out of order if they are dropped. Series and requests that are left empty
+// are removed. The requests themselves are not modified.
+func (s *sampleSorter) apply(requests []*prompb.WriteRequest) []*prompb.WriteRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sorted := make([]*prompb.WriteRequest, 0, len(requests))
+	for _, req := range requests {
+		out := &prompb.WriteRequest{Metadata: req.Metadata}
+		for _, ts := range req.Timeseries {
+			if len(ts.Samples) == 0 {
+				out.Timeseries = append(out.Timeseries, ts)
+				continue
+			}
+			samples := make([]prompb.Sample, len(ts.Samples))
+			copy(samples, ts.Samples)
+			sort.SliceStable(samples, func(i, j int) bool {
+				return samples[i].Timestamp < samples[j].Timestamp
+			})
+			if s.dropOutOfOrder {
+				samples = s.dropOlder(labelsKey(ts.Labels), samples)
+			}
+			if len(samples) == 0 && len(ts.Exemplars) == 0 && len(ts.Histograms) == 0 {
+				continue
+			}
+			ts.Samples = samples
+			out.Timeseries = append(out.Timeseries, ts)
+		}
+		if len(out.Timeseries) > 0 || len(out.Metadata) > 0 {
+			sorted = append(sorted, out)
+		}
+	}
+	return sorted
+}
+
+// dropOlder returns the sorted samples of the series of key that are newer than its last
+// written sample, and records the timestamp of the last one.
+func (s *sampleSorter) dropOlder(key string, samples []prompb.Sample) []prompb.Sample {
+	series, ok := s.series.get(key)
+	if !ok {
+		s.series.add(key, nil, samples[len(samples)-1].Timestamp)
+		return samples
+	}
+	first := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Timestamp > series.timestamp
+	})
+	samples = samples[first:]
+	if len(samples) > 0 {
+		series.timestamp = samples[len(samples)-1].Timestamp
+	}
+	return samples
+}
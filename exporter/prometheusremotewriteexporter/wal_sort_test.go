@@ -0,0 +1,97 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shuffledSeries(name string, timestamps ...int64) prompb.TimeSeries {
+	ts := prompb.TimeSeries{Labels: []prompb.Label{{Name: "__name__", Value: name}}}
+	for _, timestamp := range timestamps {
+		ts.Samples = append(ts.Samples, prompb.Sample{Value: float64(timestamp), Timestamp: timestamp})
+	}
+	rand.Shuffle(len(ts.Samples), func(i, j int) {
+		ts.Samples[i], ts.Samples[j] = ts.Samples[j], ts.Samples[i]
+	})
+	return ts
+}
+
+func timestampsByName(req *prompb.WriteRequest) map[string][]int64 {
+	timestamps := map[string][]int64{}
+	for i, ts := range req.Timeseries {
+		timestamps[labelValue(ts.Labels, "__name__")] = sampleTimestamps(&req.Timeseries[i])
+	}
+	return timestamps
+}
+
+func TestWAL_SortSamplesByTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	pwal, err := newWAL(&WALConfig{Directory: dir, SortSamplesByTimestamp: true}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+
+	in := []*prompb.WriteRequest{
+		{
+			Timeseries: []prompb.TimeSeries{
+				shuffledSeries("mem_used_percent", 10, 20, 30, 40, 50, 60),
+				shuffledSeries("cpu_used_percent", 5, 15, 25, 35),
+			},
+		},
+	}
+	inSamples := append([]prompb.Sample(nil), in[0].Timeseries[0].Samples...)
+	require.NoError(t, pwal.persistToWAL(in))
+	require.NoError(t, pwal.stop())
+	assert.Equal(t, inSamples, in[0].Timeseries[0].Samples, "the requests must not be modified")
+
+	// The sorted samples are replayed after a restart
+	pwal, err = newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	req, err := pwal.readPrompbFromWAL(context.Background(), pwal.rWALIndex.Load())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]int64{
+		"mem_used_percent": {10, 20, 30, 40, 50, 60},
+		"cpu_used_percent": {5, 15, 25, 35},
+	}, timestampsByName(req))
+}
+
+func TestSampleSorter_DropOutOfOrder(t *testing.T) {
+	sorter := newSampleSorter(&WALConfig{SortSamplesByTimestamp: true, DropOutOfOrderSamples: true, MaxTrackedSeries: 2})
+
+	out := sorter.apply([]*prompb.WriteRequest{
+		{Timeseries: []prompb.TimeSeries{shuffledSeries("a", 30, 10, 20)}},
+		{Timeseries: []prompb.TimeSeries{shuffledSeries("b", 10)}},
+	})
+	require.Len(t, out, 2)
+	assert.Equal(t, map[string][]int64{"a": {10, 20, 30}}, timestampsByName(out[0]))
+
+	// Samples that aren't newer than the last sample written for their series are dropped,
+	// and requests left without series are removed.
+	out = sorter.apply([]*prompb.WriteRequest{
+		{Timeseries: []prompb.TimeSeries{shuffledSeries("a", 25, 30, 40)}},
+		{Timeseries: []prompb.TimeSeries{shuffledSeries("b", 5)}},
+	})
+	require.Len(t, out, 1)
+	assert.Equal(t, map[string][]int64{"a": {40}}, timestampsByName(out[0]))
+
+	// c evicts b, the least recently written series, whose older samples are then kept.
+	out = sorter.apply([]*prompb.WriteRequest{
+		{Timeseries: []prompb.TimeSeries{shuffledSeries("a", 50), shuffledSeries("c", 10)}},
+		{Timeseries: []prompb.TimeSeries{shuffledSeries("a", 45), shuffledSeries("b", 5)}},
+	})
+	require.Len(t, out, 2)
+	assert.Equal(t, map[string][]int64{"a": {50}, "c": {10}}, timestampsByName(out[0]))
+	assert.Equal(t, map[string][]int64{"b": {5}}, timestampsByName(out[1]))
+	assert.Equal(t, 2, sorter.series.len())
+}
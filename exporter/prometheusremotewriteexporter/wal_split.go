@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// exportSplit exports the requests to the sink, split into requests of at most MaxSamplesPerSend
+// samples. The parts of the requests are exported by successive calls to the sink, the first part
+// of every request first, so that the samples of a series split across requests are exported in
+// order even when the sink exports the requests of a call concurrently.
+func (prwe *prweWAL) exportSplit(ctx context.Context, reqL []*prompb.WriteRequest) error {
+	limit := prwe.walConfig.MaxSamplesPerSend
+	if limit <= 0 {
+		return prwe.exportSink(ctx, reqL)
+	}
+
+	var rounds [][]*prompb.WriteRequest
+	for _, req := range reqL {
+		for i, part := range splitBySamples(req, limit) {
+			if i == len(rounds) {
+				rounds = append(rounds, nil)
+			}
+			rounds[i] = append(rounds[i], part)
+		}
+	}
+	for _, round := range rounds {
+		if err := prwe.exportSink(ctx, round); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitBySamples splits the request into requests of at most limit samples, and histograms, each.
+// A series with more samples than fit in a request is split across requests, each part keeping the
+// labels of the series, and its exemplars are kept with its first part. The requests are balanced,
+// so that a request of 1001 samples is split into requests of 501 and 500 samples with a limit of
+// 1000. The request is returned as is if it doesn't exceed the limit.
+func splitBySamples(req *prompb.WriteRequest, limit int) []*prompb.WriteRequest {
+	total := sampleCount(req)
+	if limit <= 0 || total <= limit {
+		return []*prompb.WriteRequest{req}
+	}
+	parts := (total + limit - 1) / limit
+	size := (total + parts - 1) / parts
+
+	split := make([]*prompb.WriteRequest, 0, parts)
+	current := &prompb.WriteRequest{Metadata: req.Metadata}
+	count := 0
+	for _, ts := range req.Timeseries {
+		samples, histograms, exemplars := ts.Samples, ts.Histograms, ts.Exemplars
+		for first := true; first || len(samples)+len(histograms) > 0; first = false {
+			if count == size && len(samples)+len(histograms) > 0 {
+				split = append(split, current)
+				current = &prompb.WriteRequest{}
+				count = 0
+			}
+			part := prompb.TimeSeries{Labels: ts.Labels, Exemplars: exemplars}
+			exemplars = nil
+
+			n := min(size-count, len(samples))
+			part.Samples, samples = samples[:n:n], samples[n:]
+			count += n
+			n = min(size-count, len(histograms))
+			part.Histograms, histograms = histograms[:n:n], histograms[n:]
+			count += n
+
+			current.Timeseries = append(current.Timeseries, part)
+		}
+	}
+	if len(current.Timeseries) > 0 {
+		split = append(split, current)
+	}
+	return split
+}
+
+// sampleCount is the number of samples and histograms of the request.
+func sampleCount(req *prompb.WriteRequest) int {
+	count := 0
+	for _, ts := range req.Timeseries {
+		count += len(ts.Samples) + len(ts.Histograms)
+	}
+	return count
+}
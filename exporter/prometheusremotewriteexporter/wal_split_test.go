@@ -0,0 +1,141 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func samplesRequest(series, samplesPerSeries int) *prompb.WriteRequest {
+	req := &prompb.WriteRequest{}
+	for i := 0; i < series; i++ {
+		ts := prompb.TimeSeries{Labels: []prompb.Label{{Name: "__name__", Value: fmt.Sprintf("metric_%d", i)}}}
+		for j := 0; j < samplesPerSeries; j++ {
+			ts.Samples = append(ts.Samples, prompb.Sample{Value: float64(j), Timestamp: int64(j)})
+		}
+		req.Timeseries = append(req.Timeseries, ts)
+	}
+	return req
+}
+
+// joinSeries concatenates the samples of the series of the requests that have the same labels.
+func joinSeries(reqL []*prompb.WriteRequest) []prompb.TimeSeries {
+	var series []prompb.TimeSeries
+	index := map[string]int{}
+	for _, req := range reqL {
+		for _, ts := range req.Timeseries {
+			key := fmt.Sprint(ts.Labels)
+			i, ok := index[key]
+			if !ok {
+				i = len(series)
+				index[key] = i
+				series = append(series, prompb.TimeSeries{Labels: ts.Labels})
+			}
+			series[i].Samples = append(series[i].Samples, ts.Samples...)
+			series[i].Histograms = append(series[i].Histograms, ts.Histograms...)
+			series[i].Exemplars = append(series[i].Exemplars, ts.Exemplars...)
+		}
+	}
+	return series
+}
+
+func TestSplitBySamples(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   *prompb.WriteRequest
+		limit int
+		sizes []int
+	}{
+		{
+			name:  "single series",
+			req:   samplesRequest(1, 10000),
+			limit: 1000,
+			sizes: []int{1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000},
+		},
+		{
+			name:  "many series",
+			req:   samplesRequest(100, 100),
+			limit: 1000,
+			sizes: []int{1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000},
+		},
+		{
+			name:  "balanced",
+			req:   samplesRequest(7, 143),
+			limit: 1000,
+			sizes: []int{501, 500},
+		},
+		{
+			name:  "under the limit",
+			req:   samplesRequest(10, 100),
+			limit: 1000,
+			sizes: []int{1000},
+		},
+		{
+			name:  "no limit",
+			req:   samplesRequest(1, 10000),
+			limit: 0,
+			sizes: []int{10000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			split := splitBySamples(tt.req, tt.limit)
+			var sizes []int
+			for _, req := range split {
+				sizes = append(sizes, sampleCount(req))
+			}
+			assert.Equal(t, tt.sizes, sizes)
+			assert.Equal(t, tt.req.Timeseries, joinSeries(split))
+		})
+	}
+}
+
+func TestSplitBySamples_KeepsMetadataAndExemplars(t *testing.T) {
+	req := samplesRequest(1, 3)
+	req.Timeseries[0].Exemplars = []prompb.Exemplar{{Value: 1, Timestamp: 1}}
+	req.Timeseries[0].Histograms = []prompb.Histogram{{Timestamp: 3}}
+	req.Timeseries = append(req.Timeseries, prompb.TimeSeries{Labels: []prompb.Label{{Name: "__name__", Value: "empty"}}})
+	req.Metadata = []prompb.MetricMetadata{{MetricFamilyName: "metric_0", Type: prompb.MetricMetadata_GAUGE}}
+
+	split := splitBySamples(req, 2)
+	require.Len(t, split, 2)
+	assert.Equal(t, req.Metadata, split[0].Metadata)
+	assert.Empty(t, split[1].Metadata)
+	assert.Equal(t, req.Timeseries[0].Exemplars, split[0].Timeseries[0].Exemplars)
+	assert.Equal(t, []prompb.Histogram{{Timestamp: 3}}, split[1].Timeseries[0].Histograms)
+	assert.Equal(t, req.Timeseries, joinSeries(split))
+}
+
+func TestWAL_MaxSamplesPerSend(t *testing.T) {
+	var exported [][]*prompb.WriteRequest
+	sink := func(_ context.Context, reqL []*prompb.WriteRequest) error {
+		exported = append(exported, reqL)
+		return nil
+	}
+	pwal, err := newWAL(&WALConfig{Directory: t.TempDir(), MaxSamplesPerSend: 1000}, sink)
+	require.NoError(t, err)
+
+	in := []*prompb.WriteRequest{samplesRequest(1, 10000), samplesRequest(3, 100)}
+	// The series of the second request don't have the labels of the series of the first
+	in[1].Timeseries = in[1].Timeseries[1:]
+	require.NoError(t, pwal.exportWithRetry(context.Background(), 1, in))
+
+	// The parts of a series are exported by successive calls to the sink, in order.
+	require.Len(t, exported, 10)
+	assert.Len(t, exported[0], 2)
+	var all []*prompb.WriteRequest
+	for _, reqL := range exported {
+		for _, req := range reqL {
+			assert.LessOrEqual(t, sampleCount(req), 1000)
+		}
+		all = append(all, reqL...)
+	}
+	assert.Equal(t, append(in[0].Timeseries, in[1].Timeseries...), joinSeries(all))
+}
@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+	"go.uber.org/zap"
+)
+
+// staleFlushTimeout bounds how long stopping the WAL waits for the exporting to stop and for
+// the staleness markers to be exported.
+const staleFlushTimeout = 5 * time.Second
+
+// activeSeries tracks the series written to the WAL, to mark them as stale when the WAL is
+// stopped. At most MaxTrackedSeries series are tracked, and series whose last sample is a
+// staleness marker are forgotten.
+type activeSeries struct {
+	mu     sync.Mutex
+	series *seriesLRU
+}
+
+// newActiveSeries returns nil if the series aren't marked as stale on shutdown.
+func newActiveSeries(wc *WALConfig) *activeSeries {
+	if !wc.StaleOnShutdown {
+		return nil
+	}
+	return &activeSeries{
+		series: newSeriesLRU(wc.MaxTrackedSeries),
+	}
+}
+
+// observe tracks the series of the requests that have samples.
+func (a *activeSeries) observe(requests []*prompb.WriteRequest) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, req := range requests {
+		for _, ts := range req.Timeseries {
+			if len(ts.Samples) == 0 {
+				continue
+			}
+			key := labelsKey(ts.Labels)
+			last := ts.Samples[len(ts.Samples)-1]
+			if value.IsStaleNaN(last.Value) {
+				a.series.remove(key)
+				continue
+			}
+			if series, ok := a.series.get(key); ok {
+				if last.Timestamp > series.timestamp {
+					series.timestamp = last.Timestamp
+				}
+				continue
+			}
+			a.series.add(key, ts.Labels, last.Timestamp)
+		}
+	}
+}
+
+// staleMarkers returns requests with a staleness marker for every tracked series, at now, in
+// milliseconds, or right after the last sample of the series if it is later, and forgets the series.
+func (a *activeSeries) staleMarkers(now int64) ([]*prompb.WriteRequest, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.series.len() == 0 {
+		return nil, nil
+	}
+	tsMap := make(map[string]*prompb.TimeSeries, a.series.len())
+	for key, elem := range a.series.series {
+		series := elem.Value.(*trackedSeries)
+		timestamp := now
+		if series.timestamp >= now {
+			timestamp = series.timestamp + 1
+		}
+		tsMap[key] = &prompb.TimeSeries{
+			Labels:  series.labels,
+			Samples: []prompb.Sample{{Value: math.Float64frombits(value.StaleNaN), Timestamp: timestamp}},
+		}
+	}
+	a.series = newSeriesLRU(a.series.maxSeries)
+	return batchTimeSeries(tsMap, maxBatchByteSize)
+}
+
+// flushStaleMarkers exports a staleness marker for every tracked series, once the exporting of
+// the WAL stopped. The markers are written to the WAL instead, to be exported after a restart,
+// if entries written before them weren't exported yet, as they must not be exported after the
+// markers, or if exporting the markers fails or doesn't complete within staleFlushTimeout.
+func (prwe *prweWAL) flushStaleMarkers() error {
+	if prwe.active == nil {
+		return nil
+	}
+	requests, err := prwe.active.staleMarkers(prwe.clock.Now().UnixMilli())
+	if err != nil || len(requests) == 0 {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), staleFlushTimeout)
+	defer cancel()
+	if prwe.waitForRun(ctx) && prwe.sWALIndex.Load() >= prwe.wWALIndex.Load() {
+		errE := prwe.exportSplit(ctx, requests)
+		if errE == nil {
+			return nil
+		}
+		prwe.log.Warn("failed to export the staleness markers, they are exported after a restart", zap.Error(errE))
+	}
+	return prwe.persistToWAL(requests)
+}
+
+// waitForRun waits for the routine exporting the WAL to return after it was stopped, and
+// returns false if ctx is done first.
+func (prwe *prweWAL) waitForRun(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		prwe.runWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
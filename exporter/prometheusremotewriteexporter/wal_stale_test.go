@@ -0,0 +1,174 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// readAllFromWAL returns every request of the WAL in dir after a restart.
+func readAllFromWAL(t *testing.T, dir string) []*prompb.WriteRequest {
+	pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+	var reqL []*prompb.WriteRequest
+	for i := pwal.rWALIndex.Load(); i <= pwal.wWALIndex.Load(); i++ {
+		req, err := pwal.readPrompbFromWAL(context.Background(), i)
+		require.NoError(t, err)
+		reqL = append(reqL, req)
+	}
+	return reqL
+}
+
+// staleSeries returns the names of the series with a staleness marker, and the timestamps of the markers.
+func staleSeries(reqL []*prompb.WriteRequest) map[string]int64 {
+	stale := map[string]int64{}
+	for _, req := range reqL {
+		for _, ts := range req.Timeseries {
+			for _, sample := range ts.Samples {
+				if value.IsStaleNaN(sample.Value) {
+					stale[labelValue(ts.Labels, "__name__")] = sample.Timestamp
+				}
+			}
+		}
+	}
+	return stale
+}
+
+// recordingSink returns a sink that records the requests that it exports, and a function
+// returning them.
+func recordingSink(sinkErr error) (func(context.Context, []*prompb.WriteRequest) error, func() []*prompb.WriteRequest) {
+	var mu sync.Mutex
+	var exported []*prompb.WriteRequest
+	sink := func(_ context.Context, reqL []*prompb.WriteRequest) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if sinkErr != nil {
+			return sinkErr
+		}
+		exported = append(exported, reqL...)
+		return nil
+	}
+	return sink, func() []*prompb.WriteRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*prompb.WriteRequest(nil), exported...)
+	}
+}
+
+func TestWAL_StaleOnShutdownExported(t *testing.T) {
+	dir := t.TempDir()
+	sink, exported := recordingSink(nil)
+	pwal, err := newWAL(&WALConfig{Directory: dir, StaleOnShutdown: true, BufferSize: 1}, sink)
+	require.NoError(t, err)
+	pwal.clock = newFakeClockAt(time.UnixMilli(1000))
+	require.NoError(t, pwal.run(contextWithLogger(context.Background(), zap.NewNop())))
+
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{
+		series("mem_used_percent", 10, 34),
+		series("cpu_used_percent", 2000, 13),
+	}))
+	require.Eventually(t, func() bool {
+		return pwal.sWALIndex.Load() == pwal.wWALIndex.Load()
+	}, 5*time.Second, time.Millisecond)
+	require.NoError(t, pwal.stop())
+
+	reqL := exported()
+	require.Len(t, reqL, 3)
+	assert.Empty(t, staleSeries(reqL[:2]))
+	// The markers are exported on shutdown, and not written to the WAL to be exported again
+	assert.Equal(t, map[string]int64{
+		"mem_used_percent": 1000,
+		"cpu_used_percent": 2001,
+	}, staleSeries(reqL[2:]))
+	assert.Empty(t, staleSeries(readAllFromWAL(t, dir)))
+}
+
+func TestWAL_StaleOnShutdownExportFails(t *testing.T) {
+	dir := t.TempDir()
+	sink, exported := recordingSink(errors.New("remote write returned HTTP status 503 Service Unavailable"))
+	pwal, err := newWAL(&WALConfig{Directory: dir, StaleOnShutdown: true}, sink)
+	require.NoError(t, err)
+	pwal.clock = newFakeClockAt(time.UnixMilli(1000))
+	require.NoError(t, pwal.retrieveWALIndices())
+
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 10, 34)}))
+	// The entry is exported by another WAL, as after a restart
+	pwal.sWALIndex.Store(pwal.wWALIndex.Load())
+	require.NoError(t, pwal.stop())
+
+	// The markers are exported after a restart instead
+	assert.Empty(t, exported())
+	assert.Equal(t, map[string]int64{"mem_used_percent": 1000}, staleSeries(readAllFromWAL(t, dir)))
+}
+
+// TestWAL_StaleOnShutdown checks that the markers are written to the WAL after the entries that
+// weren't exported yet, instead of being exported before them.
+func TestWAL_StaleOnShutdown(t *testing.T) {
+	dir := t.TempDir()
+	sink, exported := recordingSink(nil)
+	pwal, err := newWAL(&WALConfig{Directory: dir, StaleOnShutdown: true}, sink)
+	require.NoError(t, err)
+	shutdown := time.UnixMilli(1000)
+	pwal.clock = newFakeClockAt(shutdown)
+	require.NoError(t, pwal.retrieveWALIndices())
+
+	removed := series("disk_used_percent", 20, 1)
+	removed.Timeseries[0].Samples = append(removed.Timeseries[0].Samples, prompb.Sample{
+		Value:     math.Float64frombits(value.StaleNaN),
+		Timestamp: 30,
+	})
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{
+		series("mem_used_percent", 10, 34),
+		series("cpu_used_percent", 10, 12),
+		removed,
+	}))
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{
+		series("mem_used_percent", 20, 35),
+		// A sample after the shutdown time is marked as stale right after it
+		series("cpu_used_percent", 2000, 13),
+	}))
+	require.NoError(t, pwal.stop())
+	assert.ErrorIs(t, pwal.stop(), errAlreadyClosed)
+	assert.Empty(t, exported())
+
+	reqL := readAllFromWAL(t, dir)
+	require.Len(t, reqL, 6)
+	assert.Equal(t, map[string]int64{"disk_used_percent": 30}, staleSeries(reqL[:5]))
+	assert.Equal(t, map[string]int64{
+		"mem_used_percent": 1000,
+		"cpu_used_percent": 2001,
+	}, staleSeries(reqL[5:]))
+}
+
+func TestWAL_StaleOnShutdownMaxTrackedSeries(t *testing.T) {
+	dir := t.TempDir()
+	pwal, err := newWAL(&WALConfig{Directory: dir, StaleOnShutdown: true, MaxTrackedSeries: 1}, doNothingExportSink)
+	require.NoError(t, err)
+	pwal.clock = newFakeClockAt(time.UnixMilli(1000))
+	require.NoError(t, pwal.retrieveWALIndices())
+
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{
+		series("mem_used_percent", 10, 34),
+		series("cpu_used_percent", 10, 12),
+	}))
+	require.NoError(t, pwal.stop())
+
+	// Only the most recently written series is tracked
+	assert.Equal(t, map[string]int64{"cpu_used_percent": 1000}, staleSeries(readAllFromWAL(t, dir)))
+}
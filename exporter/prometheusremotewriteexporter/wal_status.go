@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap"
+)
+
+// sinkStatus is the outcome of the last export of the WAL entries to the sink.
+type sinkStatus int64
+
+const (
+	sinkOK sinkStatus = iota
+	sinkTransientError
+	sinkPermanentError
+)
+
+func (s sinkStatus) String() string {
+	switch s {
+	case sinkOK:
+		return "ok"
+	case sinkTransientError:
+		return "transient error"
+	case sinkPermanentError:
+		return "permanent error"
+	}
+	return "unknown"
+}
+
+// reportSinkResult updates the sink status with the result of an export to the sink. The
+// onSinkStatus callback of the WAL is called whenever the status changes, with the error of
+// the export, so that a failing sink is observable and not only logged.
+func (prwe *prweWAL) reportSinkResult(ctx context.Context, err error) {
+	status := sinkOK
+	if err != nil {
+		status = sinkTransientError
+		if consumererror.IsPermanent(err) {
+			status = sinkPermanentError
+		}
+	}
+
+	prwe.statusMu.Lock()
+	defer prwe.statusMu.Unlock()
+	prwe.sinkErr = err
+	if status == prwe.status {
+		return
+	}
+	prwe.status = status
+	prwe.record(ctx, mWALSinkStatus.M(int64(status)))
+	if prwe.onSinkStatus != nil {
+		prwe.onSinkStatus(prwe.tenant, status, err)
+	}
+}
+
+// sinkStatus returns the status of the sink as of the last export, and its error if it failed.
+func (prwe *prweWAL) sinkStatus() (sinkStatus, error) {
+	prwe.statusMu.Lock()
+	defer prwe.statusMu.Unlock()
+	return prwe.status, prwe.sinkErr
+}
+
+// reportSinkStatus is the onSinkStatus callback of the WAL of the exporter, that logs the
+// changes of the status of its sink. Without a dead-letter directory, a permanent error is also
+// reported to the host as a fatal error, as the WAL doesn't export the entries written after
+// the rejected ones anymore.
+func (prwe *prwExporter) reportSinkStatus(logger *zap.Logger) func(string, sinkStatus, error) {
+	return func(tenant string, status sinkStatus, err error) {
+		fields := []zap.Field{zap.Stringer("status", status)}
+		if tenant != "" {
+			fields = append(fields, zap.String("tenant", tenant))
+		}
+		switch status {
+		case sinkOK:
+			logger.Info("WAL sink recovered", fields...)
+		case sinkTransientError:
+			logger.Warn("WAL sink is failing", append(fields, zap.Error(err))...)
+		default:
+			logger.Error("WAL sink rejected requests permanently", append(fields, zap.Error(err))...)
+			if prwe.host != nil && prwe.wal.walConfig.DeadLetterDir == "" {
+				prwe.host.ReportFatalError(fmt.Errorf("WAL sink rejected requests permanently: %w", err))
+			}
+		}
+	}
+}
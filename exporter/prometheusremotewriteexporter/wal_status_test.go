@@ -0,0 +1,165 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+func TestWAL_SinkStatus(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	// The sink fails transiently, then rejects the request permanently until it accepts it.
+	var calls atomic.Int64
+	var accept atomic.Bool
+	errRejected := errors.New("rejected")
+	sink := func(context.Context, []*prompb.WriteRequest) error {
+		switch {
+		case calls.Add(1) == 1:
+			return errors.New("unavailable")
+		case !accept.Load():
+			return consumererror.NewPermanent(errRejected)
+		}
+		return nil
+	}
+
+	wal, err := newWAL(&WALConfig{
+		Directory:  t.TempDir(),
+		BufferSize: 1,
+		Retry:      WALRetrySettings{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond},
+	}, sink)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var statuses []sinkStatus
+	wal.onSinkStatus = func(_ string, status sinkStatus, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		statuses = append(statuses, status)
+		if status == sinkPermanentError {
+			assert.ErrorIs(t, err, errRejected)
+		}
+	}
+	reported := func() []sinkStatus {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]sinkStatus(nil), statuses...)
+	}
+
+	ctx := contextWithLogger(context.Background(), zap.NewNop())
+	require.NoError(t, wal.run(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, wal.stop())
+	})
+	require.NoError(t, wal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 0, 0)}))
+
+	// The WAL keeps running while the sink rejects the request, and reports its error
+	require.Eventually(t, func() bool {
+		return len(reported()) == 2
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []sinkStatus{sinkTransientError, sinkPermanentError}, reported())
+	status, err := wal.sinkStatus()
+	assert.Equal(t, sinkPermanentError, status)
+	assert.True(t, consumererror.IsPermanent(err))
+
+	rows, err := view.RetrieveData(mWALSinkStatus.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(sinkPermanentError), rows[0].Data.(*view.LastValueData).Value)
+
+	// Once the sink accepts the request again, the WAL reports that it recovered
+	accept.Store(true)
+	require.Eventually(t, func() bool {
+		return len(reported()) == 3
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []sinkStatus{sinkTransientError, sinkPermanentError, sinkOK}, reported())
+	status, err = wal.sinkStatus()
+	assert.Equal(t, sinkOK, status)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return wal.sWALIndex.Load() == 1
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+// fatalErrorHost records the first error reported with ReportFatalError.
+type fatalErrorHost struct {
+	component.Host
+	errs chan error
+}
+
+func (h *fatalErrorHost) ReportFatalError(err error) {
+	select {
+	case h.errs <- err:
+	default:
+	}
+}
+
+func TestPRWExporter_SinkPermanentErrorIsFatal(t *testing.T) {
+	tests := []struct {
+		name       string
+		deadLetter bool
+		fatal      bool
+	}{
+		{name: "without_dead_letter", fatal: true},
+		// The rejected requests are dead-lettered and the WAL keeps exporting
+		{name: "with_dead_letter", deadLetter: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+			}))
+			defer server.Close()
+
+			cfg := createDefaultConfig().(*Config)
+			cfg.HTTPClientSettings.Endpoint = server.URL
+			cfg.WAL = &WALConfig{Directory: t.TempDir(), BufferSize: 1}
+			if tt.deadLetter {
+				cfg.WAL.DeadLetterDir = t.TempDir()
+			}
+			prwe, err := newPRWExporter(cfg, exportertest.NewNopCreateSettings())
+			require.NoError(t, err)
+			host := &fatalErrorHost{Host: componenttest.NewNopHost(), errs: make(chan error, 1)}
+			ctx := context.Background()
+			require.NoError(t, prwe.Start(ctx, host))
+			t.Cleanup(func() {
+				assert.NoError(t, prwe.Shutdown(ctx))
+			})
+
+			require.NoError(t, prwe.wal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 0, 0)}))
+			require.Eventually(t, func() bool {
+				status, _ := prwe.wal.sinkStatus()
+				return status == sinkPermanentError
+			}, 5*time.Second, time.Millisecond)
+
+			select {
+			case err := <-host.errs:
+				require.True(t, tt.fatal, "unexpected fatal error: %v", err)
+				assert.True(t, consumererror.IsPermanent(err))
+				assert.ErrorContains(t, err, "400 Bad Request")
+			default:
+				assert.False(t, tt.fatal, "the permanent error wasn't reported to the host")
+			}
+		})
+	}
+}
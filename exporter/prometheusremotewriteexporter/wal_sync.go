@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	walSyncAlways         = "always"
+	walSyncNone           = "none"
+	walSyncIntervalPrefix = "interval:"
+)
+
+// parseWALSync parses the sync policy of the WAL. It returns whether writes are left
+// unsynced, and the interval at which unsynced writes are flushed, if any.
+func parseWALSync(policy string) (noSync bool, interval time.Duration, err error) {
+	switch {
+	case policy == "" || policy == walSyncAlways:
+		return false, 0, nil
+	case policy == walSyncNone:
+		return true, 0, nil
+	case strings.HasPrefix(policy, walSyncIntervalPrefix):
+		interval, err = time.ParseDuration(strings.TrimPrefix(policy, walSyncIntervalPrefix))
+		if err != nil {
+			return false, 0, fmt.Errorf("WAL sync %q: %w", policy, err)
+		}
+		if interval <= 0 {
+			return false, 0, fmt.Errorf("WAL sync %q: interval must be positive", policy)
+		}
+		return true, interval, nil
+	default:
+		return false, 0, fmt.Errorf("WAL sync %q must be one of %s, %s<duration> or %s",
+			policy, walSyncAlways, walSyncIntervalPrefix, walSyncNone)
+	}
+}
+
+// syncPeriodically flushes the writes to the WAL to disk every interval, if it is positive, and
+// whenever FlushCount requests were written since the last flush, until ctx is done or the WAL
+// is stopped.
+func (prwe *prweWAL) syncPeriodically(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := prwe.clock.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.Chan()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-prwe.stopChan:
+			return
+		case <-tick:
+		case <-prwe.flushNotify:
+		}
+		if err := prwe.sync(); err != nil {
+			prwe.log.Warn("failed to sync write-ahead log", zap.Error(err))
+		}
+	}
+}
+
+func (prwe *prweWAL) sync() error {
+	prwe.mu.Lock()
+	defer prwe.mu.Unlock()
+
+	if prwe.wal == nil {
+		return nil
+	}
+	if err := prwe.wal.Sync(); err != nil {
+		return err
+	}
+	prwe.unsynced = 0
+	return nil
+}
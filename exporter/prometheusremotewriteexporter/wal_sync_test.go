@@ -0,0 +1,139 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseWALSync(t *testing.T) {
+	for _, tt := range []struct {
+		policy   string
+		noSync   bool
+		interval time.Duration
+		err      string
+	}{
+		{policy: ""},
+		{policy: walSyncAlways},
+		{policy: walSyncNone, noSync: true},
+		{policy: "interval:5s", noSync: true, interval: 5 * time.Second},
+		{policy: "interval:0s", err: `WAL sync "interval:0s": interval must be positive`},
+		{policy: "interval:soon", err: `WAL sync "interval:soon": time: invalid duration "soon"`},
+		{policy: "sometimes", err: `WAL sync "sometimes" must be one of always, interval:<duration> or none`},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			noSync, interval, err := parseWALSync(tt.policy)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.noSync, noSync)
+			assert.Equal(t, tt.interval, interval)
+		})
+	}
+}
+
+// unsyncedRequests returns the number of requests written to the WAL since it was last synced.
+func unsyncedRequests(pwal *prweWAL) int {
+	pwal.mu.Lock()
+	defer pwal.mu.Unlock()
+	return pwal.unsynced
+}
+
+func TestWAL_Flush(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		flushInterval time.Duration
+		flushCount    int
+		// writes is the number of requests written before they are flushed.
+		writes int
+	}{
+		{name: "count", flushInterval: time.Hour, flushCount: 3, writes: 3},
+		{name: "interval", flushInterval: 50 * time.Millisecond, flushCount: 1000, writes: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pwal, err := newWAL(&WALConfig{
+				Directory:         t.TempDir(),
+				Sync:              walSyncNone,
+				FlushInterval:     tt.flushInterval,
+				FlushCount:        tt.flushCount,
+				TruncateFrequency: time.Hour,
+			}, doNothingExportSink)
+			require.NoError(t, err)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			require.NoError(t, pwal.run(contextWithLogger(ctx, zap.NewNop())))
+			t.Cleanup(func() {
+				assert.NoError(t, pwal.stop())
+			})
+
+			for i := 1; i < tt.writes; i++ {
+				require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", int64(i), 1)}))
+			}
+			// Fewer than flush_count requests are left unsynced until the interval elapses.
+			time.Sleep(20 * time.Millisecond)
+			assert.Equal(t, tt.writes-1, unsyncedRequests(pwal))
+			require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", int64(tt.writes), 1)}))
+			assert.Eventually(t, func() bool {
+				return unsyncedRequests(pwal) == 0
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func BenchmarkWAL_PersistSync(b *testing.B) {
+	// The same requests as TestWAL_persist
+	reqL := []*prompb.WriteRequest{
+		{
+			Timeseries: []prompb.TimeSeries{
+				{
+					Labels:  []prompb.Label{{Name: "ts1l1", Value: "ts1k1"}},
+					Samples: []prompb.Sample{{Value: 1, Timestamp: 100}},
+				},
+			},
+		},
+		{
+			Timeseries: []prompb.TimeSeries{
+				{
+					Labels:  []prompb.Label{{Name: "ts2l1", Value: "ts2k1"}},
+					Samples: []prompb.Sample{{Value: 2, Timestamp: 200}},
+				},
+				{
+					Labels:  []prompb.Label{{Name: "ts1l1", Value: "ts1k1"}},
+					Samples: []prompb.Sample{{Value: 1, Timestamp: 100}},
+				},
+			},
+		},
+	}
+
+	for _, policy := range []string{walSyncAlways, "interval:1s", walSyncNone} {
+		b.Run(policy, func(b *testing.B) {
+			pwal, err := newWAL(&WALConfig{Directory: b.TempDir(), Sync: policy}, doNothingExportSink)
+			require.NoError(b, err)
+			require.NoError(b, pwal.retrieveWALIndices())
+			_, interval, err := parseWALSync(policy)
+			require.NoError(b, err)
+			if interval > 0 {
+				go pwal.syncPeriodically(context.Background(), interval)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := pwal.persistToWAL(reqL); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			require.NoError(b, pwal.stop())
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/prometheus/prometheus/prompb"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// newChild creates the WAL of tenant, in the tenants/<tenant> directory of the WAL directory
+// and of the dead-letter directory, that is exported to exportSink. The child has its own
+// indices and truncation, so that a tenant whose sink is slow or fails doesn't hold back the
+// others. It is started and stopped with prwe, and must be created before prwe is started.
+func (prwe *prweWAL) newChild(tenant string, exportSink func(context.Context, []*prompb.WriteRequest) error) (*prweWAL, error) {
+	if _, ok := prwe.children[tenant]; ok {
+		return nil, fmt.Errorf("WAL of tenant %q already exists", tenant)
+	}
+
+	childConfig := *prwe.walConfig
+	childConfig.Directory = filepath.Join(prwe.walConfig.Directory, "tenants", tenant)
+	if prwe.walConfig.DeadLetterDir != "" {
+		childConfig.DeadLetterDir = filepath.Join(prwe.walConfig.DeadLetterDir, "tenants", tenant)
+	}
+	child, err := newWAL(&childConfig, exportSink)
+	if err != nil {
+		return nil, err
+	}
+	child.tenant = tenant
+	child.clock = prwe.clock
+	child.onSinkStatus = prwe.onSinkStatus
+
+	if prwe.children == nil {
+		prwe.children = make(map[string]*prweWAL)
+	}
+	prwe.children[tenant] = child
+	return child, nil
+}
+
+// runChildren starts the child WALs, in the order of their tenants.
+func (prwe *prweWAL) runChildren(ctx context.Context) error {
+	logger, err := loggerFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	tenants := make([]string, 0, len(prwe.children))
+	for tenant := range prwe.children {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		childCtx := contextWithLogger(ctx, logger.With(zap.String("tenant", tenant)))
+		if err := prwe.children[tenant].run(childCtx); err != nil {
+			return fmt.Errorf("WAL of tenant %q: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+// stopChildren stops every child WAL, even if stopping one of them fails.
+func (prwe *prweWAL) stopChildren() error {
+	var errs error
+	for tenant, child := range prwe.children {
+		if err := child.stop(); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("WAL of tenant %q: %w", tenant, err))
+		}
+	}
+	return errs
+}
+
+// record records the measurements with the tenant of the WAL, if it is a child WAL.
+func (prwe *prweWAL) record(ctx context.Context, ms ...stats.Measurement) {
+	var mutators []tag.Mutator
+	if prwe.tenant != "" {
+		mutators = append(mutators, tag.Upsert(tagTenant, prwe.tenant))
+	}
+	_ = stats.RecordWithTags(ctx, mutators, ms...)
+}
@@ -0,0 +1,123 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.uber.org/zap"
+)
+
+func TestWAL_ChildIsolation(t *testing.T) {
+	release := make(chan struct{})
+	stalled := func(ctx context.Context, reqs []*prompb.WriteRequest) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-release:
+			return nil
+		}
+	}
+	received := make(chan *prompb.WriteRequest, 1)
+	sink := func(ctx context.Context, reqs []*prompb.WriteRequest) error {
+		for _, req := range reqs {
+			received <- req
+		}
+		return nil
+	}
+
+	dir := t.TempDir()
+	parent, err := newWAL(&WALConfig{Directory: dir, BufferSize: 1}, doNothingExportSink)
+	require.NoError(t, err)
+	childA, err := parent.newChild("a", stalled)
+	require.NoError(t, err)
+	childB, err := parent.newChild("b", sink)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "tenants", "a"), childA.walConfig.Directory)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, parent.run(contextWithLogger(ctx, zap.NewNop())))
+	t.Cleanup(func() {
+		close(release)
+		cancel()
+		assert.NoError(t, parent.stop())
+	})
+
+	// The replay of tenant a is stalled on its first request
+	require.NoError(t, childA.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 0, 0)}))
+	want := series("mem_used_percent", 15, 34)
+	require.NoError(t, childB.persistToWAL([]*prompb.WriteRequest{want}))
+
+	select {
+	case got := <-received:
+		assert.Equal(t, want, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the requests of tenant b were not exported while tenant a was stalled")
+	}
+	assert.Equal(t, uint64(0), childA.sWALIndex.Load())
+}
+
+func TestWAL_StopChildren(t *testing.T) {
+	parent, err := newWAL(&WALConfig{Directory: t.TempDir()}, doNothingExportSink)
+	require.NoError(t, err)
+	childA, err := parent.newChild("a", doNothingExportSink)
+	require.NoError(t, err)
+	childB, err := parent.newChild("b", doNothingExportSink)
+	require.NoError(t, err)
+	_, err = parent.newChild("b", doNothingExportSink)
+	assert.EqualError(t, err, `WAL of tenant "b" already exists`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, parent.run(contextWithLogger(ctx, zap.NewNop())))
+
+	require.NoError(t, parent.stop())
+	assert.ErrorIs(t, parent.stop(), errAlreadyClosed)
+	assert.ErrorIs(t, childA.stop(), errAlreadyClosed)
+	assert.ErrorIs(t, childB.stop(), errAlreadyClosed)
+}
+
+func TestWAL_ChildBacklog(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	parent, err := newWAL(&WALConfig{Directory: t.TempDir()}, doNothingExportSink)
+	require.NoError(t, err)
+	for tenant, count := range map[string]int{"a": 2, "b": 1} {
+		child, err := parent.newChild(tenant, doNothingExportSink)
+		require.NoError(t, err)
+		require.NoError(t, child.retrieveWALIndices())
+		in := make([]*prompb.WriteRequest, 0, count)
+		for i := 0; i < count; i++ {
+			in = append(in, series("mem_used_percent", int64(i), float64(i)))
+		}
+		require.NoError(t, child.persistToWAL(in))
+		child.recordBacklog(context.Background())
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, parent.stop())
+	})
+
+	rows, err := view.RetrieveData(mWALBacklog.Name())
+	require.NoError(t, err)
+	backlog := map[string]float64{}
+	for _, row := range rows {
+		if len(row.Tags) == 0 {
+			// The WALs of other tests may still record their backlog
+			continue
+		}
+		require.Len(t, row.Tags, 1)
+		require.Equal(t, tagTenant, row.Tags[0].Key)
+		backlog[row.Tags[0].Value] = row.Data.(*view.LastValueData).Value
+	}
+	assert.Equal(t, map[string]float64{"a": 2, "b": 1}, backlog)
+}
@@ -5,14 +5,21 @@ package prometheusremotewriteexporter
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
 	"go.uber.org/zap"
 )
 
@@ -36,6 +43,42 @@ func TestWALCreation_nonNilConfig(t *testing.T) {
 	assert.NoError(t, pwal.stop())
 }
 
+func TestWALCreation_notWritable(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions don't restrict this user")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0500))
+	t.Cleanup(func() { require.NoError(t, os.Chmod(dir, 0700)) })
+
+	pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.Nil(t, pwal)
+	require.ErrorIs(t, err, ErrWALNotWritable)
+	require.ErrorIs(t, err, os.ErrPermission)
+	var notWritable *WALNotWritableError
+	require.ErrorAs(t, err, &notWritable)
+	assert.Equal(t, dir, notWritable.Path)
+}
+
+func TestWALCreation_notADirectory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0600))
+
+	pwal, err := newWAL(&WALConfig{Directory: filepath.Join(file, "wal")}, doNothingExportSink)
+	require.Nil(t, pwal)
+	require.ErrorIs(t, err, ErrWALNotWritable)
+	assert.ErrorContains(t, err, file)
+}
+
+func TestWALCreation_missingDirectory(t *testing.T) {
+	// The directory is created when the WAL is started, its parent is checked instead
+	dir := filepath.Join(t.TempDir(), "missing")
+	pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.NoError(t, err)
+	assert.NoDirExists(t, dir)
+	assert.NoError(t, pwal.stop())
+}
+
 func orderByLabelValueForEach(reqL []*prompb.WriteRequest) {
 	for _, req := range reqL {
 		orderByLabelValue(req)
@@ -180,27 +223,122 @@ func TestWAL_E2E(t *testing.T) {
 	wal, err := newWAL(&WALConfig{
 		Directory: t.TempDir(),
 	}, sink)
-	if err != nil {
-		t.Fatal(err)
-	}
+	require.NoError(t, err)
 	defer os.RemoveAll(t.TempDir())
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	log, err := zap.NewDevelopment()
-	if err != nil {
-		panic(err)
-	}
+	require.NoError(t, err)
 	ctx = contextWithLogger(ctx, log)
 
-	if err := wal.run(ctx); err != nil {
-		panic(err)
+	require.NoError(t, wal.run(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, wal.stop())
+	})
+
+	require.NoError(t, wal.persistToWAL(in))
+
+	// wait until the tail routine is no longer busy
+	wal.rNotify <- struct{}{}
+	cancel()
+
+	// wait until we received all series
+	<-done
+	require.Equal(t, in, out)
+}
+
+func TestWAL_SinkConcurrency(t *testing.T) {
+	in := []*prompb.WriteRequest{
+		series("mem_used_percent", 0, 0),
+		series("mem_used_percent", 15, 34),
+		series("mem_used_percent", 30, 99),
+	}
+	for i := len(in); i < 100; i++ {
+		in = append(in, series("mem_used_percent", int64(i*15), float64(i)))
+	}
+
+	var mu sync.Mutex
+	received := map[int64]int{}
+	sink := func(_ context.Context, reqs []*prompb.WriteRequest) error {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, req := range reqs {
+			received[req.Timeseries[0].Samples[0].Timestamp]++
+		}
+		return nil
+	}
+
+	pwal, err := newWAL(&WALConfig{
+		Directory:       t.TempDir(),
+		ReadBufferSize:  3,
+		SinkConcurrency: 4,
+	}, sink)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, pwal.run(contextWithLogger(ctx, zap.NewNop())))
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	for i := 0; i < len(in); i += 10 {
+		require.NoError(t, pwal.persistToWAL(in[i:i+10]))
+	}
+
+	// Every request is sent once, and the sent index covers them all
+	require.Eventually(t, func() bool {
+		return pwal.sWALIndex.Load() == uint64(len(in))
+	}, 10*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, len(in))
+	for _, req := range in {
+		assert.Equal(t, 1, received[req.Timeseries[0].Samples[0].Timestamp])
+	}
+}
+
+func TestWAL_E2EMetadata(t *testing.T) {
+	in := []*prompb.WriteRequest{
+		series("mem_used_percent", 0, 0),
+		series("mem_used_percent", 15, 34),
+		{
+			Metadata: []prompb.MetricMetadata{
+				{
+					Type:             prompb.MetricMetadata_GAUGE,
+					MetricFamilyName: "mem_used_percent",
+					Help:             "Percentage of memory used.",
+					Unit:             "%",
+				},
+			},
+		},
 	}
+	out := make([]*prompb.WriteRequest, 0, len(in))
 
-	if err := wal.persistToWAL(in); err != nil {
-		panic(err)
+	done := make(chan struct{})
+	sink := func(ctx context.Context, reqs []*prompb.WriteRequest) error {
+		out = append(out, reqs...)
+		if len(out) >= len(in) {
+			close(done)
+		}
+		return nil
 	}
 
+	wal, err := newWAL(&WALConfig{
+		Directory: t.TempDir(),
+	}, sink)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = contextWithLogger(ctx, zap.NewNop())
+	require.NoError(t, wal.run(ctx))
+	t.Cleanup(func() {
+		assert.NoError(t, wal.stop())
+	})
+	require.NoError(t, wal.persistToWAL(in))
+
 	// wait until the tail routine is no longer busy
 	wal.rNotify <- struct{}{}
 	cancel()
@@ -220,3 +358,464 @@ func series(name string, ts int64, value float64) *prompb.WriteRequest {
 		},
 	}
 }
+
+func histogramSeries(name string, ts int64, count uint64) *prompb.WriteRequest {
+	return &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels: []prompb.Label{{Name: "__name__", Value: name}},
+				Histograms: []prompb.Histogram{
+					{
+						Count:          &prompb.Histogram_CountInt{CountInt: count},
+						Sum:            float64(count) * 0.25,
+						Schema:         -2,
+						ZeroThreshold:  1e-128,
+						ZeroCount:      &prompb.Histogram_ZeroCountInt{ZeroCountInt: 1},
+						NegativeSpans:  []prompb.BucketSpan{{Offset: -1, Length: 1}},
+						NegativeDeltas: []int64{1},
+						PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}, {Offset: 3, Length: 1}},
+						PositiveDeltas: []int64{int64(count) - 3, -1, 1},
+						ResetHint:      prompb.Histogram_GAUGE,
+						Timestamp:      ts,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestWAL_NativeHistograms(t *testing.T) {
+	in := []*prompb.WriteRequest{
+		histogramSeries("http_server_duration", 0, 5),
+		histogramSeries("http_server_duration", 15, 9),
+		histogramSeries("http_server_duration", 30, 12),
+	}
+	out := make([]*prompb.WriteRequest, 0, len(in))
+
+	done := make(chan struct{})
+	sink := func(ctx context.Context, reqs []*prompb.WriteRequest) error {
+		out = append(out, reqs...)
+		if len(out) >= len(in) {
+			close(done)
+		}
+		return nil
+	}
+
+	dir := t.TempDir()
+	pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	require.NoError(t, pwal.persistToWAL(in))
+	require.NoError(t, pwal.stop())
+
+	// The histograms are replayed after a restart
+	pwal, err = newWAL(&WALConfig{Directory: dir}, sink)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, pwal.run(contextWithLogger(ctx, zap.NewNop())))
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	// wait until the tail routine is no longer busy
+	pwal.rNotify <- struct{}{}
+	cancel()
+
+	// wait until we received all series
+	<-done
+	require.Equal(t, in, out)
+}
+
+func TestWAL_MaxRetainedSegments(t *testing.T) {
+	for _, tt := range []struct {
+		name                string
+		maxRetainedSegments int
+	}{
+		{name: "unbounded", maxRetainedSegments: 0},
+		{name: "bounded", maxRetainedSegments: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var delivered []*prompb.WriteRequest
+			sink := func(_ context.Context, reqL []*prompb.WriteRequest) error {
+				delivered = append(delivered, reqL...)
+				return nil
+			}
+
+			pwal, err := newWAL(&WALConfig{
+				Directory:           t.TempDir(),
+				MaxRetainedSegments: tt.maxRetainedSegments,
+				SegmentSize:         256,
+			}, sink)
+			require.NoError(t, err)
+			require.NoError(t, pwal.retrieveWALIndices())
+			t.Cleanup(func() {
+				assert.NoError(t, pwal.stop())
+			})
+
+			ctx := context.Background()
+			var in []*prompb.WriteRequest
+			maxSegments := 0
+			for round := 0; round < 20; round++ {
+				batch := make([]*prompb.WriteRequest, 0, 10)
+				for i := 0; i < cap(batch); i++ {
+					batch = append(batch, series("mem_used_percent", int64(len(in)+i), float64(round)))
+				}
+				in = append(in, batch...)
+				require.NoError(t, pwal.persistToWAL(batch))
+
+				reqL := make([]*prompb.WriteRequest, 0, len(batch))
+				for range batch {
+					req, err := pwal.readPrompbFromWAL(ctx, pwal.rWALIndex.Load())
+					require.NoError(t, err)
+					reqL = append(reqL, req)
+				}
+				require.NoError(t, pwal.exportThenFrontTruncateWAL(ctx, reqL))
+
+				segments, err := listWALSegments(pwal.walPath)
+				require.NoError(t, err)
+				if len(segments) > maxSegments {
+					maxSegments = len(segments)
+				}
+			}
+
+			require.Equal(t, in, delivered)
+			if tt.maxRetainedSegments > 0 {
+				assert.LessOrEqual(t, maxSegments, tt.maxRetainedSegments)
+			} else {
+				assert.Greater(t, maxSegments, 2)
+			}
+		})
+	}
+}
+
+func TestWAL_SegmentSize(t *testing.T) {
+	pwal, err := newWAL(&WALConfig{
+		Directory:   t.TempDir(),
+		SegmentSize: minWALSegmentSize,
+	}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	in := make([]*prompb.WriteRequest, 0, 400)
+	for i := 0; i < cap(in); i++ {
+		in = append(in, series("mem_used_percent", int64(i), float64(i)))
+	}
+	require.NoError(t, pwal.persistToWAL(in))
+
+	before, err := listWALSegments(pwal.walPath)
+	require.NoError(t, err)
+	require.Greater(t, len(before), 3)
+	sizes := map[string]int64{}
+	for _, segment := range before {
+		info, err := os.Stat(segment.path)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(2*minWALSegmentSize))
+		sizes[segment.path] = info.Size()
+	}
+
+	ctx := context.Background()
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), len(in)/2)
+	require.NoError(t, err)
+	require.NoError(t, pwal.exportThenFrontTruncateWAL(ctx, reqL))
+
+	// The segments before the first entry that wasn't exported are removed whole, the one
+	// holding it starts at it, and the ones after it are left untouched.
+	after, err := listWALSegments(pwal.walPath)
+	require.NoError(t, err)
+	require.Less(t, len(after), len(before))
+	assert.Equal(t, pwal.rWALIndex.Load(), after[0].firstIndex)
+	for _, segment := range after[1:] {
+		info, err := os.Stat(segment.path)
+		require.NoError(t, err)
+		assert.Equal(t, sizes[segment.path], info.Size())
+	}
+	assert.Equal(t, before[len(before)-len(after)+1:], after[1:])
+}
+
+func TestWAL_MaxBytes(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	const maxBytes = 1024
+	pwal, err := newWAL(&WALConfig{
+		Directory:   t.TempDir(),
+		MaxBytes:    maxBytes,
+		SegmentSize: 256,
+	}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	// The sink is down, so nothing is read from the WAL while it grows past the limit
+	var in []*prompb.WriteRequest
+	for round := 0; round < 10; round++ {
+		batch := make([]*prompb.WriteRequest, 0, 10)
+		for i := 0; i < cap(batch); i++ {
+			batch = append(batch, series("mem_used_percent", int64(len(in)+i), float64(round)))
+		}
+		in = append(in, batch...)
+		require.NoError(t, pwal.persistToWAL(batch))
+	}
+
+	// The indices are retrieved again after a failed export
+	require.NoError(t, pwal.retrieveWALIndices())
+	segments, err := listWALSegments(pwal.walPath)
+	require.NoError(t, err)
+	var total int64
+	for _, segment := range segments {
+		info, err := os.Stat(segment.path)
+		require.NoError(t, err)
+		total += info.Size()
+	}
+	assert.LessOrEqual(t, total, int64(maxBytes))
+
+	// The oldest entries were dropped, the newest ones are still read in order
+	first := pwal.rWALIndex.Load()
+	require.Greater(t, first, uint64(1))
+	for i := first; i <= pwal.wWALIndex.Load(); i++ {
+		req, err := pwal.readPrompbFromWAL(context.Background(), i)
+		require.NoError(t, err)
+		require.Equal(t, in[i-1], req)
+	}
+
+	rows, err := view.RetrieveData(mWALDroppedRequests.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(first-1), rows[0].Data.(*view.SumData).Value)
+}
+
+// TestWAL_MaxBytesWhileExporting checks that the WAL is kept within its max bytes while shards
+// or sink workers are stuck exporting, and that they resume after the entries that were removed.
+func TestWAL_MaxBytesWhileExporting(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*WALConfig)
+	}{
+		{name: "shards", modify: func(wc *WALConfig) { wc.Shards = 2 }},
+		{name: "sink_concurrency", modify: func(wc *WALConfig) { wc.SinkConcurrency = 2 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			views := MetricViews()
+			require.NoError(t, view.Register(views...))
+			t.Cleanup(func() { view.Unregister(views...) })
+
+			const maxBytes = 1024
+			walConfig := &WALConfig{
+				Directory:         t.TempDir(),
+				MaxBytes:          maxBytes,
+				SegmentSize:       256,
+				TruncateFrequency: time.Minute,
+				// Most entries are not read while the sink is stuck
+				BufferSize:     5,
+				ReadBufferSize: 5,
+			}
+			tt.modify(walConfig)
+			// The sink is stuck until the gate is closed.
+			gate := make(chan struct{})
+			var exports atomic.Int64
+			sink := func(ctx context.Context, _ []*prompb.WriteRequest) error {
+				exports.Add(1)
+				select {
+				case <-gate:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			pwal, err := newWAL(walConfig, sink)
+			require.NoError(t, err)
+			clock := newFakeClock()
+			pwal.clock = clock
+			require.NoError(t, pwal.run(contextWithLogger(context.Background(), zap.NewNop())))
+			t.Cleanup(func() {
+				assert.NoError(t, pwal.stop())
+			})
+
+			for i := 0; i < 100; i++ {
+				require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series(fmt.Sprintf("metric_%d", i), int64(i), float64(i))}))
+			}
+			require.Eventually(t, func() bool { return exports.Load() > 0 }, 5*time.Second, time.Millisecond)
+
+			walSize := func() int64 {
+				pwal.mu.Lock()
+				defer pwal.mu.Unlock()
+				segments, err := listWALSegments(pwal.walPath)
+				require.NoError(t, err)
+				var total int64
+				for _, segment := range segments {
+					info, err := os.Stat(segment.path)
+					require.NoError(t, err)
+					total += info.Size()
+				}
+				return total
+			}
+			require.Greater(t, walSize(), int64(maxBytes))
+			// The ticker may not be created yet when the clock is first advanced.
+			require.Eventually(t, func() bool {
+				clock.Advance(time.Minute)
+				return walSize() <= maxBytes
+			}, 5*time.Second, 5*time.Millisecond)
+
+			rows, err := view.RetrieveData(mWALDroppedRequests.Name())
+			require.NoError(t, err)
+			require.Len(t, rows, 1)
+			assert.Greater(t, rows[0].Data.(*view.SumData).Value, float64(0))
+
+			// Once the sink recovers, every entry that is left is exported.
+			close(gate)
+			require.Eventually(t, func() bool {
+				return pwal.sWALIndex.Load() == pwal.wWALIndex.Load()
+			}, 5*time.Second, 5*time.Millisecond)
+		})
+	}
+}
+
+func TestWAL_ReadBatch(t *testing.T) {
+	pwal, err := newWAL(&WALConfig{Directory: t.TempDir()}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	in := make([]*prompb.WriteRequest, 0, 5)
+	for i := 0; i < cap(in); i++ {
+		in = append(in, series("mem_used_percent", int64(i), float64(i)))
+	}
+	require.NoError(t, pwal.persistToWAL(in))
+
+	ctx := context.Background()
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 3)
+	require.NoError(t, err)
+	assert.Equal(t, in[:3], reqL)
+
+	// The batch is cut short at the last index
+	reqL, err = pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 3)
+	require.NoError(t, err)
+	assert.Equal(t, in[3:], reqL)
+	assert.Equal(t, uint64(len(in)+1), pwal.rWALIndex.Load())
+
+	// An undecodable entry ends the batch, the entries before it are returned
+	require.NoError(t, pwal.persistToWAL(in[:1]))
+	require.NoError(t, pwal.wal.Write(pwal.wWALIndex.Add(1), []byte("abc")))
+	require.NoError(t, pwal.persistToWAL(in[1:2]))
+	reqL, err = pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 3)
+	require.ErrorContains(t, err, "decode WAL entry 7")
+	assert.Equal(t, in[:1], reqL)
+	assert.Equal(t, uint64(len(in)+2), pwal.rWALIndex.Load())
+}
+
+func TestWAL_Backlog(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	pwal, err := newWAL(&WALConfig{Directory: t.TempDir()}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	in := make([]*prompb.WriteRequest, 0, 5)
+	for i := 0; i < cap(in); i++ {
+		in = append(in, series("mem_used_percent", int64(i), float64(i)))
+	}
+	require.NoError(t, pwal.persistToWAL(in))
+
+	ctx := context.Background()
+	pwal.recordBacklog(ctx)
+	rows, err := view.RetrieveData(mWALBacklog.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(5), rows[0].Data.(*view.LastValueData).Value)
+
+	reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), 3)
+	require.NoError(t, err)
+	require.NoError(t, pwal.exportThenFrontTruncateWAL(ctx, reqL))
+	assert.Equal(t, uint64(3), pwal.sWALIndex.Load())
+
+	rows, err = view.RetrieveData(mWALBacklog.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(2), rows[0].Data.(*view.LastValueData).Value)
+
+	rows, err = view.RetrieveData(mWALReplayedRequests.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, float64(3), rows[0].Data.(*view.SumData).Value)
+}
+
+func TestWAL_Exemplars(t *testing.T) {
+	in := []*prompb.WriteRequest{
+		{
+			Timeseries: []prompb.TimeSeries{
+				{
+					Labels:  []prompb.Label{{Name: "__name__", Value: "http_server_duration_bucket"}, {Name: "le", Value: "0.5"}},
+					Samples: []prompb.Sample{{Value: 3, Timestamp: 100}},
+					Exemplars: []prompb.Exemplar{
+						{
+							Labels:    []prompb.Label{{Name: "trace_id", Value: "4bf92f3577b34da6a3ce929d0e0e4736"}, {Name: "span_id", Value: "00f067aa0ba902b7"}},
+							Value:     0.42,
+							Timestamp: 99,
+						},
+					},
+				},
+				{
+					// A series may carry exemplars only
+					Labels: []prompb.Label{{Name: "__name__", Value: "http_server_duration_bucket"}, {Name: "le", Value: "1"}},
+					Exemplars: []prompb.Exemplar{
+						{
+							Labels:    []prompb.Label{{Name: "trace_id", Value: "0af7651916cd43dd8448eb211c80319c"}},
+							Value:     0.87,
+							Timestamp: 98,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, dedupe := range []bool{false, true} {
+		t.Run(fmt.Sprintf("dedupe_samples=%t", dedupe), func(t *testing.T) {
+			out := make(chan *prompb.WriteRequest, len(in))
+			sink := func(_ context.Context, reqs []*prompb.WriteRequest) error {
+				for _, req := range reqs {
+					out <- req
+				}
+				return nil
+			}
+
+			pwal, err := newWAL(&WALConfig{Directory: t.TempDir(), DedupeSamples: dedupe}, sink)
+			require.NoError(t, err)
+			require.NoError(t, pwal.retrieveWALIndices())
+			t.Cleanup(func() {
+				assert.NoError(t, pwal.stop())
+			})
+			require.NoError(t, pwal.persistToWAL(in))
+
+			// The replayed entry holds the exemplars
+			ctx := context.Background()
+			reqL, err := pwal.readPrompbBatchFromWAL(ctx, pwal.rWALIndex.Load(), len(in))
+			require.NoError(t, err)
+			assert.Equal(t, in, reqL)
+
+			// The sink receives the exemplars
+			require.NoError(t, pwal.exportThenFrontTruncateWAL(ctx, reqL))
+			select {
+			case req := <-out:
+				assert.Equal(t, in[0], req)
+			default:
+				t.Fatal("the sink received no request")
+			}
+		})
+	}
+}
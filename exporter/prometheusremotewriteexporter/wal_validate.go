@@ -0,0 +1,108 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/multierr"
+)
+
+// defaultWALSegmentSize is the size that segment files are rolled over at without a SegmentSize.
+const defaultWALSegmentSize = 20 * 1024 * 1024
+
+func (wc *WALConfig) segmentSize() int {
+	if wc.SegmentSize > 0 {
+		return wc.SegmentSize
+	}
+	return defaultWALSegmentSize
+}
+
+// Validate checks the WAL settings on their own and against each other, and whether the
+// directory can be written to. It is called along with the validation of the exporter
+// config, and returns every problem it found at once.
+func (wc *WALConfig) Validate() error {
+	var errs error
+	if wc.Directory == "" {
+		errs = multierr.Append(errs, errors.New("WAL directory must be set"))
+	} else if err := checkWritable(wc.path()); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	if wc.BufferSize < 0 {
+		errs = multierr.Append(errs, errors.New("WAL buffer size can't be negative"))
+	}
+	if wc.BufferSizeBytes < 0 {
+		errs = multierr.Append(errs, errors.New("WAL buffer size bytes can't be negative"))
+	}
+	if wc.BufferSize > 0 && wc.BufferSizeBytes > 0 {
+		errs = multierr.Append(errs, errors.New("WAL buffer size and buffer size bytes can't both be set"))
+	}
+	if wc.ReadBufferSize < 0 {
+		errs = multierr.Append(errs, errors.New("WAL read buffer size can't be negative"))
+	}
+	if wc.TruncateFrequency < 0 {
+		errs = multierr.Append(errs, errors.New("WAL truncate frequency can't be negative"))
+	}
+
+	if wc.MaxRetainedSegments < 0 {
+		errs = multierr.Append(errs, errors.New("WAL max retained segments can't be negative"))
+	}
+	if wc.SegmentSize != 0 && wc.SegmentSize < minWALSegmentSize {
+		errs = multierr.Append(errs, fmt.Errorf("WAL segment size can't be less than %d bytes", minWALSegmentSize))
+	}
+	if wc.MaxBytes < 0 {
+		errs = multierr.Append(errs, errors.New("WAL max bytes can't be negative"))
+	} else if wc.MaxBytes > 0 && wc.MaxBytes < int64(wc.segmentSize()) {
+		// Segments are only removed a whole file at a time, so a single segment would exceed the limit
+		errs = multierr.Append(errs, fmt.Errorf("WAL max bytes can't be less than the segment size of %d bytes", wc.segmentSize()))
+	}
+
+	if err := wc.Retry.validate(); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	if err := wc.CircuitBreaker.validate(); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	if wc.SinkConcurrency < 0 {
+		errs = multierr.Append(errs, errors.New("WAL sink concurrency can't be negative"))
+	}
+	if wc.Shards < 0 {
+		errs = multierr.Append(errs, errors.New("WAL shards can't be negative"))
+	}
+	if wc.Shards > 1 && wc.SinkConcurrency > 1 {
+		errs = multierr.Append(errs, errors.New("WAL shards and sink concurrency can't both be set"))
+	}
+
+	if wc.MaxSamplesPerSend < 0 {
+		errs = multierr.Append(errs, errors.New("WAL max samples per send can't be negative"))
+	}
+
+	if wc.MaxTrackedSeries < 0 {
+		errs = multierr.Append(errs, errors.New("WAL max tracked series can't be negative"))
+	}
+	if wc.DropOutOfOrderSamples && !wc.SortSamplesByTimestamp {
+		errs = multierr.Append(errs, errors.New("WAL out of order samples can only be dropped when samples are sorted by timestamp"))
+	}
+
+	if err := validateWALCompression(wc.Compression); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	if err := validateWALFutureSamples(wc.FutureTolerance, wc.FutureSampleAction); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	noSync, _, err := parseWALSync(wc.Sync)
+	if err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	if wc.FlushInterval < 0 || wc.FlushCount < 0 {
+		errs = multierr.Append(errs, errors.New("WAL flush interval and flush count can't be negative"))
+	} else if err == nil && !noSync && (wc.FlushInterval > 0 || wc.FlushCount > 0) {
+		errs = multierr.Append(errs, fmt.Errorf("WAL flush interval and flush count only apply with a sync of %s or %s<duration>", walSyncNone, walSyncIntervalPrefix))
+	}
+	return errs
+}
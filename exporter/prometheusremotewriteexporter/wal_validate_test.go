@@ -0,0 +1,203 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+)
+
+func TestWALConfig_Validate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0600))
+
+	tests := []struct {
+		name   string
+		modify func(*WALConfig)
+		err    string
+	}{
+		{
+			name:   "valid",
+			modify: func(*WALConfig) {},
+		},
+		{
+			name:   "missing directory",
+			modify: func(wc *WALConfig) { wc.Directory = "" },
+			err:    "WAL directory must be set",
+		},
+		{
+			name:   "directory under a file",
+			modify: func(wc *WALConfig) { wc.Directory = filepath.Join(file, "wal") },
+			err:    "WAL directory is not writable",
+		},
+		{
+			name:   "negative buffer size",
+			modify: func(wc *WALConfig) { wc.BufferSize = -1 },
+			err:    "WAL buffer size can't be negative",
+		},
+		{
+			name:   "negative buffer size bytes",
+			modify: func(wc *WALConfig) { wc.BufferSizeBytes = -1 },
+			err:    "WAL buffer size bytes can't be negative",
+		},
+		{
+			name: "buffer size and buffer size bytes",
+			modify: func(wc *WALConfig) {
+				wc.BufferSize = 10
+				wc.BufferSizeBytes = 1024
+			},
+			err: "WAL buffer size and buffer size bytes can't both be set",
+		},
+		{
+			name:   "negative read buffer size",
+			modify: func(wc *WALConfig) { wc.ReadBufferSize = -1 },
+			err:    "WAL read buffer size can't be negative",
+		},
+		{
+			name:   "negative truncate frequency",
+			modify: func(wc *WALConfig) { wc.TruncateFrequency = -time.Second },
+			err:    "WAL truncate frequency can't be negative",
+		},
+		{
+			name:   "negative max retained segments",
+			modify: func(wc *WALConfig) { wc.MaxRetainedSegments = -1 },
+			err:    "WAL max retained segments can't be negative",
+		},
+		{
+			name:   "small segment size",
+			modify: func(wc *WALConfig) { wc.SegmentSize = 1024 },
+			err:    "WAL segment size can't be less than 4096 bytes",
+		},
+		{
+			name:   "negative max bytes",
+			modify: func(wc *WALConfig) { wc.MaxBytes = -1 },
+			err:    "WAL max bytes can't be negative",
+		},
+		{
+			name: "max bytes less than segment size",
+			modify: func(wc *WALConfig) {
+				wc.SegmentSize = 1 << 20
+				wc.MaxBytes = 1 << 16
+			},
+			err: "WAL max bytes can't be less than the segment size of 1048576 bytes",
+		},
+		{
+			name:   "max bytes less than default segment size",
+			modify: func(wc *WALConfig) { wc.MaxBytes = 1 << 20 },
+			err:    "WAL max bytes can't be less than the segment size of 20971520 bytes",
+		},
+		{
+			name:   "invalid retry",
+			modify: func(wc *WALConfig) { wc.Retry.RandomizationFactor = 2 },
+			err:    "WAL retry randomization factor must be between 0 and 1",
+		},
+		{
+			name:   "invalid circuit breaker",
+			modify: func(wc *WALConfig) { wc.CircuitBreaker.Cooldown = -time.Second },
+			err:    "WAL circuit breaker cooldown can't be negative",
+		},
+		{
+			name:   "negative sink concurrency",
+			modify: func(wc *WALConfig) { wc.SinkConcurrency = -1 },
+			err:    "WAL sink concurrency can't be negative",
+		},
+		{
+			name:   "negative shards",
+			modify: func(wc *WALConfig) { wc.Shards = -1 },
+			err:    "WAL shards can't be negative",
+		},
+		{
+			name: "shards and sink concurrency",
+			modify: func(wc *WALConfig) {
+				wc.Shards = 2
+				wc.SinkConcurrency = 2
+			},
+			err: "WAL shards and sink concurrency can't both be set",
+		},
+		{
+			name:   "negative max samples per send",
+			modify: func(wc *WALConfig) { wc.MaxSamplesPerSend = -1 },
+			err:    "WAL max samples per send can't be negative",
+		},
+		{
+			name:   "negative max tracked series",
+			modify: func(wc *WALConfig) { wc.MaxTrackedSeries = -1 },
+			err:    "WAL max tracked series can't be negative",
+		},
+		{
+			name:   "drop out of order samples without sort",
+			modify: func(wc *WALConfig) { wc.DropOutOfOrderSamples = true },
+			err:    "WAL out of order samples can only be dropped when samples are sorted by timestamp",
+		},
+		{
+			name:   "invalid compression",
+			modify: func(wc *WALConfig) { wc.Compression = "gzip" },
+			err:    `WAL compression "gzip" must be one of none, snappy or zstd`,
+		},
+		{
+			name:   "negative future tolerance",
+			modify: func(wc *WALConfig) { wc.FutureTolerance = -time.Second },
+			err:    "WAL future tolerance can't be negative",
+		},
+		{
+			name:   "invalid sync",
+			modify: func(wc *WALConfig) { wc.Sync = "sometimes" },
+			err:    `WAL sync "sometimes" must be one of always, interval:<duration> or none`,
+		},
+		{
+			name:   "negative flush interval",
+			modify: func(wc *WALConfig) { wc.FlushInterval = -time.Second },
+			err:    "WAL flush interval and flush count can't be negative",
+		},
+		{
+			name:   "flush count with sync always",
+			modify: func(wc *WALConfig) { wc.FlushCount = 10 },
+			err:    "WAL flush interval and flush count only apply with a sync of none or interval:<duration>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wc := &WALConfig{Directory: dir}
+			tt.modify(wc)
+			err := wc.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Len(t, multierr.Errors(err), 1)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestWALConfig_ValidateAggregatesErrors(t *testing.T) {
+	wc := &WALConfig{
+		Directory:  t.TempDir(),
+		BufferSize: -1,
+		MaxBytes:   -1,
+		Sync:       "sometimes",
+	}
+	err := wc.Validate()
+	assert.Equal(t, []string{
+		"WAL buffer size can't be negative",
+		"WAL max bytes can't be negative",
+		`WAL sync "sometimes" must be one of always, interval:<duration> or none`,
+	}, errorStrings(multierr.Errors(err)))
+}
+
+func errorStrings(errs []error) []string {
+	var s []string
+	for _, err := range errs {
+		s = append(s, err.Error())
+	}
+	return s
+}
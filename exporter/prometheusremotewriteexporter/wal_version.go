@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// WAL entries start, after their shard if any, with a header byte followed by the version of
+// their format as a uvarint, and then the entry itself, which may be compressed. Like the other
+// headers, the header encodes field number 0, which a marshalled WriteRequest never starts with.
+// Entries without a version, such as those written before it was recorded, are version 1.
+const walHeaderVersion byte = 0x04
+
+// walEntryVersion is the version of the format of the entries that persistToWAL writes, and
+// the latest one that can be read. It changes when entries written with it can't be read by
+// an exporter that only knows an earlier version.
+const walEntryVersion uint64 = 1
+
+// ErrUnsupportedWALVersion is matched by the error of reading a WAL entry whose format is more
+// recent than the one this exporter reads.
+var ErrUnsupportedWALVersion = errors.New("unsupported WAL entry version")
+
+// UnsupportedWALVersionError is the error of reading a WAL entry whose format is more recent than
+// walEntryVersion, such as one written by a newer collector before a downgrade. It matches
+// ErrUnsupportedWALVersion.
+type UnsupportedWALVersionError struct {
+	// Version is the version of the format of the entry.
+	Version uint64
+}
+
+func (e *UnsupportedWALVersionError) Error() string {
+	return fmt.Sprintf("%v %d, the latest supported version is %d", ErrUnsupportedWALVersion, e.Version, walEntryVersion)
+}
+
+func (e *UnsupportedWALVersionError) Is(target error) bool {
+	return target == ErrUnsupportedWALVersion
+}
+
+// versionWALEntry prefixes the entry with the version it is written with.
+func versionWALEntry(version uint64, entry []byte) []byte {
+	versioned := make([]byte, 0, 1+binary.MaxVarintLen64+len(entry))
+	versioned = append(versioned, walHeaderVersion)
+	versioned = binary.AppendUvarint(versioned, version)
+	return append(versioned, entry...)
+}
+
+// unversionWALEntry returns the entry without its version, or an *UnsupportedWALVersionError if
+// the version is more recent than walEntryVersion.
+func unversionWALEntry(entry []byte) ([]byte, error) {
+	if len(entry) == 0 || entry[0] != walHeaderVersion {
+		return entry, nil
+	}
+	version, n := binary.Uvarint(entry[1:])
+	if n <= 0 {
+		return nil, errors.New("invalid version header")
+	}
+	if version > walEntryVersion {
+		return nil, &UnsupportedWALVersionError{Version: version}
+	}
+	return entry[1+n:], nil
+}
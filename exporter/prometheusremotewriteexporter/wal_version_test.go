@@ -0,0 +1,142 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/wal"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWAL_UnsupportedVersion(t *testing.T) {
+	pwal, err := newWAL(&WALConfig{Directory: t.TempDir(), Compression: walCompressionSnappy, Shards: 2}, doNothingExportSink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	in := series("mem_used_percent", 100, 1)
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{in}))
+	protoBlob, err := proto.Marshal(in)
+	require.NoError(t, err)
+	// Entries written before they had a version are version 1.
+	require.NoError(t, pwal.wal.Write(2, protoBlob))
+	// An entry written by a future version, whose format can't be known.
+	require.NoError(t, pwal.wal.Write(3, tagWALEntry(1, versionWALEntry(walEntryVersion+1, []byte{0xff, 0xff}))))
+
+	ctx := context.Background()
+	for _, index := range []uint64{1, 2} {
+		got, err := pwal.readPrompbFromWAL(ctx, index)
+		require.NoError(t, err)
+		assert.Equal(t, in, got)
+	}
+
+	_, err = pwal.readPrompbFromWAL(ctx, 3)
+	require.ErrorIs(t, err, ErrUnsupportedWALVersion)
+	var versionErr *UnsupportedWALVersionError
+	require.True(t, errors.As(err, &versionErr), err)
+	assert.Equal(t, walEntryVersion+1, versionErr.Version)
+	assert.EqualError(t, err, "decode WAL entry 3: unsupported WAL entry version 2, the latest supported version is 1")
+}
+
+// TestWAL_RepairUnsupportedVersion checks that entries written by a newer version are not
+// repaired as corrupt ones, as when the collector is downgraded.
+func TestWAL_RepairUnsupportedVersion(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		garbage []byte
+	}{
+		{name: "last_entry"},
+		// A torn entry follows the one of the newer version
+		{name: "torn_entry_after", garbage: binary.AppendUvarint(nil, 64)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			pwal, err := newWAL(&WALConfig{Directory: dir}, doNothingExportSink)
+			require.NoError(t, err)
+			require.NoError(t, pwal.retrieveWALIndices())
+			require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{series("mem_used_percent", 1, 10)}))
+			require.NoError(t, pwal.wal.Write(2, tagWALEntry(1, versionWALEntry(walEntryVersion+1, []byte{0xff, 0xff}))))
+			require.NoError(t, pwal.stop())
+
+			segments, err := listWALSegments(pwal.walPath)
+			require.NoError(t, err)
+			tail := segments[len(segments)-1].path
+			f, err := os.OpenFile(tail, os.O_APPEND|os.O_WRONLY, 0600)
+			require.NoError(t, err)
+			_, err = f.Write(tt.garbage)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+			before, err := os.ReadFile(tail)
+			require.NoError(t, err)
+
+			pwal, err = newWAL(&WALConfig{Directory: dir, RepairOnCorruption: true}, doNothingExportSink)
+			require.NoError(t, err)
+			err = pwal.retrieveWALIndices()
+			require.ErrorIs(t, err, ErrUnsupportedWALVersion)
+			assert.NotErrorIs(t, err, wal.ErrCorrupt)
+
+			after, err := os.ReadFile(tail)
+			require.NoError(t, err)
+			assert.Equal(t, before, after)
+		})
+	}
+}
+
+// TestWAL_RunStopsAtUnsupportedVersion checks that an entry written by a newer version stops
+// the export of the WAL once, instead of being read again in a loop.
+func TestWAL_RunStopsAtUnsupportedVersion(t *testing.T) {
+	var exported atomic.Int64
+	sink := func(_ context.Context, reqL []*prompb.WriteRequest) error {
+		exported.Add(int64(len(reqL)))
+		return nil
+	}
+	pwal, err := newWAL(&WALConfig{Directory: t.TempDir(), BufferSize: 1}, sink)
+	require.NoError(t, err)
+	require.NoError(t, pwal.retrieveWALIndices())
+	in := series("mem_used_percent", 100, 1)
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{in}))
+	require.NoError(t, pwal.wal.Write(2, tagWALEntry(1, versionWALEntry(walEntryVersion+1, []byte{0xff, 0xff}))))
+	pwal.wWALIndex.Store(2)
+	require.NoError(t, pwal.persistToWAL([]*prompb.WriteRequest{in}))
+
+	core, logs := observer.New(zapcore.ErrorLevel)
+	require.NoError(t, pwal.run(contextWithLogger(context.Background(), zap.New(core))))
+	t.Cleanup(func() {
+		assert.NoError(t, pwal.stop())
+	})
+
+	require.Eventually(t, func() bool { return logs.Len() > 0 }, 5*time.Second, time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "WAL entry was written by a newer version, the WAL is no longer exported", logs.All()[0].Message)
+	assert.Equal(t, int64(1), exported.Load())
+}
+
+func TestUnversionWALEntry(t *testing.T) {
+	entry, err := unversionWALEntry(versionWALEntry(walEntryVersion, []byte{walHeaderZstd, 1}))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{walHeaderZstd, 1}, entry)
+
+	entry, err = unversionWALEntry([]byte{walHeaderZstd, 1})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{walHeaderZstd, 1}, entry)
+
+	_, err = unversionWALEntry([]byte{walHeaderVersion})
+	assert.EqualError(t, err, "invalid version header")
+}
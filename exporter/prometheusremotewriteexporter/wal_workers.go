@@ -0,0 +1,140 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// sentTracker advances the sent index of the WAL over ranges of entries that are exported
+// out of order, once every entry before a range was exported too.
+type sentTracker struct {
+	mu sync.Mutex
+	// pending maps the first index of an exported range, that can't be marked as sent yet,
+	// to its last index.
+	pending map[uint64]uint64
+}
+
+// complete records that the entries from first to last were exported.
+func (prwe *prweWAL) complete(ctx context.Context, tracker *sentTracker, first, last uint64) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	tracker.pending[first] = last
+	for advanced := true; advanced; {
+		advanced = false
+		next := prwe.sWALIndex.Load() + 1
+		// A range starts before the sent index if its first entries were removed to stay
+		// within max bytes.
+		for start, end := range tracker.pending {
+			if start > next {
+				continue
+			}
+			delete(tracker.pending, start)
+			storeMax(prwe.sWALIndex, end)
+			advanced = true
+		}
+	}
+	prwe.writeCheckpoint()
+	prwe.record(ctx, mWALReplayedRequests.M(int64(last-first+1)))
+	prwe.recordBacklog(ctx)
+}
+
+// runSinkWorkers exports the entries of the WAL with SinkConcurrency workers, each reading
+// the next range of consecutive entries and exporting it, until ctx is done, the WAL is
+// stopped or a worker fails. The WAL is truncated up to the sent index every truncate frequency.
+func (prwe *prweWAL) runSinkWorkers(ctx context.Context, signalStart func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Entries that were exported before a restart are not read again.
+	prwe.rWALIndex.Store(max(prwe.rWALIndex.Load(), prwe.sWALIndex.Load()+1))
+	prwe.sWALIndex.Store(prwe.rWALIndex.Load() - 1)
+	tracker := &sentTracker{pending: map[uint64]uint64{}}
+
+	var (
+		wg     sync.WaitGroup
+		errMu  sync.Mutex
+		errs   error
+		readMu sync.Mutex
+	)
+	fail := func(err error) {
+		select {
+		case <-prwe.stopChan:
+			// Reads and truncation fail once the WAL is closed, which isn't an error.
+			cancel()
+			return
+		default:
+		}
+		errMu.Lock()
+		errs = multierr.Append(errs, err)
+		errMu.Unlock()
+		cancel()
+	}
+
+	count := min(prwe.walConfig.readBufferSize(), prwe.walConfig.batchCount())
+	for i := 0; i < prwe.walConfig.sinkConcurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				// Ranges are read one at a time, so that a single worker waits for
+				// writes on rNotify and the ranges follow each other.
+				readMu.Lock()
+				first := prwe.rWALIndex.Load()
+				buf := &walBuffer{maxCount: count, maxBytes: prwe.walConfig.BufferSizeBytes}
+				err := prwe.readBatchFromWAL(ctx, first, count, buf.visit)
+				readMu.Unlock()
+				if errors.Is(err, errWALEntriesRemoved) {
+					// The read index was moved past them
+					continue
+				}
+				reqL := buf.reqL
+
+				if len(reqL) > 0 {
+					if errS := prwe.exportWithRetry(ctx, first, reqL); errS != nil {
+						fail(errS)
+						return
+					}
+					prwe.complete(ctx, tracker, first, first+uint64(len(reqL))-1)
+				}
+				if err != nil {
+					if ctx.Err() == nil {
+						fail(err)
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	signalStart()
+
+	ticker := prwe.clock.NewTicker(prwe.walConfig.truncateFrequency())
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-prwe.stopChan:
+			done = true
+		case <-ticker.Chan():
+			if err := prwe.syncAndTruncateFront(prwe.sWALIndex.Load() + 1); err != nil {
+				fail(err)
+			} else if err = prwe.truncateOverMaxBytes(); err != nil {
+				fail(err)
+			}
+		}
+	}
+	cancel()
+	wg.Wait()
+
+	errMu.Lock()
+	defer errMu.Unlock()
+	return errs
+}
@@ -0,0 +1,49 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/prometheusremotewriteexporter"
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/snappy"
+)
+
+const (
+	wireCompressionSnappyBlock  = "snappy-block"
+	wireCompressionSnappyStream = "snappy-stream"
+	wireCompressionNone         = "none"
+)
+
+func validateWireCompression(compression string) error {
+	switch compression {
+	case "", wireCompressionSnappyBlock, wireCompressionSnappyStream, wireCompressionNone:
+		return nil
+	default:
+		return fmt.Errorf("invalid wire compression %q, must be %q, %q or %q",
+			compression, wireCompressionSnappyBlock, wireCompressionSnappyStream, wireCompressionNone)
+	}
+}
+
+// encodeWireBody encodes a marshalled WriteRequest into the body it is sent with, and returns the
+// Content-Encoding of the body, which is empty when it isn't compressed.
+func encodeWireBody(compression string, data []byte) ([]byte, string, error) {
+	switch compression {
+	case wireCompressionNone:
+		return data, "", nil
+	case wireCompressionSnappyStream:
+		var buf bytes.Buffer
+		w := snappy.NewBufferedWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, "", err
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "x-snappy-framed", nil
+	default:
+		buf := make([]byte, len(data), cap(data))
+		return snappy.Encode(buf, data), "snappy", nil
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewriteexporter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWireCompression(t *testing.T) {
+	writeReq := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "test_metric"}, {Name: "job", Value: "test"}},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}, {Value: 2, Timestamp: 2000}},
+		}},
+	}
+
+	tests := []struct {
+		name            string
+		compression     string
+		contentEncoding string
+		decode          func([]byte) ([]byte, error)
+	}{
+		{
+			name:            "default",
+			contentEncoding: "snappy",
+			decode:          func(body []byte) ([]byte, error) { return snappy.Decode(nil, body) },
+		},
+		{
+			name:            "snappy block",
+			compression:     wireCompressionSnappyBlock,
+			contentEncoding: "snappy",
+			decode:          func(body []byte) ([]byte, error) { return snappy.Decode(nil, body) },
+		},
+		{
+			name:            "snappy stream",
+			compression:     wireCompressionSnappyStream,
+			contentEncoding: "x-snappy-framed",
+			decode: func(body []byte) ([]byte, error) {
+				return io.ReadAll(snappy.NewReader(bytes.NewReader(body)))
+			},
+		},
+		{
+			name:        "none",
+			compression: wireCompressionNone,
+			decode:      func(body []byte) ([]byte, error) { return body, nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received prompb.WriteRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.contentEncoding, r.Header.Get("Content-Encoding"))
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				data, err := tt.decode(body)
+				assert.NoError(t, err)
+				assert.NoError(t, proto.Unmarshal(data, &received))
+			}))
+			defer server.Close()
+			endpointURL, err := url.Parse(server.URL)
+			require.NoError(t, err)
+
+			prwe := &prwExporter{client: server.Client(), wireCompression: tt.compression}
+			require.NoError(t, prwe.execute(context.Background(), endpointURL, nil, writeReq))
+			assert.Equal(t, *writeReq, received)
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/fingerprint"
+)
+
+const (
+	archiveModeNone    = "none"
+	archiveModeMembers = "members"
+)
+
+// archiveMemberSeparator separates the path of an archive from the name of a member
+// in the synthetic path of the member
+const archiveMemberSeparator = "//"
+
+func archiveMemberPath(archive, member string) string {
+	return archive + archiveMemberSeparator + member
+}
+
+// path returns the path of the file, or the synthetic path of the archive member that is read from it
+func (r *Reader) path() string {
+	if r.ArchiveMember != "" {
+		return archiveMemberPath(r.file.Name(), r.ArchiveMember)
+	}
+	return r.file.Name()
+}
+
+// isArchive reports whether the file at path is a tar or zip archive, by its extension
+func isArchive(path string) bool {
+	return isTar(path) || strings.HasSuffix(path, ".zip")
+}
+
+func isTar(path string) bool {
+	return strings.HasSuffix(path, ".tar") || isTarGzip(path)
+}
+
+func isTarGzip(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// walkArchive calls fn with the name of each regular file in the archive, and a function
+// that opens its content, until fn returns false
+func walkArchive(file *os.File, fn func(name string, open func() (io.Reader, error)) bool) error {
+	if _, err := file.Seek(0, 0); err != nil {
+		return err
+	}
+	if !isTar(file.Name()) {
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(file, info.Size())
+		if err != nil {
+			return fmt.Errorf("read zip: %w", err)
+		}
+		for _, member := range zr.File {
+			if !member.Mode().IsRegular() {
+				continue
+			}
+			open := func() (io.Reader, error) {
+				return member.Open()
+			}
+			if !fn(member.Name, open) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	var stream io.Reader = file
+	if isTarGzip(file.Name()) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("read gzip header: %w", err)
+		}
+		stream = gz
+	}
+	tr := tar.NewReader(stream)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		open := func() (io.Reader, error) {
+			return tr, nil
+		}
+		if !fn(hdr.Name, open) {
+			return nil
+		}
+	}
+}
+
+// archiveMembers lists the names of the regular files in an archive
+func archiveMembers(file *os.File) ([]string, error) {
+	var members []string
+	err := walkArchive(file, func(name string, _ func() (io.Reader, error)) bool {
+		members = append(members, name)
+		return true
+	})
+	return members, err
+}
+
+// openArchiveMember returns the content of a member of an archive. Tar archives cannot be
+// positioned at a member, so they are read from the start up to the member.
+func openArchiveMember(file *os.File, member string) (io.Reader, error) {
+	var stream io.Reader
+	var openErr error
+	err := walkArchive(file, func(name string, open func() (io.Reader, error)) bool {
+		if name != member {
+			return true
+		}
+		stream, openErr = open()
+		return false
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case openErr != nil:
+		return nil, fmt.Errorf("open archive member %s: %w", member, openErr)
+	case stream == nil:
+		return nil, fmt.Errorf("archive member %s not found", member)
+	}
+	return stream, nil
+}
+
+// makeArchiveReaders creates a reader for each member of an archive, each with its own handle
+// of the archive. The members of a known archive are read on from the offsets of their
+// readers, the members of a new archive are listed from it.
+func (m *Manager) makeArchiveReaders(ctx context.Context, f *openedFile) []*Reader {
+	defer func() {
+		if err := f.file.Close(); err != nil {
+			m.Errorf("problem closing file %s", f.file.Name())
+		}
+	}()
+
+	oldReaders := m.findArchiveMatches(f.fp)
+	var members []string
+	if len(oldReaders) == 0 {
+		var err error
+		if members, err = archiveMembers(f.file); err != nil {
+			m.Errorw("Failed to list archive members", "path", f.file.Name(), zap.Error(err))
+			return nil
+		}
+	}
+
+	readers := make([]*Reader, 0, len(oldReaders)+len(members))
+	for _, oldReader := range oldReaders {
+		if r := m.makeArchiveReader(ctx, f, oldReader, oldReader.ArchiveMember); r != nil {
+			readers = append(readers, r)
+		}
+	}
+	for _, member := range members {
+		if r := m.makeArchiveReader(ctx, f, nil, member); r != nil {
+			readers = append(readers, r)
+		}
+	}
+	return readers
+}
+
+// makeArchiveReader creates the reader of a member of an archive from its known reader, if any
+func (m *Manager) makeArchiveReader(ctx context.Context, f *openedFile, oldReader *Reader, member string) *Reader {
+	file, err := os.Open(f.file.Name()) // #nosec - operator must read in files defined by user
+	if err != nil {
+		m.Debugw("Failed to open file", zap.Error(err))
+		return nil
+	}
+	var r *Reader
+	if oldReader != nil {
+		r, err = m.readerFactory.copy(oldReader, file)
+	} else if r, err = m.readerFactory.newReaderBuilder().
+		withFile(file).
+		withFingerprint(f.fp.Copy()).
+		withArchiveMember(member).
+		build(); err == nil {
+		m.fileOpened(ctx, r)
+	}
+	if err != nil {
+		m.Errorw("Failed to create reader", zap.Error(err))
+		if closeErr := file.Close(); closeErr != nil {
+			m.Errorf("problem closing file %s", file.Name())
+		}
+		return nil
+	}
+	return r
+}
+
+// findArchiveMatches removes the readers of the members of the archive with the
+// fingerprint from the known files, and returns them
+func (m *Manager) findArchiveMatches(fp *fingerprint.Fingerprint) []*Reader {
+	var matches []*Reader
+	known := m.knownFiles[:0]
+	for _, oldReader := range m.knownFiles {
+		if oldReader.ArchiveMember != "" && fp.StartsWith(oldReader.Fingerprint) {
+			matches = append(matches, oldReader)
+			continue
+		}
+		known = append(known, oldReader)
+	}
+	m.knownFiles = known
+	return matches
+}
@@ -0,0 +1,149 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+// archiveMember is the name and content of a member of a test archive
+type archiveMember struct {
+	name    string
+	content string
+}
+
+func tarGzip(t *testing.T, members ...archiveMember) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	require.NoError(t, w.WriteHeader(&tar.Header{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for _, member := range members {
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: member.name, Typeflag: tar.TypeReg, Mode: 0600, Size: int64(len(member.content))}))
+		_, err := w.Write([]byte(member.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, members ...archiveMember) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, member := range members {
+		f, err := w.Create(member.name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(member.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveMembers(t *testing.T) {
+	testCases := []struct {
+		name    string
+		archive func(*testing.T, ...archiveMember) []byte
+	}{
+		{"bundle.tar.gz", tarGzip},
+		{"bundle.zip", zipArchive},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			cfg := NewConfig().includeDir(tempDir)
+			cfg.StartAt = "beginning"
+			cfg.ArchiveMode = archiveModeMembers
+			cfg.IncludeFilePath = true
+			operator, emitCalls := buildTestManager(t, cfg)
+			operator.persister = testutil.NewMockPersister("test")
+			defer func() {
+				require.NoError(t, operator.Stop())
+			}()
+
+			path := filepath.Join(tempDir, tc.name)
+			appendFile(t, path, tc.archive(t,
+				archiveMember{"logs/a.log", "a1\na2\n"},
+				archiveMember{"logs/b.log", "b1\n"},
+			))
+
+			operator.poll(context.Background())
+			tokens := map[string][]byte{}
+			paths := map[string]any{}
+			for i := 0; i < 3; i++ {
+				call := <-emitCalls
+				name := call.attrs[logFileName].(string)
+				tokens[name] = append(tokens[name], call.token...)
+				paths[name] = call.attrs[logFilePath]
+			}
+			require.Equal(t, map[string][]byte{
+				tc.name + "//logs/a.log": []byte("a1a2"),
+				tc.name + "//logs/b.log": []byte("b1"),
+			}, tokens)
+			require.Equal(t, map[string]any{
+				tc.name + "//logs/a.log": path + "//logs/a.log",
+				tc.name + "//logs/b.log": path + "//logs/b.log",
+			}, paths)
+
+			// Each member is read once, and tracked with the fingerprint of the archive
+			operator.poll(context.Background())
+			expectNoTokens(t, emitCalls)
+			require.Len(t, operator.knownFiles, 2)
+			for _, r := range operator.knownFiles {
+				require.Equal(t, operator.knownFiles[0].Fingerprint, r.Fingerprint)
+			}
+		})
+	}
+}
+
+func TestArchiveMembersRestart(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.ArchiveMode = archiveModeMembers
+	cfg.PollInterval = 10 * time.Millisecond
+	persister := testutil.NewMockPersister("test")
+
+	appendFile(t, filepath.Join(tempDir, "bundle.tar.gz"), tarGzip(t,
+		archiveMember{"a.log", "a1\n"},
+		archiveMember{"b.log", "b1\n"},
+	))
+
+	op1, emitCalls1 := buildTestManager(t, cfg)
+	require.NoError(t, op1.Start(persister))
+	waitForTokens(t, emitCalls1, [][]byte{[]byte("a1"), []byte("b1")})
+	require.NoError(t, op1.Stop())
+
+	// The offsets of the members are restored, so they are not read again
+	op2, emitCalls2 := buildTestManager(t, cfg)
+	require.NoError(t, op2.Start(persister))
+	expectNoTokens(t, emitCalls2)
+	require.NoError(t, op2.Stop())
+}
+
+func TestArchiveModeNone(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+
+	path := filepath.Join(tempDir, "bundle.tar")
+	appendFile(t, path, []byte("not an archive\n"))
+
+	// Without archive_mode, archives are read like any other file
+	operator.poll(context.Background())
+	waitForTokenWithAttributes(t, emitCalls, []byte("not an archive"), map[string]any{logFileName: "bundle.tar"})
+}
@@ -0,0 +1,186 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
+)
+
+// BatchConfig groups the tokens read from a file into batches that are emitted at once
+type BatchConfig struct {
+	MaxTokens     int             `mapstructure:"max_tokens,omitempty"`
+	MaxBytes      helper.ByteSize `mapstructure:"max_bytes,omitempty"`
+	FlushInterval time.Duration   `mapstructure:"flush_interval,omitempty"`
+}
+
+// validate returns an error describing why the configuration is invalid, or nil if the configuration is valid.
+func (c *BatchConfig) validate() error {
+	if c.MaxTokens < 0 {
+		return errors.New("`max_tokens` must not be negative")
+	}
+	if c.MaxBytes < 0 {
+		return errors.New("`max_bytes` must not be negative")
+	}
+	if c.MaxTokens == 0 && c.MaxBytes == 0 {
+		return errors.New("at least one of `max_tokens` or `max_bytes` must be set")
+	}
+	if c.FlushInterval < 0 {
+		return errors.New("`flush_interval` must not be negative")
+	}
+	return nil
+}
+
+func (c *BatchConfig) buildBatchSettings() *batchSettings {
+	return &batchSettings{
+		maxTokens:     c.MaxTokens,
+		maxBytes:      int(c.MaxBytes),
+		flushInterval: c.FlushInterval,
+	}
+}
+
+type batchSettings struct {
+	maxTokens     int
+	maxBytes      int
+	flushInterval time.Duration
+}
+
+// tokenBatch holds the tokens read from a file that were not emitted yet
+type tokenBatch struct {
+	tokens [][]byte
+	// ranges holds the range of the file that each token occupied
+	ranges  []recordRange
+	size    int
+	started time.Time
+	// offset is the position right after the last token of the batch
+	offset int64
+}
+
+// recordRange is the range of a file from offset up to end
+type recordRange struct {
+	offset int64
+	end    int64
+}
+
+// batching reports whether tokens are currently collected into batches.
+// Header lines are always processed one at a time.
+func (r *Reader) batching() bool {
+	return r.batchSettings != nil && (r.headerSettings == nil || r.HeaderFinalized)
+}
+
+func (r *Reader) appendToBatch(token []byte, offset, end int64) {
+	if r.batch == nil {
+		r.batch = &tokenBatch{}
+	}
+	if len(r.batch.tokens) == 0 {
+		r.batch.started = time.Now()
+	}
+	copied := make([]byte, len(token))
+	copy(copied, token)
+	r.batch.tokens = append(r.batch.tokens, copied)
+	r.batch.ranges = append(r.batch.ranges, recordRange{offset: offset, end: end})
+	r.batch.size += len(copied)
+}
+
+// advance records that the file was consumed up to pos. While tokens are
+// waiting in a batch, the offset only moves once they are emitted.
+func (r *Reader) advance(pos int64) {
+	if r.batch != nil && len(r.batch.tokens) > 0 {
+		r.batch.offset = pos
+		return
+	}
+	r.Offset = pos
+}
+
+// readOffset returns the position up to which the file was consumed,
+// including tokens waiting in a batch.
+func (r *Reader) readOffset() int64 {
+	if r.batch != nil && len(r.batch.tokens) > 0 {
+		return r.batch.offset
+	}
+	return r.Offset
+}
+
+func (r *Reader) batchFull() bool {
+	if r.batch == nil {
+		return false
+	}
+	return (r.batchSettings.maxTokens > 0 && len(r.batch.tokens) >= r.batchSettings.maxTokens) ||
+		(r.batchSettings.maxBytes > 0 && r.batch.size >= r.batchSettings.maxBytes)
+}
+
+func (r *Reader) batchExpired() bool {
+	return r.batch != nil && len(r.batch.tokens) > 0 && time.Since(r.batch.started) >= r.batchSettings.flushInterval
+}
+
+// flushBatch emits the pending tokens and moves the offset past them. It returns false
+// if emitting was interrupted by ctx, or failed with at_least_once other than with a
+// permanent error, in which case the tokens that were not emitted are kept for a later flush.
+func (r *Reader) flushBatch(ctx context.Context) bool {
+	if r.batch == nil || len(r.batch.tokens) == 0 {
+		return true
+	}
+
+	var err error
+	if r.emitBatch != nil {
+		// A batch is emitted as one record, which spans the ranges of its tokens
+		first, last := r.batch.ranges[0], r.batch.ranges[len(r.batch.ranges)-1]
+		err = r.emitBatch(ctx, r.batch.tokens, r.recordAttributes(first.offset, last.end))
+	} else if r.atLeastOnce {
+		err = r.emitBatchTokens(ctx)
+	} else {
+		for i, token := range r.batch.tokens {
+			rng := r.batch.ranges[i]
+			err = multierr.Append(err, r.emit(ctx, token, r.recordAttributes(rng.offset, rng.end)))
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		if r.atLeastOnce && !consumererror.IsPermanent(err) {
+			r.eof = false
+			r.Warnw("Failed to emit batch, it is emitted again on the next poll", zap.Error(err))
+			return false
+		}
+		r.logEmitError(ctx, "process batch: %w", err)
+	}
+
+	r.Offset = r.batch.offset
+	r.batch = nil
+	return true
+}
+
+// emitBatchTokens emits the pending tokens one at a time, and removes them
+// from the batch as they are emitted until one of them fails other than with
+// a permanent error, which drops the token
+func (r *Reader) emitBatchTokens(ctx context.Context) error {
+	for len(r.batch.tokens) > 0 {
+		token, rng := r.batch.tokens[0], r.batch.ranges[0]
+		if err := r.emit(ctx, token, r.recordAttributes(rng.offset, rng.end)); err != nil {
+			if !consumererror.IsPermanent(err) {
+				return err
+			}
+			r.logEmitError(ctx, "process: %w", err)
+		}
+		r.batch.tokens = r.batch.tokens[1:]
+		r.batch.ranges = r.batch.ranges[1:]
+		r.batch.size -= len(token)
+	}
+	return nil
+}
+
+// takeBatch hands the pending tokens over to a reader of the same file.
+func (r *Reader) takeBatch() *tokenBatch {
+	batch := r.batch
+	r.batch = nil
+	return batch
+}
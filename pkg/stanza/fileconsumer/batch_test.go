@@ -0,0 +1,191 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/emit"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func testBatchEmitFunc(batchChan chan [][]byte) emit.BatchCallback {
+	return func(_ context.Context, tokens [][]byte, _ map[string]any) error {
+		batchChan <- tokens
+		return nil
+	}
+}
+
+func testBatchReader(t *testing.T, cfg *BatchConfig, content string) (*Reader, chan [][]byte) {
+	require.NoError(t, cfg.validate())
+	batchChan := make(chan [][]byte, 100)
+	f, _ := testReaderFactory(t)
+	f.readerConfig.batchSettings = cfg.buildBatchSettings()
+	f.readerConfig.emitBatch = testBatchEmitFunc(batchChan)
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, content)
+
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+	return r, batchChan
+}
+
+func readBatch(t *testing.T, c chan [][]byte) [][]byte {
+	select {
+	case batch := <-c:
+		return batch
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "Timed out waiting for batch")
+	}
+	return nil
+}
+
+func expectNoBatches(t *testing.T, c chan [][]byte) {
+	select {
+	case batch := <-c:
+		require.FailNow(t, "Received unexpected batch", "%q", batch)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestBatchMaxTokens(t *testing.T) {
+	r, batchChan := testBatchReader(t, &BatchConfig{MaxTokens: 3, FlushInterval: time.Hour}, "a1\na2\na3\nb1\nb2\nb3\nc1\n")
+
+	r.ReadToEnd(context.Background())
+	require.Equal(t, [][]byte{[]byte("a1"), []byte("a2"), []byte("a3")}, readBatch(t, batchChan))
+	require.Equal(t, [][]byte{[]byte("b1"), []byte("b2"), []byte("b3")}, readBatch(t, batchChan))
+	expectNoBatches(t, batchChan)
+
+	// The offset does not include the token waiting in the partial batch
+	require.Equal(t, int64(18), r.Offset)
+	require.True(t, r.flushBatch(context.Background()))
+	require.Equal(t, [][]byte{[]byte("c1")}, readBatch(t, batchChan))
+	require.Equal(t, int64(21), r.Offset)
+}
+
+func TestBatchMaxBytes(t *testing.T) {
+	r, batchChan := testBatchReader(t, &BatchConfig{MaxBytes: 10, FlushInterval: time.Hour}, "1234\n5678\n9abc\ndefg\n")
+
+	r.ReadToEnd(context.Background())
+	require.Equal(t, [][]byte{[]byte("1234"), []byte("5678"), []byte("9abc")}, readBatch(t, batchChan))
+	expectNoBatches(t, batchChan)
+	require.Equal(t, int64(15), r.Offset)
+}
+
+func TestBatchRecordOffset(t *testing.T) {
+	f, _ := testReaderFactory(t)
+	f.readerConfig.includeRecordOffset = true
+	f.readerConfig.batchSettings = (&BatchConfig{MaxTokens: 2}).buildBatchSettings()
+	var ranges [][2]any
+	f.readerConfig.emitBatch = func(_ context.Context, _ [][]byte, attrs map[string]any) error {
+		ranges = append(ranges, [2]any{attrs[logFileRecordOffset], attrs[logFileRecordLength]})
+		return nil
+	}
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, "a1\na2\nb1\nb2\n")
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+	r.ReadToEnd(context.Background())
+
+	// A batch spans the ranges of its tokens
+	require.Equal(t, [][2]any{{int64(0), int64(6)}, {int64(6), int64(6)}}, ranges)
+}
+
+func TestBatchFlushInterval(t *testing.T) {
+	r, batchChan := testBatchReader(t, &BatchConfig{MaxTokens: 100, FlushInterval: 100 * time.Millisecond}, "a\nb\n")
+
+	r.ReadToEnd(context.Background())
+	expectNoBatches(t, batchChan)
+	require.Equal(t, int64(0), r.Offset)
+
+	// More lines join the pending batch, which is emitted once the interval expired
+	writeString(t, r.file, "c\n")
+	time.Sleep(100 * time.Millisecond)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, readBatch(t, batchChan))
+	require.Equal(t, int64(6), r.Offset)
+}
+
+func TestBatchFlushedAtEOF(t *testing.T) {
+	r, batchChan := testBatchReader(t, &BatchConfig{MaxTokens: 3}, "a1\na2\na3\nb1\n")
+
+	// Without a flush interval, the partial batch is emitted as soon as the end of the file is reached
+	r.ReadToEnd(context.Background())
+	require.Equal(t, [][]byte{[]byte("a1"), []byte("a2"), []byte("a3")}, readBatch(t, batchChan))
+	require.Equal(t, [][]byte{[]byte("b1")}, readBatch(t, batchChan))
+	expectNoBatches(t, batchChan)
+	require.Equal(t, int64(12), r.Offset)
+
+	// Content appended afterwards starts a new batch
+	writeString(t, r.file, "c1\n")
+	r.ReadToEnd(context.Background())
+	require.Equal(t, [][]byte{[]byte("c1")}, readBatch(t, batchChan))
+	require.Equal(t, int64(15), r.Offset)
+}
+
+func TestBatchFlushedOnStop(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "line1\nline2\n")
+
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.Batch = &BatchConfig{MaxTokens: 100, FlushInterval: time.Hour}
+
+	batchChan := make(chan [][]byte, 100)
+	operator, err := cfg.BuildWithBatchEmit(testutil.Logger(t), testBatchEmitFunc(batchChan))
+	require.NoError(t, err)
+
+	persister := testutil.NewMockPersister("test")
+	operator.persister = persister
+	operator.poll(context.Background())
+	expectNoBatches(t, batchChan)
+
+	require.NoError(t, operator.Stop())
+	require.Equal(t, [][]byte{[]byte("line1"), []byte("line2")}, readBatch(t, batchChan))
+
+	// The offset of the flushed batch was persisted
+	operator2, _ := buildTestManager(t, cfg)
+	operator2.persister = persister
+	require.NoError(t, operator2.loadLastPollFiles(context.Background()))
+	require.Len(t, operator2.knownFiles, 1)
+	require.Equal(t, int64(12), operator2.knownFiles[0].Offset)
+}
+
+func TestBatchAtLeastOnce(t *testing.T) {
+	r, batchChan := testBatchReader(t, &BatchConfig{MaxTokens: 2, FlushInterval: time.Hour}, "a1\na2\nb1\nb2\n")
+	r.atLeastOnce = true
+	emitToChan := r.emitBatch
+	failing := true
+	r.emitBatch = func(ctx context.Context, tokens [][]byte, attrs map[string]any) error {
+		if failing {
+			return errors.New("sink is full")
+		}
+		return emitToChan(ctx, tokens, attrs)
+	}
+
+	// The batch that could not be emitted is kept, and the file is not read further
+	r.ReadToEnd(context.Background())
+	expectNoBatches(t, batchChan)
+	require.Zero(t, r.Offset)
+	require.Equal(t, [][]byte{[]byte("a1"), []byte("a2")}, r.batch.tokens)
+
+	failing = false
+	r.ReadToEnd(context.Background())
+	require.Equal(t, [][]byte{[]byte("a1"), []byte("a2")}, readBatch(t, batchChan))
+	require.Equal(t, [][]byte{[]byte("b1"), []byte("b2")}, readBatch(t, batchChan))
+	expectNoBatches(t, batchChan)
+	require.Equal(t, int64(12), r.Offset)
+}
@@ -4,6 +4,8 @@
 package fileconsumer
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -180,3 +182,70 @@ func BenchmarkFileInput(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkFileInputBatch(b *testing.B) {
+	// A batch size of 0 emits every token on its own
+	for _, maxTokens := range []int{0, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("MaxTokens%d", maxTokens), func(b *testing.B) {
+			rootDir := b.TempDir()
+			file := simpleTextFile(b, openFile(b, filepath.Join(rootDir, "file0.log")))
+			for i := 0; i < b.N; i++ {
+				file.log(i)
+			}
+
+			cfg := NewConfig()
+			cfg.Include = []string{filepath.Join(rootDir, "file0.log")}
+			cfg.StartAt = "beginning"
+
+			received := make(chan int)
+			var op *Manager
+			var err error
+			if maxTokens == 0 {
+				op, err = cfg.Build(testutil.Logger(b), func(_ context.Context, _ []byte, _ map[string]any) error {
+					received <- 1
+					return nil
+				})
+			} else {
+				cfg.Batch = &BatchConfig{MaxTokens: maxTokens}
+				op, err = cfg.BuildWithBatchEmit(testutil.Logger(b), func(_ context.Context, tokens [][]byte, _ map[string]any) error {
+					received <- len(tokens)
+					return nil
+				})
+			}
+			require.NoError(b, err)
+
+			b.ResetTimer()
+			err = op.Start(testutil.NewMockPersister("test"))
+			defer func() {
+				require.NoError(b, op.Stop())
+			}()
+			require.NoError(b, err)
+
+			for n := 0; n < b.N; {
+				n += <-received
+			}
+		})
+	}
+}
+
+func BenchmarkFileInputColdStart(b *testing.B) {
+	rootDir := b.TempDir()
+	writeSmallFiles(b, rootDir, 10000)
+
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("FingerprintConcurrency%d", concurrency), func(b *testing.B) {
+			cfg := NewConfig().includeDir(rootDir)
+			cfg.StartAt = "beginning"
+			cfg.FingerprintConcurrency = concurrency
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				op, err := cfg.Build(testutil.Logger(b), nopEmitFunc)
+				require.NoError(b, err)
+				op.persister = testutil.NewMockPersister("test")
+				op.poll(context.Background())
+				require.NoError(b, op.Stop())
+			}
+		})
+	}
+}
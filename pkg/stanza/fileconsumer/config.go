@@ -5,8 +5,11 @@ package fileconsumer // import "github.com/open-telemetry/opentelemetry-collecto
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"runtime"
 	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
@@ -23,6 +26,28 @@ const (
 	defaultMaxConcurrentFiles = 1024
 )
 
+const (
+	encodingErrorModeReplace   = "replace"
+	encodingErrorModeDropBytes = "drop-bytes"
+	encodingErrorModeSkipToken = "skip-token"
+	encodingErrorModeStopFile  = "stop-file"
+)
+
+const (
+	framingDelimited    = "delimited"
+	framingLengthPrefix = "length_prefix"
+)
+
+// The fingerprint strategy only changes the form in which fingerprints are persisted: with
+// content-hash, a digest of the first bytes of a file is stored instead of the bytes. Files are
+// matched by their first bytes with both strategies, so a file rewritten with the same first
+// bytes, as by copytruncate, still matches. It is read again from the beginning only if it is
+// detected as truncated, by being smaller than the offset it was read to.
+const (
+	fingerprintStrategyPrefix      = "prefix"
+	fingerprintStrategyContentHash = "content-hash"
+)
+
 var allowFileDeletion = featuregate.GlobalRegistry().MustRegister(
 	"filelog.allowFileDeletion",
 	featuregate.StageAlpha,
@@ -40,36 +65,87 @@ var AllowHeaderMetadataParsing = featuregate.GlobalRegistry().MustRegister(
 // NewConfig creates a new input config with default values
 func NewConfig() *Config {
 	return &Config{
-		IncludeFileName:         true,
-		IncludeFilePath:         false,
-		IncludeFileNameResolved: false,
-		IncludeFilePathResolved: false,
-		PollInterval:            200 * time.Millisecond,
-		Splitter:                helper.NewSplitterConfig(),
-		StartAt:                 "end",
-		FingerprintSize:         fingerprint.DefaultSize,
-		MaxLogSize:              defaultMaxLogSize,
-		MaxConcurrentFiles:      defaultMaxConcurrentFiles,
-		MaxBatches:              0,
+		IncludeFileName:          true,
+		IncludeFilePath:          false,
+		IncludeFileNameResolved:  false,
+		IncludeFilePathResolved:  false,
+		ResolveSymlinksOnWindows: false,
+		IncludeFileInode:         false,
+		IncludeFileSize:          false,
+		IncludeFileModTime:       false,
+		IncludeFileOwner:         false,
+		IncludeFileMode:          false,
+		IncludeRecordOffset:      false,
+		PollInterval:             200 * time.Millisecond,
+		Splitter:                 helper.NewSplitterConfig(),
+		StartAt:                  "end",
+		FingerprintSize:          fingerprint.DefaultSize,
+		MaxLogSize:               defaultMaxLogSize,
+		MaxConcurrentFiles:       defaultMaxConcurrentFiles,
+		MaxBatches:               0,
+		EncodingErrorMode:        encodingErrorModeReplace,
+		FingerprintStrategy:      fingerprintStrategyPrefix,
+		Decompression:            decompressionNone,
+		Framing:                  framingDelimited,
+		ReadMode:                 readModeBuffered,
+		ArchiveMode:              archiveModeNone,
+		OnDeleteWhileReading:     onDeleteFinishInode,
 	}
 }
 
 // Config is the configuration of a file input operator
 type Config struct {
-	MatchingCriteria        `mapstructure:",squash"`
-	IncludeFileName         bool                  `mapstructure:"include_file_name,omitempty"`
-	IncludeFilePath         bool                  `mapstructure:"include_file_path,omitempty"`
-	IncludeFileNameResolved bool                  `mapstructure:"include_file_name_resolved,omitempty"`
-	IncludeFilePathResolved bool                  `mapstructure:"include_file_path_resolved,omitempty"`
-	PollInterval            time.Duration         `mapstructure:"poll_interval,omitempty"`
-	StartAt                 string                `mapstructure:"start_at,omitempty"`
-	FingerprintSize         helper.ByteSize       `mapstructure:"fingerprint_size,omitempty"`
-	MaxLogSize              helper.ByteSize       `mapstructure:"max_log_size,omitempty"`
-	MaxConcurrentFiles      int                   `mapstructure:"max_concurrent_files,omitempty"`
-	MaxBatches              int                   `mapstructure:"max_batches,omitempty"`
-	DeleteAfterRead         bool                  `mapstructure:"delete_after_read,omitempty"`
-	Splitter                helper.SplitterConfig `mapstructure:",squash,omitempty"`
-	Header                  *HeaderConfig         `mapstructure:"header,omitempty"`
+	MatchingCriteria         `mapstructure:",squash"`
+	IncludeFileName          bool                  `mapstructure:"include_file_name,omitempty"`
+	IncludeFilePath          bool                  `mapstructure:"include_file_path,omitempty"`
+	IncludeFileNameResolved  bool                  `mapstructure:"include_file_name_resolved,omitempty"`
+	IncludeFilePathResolved  bool                  `mapstructure:"include_file_path_resolved,omitempty"`
+	ResolveSymlinksOnWindows bool                  `mapstructure:"resolve_symlinks_on_windows,omitempty"`
+	IncludeFileInode         bool                  `mapstructure:"include_file_inode,omitempty"`
+	IncludeFileSize          bool                  `mapstructure:"include_file_size,omitempty"`
+	IncludeFileModTime       bool                  `mapstructure:"include_file_mod_time,omitempty"`
+	IncludeFileOwner         bool                  `mapstructure:"include_file_owner,omitempty"`
+	IncludeFileMode          bool                  `mapstructure:"include_file_mode,omitempty"`
+	IncludeRecordOffset      bool                  `mapstructure:"include_record_offset,omitempty"`
+	PollInterval             time.Duration         `mapstructure:"poll_interval,omitempty"`
+	StartAt                  string                `mapstructure:"start_at,omitempty"`
+	FingerprintSize          helper.ByteSize       `mapstructure:"fingerprint_size,omitempty"`
+	MaxLogSize               helper.ByteSize       `mapstructure:"max_log_size,omitempty"`
+	MaxLogSizeOverrides      []MaxLogSizeOverride  `mapstructure:"max_log_size_overrides,omitempty"`
+	MaxConcurrentFiles       int                   `mapstructure:"max_concurrent_files,omitempty"`
+	MaxBatches               int                   `mapstructure:"max_batches,omitempty"`
+	MaxOpenFiles             int                   `mapstructure:"max_open_files,omitempty"`
+	DeleteAfterRead          bool                  `mapstructure:"delete_after_read,omitempty"`
+	DeleteGracePeriod        time.Duration         `mapstructure:"delete_grace_period,omitempty"`
+	MaxFileAge               time.Duration         `mapstructure:"max_file_age,omitempty"`
+	MoveAfterRead            bool                  `mapstructure:"move_after_read,omitempty"`
+	MoveDestination          string                `mapstructure:"move_destination,omitempty"`
+	Splitter                 helper.SplitterConfig `mapstructure:",squash,omitempty"`
+	Header                   *HeaderConfig         `mapstructure:"header,omitempty"`
+	EncodingErrorMode        string                `mapstructure:"encoding_error_mode,omitempty"`
+	NFS                      *NFSConfig            `mapstructure:"nfs,omitempty"`
+	Batch                    *BatchConfig          `mapstructure:"batch,omitempty"`
+	SkipHeaderLines          int                   `mapstructure:"skip_header_lines,omitempty"`
+	FingerprintStrategy      string                `mapstructure:"fingerprint_strategy,omitempty"`
+	FingerprintOffset        helper.ByteSize       `mapstructure:"fingerprint_offset,omitempty"`
+	Decompression            string                `mapstructure:"decompression,omitempty"`
+	AllowFIFO                bool                  `mapstructure:"allow_fifo,omitempty"`
+	MetricsIncludeFilePath   bool                  `mapstructure:"metrics_include_file_path,omitempty"`
+	AttributesFromPath       string                `mapstructure:"attributes_from_path,omitempty"`
+	AtLeastOnce              bool                  `mapstructure:"at_least_once,omitempty"`
+	Framing                  string                `mapstructure:"framing,omitempty"`
+	EmitOnOpen               bool                  `mapstructure:"emit_on_open,omitempty"`
+	EmitOnClose              bool                  `mapstructure:"emit_on_close,omitempty"`
+	RateLimit                *RateLimitConfig      `mapstructure:"rate_limit,omitempty"`
+	ReadMode                 string                `mapstructure:"read_mode,omitempty"`
+	PollBackoff              *PollBackoffConfig    `mapstructure:"poll_backoff,omitempty"`
+	ArchiveMode              string                `mapstructure:"archive_mode,omitempty"`
+	HeartbeatInterval        time.Duration         `mapstructure:"heartbeat_interval,omitempty"`
+	OnDeleteWhileReading     string                `mapstructure:"on_delete_while_reading,omitempty"`
+	StorageKeyPrefix         string                `mapstructure:"storage_key_prefix,omitempty"`
+	PreserveRecordBytes      bool                  `mapstructure:"preserve_record_bytes,omitempty"`
+	FingerprintConcurrency   int                   `mapstructure:"fingerprint_concurrency,omitempty"`
+	FileAttributeKeys        *AttributeKeysConfig  `mapstructure:"file_attribute_keys,omitempty"`
 }
 
 // Build will build a file input operator from the supplied configuration
@@ -79,7 +155,7 @@ func (c Config) Build(logger *zap.SugaredLogger, emit emit.Callback) (*Manager,
 	}
 
 	// Ensure that splitter is buildable
-	factory := newMultilineSplitterFactory(c.Splitter)
+	factory := c.buildSplitterFactory()
 	if _, err := factory.Build(int(c.MaxLogSize)); err != nil {
 		return nil, err
 	}
@@ -87,6 +163,34 @@ func (c Config) Build(logger *zap.SugaredLogger, emit emit.Callback) (*Manager,
 	return c.buildManager(logger, emit, factory)
 }
 
+// buildSplitterFactory returns the factory of the split func that frames the tokens of files
+func (c Config) buildSplitterFactory() splitterFactory {
+	if c.Framing == framingLengthPrefix {
+		return newLengthPrefixSplitterFactory()
+	}
+	return newMultilineSplitterFactory(c.Splitter)
+}
+
+// BuildWithBatchEmit will build a file input operator that emits the tokens of each batch at once
+func (c Config) BuildWithBatchEmit(logger *zap.SugaredLogger, emitBatch emit.BatchCallback) (*Manager, error) {
+	if c.Batch == nil {
+		return nil, fmt.Errorf("`batch` must be specified to emit batches")
+	}
+	if emitBatch == nil {
+		return nil, fmt.Errorf("must provide batch emit function")
+	}
+
+	// Tokens that are not batched are passed on as a batch of their own
+	m, err := c.Build(logger, func(ctx context.Context, token []byte, attrs map[string]any) error {
+		return emitBatch(ctx, [][]byte{token}, attrs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.readerFactory.readerConfig.emitBatch = emitBatch
+	return m, nil
+}
+
 // BuildWithSplitFunc will build a file input operator with customized splitFunc function
 func (c Config) BuildWithSplitFunc(logger *zap.SugaredLogger, emit emit.Callback, splitFunc bufio.SplitFunc) (*Manager, error) {
 	if err := c.validate(); err != nil {
@@ -97,6 +201,10 @@ func (c Config) BuildWithSplitFunc(logger *zap.SugaredLogger, emit emit.Callback
 		return nil, fmt.Errorf("must provide split function")
 	}
 
+	if c.Framing != framingDelimited {
+		return nil, fmt.Errorf("`framing` '%s' cannot be used with a custom split function", c.Framing)
+	}
+
 	// Ensure that splitter is buildable
 	factory := newCustomizeSplitterFactory(c.Splitter.Flusher, splitFunc)
 	if _, err := factory.Build(int(c.MaxLogSize)); err != nil {
@@ -114,7 +222,7 @@ func (c Config) buildManager(logger *zap.SugaredLogger, emit emit.Callback, fact
 	switch c.StartAt {
 	case "beginning":
 		startAtBeginning = true
-	case "end":
+	case "end", startAtEndSkipFingerprint:
 		startAtBeginning = false
 	default:
 		return nil, fmt.Errorf("invalid start_at location '%s'", c.StartAt)
@@ -133,36 +241,111 @@ func (c Config) buildManager(logger *zap.SugaredLogger, emit emit.Callback, fact
 		}
 	}
 
+	var attributesFromPath *regexp.Regexp
+	if c.AttributesFromPath != "" {
+		attributesFromPath = regexp.MustCompile(c.AttributesFromPath) // compile error checked in validate
+	}
+
+	var nfs *nfsSettings
+	if c.NFS != nil {
+		nfs = c.NFS.buildNFSSettings()
+	}
+
+	var bs *batchSettings
+	if c.Batch != nil {
+		bs = c.Batch.buildBatchSettings()
+	}
+
+	var rateLimitPerFile int
+	if c.RateLimit != nil {
+		rateLimitPerFile = int(c.RateLimit.PerFile)
+	}
+
+	fingerprintConcurrency := c.FingerprintConcurrency
+	if fingerprintConcurrency == 0 {
+		fingerprintConcurrency = runtime.GOMAXPROCS(0)
+	}
+
+	var pb *pollBackoff
+	if c.PollBackoff != nil {
+		pb = c.PollBackoff.buildPollBackoff(c.PollInterval)
+	}
+
 	return &Manager{
 		SugaredLogger: logger.With("component", "fileconsumer"),
 		cancel:        func() {},
 		readerFactory: readerFactory{
 			SugaredLogger: logger.With("component", "fileconsumer"),
 			readerConfig: &readerConfig{
-				fingerprintSize:         int(c.FingerprintSize),
-				maxLogSize:              int(c.MaxLogSize),
-				emit:                    emit,
-				includeFileName:         c.IncludeFileName,
-				includeFilePath:         c.IncludeFilePath,
-				includeFileNameResolved: c.IncludeFileNameResolved,
-				includeFilePathResolved: c.IncludeFilePathResolved,
+				fingerprintSize:          int(c.FingerprintSize),
+				maxLogSize:               int(c.MaxLogSize),
+				maxLogSizeOverrides:      c.MaxLogSizeOverrides,
+				emit:                     emit,
+				includeFileName:          c.IncludeFileName,
+				includeFilePath:          c.IncludeFilePath,
+				includeFileNameResolved:  c.IncludeFileNameResolved,
+				includeFilePathResolved:  c.IncludeFilePathResolved,
+				resolveSymlinksOnWindows: c.ResolveSymlinksOnWindows,
+				includeFileInode:         c.IncludeFileInode,
+				includeFileSize:          c.IncludeFileSize,
+				includeFileModTime:       c.IncludeFileModTime,
+				includeFileOwner:         c.IncludeFileOwner,
+				includeFileMode:          c.IncludeFileMode,
+				includeRecordOffset:      c.IncludeRecordOffset,
+				encodingErrorMode:        c.EncodingErrorMode,
+				nfs:                      nfs,
+				batchSettings:            bs,
+				skipHeaderLines:          c.SkipHeaderLines,
+				fingerprintStrategy:      c.FingerprintStrategy,
+				fingerprintOffset:        int64(c.FingerprintOffset),
+				decompression:            c.Decompression,
+				allowFIFO:                c.AllowFIFO,
+				metricsIncludeFilePath:   c.MetricsIncludeFilePath,
+				attributesFromPath:       attributesFromPath,
+				atLeastOnce:              c.AtLeastOnce,
+				emitOnOpen:               c.EmitOnOpen,
+				emitOnClose:              c.EmitOnClose,
+				rateLimitPerFile:         rateLimitPerFile,
+				readMode:                 c.ReadMode,
+				onDeleteWhileReading:     c.OnDeleteWhileReading,
+				preserveRecordBytes:      c.PreserveRecordBytes,
+				fileAttributeKeys:        c.FileAttributeKeys.buildFileAttributeKeys(),
 			},
 			fromBeginning:   startAtBeginning,
 			splitterFactory: factory,
 			encodingConfig:  c.Splitter.EncodingConfig,
 			headerSettings:  hs,
 		},
-		finder:          c.MatchingCriteria,
-		roller:          newRoller(),
-		pollInterval:    c.PollInterval,
-		maxBatchFiles:   c.MaxConcurrentFiles / 2,
-		maxBatches:      c.MaxBatches,
-		deleteAfterRead: c.DeleteAfterRead,
-		knownFiles:      make([]*Reader, 0, 10),
-		seenPaths:       make(map[string]struct{}, 100),
+		finder:                 c.MatchingCriteria,
+		storageKeyPrefix:       c.StorageKeyPrefix,
+		roller:                 newRoller(),
+		pollInterval:           c.PollInterval,
+		pollBackoff:            pb,
+		maxBatchFiles:          c.MaxConcurrentFiles / 2,
+		maxBatches:             c.MaxBatches,
+		maxOpenFiles:           c.MaxOpenFiles,
+		deleteAfterRead:        c.DeleteAfterRead,
+		deleteGrace:            c.DeleteGracePeriod,
+		maxFileAge:             c.MaxFileAge,
+		moveAfterRead:          c.MoveAfterRead,
+		moveDestination:        c.MoveDestination,
+		skipFingerprint:        c.StartAt == startAtEndSkipFingerprint,
+		archiveMode:            c.ArchiveMode,
+		fingerprintConcurrency: fingerprintConcurrency,
+		heartbeatInterval:      c.HeartbeatInterval,
+		now:                    time.Now,
+		knownFiles:             make([]*Reader, 0, 10),
+		seenPaths:              make(map[string]struct{}, 100),
+		collisions:             make(map[[2]string]struct{}),
+		fifoReaders:            make(map[string]*Reader),
 	}, nil
 }
 
+// startsAtEnd returns true if files that exist at startup are read from their end
+func (c Config) startsAtEnd() bool {
+	return c.StartAt == "end" || c.StartAt == startAtEndSkipFingerprint
+}
+
 func (c Config) validate() error {
 	if c.DeleteAfterRead && !allowFileDeletion.IsEnabled() {
 		return fmt.Errorf("`delete_after_read` requires feature gate `%s`", allowFileDeletion.ID())
@@ -192,11 +375,28 @@ func (c Config) validate() error {
 		}
 	}
 
-	if len(c.OrderingCriteria.SortBy) != 0 && c.OrderingCriteria.Regex == "" {
-		return fmt.Errorf("`regex` must be specified when `sort_by` is specified")
+	if c.AttributesFromPath != "" {
+		re, err := regexp.Compile(c.AttributesFromPath)
+		if err != nil {
+			return fmt.Errorf("compile `attributes_from_path`: %w", err)
+		}
+		var named bool
+		for _, name := range re.SubexpNames() {
+			named = named || name != ""
+		}
+		if !named {
+			return errors.New("`attributes_from_path` must have a named capture group")
+		}
+	}
+
+	if c.OrderingCriteria.TopN < 0 {
+		return errors.New("`top_n` must not be negative")
 	}
 
 	for _, sr := range c.OrderingCriteria.SortBy {
+		if sr.usesRegex() && c.OrderingCriteria.Regex == "" {
+			return fmt.Errorf("`regex` must be specified when a `sort_by` rule uses a `regex_key`")
+		}
 		if err := sr.validate(); err != nil {
 			return err
 		}
@@ -206,6 +406,12 @@ func (c Config) validate() error {
 		return fmt.Errorf("`max_log_size` must be positive")
 	}
 
+	for _, o := range c.MaxLogSizeOverrides {
+		if err := o.validate(); err != nil {
+			return err
+		}
+	}
+
 	if c.MaxConcurrentFiles <= 1 {
 		return fmt.Errorf("`max_concurrent_files` must be greater than 1")
 	}
@@ -214,28 +420,162 @@ func (c Config) validate() error {
 		return fmt.Errorf("`fingerprint_size` must be at least %d bytes", fingerprint.MinSize)
 	}
 
-	if c.DeleteAfterRead && c.StartAt == "end" {
+	if c.FingerprintOffset < 0 {
+		return errors.New("`fingerprint_offset` must not be negative")
+	}
+
+	if c.DeleteAfterRead && c.startsAtEnd() {
 		return fmt.Errorf("`delete_after_read` cannot be used with `start_at: end`")
 	}
 
-	if c.Header != nil && c.StartAt == "end" {
+	if c.DeleteGracePeriod < 0 {
+		return errors.New("`delete_grace_period` must not be negative")
+	}
+
+	if c.MaxFileAge < 0 {
+		return errors.New("`max_file_age` must not be negative")
+	}
+
+	if c.HeartbeatInterval < 0 {
+		return errors.New("`heartbeat_interval` must not be negative")
+	}
+
+	if c.DeleteGracePeriod > 0 && !c.DeleteAfterRead && !c.MoveAfterRead {
+		return errors.New("`delete_grace_period` requires `delete_after_read` or `move_after_read`")
+	}
+
+	if c.MoveAfterRead {
+		if c.DeleteAfterRead {
+			return errors.New("`move_after_read` cannot be used with `delete_after_read`")
+		}
+		if c.startsAtEnd() {
+			return errors.New("`move_after_read` cannot be used with `start_at: end`")
+		}
+		if c.MoveDestination == "" {
+			return errors.New("`move_after_read` requires `move_destination`")
+		}
+	}
+
+	if c.Header != nil && c.startsAtEnd() {
 		return fmt.Errorf("`header` cannot be specified with `start_at: end`")
 	}
 
+	if c.SkipHeaderLines < 0 {
+		return errors.New("`skip_header_lines` must not be negative")
+	}
+
+	if c.SkipHeaderLines > 0 && c.Header != nil {
+		return fmt.Errorf("`skip_header_lines` cannot be specified with `header`")
+	}
+
 	if c.MaxBatches < 0 {
 		return errors.New("`max_batches` must not be negative")
 	}
 
+	if c.FingerprintConcurrency < 0 {
+		return errors.New("`fingerprint_concurrency` must not be negative")
+	}
+
+	if c.MaxOpenFiles < 0 {
+		return errors.New("`max_open_files` must not be negative")
+	}
+
 	_, err := c.Splitter.EncodingConfig.Build()
 	if err != nil {
 		return err
 	}
 
+	switch c.EncodingErrorMode {
+	case encodingErrorModeReplace, encodingErrorModeDropBytes, encodingErrorModeSkipToken, encodingErrorModeStopFile:
+	default:
+		return fmt.Errorf("invalid `encoding_error_mode` '%s', must be one of '%s', '%s', '%s' or '%s'", c.EncodingErrorMode, encodingErrorModeReplace, encodingErrorModeDropBytes, encodingErrorModeSkipToken, encodingErrorModeStopFile)
+	}
+
+	switch c.FingerprintStrategy {
+	case fingerprintStrategyPrefix, fingerprintStrategyContentHash:
+	default:
+		return fmt.Errorf("invalid `fingerprint_strategy` '%s', must be one of '%s' or '%s'", c.FingerprintStrategy, fingerprintStrategyPrefix, fingerprintStrategyContentHash)
+	}
+
+	switch c.ReadMode {
+	case readModeBuffered:
+	case readModeMmap:
+		if !mmapSupported {
+			return fmt.Errorf("`read_mode: %s` is not supported on this platform", readModeMmap)
+		}
+	default:
+		return fmt.Errorf("invalid `read_mode` '%s', must be one of '%s' or '%s'", c.ReadMode, readModeBuffered, readModeMmap)
+	}
+
+	switch c.Framing {
+	case framingDelimited:
+	case framingLengthPrefix:
+		if c.Header != nil {
+			return fmt.Errorf("`header` cannot be specified with `framing: %s`", framingLengthPrefix)
+		}
+		if c.Splitter.Multiline.LineStartPattern != "" || c.Splitter.Multiline.LineEndPattern != "" {
+			return fmt.Errorf("`multiline` cannot be specified with `framing: %s`", framingLengthPrefix)
+		}
+		if c.PreserveRecordBytes {
+			return fmt.Errorf("`preserve_record_bytes` cannot be specified with `framing: %s`", framingLengthPrefix)
+		}
+	default:
+		return fmt.Errorf("invalid `framing` '%s', must be one of '%s' or '%s'", c.Framing, framingDelimited, framingLengthPrefix)
+	}
+
+	switch c.Decompression {
+	case decompressionNone, decompressionGzip, decompressionAuto:
+	default:
+		return fmt.Errorf("invalid `decompression` '%s', must be one of '%s', '%s' or '%s'", c.Decompression, decompressionAuto, decompressionGzip, decompressionNone)
+	}
+
+	switch c.ArchiveMode {
+	case archiveModeNone, archiveModeMembers:
+	default:
+		return fmt.Errorf("invalid `archive_mode` '%s', must be one of '%s' or '%s'", c.ArchiveMode, archiveModeNone, archiveModeMembers)
+	}
+
+	switch c.OnDeleteWhileReading {
+	case onDeleteFinishInode, onDeleteCloseImmediately:
+	default:
+		return fmt.Errorf("invalid `on_delete_while_reading` '%s', must be one of '%s' or '%s'", c.OnDeleteWhileReading, onDeleteFinishInode, onDeleteCloseImmediately)
+	}
+
 	if c.Header != nil {
 		if err := c.Header.validate(); err != nil {
 			return fmt.Errorf("invalid config for `header`: %w", err)
 		}
 	}
 
+	if c.NFS != nil {
+		if err := c.NFS.validate(); err != nil {
+			return fmt.Errorf("invalid config for `nfs`: %w", err)
+		}
+	}
+
+	if c.Batch != nil {
+		if err := c.Batch.validate(); err != nil {
+			return fmt.Errorf("invalid config for `batch`: %w", err)
+		}
+	}
+
+	if c.RateLimit != nil {
+		if err := c.RateLimit.validate(); err != nil {
+			return fmt.Errorf("invalid config for `rate_limit`: %w", err)
+		}
+	}
+
+	if c.PollBackoff != nil {
+		if err := c.PollBackoff.validate(c.PollInterval); err != nil {
+			return fmt.Errorf("invalid config for `poll_backoff`: %w", err)
+		}
+	}
+
+	if c.FileAttributeKeys != nil {
+		if err := c.FileAttributeKeys.validate(); err != nil {
+			return fmt.Errorf("invalid config for `file_attribute_keys`: %w", err)
+		}
+	}
+
 	return nil
 }
@@ -198,6 +198,24 @@ func TestUnmarshal(t *testing.T) {
 					return newMockOperatorConfig(cfg)
 				}(),
 			},
+			{
+				Name: "sort_by_mtime",
+				Expect: func() *mockOperatorConfig {
+					cfg := NewConfig()
+					cfg.OrderingCriteria.TopN = 10
+					cfg.OrderingCriteria.SortBy = []SortRuleImpl{
+						{
+							&MtimeSortRule{
+								BaseSortRule: BaseSortRule{
+									SortType:  sortTypeMtime,
+									Ascending: true,
+								},
+							},
+						},
+					}
+					return newMockOperatorConfig(cfg)
+				}(),
+			},
 			{
 				Name: "poll_interval_no_units",
 				Expect: func() *mockOperatorConfig {
@@ -366,6 +384,16 @@ func TestUnmarshal(t *testing.T) {
 					return newMockOperatorConfig(cfg)
 				}(),
 			},
+			{
+				Name: "max_log_size_overrides",
+				Expect: func() *mockOperatorConfig {
+					cfg := NewConfig()
+					cfg.MaxLogSizeOverrides = []MaxLogSizeOverride{
+						{Pattern: "/var/log/dumps/*.json", MaxLogSize: helper.ByteSize(16777216)},
+					}
+					return newMockOperatorConfig(cfg)
+				}(),
+			},
 			{
 				Name: "encoding_lower",
 				Expect: func() *mockOperatorConfig {
@@ -546,6 +574,263 @@ func TestBuild(t *testing.T) {
 			require.Error,
 			nil,
 		},
+		{
+			"ValidStartAtEndSkipFingerprint",
+			func(f *Config) {
+				f.StartAt = "end-skip-fingerprint"
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.False(t, m.readerFactory.fromBeginning)
+				require.True(t, m.skipFingerprint)
+			},
+		},
+		{
+			"InvalidStartAtEndSkipFingerprintDelete",
+			func(f *Config) {
+				f.StartAt = "end-skip-fingerprint"
+				f.DeleteAfterRead = true
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"NegativeDeleteGracePeriod",
+			func(f *Config) {
+				f.StartAt = "beginning"
+				f.DeleteAfterRead = true
+				f.DeleteGracePeriod = -time.Second
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"DeleteGracePeriodWithoutDelete",
+			func(f *Config) {
+				f.DeleteGracePeriod = time.Second
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"MaxFileAge",
+			func(f *Config) {
+				f.MaxFileAge = 24 * time.Hour
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, 24*time.Hour, m.maxFileAge)
+			},
+		},
+		{
+			"NegativeMaxFileAge",
+			func(f *Config) {
+				f.MaxFileAge = -time.Hour
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"HeartbeatInterval",
+			func(f *Config) {
+				f.HeartbeatInterval = time.Minute
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, time.Minute, m.heartbeatInterval)
+			},
+		},
+		{
+			"NegativeHeartbeatInterval",
+			func(f *Config) {
+				f.HeartbeatInterval = -time.Minute
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"OnDeleteWhileReadingCloseImmediately",
+			func(f *Config) {
+				f.OnDeleteWhileReading = onDeleteCloseImmediately
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, onDeleteCloseImmediately, m.readerFactory.readerConfig.onDeleteWhileReading)
+			},
+		},
+		{
+			"BadOnDeleteWhileReading",
+			func(f *Config) {
+				f.OnDeleteWhileReading = "keep"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"StorageKeyPrefix",
+			func(f *Config) {
+				f.StorageKeyPrefix = "instance1"
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, "instance1", m.storageKeyPrefix)
+			},
+		},
+		{
+			"PreserveRecordBytes",
+			func(f *Config) {
+				f.PreserveRecordBytes = true
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.True(t, m.readerFactory.readerConfig.preserveRecordBytes)
+			},
+		},
+		{
+			"FileAttributeKeys",
+			func(f *Config) {
+				f.FileAttributeKeys = &AttributeKeysConfig{FileName: "source.file", FilePath: "source.path"}
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				keys := m.readerFactory.readerConfig.fileAttributeKeys.resolve()
+				require.Equal(t, "source.file", keys.fileName)
+				require.Equal(t, "source.path", keys.filePath)
+				require.Equal(t, logFileNameResolved, keys.fileNameResolved)
+				require.Equal(t, logFilePathResolved, keys.filePathResolved)
+			},
+		},
+		{
+			"FileAttributeKeysDuplicate",
+			func(f *Config) {
+				f.FileAttributeKeys = &AttributeKeysConfig{FilePath: logFileName}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"FingerprintConcurrency",
+			func(f *Config) {
+				f.FingerprintConcurrency = 4
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, 4, m.fingerprintConcurrency)
+			},
+		},
+		{
+			"BadFingerprintConcurrency",
+			func(f *Config) {
+				f.FingerprintConcurrency = -1
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"MoveWithoutDestination",
+			func(f *Config) {
+				f.StartAt = "beginning"
+				f.MoveAfterRead = true
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"MoveWithDelete",
+			func(f *Config) {
+				f.StartAt = "beginning"
+				f.MoveAfterRead = true
+				f.MoveDestination = "/var/log/archive"
+				f.DeleteAfterRead = true
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"ValidMove",
+			func(f *Config) {
+				f.StartAt = "beginning"
+				f.MoveAfterRead = true
+				f.MoveDestination = "/var/log/archive"
+				f.DeleteGracePeriod = time.Second
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.True(t, m.moveAfterRead)
+				require.Equal(t, "/var/log/archive", m.moveDestination)
+			},
+		},
+		{
+			"ValidMaxLogSizeOverrides",
+			func(f *Config) {
+				f.MaxLogSizeOverrides = []MaxLogSizeOverride{
+					{Pattern: "/var/log/dumps/*.json", MaxLogSize: 1 << 24},
+				}
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, 1<<24, m.readerFactory.readerConfig.maxLogSizeFor("/var/log/dumps/a.json"))
+				require.Equal(t, defaultMaxLogSize, m.readerFactory.readerConfig.maxLogSizeFor("/var/log/app.log"))
+			},
+		},
+		{
+			"MaxLogSizeOverrideWithoutPattern",
+			func(f *Config) {
+				f.MaxLogSizeOverrides = []MaxLogSizeOverride{{MaxLogSize: 1 << 24}}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"InvalidMaxLogSizeOverridePattern",
+			func(f *Config) {
+				f.MaxLogSizeOverrides = []MaxLogSizeOverride{{Pattern: "[", MaxLogSize: 1 << 24}}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"NonPositiveMaxLogSizeOverride",
+			func(f *Config) {
+				f.MaxLogSizeOverrides = []MaxLogSizeOverride{{Pattern: "*.json"}}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"ValidAttributesFromPath",
+			func(f *Config) {
+				f.AttributesFromPath = `/var/log/pods/(?P<ns>[^_]+)_(?P<pod>[^/]+)/`
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, []string{"", "ns", "pod"}, m.readerFactory.readerConfig.attributesFromPath.SubexpNames())
+			},
+		},
+		{
+			"InvalidAttributesFromPath",
+			func(f *Config) {
+				f.AttributesFromPath = `(?P<ns>`
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"AttributesFromPathWithoutNamedGroup",
+			func(f *Config) {
+				f.AttributesFromPath = `/var/log/pods/([^/]+)/`
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"NegativeMaxOpenFiles",
+			func(f *Config) {
+				f.MaxOpenFiles = -1
+			},
+			require.Error,
+			nil,
+		},
 		{
 			"InvalidMaxBatches",
 			func(f *Config) {
@@ -564,6 +849,273 @@ func TestBuild(t *testing.T) {
 				require.Equal(t, 6, m.maxBatches)
 			},
 		},
+		{
+			"EncodingErrorModeDropBytes",
+			func(f *Config) {
+				f.EncodingErrorMode = "drop-bytes"
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, "drop-bytes", m.readerFactory.readerConfig.encodingErrorMode)
+			},
+		},
+		{
+			"EmitOnOpenAndClose",
+			func(f *Config) {
+				f.EmitOnOpen = true
+				f.EmitOnClose = true
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.True(t, m.readerFactory.readerConfig.emitOnOpen)
+				require.True(t, m.readerFactory.readerConfig.emitOnClose)
+			},
+		},
+		{
+			"EncodingErrorModeStopFile",
+			func(f *Config) {
+				f.EncodingErrorMode = "stop-file"
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, "stop-file", m.readerFactory.readerConfig.encodingErrorMode)
+			},
+		},
+		{
+			"ContentHashFingerprint",
+			func(f *Config) {
+				f.FingerprintStrategy = "content-hash"
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, "content-hash", m.readerFactory.readerConfig.fingerprintStrategy)
+			},
+		},
+		{
+			"NegativeFingerprintOffset",
+			func(f *Config) {
+				f.FingerprintOffset = -1
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"Decompression",
+			func(f *Config) {
+				f.Decompression = "auto"
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, "auto", m.readerFactory.readerConfig.decompression)
+			},
+		},
+		{
+			"BadDecompression",
+			func(f *Config) {
+				f.Decompression = "zstd"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"LengthPrefixFraming",
+			func(f *Config) {
+				f.Framing = framingLengthPrefix
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.IsType(t, &lengthPrefixSplitterFactory{}, m.readerFactory.splitterFactory)
+			},
+		},
+		{
+			"BadFraming",
+			func(f *Config) {
+				f.Framing = "protobuf"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"LengthPrefixFramingWithMultiline",
+			func(f *Config) {
+				f.Framing = framingLengthPrefix
+				f.Splitter.Multiline = helper.MultilineConfig{LineStartPattern: "START"}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"LengthPrefixFramingWithPreserveRecordBytes",
+			func(f *Config) {
+				f.Framing = framingLengthPrefix
+				f.PreserveRecordBytes = true
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"ArchiveModeMembers",
+			func(f *Config) {
+				f.ArchiveMode = archiveModeMembers
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, archiveModeMembers, m.archiveMode)
+			},
+		},
+		{
+			"IncludeRecordOffset",
+			func(f *Config) {
+				f.IncludeRecordOffset = true
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.True(t, m.readerFactory.readerConfig.includeRecordOffset)
+			},
+		},
+		{
+			"BadArchiveMode",
+			func(f *Config) {
+				f.ArchiveMode = "rar"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"BadReadMode",
+			func(f *Config) {
+				f.ReadMode = "direct"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"BadFingerprintStrategy",
+			func(f *Config) {
+				f.FingerprintStrategy = "sha256"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"BadEncodingErrorMode",
+			func(f *Config) {
+				f.EncodingErrorMode = "ignore"
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"NFSDefaults",
+			func(f *Config) {
+				f.NFS = &NFSConfig{}
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, &nfsSettings{
+					reopenInterval:     defaultNFSReopenInterval,
+					staleHandleRetries: defaultNFSStaleHandleRetries,
+				}, m.readerFactory.readerConfig.nfs)
+			},
+		},
+		{
+			"NFSNegativeRetries",
+			func(f *Config) {
+				f.NFS = &NFSConfig{StaleHandleRetries: -1}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"Batch",
+			func(f *Config) {
+				f.Batch = &BatchConfig{MaxTokens: 100, MaxBytes: 1024, FlushInterval: time.Second}
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, &batchSettings{
+					maxTokens:     100,
+					maxBytes:      1024,
+					flushInterval: time.Second,
+				}, m.readerFactory.readerConfig.batchSettings)
+			},
+		},
+		{
+			"SkipHeaderLines",
+			func(f *Config) {
+				f.SkipHeaderLines = 1
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, 1, m.readerFactory.readerConfig.skipHeaderLines)
+			},
+		},
+		{
+			"NegativeSkipHeaderLines",
+			func(f *Config) {
+				f.SkipHeaderLines = -1
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"BatchNoLimit",
+			func(f *Config) {
+				f.Batch = &BatchConfig{FlushInterval: time.Second}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"RateLimit",
+			func(f *Config) {
+				f.RateLimit = &RateLimitConfig{PerFile: 1024}
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, 1024, m.readerFactory.readerConfig.rateLimitPerFile)
+			},
+		},
+		{
+			"RateLimitNoBudget",
+			func(f *Config) {
+				f.RateLimit = &RateLimitConfig{}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"PollBackoffDefaults",
+			func(f *Config) {
+				f.PollBackoff = &PollBackoffConfig{MaxPollInterval: time.Minute}
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, &pollBackoff{
+					baseInterval: 10 * time.Millisecond,
+					maxInterval:  time.Minute,
+					multiplier:   defaultPollBackoffMultiplier,
+					idlePolls:    defaultPollBackoffIdlePolls,
+					interval:     10 * time.Millisecond,
+				}, m.pollBackoff)
+			},
+		},
+		{
+			"PollBackoffMaxBelowPollInterval",
+			func(f *Config) {
+				f.PollBackoff = &PollBackoffConfig{MaxPollInterval: time.Millisecond}
+			},
+			require.Error,
+			nil,
+		},
+		{
+			"PollBackoffBadMultiplier",
+			func(f *Config) {
+				f.PollBackoff = &PollBackoffConfig{MaxPollInterval: time.Minute, Multiplier: 0.5}
+			},
+			require.Error,
+			nil,
+		},
 		{
 			"HeaderConfigNoFlag",
 			func(f *Config) {
@@ -589,6 +1141,33 @@ func TestBuild(t *testing.T) {
 			require.Error,
 			nil,
 		},
+		{
+			"GoodOrderingCriteriaMtime",
+			func(f *Config) {
+				f.OrderingCriteria.TopN = 10
+				f.OrderingCriteria.SortBy = []SortRuleImpl{
+					{
+						&MtimeSortRule{
+							BaseSortRule: BaseSortRule{
+								SortType: sortTypeMtime,
+							},
+						},
+					},
+				}
+			},
+			require.NoError,
+			func(t *testing.T, m *Manager) {
+				require.Equal(t, 10, m.finder.OrderingCriteria.TopN)
+			},
+		},
+		{
+			"NegativeOrderingCriteriaTopN",
+			func(f *Config) {
+				f.OrderingCriteria.TopN = -1
+			},
+			require.Error,
+			nil,
+		},
 		{
 			"BasicOrderingCriteriaTimetsamp",
 			func(f *Config) {
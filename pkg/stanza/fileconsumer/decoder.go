@@ -0,0 +1,115 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// errInvalidInput is returned by a strictDecoder for a byte sequence that is invalid in its encoding
+var errInvalidInput = errors.New("byte sequence is invalid in the configured encoding")
+
+// strictDecoder is a transform.Transformer that decodes like the decoder of an encoding, but
+// returns errInvalidInput for a byte sequence that is invalid in the encoding, instead of the
+// U+FFFD that the decoder substitutes for it. With drop, the sequence is removed instead.
+type strictDecoder struct {
+	decoder transform.Transformer
+	// replacement is U+FFFD in the encoding. It decodes to U+FFFD without being invalid.
+	replacement  []byte
+	drop         bool
+	decodeBuffer []byte
+}
+
+func newStrictDecoder(enc encoding.Encoding, drop bool) *strictDecoder {
+	replacement, err := enc.NewEncoder().Bytes([]byte(string(utf8.RuneError)))
+	if err != nil {
+		// The encoding can't represent U+FFFD, so every U+FFFD is substituted
+		replacement = nil
+	}
+	return &strictDecoder{
+		decoder:      enc.NewDecoder(),
+		replacement:  replacement,
+		drop:         drop,
+		decodeBuffer: make([]byte, 1<<12),
+	}
+}
+
+// Reset implements transform.Transformer
+func (d *strictDecoder) Reset() {
+	d.decoder.Reset()
+}
+
+// Transform implements transform.Transformer. The decoder is given one more byte of src at a
+// time until it decodes a character, so that every character is checked against the bytes
+// that it was decoded from.
+func (d *strictDecoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		var n, m int
+		for end := nSrc + 1; ; end++ {
+			n, m, err = d.decoder.Transform(dst[nDst:], src[nSrc:end], atEOF && end == len(src))
+			if m > 0 || !errors.Is(err, transform.ErrShortSrc) || end == len(src) {
+				break
+			}
+		}
+		n, substituted := d.dropSubstituted(dst[nDst:nDst+n], src[nSrc:nSrc+m])
+		if substituted && !d.drop {
+			return nDst, nSrc, errInvalidInput
+		}
+		nDst, nSrc = nDst+n, nSrc+m
+		if err != nil && (m == 0 || !errors.Is(err, transform.ErrShortSrc)) {
+			return nDst, nSrc, err
+		}
+	}
+	return nDst, nSrc, nil
+}
+
+// dropSubstituted removes the U+FFFD that the decoder substituted for bytes of src from
+// decoded, and returns its new length and whether there were any. The decoder only needs
+// more than the bytes of a character when they are invalid, in which case the U+FFFD for
+// them is followed by the character of the last bytes.
+func (d *strictDecoder) dropSubstituted(decoded, src []byte) (int, bool) {
+	var n int
+	var substituted bool
+	for i := 0; i < len(decoded); {
+		r, size := utf8.DecodeRune(decoded[i:])
+		var invalid bool
+		if r == utf8.RuneError && i == 0 {
+			invalid = size < len(decoded) || !bytes.Equal(src, d.replacement)
+		} else if r == utf8.RuneError {
+			invalid = !bytes.HasSuffix(src, d.replacement)
+		}
+		if invalid {
+			substituted = true
+		} else {
+			n += copy(decoded[n:], decoded[i:i+size])
+		}
+		i += size
+	}
+	return n, substituted
+}
+
+// Decode converts the bytes in msgBuf to utf-8 like helper.Encoding.Decode
+func (d *strictDecoder) Decode(msgBuf []byte) ([]byte, error) {
+	for {
+		d.Reset()
+		nDst, _, err := d.Transform(d.decodeBuffer, msgBuf, true)
+		if err == nil {
+			return d.decodeBuffer[:nDst], nil
+		}
+		if errors.Is(err, transform.ErrShortDst) {
+			d.decodeBuffer = make([]byte, len(d.decodeBuffer)*2)
+			continue
+		}
+		if errors.Is(err, errInvalidInput) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("transform encoding: %w", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestStrictDecoder(t *testing.T) {
+	utf16le := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+
+	testCases := []struct {
+		name     string
+		enc      encoding.Encoding
+		input    []byte
+		expected string
+		dropped  string
+	}{
+		{"UTF8Valid", unicode.UTF8, []byte("héllo"), "héllo", "héllo"},
+		{"UTF8Replacement", unicode.UTF8, []byte("a�b"), "a�b", "a�b"},
+		{"UTF8Invalid", unicode.UTF8, []byte{'a', 0xc5, 'b'}, "", "ab"},
+		{"UTF8InvalidBeforeReplacement", unicode.UTF8, append([]byte{'a', 0xc5}, "�"...), "", "a�"},
+		{"UTF8InvalidTwice", unicode.UTF8, []byte{'a', 0xc5, 0xff, 'b'}, "", "ab"},
+		{"UTF8Truncated", unicode.UTF8, []byte{'a', 0xe2, 0x82}, "", "a"},
+		{"UTF16Replacement", utf16le, []byte{'a', 0, 0xfd, 0xff}, "a�", "a�"},
+		{"UTF16UnpairedSurrogate", utf16le, []byte{'a', 0, 0x00, 0xd8, 'b', 0}, "", "ab"},
+		{"UTF16UnpairedSurrogateBeforeReplacement", utf16le, []byte{0x00, 0xd8, 0xfd, 0xff}, "", "�"},
+		{"UTF16OddLength", utf16le, []byte{'a', 0, 'b'}, "", "a"},
+		{"ShiftJIS", japanese.ShiftJIS, []byte{0x82, 0xa0, 'a'}, "あa", "あa"},
+		{"ShiftJISInvalid", japanese.ShiftJIS, []byte{0x82, ' '}, "", " "},
+		{"Windows1252Undefined", charmap.Windows1252, []byte{'a', 0x81, 'b'}, "", "ab"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded, err := newStrictDecoder(tc.enc, false).Decode(tc.input)
+			if tc.expected == "" {
+				require.ErrorIs(t, err, errInvalidInput)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, string(decoded))
+			}
+
+			decoded, err = newStrictDecoder(tc.enc, true).Decode(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.dropped, string(decoded))
+		})
+	}
+}
+
+func TestStrictDecoderGrowsBuffer(t *testing.T) {
+	d := newStrictDecoder(unicode.UTF8, false)
+	input := make([]byte, 3*len(d.decodeBuffer))
+	for i := range input {
+		input[i] = 'a'
+	}
+	decoded, err := d.Decode(input)
+	require.NoError(t, err)
+	require.Equal(t, input, decoded)
+}
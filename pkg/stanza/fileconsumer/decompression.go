@@ -0,0 +1,102 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	decompressionNone = "none"
+	decompressionGzip = "gzip"
+	decompressionAuto = "auto"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// isCompressed reports whether file must be decompressed before it is read
+func isCompressed(file *os.File, decompression string) bool {
+	switch decompression {
+	case decompressionGzip:
+		return true
+	case decompressionAuto:
+		if strings.HasSuffix(file.Name(), ".gz") {
+			return true
+		}
+		magic := make([]byte, len(gzipMagic))
+		n, _ := file.ReadAt(magic, 0)
+		return bytes.Equal(magic[:n], gzipMagic)
+	default:
+		return false
+	}
+}
+
+// decompressor counts the bytes read from the decompressed stream of a file
+type decompressor struct {
+	io.Reader
+	pos int64
+}
+
+func (d *decompressor) Read(p []byte) (int, error) {
+	n, err := d.Reader.Read(p)
+	d.pos += int64(n)
+	return n, err
+}
+
+// openDecompressor positions a new decompressed stream of the file at the read offset.
+// Compressed streams cannot be resumed at an arbitrary position, so the stream is
+// decompressed from the start and the content that was already emitted is discarded.
+func (r *Reader) openDecompressor() error {
+	r.decompressor = nil
+	if _, err := r.file.Seek(0, 0); err != nil {
+		return err
+	}
+	stream, err := r.openStream()
+	if err != nil {
+		return err
+	}
+	d := &decompressor{Reader: stream}
+	if _, err = io.CopyN(io.Discard, d, r.readOffset()); err != nil {
+		return fmt.Errorf("skip to offset: %w", err)
+	}
+	r.decompressor = d
+	return nil
+}
+
+// openStream returns the decompressed content of the file, or of its archive member
+func (r *Reader) openStream() (io.Reader, error) {
+	if r.ArchiveMember != "" {
+		return openArchiveMember(r.file, r.ArchiveMember)
+	}
+	gz, err := gzip.NewReader(r.file)
+	if err != nil {
+		return nil, fmt.Errorf("read gzip header: %w", err)
+	}
+	return gz, nil
+}
+
+// compressedSize returns the size of the compressed file, and whether it was read
+// to that size before, in which case it is not decompressed again until it grows.
+func (r *Reader) compressedSize() (int64, bool) {
+	info, err := r.file.Stat()
+	if err != nil {
+		return 0, false
+	}
+	return info.Size(), r.CompressedOffset > 0 && r.CompressedOffset == info.Size()
+}
+
+// markCompressedReadComplete records that every byte of the decompressed stream of
+// a compressed file of the given size was emitted
+func (r *Reader) markCompressedReadComplete(size int64) {
+	if r.decompressor == nil || r.decompressor.pos != r.readOffset() {
+		// A partial token at the end of the stream may still be flushed later
+		return
+	}
+	r.CompressedOffset = size
+}
@@ -0,0 +1,171 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func gzipLines(t *testing.T, lines ...string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	for _, line := range lines {
+		_, err := w.Write([]byte(line + "\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func appendFile(t *testing.T, path string, content []byte) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Write(content)
+	require.NoError(t, err)
+}
+
+func TestDecompressionDetection(t *testing.T) {
+	tempDir := t.TempDir()
+	gzPath := filepath.Join(tempDir, "app.log.1.gz")
+	appendFile(t, gzPath, gzipLines(t, "compressed"))
+	magicPath := filepath.Join(tempDir, "app.log.2")
+	appendFile(t, magicPath, gzipLines(t, "compressed"))
+	plainPath := filepath.Join(tempDir, "app.log")
+	appendFile(t, plainPath, []byte("plain\n"))
+
+	testCases := []struct {
+		decompression string
+		path          string
+		expected      bool
+	}{
+		{decompressionAuto, gzPath, true},
+		{decompressionAuto, magicPath, true},
+		{decompressionAuto, plainPath, false},
+		{decompressionGzip, plainPath, true},
+		{decompressionNone, gzPath, false},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s_%s", tc.decompression, filepath.Base(tc.path)), func(t *testing.T) {
+			require.Equal(t, tc.expected, isCompressed(openFile(t, tc.path), tc.decompression))
+		})
+	}
+}
+
+func TestDecompressionReadToEnd(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	f.readerConfig.decompression = decompressionAuto
+	f.fromBeginning = false
+
+	path := filepath.Join(t.TempDir(), "app.log.1.gz")
+	appendFile(t, path, gzipLines(t, "line 1", "line 2"))
+
+	r, err := f.newReaderBuilder().withFile(openFile(t, path)).build()
+	require.NoError(t, err)
+	defer r.Close()
+
+	// Compressed files are read from the beginning regardless of start_at
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("line 1"), readToken(t, emitChan))
+	require.Equal(t, []byte("line 2"), readToken(t, emitChan))
+	require.Equal(t, int64(len("line 1\nline 2\n")), r.Offset)
+	require.Equal(t, int64(len(r.Fingerprint.FirstBytes)), r.CompressedOffset)
+
+	// The file is not decompressed again until it grows
+	r.ReadToEnd(context.Background())
+	expectNoTokens(t, emitChan)
+	appendFile(t, path, gzipLines(t, "line 3"))
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("line 3"), readToken(t, emitChan))
+	expectNoTokens(t, emitChan)
+}
+
+func TestDecompressionResumeMidFile(t *testing.T) {
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %02d", i)
+	}
+	path := filepath.Join(t.TempDir(), "app.log.1.gz")
+	appendFile(t, path, gzipLines(t, lines...))
+
+	f, emitChan := testReaderFactory(t)
+
+	// Interrupt the first read at the 40th line, as a shutdown would
+	ctx, cancel := context.WithCancel(context.Background())
+	var emitted int
+	f.readerConfig = &readerConfig{
+		fingerprintSize: f.readerConfig.fingerprintSize,
+		maxLogSize:      f.readerConfig.maxLogSize,
+		decompression:   decompressionAuto,
+		emit: func(ctx context.Context, _ []byte, _ map[string]any) error {
+			if emitted++; emitted == 40 {
+				cancel()
+				return ctx.Err()
+			}
+			return nil
+		},
+	}
+	r, err := f.newReaderBuilder().withFile(openFile(t, path)).build()
+	require.NoError(t, err)
+	r.ReadToEnd(ctx)
+	r.Close()
+	require.Equal(t, int64(39*len("line 00\n")), r.Offset)
+	require.Zero(t, r.CompressedOffset)
+
+	// A reader for a new handle of the same file resumes at the interrupted line
+	f.readerConfig = &readerConfig{
+		fingerprintSize: f.readerConfig.fingerprintSize,
+		maxLogSize:      f.readerConfig.maxLogSize,
+		decompression:   decompressionAuto,
+		emit:            testEmitFunc(emitChan),
+	}
+	r2, err := f.copy(r, openFile(t, path))
+	require.NoError(t, err)
+	defer r2.Close()
+	r2.ReadToEnd(context.Background())
+	for _, line := range lines[39:] {
+		require.Equal(t, []byte(line), readToken(t, emitChan))
+	}
+	expectNoTokens(t, emitChan)
+}
+
+func TestDecompressionRestart(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.Decompression = decompressionAuto
+
+	path := filepath.Join(tempDir, "app.log.1.gz")
+	appendFile(t, path, gzipLines(t, "line 1", "line 2"))
+
+	persister := testutil.NewUnscopedMockPersister()
+	op1, emitCalls1 := buildTestManager(t, cfg)
+	op1.persister = persister
+	op1.poll(context.Background())
+	waitForTokens(t, emitCalls1, [][]byte{[]byte("line 1"), []byte("line 2")})
+	require.NoError(t, op1.Stop())
+
+	// Concatenated gzip members form a single stream
+	appendFile(t, path, gzipLines(t, "line 3"))
+
+	op2, emitCalls2 := buildTestManager(t, cfg)
+	op2.persister = persister
+	require.NoError(t, op2.loadLastPollFiles(context.Background()))
+	op2.poll(context.Background())
+	defer func() {
+		require.NoError(t, op2.Stop())
+	}()
+	waitForToken(t, emitCalls2, []byte("line 3"))
+	expectNoTokens(t, emitCalls2)
+}
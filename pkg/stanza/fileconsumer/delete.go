@@ -0,0 +1,31 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import "go.uber.org/zap"
+
+const (
+	// onDeleteFinishInode reads a file that was deleted while it was open to its end
+	onDeleteFinishInode = "finish-inode"
+	// onDeleteCloseImmediately stops reading a file as soon as it was deleted
+	onDeleteCloseImmediately = "close-immediately"
+)
+
+// deletedWhileReading reports whether the file of the reader was deleted while it was open,
+// and is no longer read with on_delete_while_reading close-immediately. The pending tokens
+// of the reader are still emitted.
+func (r *Reader) deletedWhileReading() bool {
+	if r.onDeleteWhileReading != onDeleteCloseImmediately || r.file == nil || r.evicted || r.fifo != nil {
+		return false
+	}
+	deleted, err := fileDeleted(r.file)
+	if err != nil {
+		r.Debugw("Failed to check whether the file was deleted", zap.Error(err))
+		return false
+	}
+	if deleted {
+		r.Debugw("Stopped reading file that was deleted while it was read")
+	}
+	return deleted
+}
@@ -0,0 +1,27 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+// +build !windows
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileDeleted reports whether an open file has no link left, once it was deleted. The open
+// file can still be read until it is closed.
+func fileDeleted(file *os.File) (bool, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, fmt.Errorf("unexpected file info type %T", info.Sys())
+	}
+	return stat.Nlink == 0, nil
+}
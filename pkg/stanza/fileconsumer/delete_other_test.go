@@ -0,0 +1,60 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+// +build !windows
+
+package fileconsumer
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func TestDeleteWhileReading(t *testing.T) {
+	for _, policy := range []string{onDeleteFinishInode, onDeleteCloseImmediately} {
+		policy := policy
+		t.Run(policy, func(t *testing.T) {
+			tempDir := t.TempDir()
+			cfg := NewConfig().includeDir(tempDir)
+			cfg.StartAt = "beginning"
+			cfg.OnDeleteWhileReading = policy
+			operator, emitCalls := buildTestManager(t, cfg)
+			operator.persister = testutil.NewMockPersister("test")
+			defer func() {
+				require.NoError(t, operator.Stop())
+			}()
+
+			temp := openTemp(t, tempDir)
+			writeString(t, temp, "testlog1\n")
+			operator.poll(context.Background())
+			waitForToken(t, emitCalls, []byte("testlog1"))
+
+			// The file is deleted while the reader still holds it open
+			writeString(t, temp, "testlog2\n")
+			require.NoError(t, os.Remove(temp.Name()))
+			operator.poll(context.Background())
+			if policy == onDeleteFinishInode {
+				waitForToken(t, emitCalls, []byte("testlog2"))
+			}
+			expectNoTokens(t, emitCalls)
+		})
+	}
+}
+
+func TestFileDeleted(t *testing.T) {
+	temp := openTemp(t, t.TempDir())
+	deleted, err := fileDeleted(temp)
+	require.NoError(t, err)
+	require.False(t, deleted)
+
+	require.NoError(t, os.Remove(temp.Name()))
+	deleted, err = fileDeleted(temp)
+	require.NoError(t, err)
+	require.True(t, deleted)
+}
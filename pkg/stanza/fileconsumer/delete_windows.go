@@ -0,0 +1,34 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+// +build windows
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileStandardInfo is the FILE_STANDARD_INFO of a file
+type fileStandardInfo struct {
+	AllocationSize int64
+	EndOfFile      int64
+	NumberOfLinks  uint32
+	DeletePending  bool
+	Directory      bool
+}
+
+// fileDeleted reports whether an open file was deleted. A file that is deleted while it is
+// open is only removed once its last handle is closed, and can still be read until then.
+func fileDeleted(file *os.File) (bool, error) {
+	var info fileStandardInfo
+	err := windows.GetFileInformationByHandleEx(windows.Handle(file.Fd()), windows.FileStandardInfo, (*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+	if err != nil {
+		return false, err
+	}
+	return info.DeletePending || info.NumberOfLinks == 0, nil
+}
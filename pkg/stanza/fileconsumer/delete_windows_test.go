@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+// +build windows
+
+package fileconsumer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
+)
+
+// openShareDelete opens the file at path so that it can be deleted while it is open, like
+// files are on other platforms
+func openShareDelete(t *testing.T, path string) *os.File {
+	name, err := windows.UTF16PtrFromString(path)
+	require.NoError(t, err)
+	handle, err := windows.CreateFile(name, windows.GENERIC_READ,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	require.NoError(t, err)
+	file := os.NewFile(uintptr(handle), path)
+	t.Cleanup(func() { _ = file.Close() })
+	return file
+}
+
+func TestDeleteWhileReading(t *testing.T) {
+	for _, policy := range []string{onDeleteFinishInode, onDeleteCloseImmediately} {
+		policy := policy
+		t.Run(policy, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			require.NoError(t, os.WriteFile(path, []byte("testlog1\ntestlog2\n"), 0600))
+
+			f, emitChan := testReaderFactory(t)
+			f.readerConfig.onDeleteWhileReading = policy
+			file := openShareDelete(t, path)
+			fp, err := f.newFingerprint(file)
+			require.NoError(t, err)
+			r, err := f.newReader(file, fp)
+			require.NoError(t, err)
+			defer r.Close()
+
+			// The file is deleted while the reader holds it open
+			require.NoError(t, os.Remove(path))
+			deleted, err := fileDeleted(file)
+			require.NoError(t, err)
+			require.True(t, deleted)
+
+			r.ReadToEnd(context.Background())
+			if policy == onDeleteFinishInode {
+				require.Equal(t, []byte("testlog1"), readToken(t, emitChan))
+				require.Equal(t, []byte("testlog2"), readToken(t, emitChan))
+			}
+			expectNoTokens(t, emitChan)
+		})
+	}
+}
@@ -8,3 +8,6 @@ import (
 )
 
 type Callback func(ctx context.Context, token []byte, attrs map[string]any) error
+
+// BatchCallback receives tokens read from a single file that are emitted together
+type BatchCallback func(ctx context.Context, tokens [][]byte, attrs map[string]any) error
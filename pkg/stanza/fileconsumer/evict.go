@@ -0,0 +1,51 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// evictIdleReaders closes the files of the readers that did not read new content since
+// pollStart, least recently active first, until at most maxOpenFiles files remain open.
+// Evicted readers keep their fingerprint and offset, and reopen their file when read again.
+func (m *Manager) evictIdleReaders(readers []*Reader, pollStart time.Time) {
+	if m.maxOpenFiles == 0 {
+		return
+	}
+	idle := make([]*Reader, 0, len(readers))
+	open := 0
+	for _, r := range readers {
+		if r.evicted || r.removed {
+			continue
+		}
+		open++
+		if r.lastChange.Before(pollStart) {
+			idle = append(idle, r)
+		}
+	}
+	sort.SliceStable(idle, func(i, j int) bool {
+		return idle[i].lastChange.Before(idle[j].lastChange)
+	})
+	for i := 0; i < len(idle) && open > m.maxOpenFiles; i++ {
+		idle[i].evict()
+		open--
+	}
+}
+
+// evict closes the file of the reader until it is read again
+func (r *Reader) evict() {
+	// Files are already closed if the roller does not keep them open between polls
+	if err := r.file.Close(); err == nil {
+		openFiles.Add(-1)
+	} else if !errors.Is(err, os.ErrClosed) {
+		r.Debugw("Problem closing evicted file", zap.Error(err))
+	}
+	r.evicted = true
+}
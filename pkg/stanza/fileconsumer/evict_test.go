@@ -0,0 +1,90 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func TestEvictIdleReaders(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.MaxOpenFiles = 1
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	active := openTemp(t, tempDir)
+	writeString(t, active, "active1\n")
+	idle := openTemp(t, tempDir)
+	writeString(t, idle, "idle1\n")
+
+	operator.poll(context.Background())
+	waitForTokens(t, emitCalls, [][]byte{[]byte("active1"), []byte("idle1")})
+	for _, r := range operator.knownFiles {
+		require.False(t, r.evicted)
+	}
+
+	// The file that did not change in the last poll is closed
+	writeString(t, active, "active2\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("active2"))
+	expectNoTokens(t, emitCalls)
+	for _, r := range operator.knownFiles {
+		require.Equal(t, r.file.Name() == idle.Name(), r.evicted, r.file.Name())
+	}
+
+	// The evicted file resumes at its offset
+	writeString(t, idle, "idle2\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("idle2"))
+	expectNoTokens(t, emitCalls)
+}
+
+func TestEvictedReaderReopens(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, "testlog1\n")
+
+	r, err := f.newReaderBuilder().withFile(openFile(t, temp.Name())).build()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("testlog1"), readToken(t, emitChan))
+
+	r.evict()
+	writeString(t, temp, "testlog2\ntestlog3\n")
+	r.ReadToEnd(context.Background())
+	require.False(t, r.evicted)
+	require.Equal(t, []byte("testlog2"), readToken(t, emitChan))
+	require.Equal(t, []byte("testlog3"), readToken(t, emitChan))
+	expectNoTokens(t, emitChan)
+	r.Close()
+}
+
+func TestEvictedReaderReplacedFile(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, "testlog1\n")
+
+	r, err := f.newReaderBuilder().withFile(openFile(t, temp.Name())).build()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("testlog1"), readToken(t, emitChan))
+
+	// A different file at the path of an evicted reader is not read from its offset
+	r.evict()
+	require.NoError(t, os.WriteFile(temp.Name(), []byte("replaced\n"), 0600))
+	r.ReadToEnd(context.Background())
+	require.True(t, r.evicted)
+	expectNoTokens(t, emitChan)
+}
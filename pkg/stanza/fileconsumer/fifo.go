@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fifoReadTimeout is how long a read waits for a named pipe to have data
+const fifoReadTimeout = 10 * time.Millisecond
+
+// isNamedPipe returns true if the path refers to a named pipe
+func isNamedPipe(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeNamedPipe != 0
+}
+
+// fifoBuffer retains the bytes read from a named pipe that were not consumed yet.
+// Unlike a file, a pipe cannot be read again at the offset where the next poll resumes.
+type fifoBuffer struct {
+	buf   []byte
+	start int64 // offset of the first byte of buf
+	pos   int64 // offset of the next read
+}
+
+// readFIFO reads from the retained bytes of a named pipe, then from the pipe itself.
+// A pipe without data, or without a writer, is at its end until the next poll.
+func (r *Reader) readFIFO(dst []byte) (int, error) {
+	f := r.fifo
+	if consumed := r.readOffset() - f.start; consumed > 0 {
+		f.buf = f.buf[min0(int(consumed), len(f.buf)):]
+		f.start += consumed
+	}
+	if i := f.pos - f.start; i < int64(len(f.buf)) {
+		n := copy(dst, f.buf[i:])
+		f.pos += int64(n)
+		return n, nil
+	}
+
+	if err := r.file.SetReadDeadline(time.Now().Add(fifoReadTimeout)); err != nil {
+		return 0, err
+	}
+	n, err := fileRead(r.file, dst)
+	f.buf = append(f.buf, dst[:n]...)
+	f.pos += int64(n)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// fifoReader returns the reader of a named pipe, which is kept open while the path matches
+func (m *Manager) fifoReader(ctx context.Context, path string) *Reader {
+	if r, ok := m.fifoReaders[path]; ok {
+		return r
+	}
+	m.Infow("Started watching named pipe", "path", path)
+	file, err := openFIFO(path)
+	if err != nil {
+		m.Debugw("Failed to open named pipe", "path", path, zap.Error(err))
+		return nil
+	}
+	r, err := m.readerFactory.newReader(file, nil)
+	if err != nil {
+		m.Errorw("Failed to create reader", zap.Error(err))
+		return nil
+	}
+	m.fifoReaders[path] = r
+	m.fileOpened(ctx, r)
+	return r
+}
+
+// closeLostFIFOs closes the readers of named pipes that no longer match
+func (m *Manager) closeLostFIFOs(ctx context.Context, matches []string) {
+	current := make(map[string]struct{}, len(matches))
+	for _, path := range matches {
+		current[path] = struct{}{}
+	}
+	for path, r := range m.fifoReaders {
+		if _, ok := current[path]; !ok {
+			r.flushBatch(ctx)
+			r.Close()
+			delete(m.fifoReaders, path)
+			m.fileClosed(ctx, r)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+// +build !windows
+
+package fileconsumer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func TestFIFO(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "end"
+	cfg.AllowFIFO = true
+	cfg.Splitter.Flusher.Period = time.Hour
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewUnscopedMockPersister()
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	path := filepath.Join(tempDir, "app.pipe")
+	require.NoError(t, syscall.Mkfifo(path, 0600))
+
+	// Without a writer the pipe is at its end
+	operator.poll(context.Background())
+	require.Len(t, operator.fifoReaders, 1)
+	require.Empty(t, operator.knownFiles)
+
+	writer, err := os.OpenFile(path, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = writer.WriteString("line 1\nline 2\npart")
+	require.NoError(t, err)
+
+	// Pipes are read from their start regardless of start_at
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("line 1"))
+	waitForToken(t, emitCalls, []byte("line 2"))
+	expectNoTokens(t, emitCalls)
+
+	// A partial token is kept until the rest of it arrives
+	_, err = writer.WriteString("ial\n")
+	require.NoError(t, err)
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("partial"))
+
+	// The pipe is still read after the writer goes away and a new one connects
+	require.NoError(t, writer.Close())
+	operator.poll(context.Background())
+	expectNoTokens(t, emitCalls)
+
+	writer, err = os.OpenFile(path, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	defer writer.Close()
+	_, err = writer.WriteString("line 3\n")
+	require.NoError(t, err)
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("line 3"))
+
+	// The reader is closed once the pipe no longer matches
+	require.NoError(t, os.Remove(path))
+	operator.poll(context.Background())
+	require.Empty(t, operator.fifoReaders)
+}
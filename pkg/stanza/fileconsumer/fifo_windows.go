@@ -0,0 +1,17 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+// +build windows
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"errors"
+	"os"
+)
+
+// openFIFO is not supported, named pipes on Windows are not part of the file system
+func openFIFO(string) (*os.File, error) {
+	return nil, errors.New("named pipes are not supported on windows")
+}
@@ -9,9 +9,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/bmatcuk/doublestar/v4"
+	"go.opencensus.io/stats"
 	"go.uber.org/zap"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/fingerprint"
@@ -23,6 +26,15 @@ const (
 	logFilePath         = "log.file.path"
 	logFileNameResolved = "log.file.name_resolved"
 	logFilePathResolved = "log.file.path_resolved"
+	logFileInode        = "log.file.inode"
+	logFileDevice       = "log.file.device"
+	logFileSize         = "log.file.size"
+	logFileModTime      = "log.file.mtime"
+	logFileOwnerUID     = "log.file.owner.uid"
+	logFileOwnerGID     = "log.file.owner.gid"
+	logFileMode         = "log.file.mode"
+	logFileRecordOffset = "log.file.record_offset"
+	logFileRecordLength = "log.file.record_length"
 )
 
 // Deprecated: [v0.82.0] Use emit.Callback instead. This will be removed in a future release, tentatively v0.84.0.
@@ -38,34 +50,60 @@ type Manager struct {
 	roller        roller
 	persister     operator.Persister
 
+	// storageKeyPrefix namespaces the keys of the persister, so that several managers can
+	// share a storage client
+	storageKeyPrefix string
+
 	pollInterval    time.Duration
+	pollBackoff     *pollBackoff
 	maxBatches      int
 	maxBatchFiles   int
+	maxOpenFiles    int
 	deleteAfterRead bool
-
-	knownFiles []*Reader
-	seenPaths  map[string]struct{}
-
-	currentFps []*fingerprint.Fingerprint
+	deleteGrace     time.Duration
+	maxFileAge      time.Duration
+	moveAfterRead   bool
+	moveDestination string
+	skipFingerprint bool
+	archiveMode     string
+	// fingerprintConcurrency is the number of newly found files that are fingerprinted at once
+	fingerprintConcurrency int
+	observer               ReaderObserver
+	// heartbeatInterval is how long a file may have no new content before a heartbeat is
+	// emitted for it, and now is the time it is measured with
+	heartbeatInterval time.Duration
+	now               func() time.Time
+
+	knownFiles  []*Reader
+	seenPaths   map[string]struct{}
+	collisions  map[[2]string]struct{}
+	fifoReaders map[string]*Reader
+
+	currentFps   []*fingerprint.Fingerprint
+	currentPaths []string
 }
 
 func (m *Manager) Start(persister operator.Persister) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	m.cancel = cancel
+	if persister != nil && m.storageKeyPrefix != "" {
+		persister = operator.NewScopedPersister(m.storageKeyPrefix, persister)
+	}
 	m.persister = persister
+	if persister != nil {
+		m.readerFactory.readerConfig.headerPersister = operator.NewScopedPersister(headerPersisterScope, persister)
+	}
+
+	if err := m.validateStartup(ctx); err != nil {
+		return err
+	}
 
 	// Load offsets from disk
 	if err := m.loadLastPollFiles(ctx); err != nil {
 		return fmt.Errorf("read known files from database: %w", err)
 	}
 
-	if files, err := m.finder.FindFiles(); err != nil {
-		m.Warnw("error occurred while finding files", "error", err.Error())
-	} else if len(files) == 0 {
-		m.Warnw("no files match the configured include patterns",
-			"include", m.finder.Include,
-			"exclude", m.finder.Exclude)
-	}
+	m.logMatchPreview()
 
 	// Start polling goroutine
 	m.startPoller(ctx)
@@ -73,26 +111,94 @@ func (m *Manager) Start(persister operator.Persister) error {
 	return nil
 }
 
+// logMatchPreview logs the files that the configured patterns match at startup, and
+// warns about the patterns that match nothing
+func (m *Manager) logMatchPreview() {
+	result, err := m.finder.DryRun()
+	if err != nil {
+		m.Warnw("error occurred while finding files", "error", err.Error())
+	}
+	for _, warning := range result.Warnings {
+		m.Warn(warning)
+	}
+	m.Debugw("Files matched at startup", "files", result.Files)
+}
+
+const startupProbeKey = "startupProbe"
+
+// validateStartup reports misconfigurations that would otherwise leave the consumer
+// silently idle: include patterns rooted in missing directories and an offset
+// storage that does not accept writes.
+func (m *Manager) validateStartup(ctx context.Context) error {
+	if err := m.validateIncludeDirs(); err != nil {
+		return err
+	}
+
+	if err := m.persister.Set(ctx, startupProbeKey, []byte{}); err != nil {
+		return fmt.Errorf("offset storage is not writable, check the storage extension: %w", err)
+	}
+	if err := m.persister.Delete(ctx, startupProbeKey); err != nil {
+		return fmt.Errorf("offset storage is not writable, check the storage extension: %w", err)
+	}
+	return nil
+}
+
+// validateIncludeDirs returns an error unless at least one include pattern is rooted in an existing directory.
+func (m *Manager) validateIncludeDirs() error {
+	bases := make([]string, 0, len(m.finder.Include))
+	for _, include := range m.finder.Include {
+		base, _ := doublestar.SplitPattern(filepath.ToSlash(include))
+		if info, err := os.Stat(filepath.FromSlash(base)); err == nil && info.IsDir() {
+			return nil
+		}
+		bases = append(bases, base)
+	}
+	return fmt.Errorf("no `include` pattern matches an existing directory, create one of %v or fix the pattern", bases)
+}
+
 // Stop will stop the file monitoring process
 func (m *Manager) Stop() error {
 	m.cancel()
 	m.wg.Wait()
+	// Emit the tokens still waiting in partial batches before their offsets are persisted
+	for _, reader := range m.knownFiles {
+		reader.flushBatch(context.Background())
+	}
+	m.closeLostFIFOs(context.Background(), nil)
+	// Reads interrupted by the cancellation stop at a token boundary,
+	// persist their offsets with a context that is still valid.
+	if m.persister != nil {
+		m.syncLastPollFiles(context.Background())
+	}
 	m.roller.cleanup()
 	for _, reader := range m.knownFiles {
 		reader.Close()
 	}
 	m.knownFiles = nil
 	m.cancel = nil
+	recordOpenFiles(context.Background())
 	return nil
 }
 
+// NewReaderAt creates a reader for the file at path that resumes at offset, regardless of
+// start_at, for positions that were stored outside of the Manager. firstBytes are the bytes
+// that the fingerprint of the file was taken from, the FirstBytes of the Fingerprint of its
+// Reader. ErrFingerprintMismatch is returned if the file no longer starts with them.
+func (m *Manager) NewReaderAt(path string, offset int64, firstBytes []byte) (*Reader, error) {
+	return m.readerFactory.newReaderAt(path, offset, &fingerprint.Fingerprint{
+		FirstBytes: firstBytes,
+		Offset:     m.readerFactory.readerConfig.fingerprintOffset,
+	})
+}
+
 // startPoller kicks off a goroutine that will poll the filesystem periodically,
 // checking if there are new files or new logs in the watched files
 func (m *Manager) startPoller(ctx context.Context) {
 	m.wg.Add(1)
 	go func() {
 		defer m.wg.Done()
-		globTicker := time.NewTicker(m.pollInterval)
+		interval := m.pollInterval
+		globTicker := time.NewTicker(interval)
 		defer globTicker.Stop()
 
 		for {
@@ -103,12 +209,18 @@ func (m *Manager) startPoller(ctx context.Context) {
 			}
 
 			m.poll(ctx)
+			if next := m.nextPollInterval(ctx); next != interval {
+				interval = next
+				globTicker.Reset(interval)
+			}
 		}
 	}()
 }
 
 // poll checks all the watched paths for new entries
 func (m *Manager) poll(ctx context.Context) {
+	defer recordOpenFiles(ctx)
+
 	// Increment the generation on all known readers
 	// This is done here because the next generation is about to start
 	for i := 0; i < len(m.knownFiles); i++ {
@@ -123,6 +235,9 @@ func (m *Manager) poll(ctx context.Context) {
 	if err != nil {
 		m.Errorf("error finding files: %s", err)
 	}
+	if m.readerFactory.readerConfig.allowFIFO {
+		m.closeLostFIFOs(ctx, matches)
+	}
 
 	for len(matches) > m.maxBatchFiles {
 		m.consume(ctx, matches[:m.maxBatchFiles])
@@ -142,13 +257,48 @@ func (m *Manager) poll(ctx context.Context) {
 
 func (m *Manager) consume(ctx context.Context, paths []string) {
 	m.Debug("Consuming files")
+	consumeStart := time.Now()
 	readers := make([]*Reader, 0, len(paths))
+	files := make([]*openedFile, 0, len(paths))
+	var fifos, retired, retiring []*Reader
+	var pending []string
 	for _, path := range paths {
-		r := m.makeReader(path)
-		if r != nil {
-			readers = append(readers, r)
+		if m.readerFactory.readerConfig.allowFIFO && isNamedPipe(path) {
+			// Named pipes are not tracked by fingerprint, their reader stays open instead
+			if r := m.fifoReader(ctx, path); r != nil {
+				fifos = append(fifos, r)
+			}
+			continue
+		}
+		if m.stale(path) {
+			// Files that were not modified for max_file_age are not read
+			if r, closing := m.retire(ctx, path); r != nil {
+				retired = append(retired, r)
+				if closing {
+					retiring = append(retiring, r)
+				}
+			}
+			continue
+		}
+		if m.skipFingerprint {
+			if r, ok := m.makeDeferredReader(ctx, path); ok {
+				if r != nil {
+					readers = append(readers, r)
+				}
+				continue
+			}
+		}
+		m.logNewPath(path)
+		pending = append(pending, path)
+	}
+	// The files are fingerprinted concurrently, then those with a duplicate fingerprint
+	// are discarded in the order of their paths, as if they were opened one at a time
+	for i, f := range m.makeFingerprints(pending) {
+		if f = m.keepFile(ctx, pending[i], f); f != nil {
+			files = append(files, f)
 		}
 	}
+	readers = append(readers, m.makeReaders(ctx, files)...)
 
 	// take care of files which disappeared from the pattern since the last poll cycle
 	// this can mean either files which were removed, or rotated into a name not matching the pattern
@@ -161,29 +311,35 @@ func (m *Manager) consume(ctx context.Context, paths []string) {
 		go func(r *Reader) {
 			defer wg.Done()
 			r.ReadToEnd(ctx)
-			// Delete a file if deleteAfterRead is enabled and we reached the end of the file
+			// Delete or move a file if enabled and we reached the end of the file
 			if m.deleteAfterRead && r.eof {
-				r.Close()
-				if err := os.Remove(r.file.Name()); err != nil {
-					m.Errorf("could not delete %s", r.file.Name())
-				}
+				m.deleteFile(ctx, r)
+			} else if m.moveAfterRead && r.eof {
+				m.moveFile(ctx, r)
 			}
 		}(reader)
 	}
+	for _, reader := range fifos {
+		wg.Add(1)
+		go func(r *Reader) {
+			defer wg.Done()
+			r.ReadToEnd(ctx)
+		}(reader)
+	}
 	wg.Wait()
 
 	// Save off any files that were not fully read
-	if m.deleteAfterRead {
+	if m.deleteAfterRead || m.moveAfterRead {
 		unfinished := make([]*Reader, 0, len(readers))
 		for _, r := range readers {
-			if !r.eof {
+			if !r.removed {
 				unfinished = append(unfinished, r)
 			}
 		}
 		readers = unfinished
 
-		// If all files were read and deleted then no need to do bookkeeping on readers
-		if len(readers) == 0 {
+		// If all files were read and removed then no need to do bookkeeping on readers
+		if len(readers) == 0 && len(retired) == 0 {
 			return
 		}
 	}
@@ -191,13 +347,60 @@ func (m *Manager) consume(ctx context.Context, paths []string) {
 	// Any new files that appear should be consumed entirely
 	m.readerFactory.fromBeginning = true
 
+	m.emitHeartbeats(ctx, readers)
 	m.roller.roll(ctx, readers)
-	m.saveCurrent(readers)
+	m.closeRetired(ctx, retiring)
+	m.evictIdleReaders(readers, consumeStart)
+	m.saveCurrent(ctx, append(readers, retired...))
 	m.syncLastPollFiles(ctx)
 	m.clearCurrentFingerprints()
 }
 
-func (m *Manager) makeFingerprint(path string) (*fingerprint.Fingerprint, *os.File) {
+// consumed returns true if a file that was read to its end has not changed for the grace
+// period, and the file at its path is still the one that was read. The reader's pending
+// tokens are then emitted and its handle is released, so that the file can be removed.
+func (m *Manager) consumed(ctx context.Context, r *Reader) bool {
+	if time.Since(r.lastChange) < m.deleteGrace {
+		return false
+	}
+	path := r.file.Name()
+	file, err := os.Open(path) // #nosec - operator must read in files defined by user
+	if err != nil {
+		m.Debugw("Failed to open file before removing it", "path", path, zap.Error(err))
+		return false
+	}
+	fp, err := m.readerFactory.newFingerprint(file)
+	if closeErr := file.Close(); closeErr != nil {
+		m.Errorf("problem closing file %s", path)
+	}
+	if err != nil || !fp.Equal(r.Fingerprint) {
+		m.Debugw("Not removing file that changed since it was read", "path", path)
+		return false
+	}
+
+	r.flushBatch(ctx)
+	r.Close()
+	r.removed = true
+	m.fileClosed(ctx, r)
+	return true
+}
+
+// deleteFile deletes a file that was consumed
+func (m *Manager) deleteFile(ctx context.Context, r *Reader) {
+	if !m.consumed(ctx, r) {
+		return
+	}
+	path := r.file.Name()
+	if err := os.Remove(path); err != nil {
+		m.Errorf("could not delete %s", path)
+		return
+	}
+	stats.Record(ctx, mFilesDeleted.M(1))
+	m.Debugw("Deleted file after it was read", "path", path)
+}
+
+// logNewPath logs the first time a file is found at path
+func (m *Manager) logNewPath(path string) {
 	if _, ok := m.seenPaths[path]; !ok {
 		if m.readerFactory.fromBeginning {
 			m.Infow("Started watching file", "path", path)
@@ -206,6 +409,21 @@ func (m *Manager) makeFingerprint(path string) (*fingerprint.Fingerprint, *os.Fi
 		}
 		m.seenPaths[path] = struct{}{}
 	}
+}
+
+// logCollision records the first time a file at path is found with the same fingerprint as the
+// file at duplicate, so that a pair of files that keeps colliding is only counted once
+func (m *Manager) logCollision(ctx context.Context, path, duplicate string) {
+	pair := [2]string{path, duplicate}
+	if _, ok := m.collisions[pair]; ok {
+		return
+	}
+	stats.Record(ctx, mFingerprintCollisions.M(1))
+	m.Debugw("Skipping file with the same fingerprint as another file", "path", path, "other_path", duplicate)
+	m.collisions[pair] = struct{}{}
+}
+
+func (m *Manager) makeFingerprint(path string) (*fingerprint.Fingerprint, *os.File) {
 	file, err := os.Open(path) // #nosec - operator must read in files defined by user
 	if err != nil {
 		m.Debugf("Failed to open file", zap.Error(err))
@@ -230,27 +448,88 @@ func (m *Manager) makeFingerprint(path string) (*fingerprint.Fingerprint, *os.Fi
 	return fp, file
 }
 
-func (m *Manager) checkDuplicates(fp *fingerprint.Fingerprint) bool {
+// checkDuplicates returns the path of the file read this polling interval that has the same fingerprint, if any
+func (m *Manager) checkDuplicates(fp *fingerprint.Fingerprint) (string, bool) {
 	for i := 0; i < len(m.currentFps); i++ {
 		if fp.Equal(m.currentFps[i]) {
-			return true
+			return m.currentPaths[i], true
 		}
 	}
-	return false
+	return "", false
+}
+
+// openedFile is a file found in a polling interval, with the fingerprint it was opened with
+type openedFile struct {
+	file *os.File
+	fp   *fingerprint.Fingerprint
 }
 
-// makeReader take a file path, then creates reader,
-// discarding any that have a duplicate fingerprint to other files that have already
-// been read this polling interval
-func (m *Manager) makeReader(path string) *Reader {
-	// Open the files first to minimize the time between listing and opening
+// openFile opens the file at path and takes its fingerprint, discarding files with a
+// duplicate fingerprint to other files that have already been read this polling interval
+func (m *Manager) openFile(ctx context.Context, path string) *openedFile {
+	m.logNewPath(path)
 	fp, file := m.makeFingerprint(path)
 	if fp == nil {
 		return nil
 	}
+	return m.keepFile(ctx, path, &openedFile{file: file, fp: fp})
+}
+
+// makeFingerprints opens the files at paths and takes their fingerprints, with up to
+// fingerprintConcurrency files at a time. The opened file of a path is at the same index,
+// and is nil if the file could not be fingerprinted.
+func (m *Manager) makeFingerprints(paths []string) []*openedFile {
+	files := make([]*openedFile, len(paths))
+	open := func(i int) {
+		// Open the files first to minimize the time between listing and opening
+		if fp, file := m.makeFingerprint(paths[i]); fp != nil {
+			files[i] = &openedFile{file: file, fp: fp}
+		}
+	}
+
+	workers := m.fingerprintConcurrency
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+	if workers <= 1 {
+		for i := range paths {
+			open(i)
+		}
+		return files
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				open(i)
+			}
+		}()
+	}
+	for i := range paths {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return files
+}
+
+// keepFile returns the file opened at path, unless it is nil or has a duplicate fingerprint
+// to another file that has already been read this polling interval, in which case it is closed
+func (m *Manager) keepFile(ctx context.Context, path string, f *openedFile) *openedFile {
+	if f == nil {
+		return nil
+	}
+	fp, file := f.fp, f.file
 
 	// Exclude any empty fingerprints or duplicate fingerprints to avoid doubling up on copy-truncate files
-	if m.checkDuplicates(fp) {
+	if duplicate, ok := m.checkDuplicates(fp); ok {
+		if duplicate != path {
+			m.logCollision(ctx, path, duplicate)
+		}
 		if err := file.Close(); err != nil {
 			m.Errorf("problem closing file", "file", file.Name())
 		}
@@ -258,53 +537,124 @@ func (m *Manager) makeReader(path string) *Reader {
 	}
 
 	m.currentFps = append(m.currentFps, fp)
-	reader, err := m.newReader(file, fp)
-	if err != nil {
-		m.Errorw("Failed to create reader", zap.Error(err))
-		return nil
+	m.currentPaths = append(m.currentPaths, path)
+	return f
+}
+
+// makeReaders creates the readers of the files opened this polling interval. A file that
+// is still the file of a known reader takes over that reader first, wherever it was renamed
+// to, so that a new file that starts with the same content cannot take over its offset.
+func (m *Manager) makeReaders(ctx context.Context, files []*openedFile) []*Reader {
+	oldReaders := make([]*Reader, len(files))
+	for i, f := range files {
+		oldReaders[i] = m.findFileMatch(f)
 	}
 
-	return reader
+	readers := make([]*Reader, 0, len(files))
+	for i, f := range files {
+		if m.archiveMode == archiveModeMembers && isArchive(f.file.Name()) {
+			readers = append(readers, m.makeArchiveReaders(ctx, f)...)
+			continue
+		}
+		r, err := m.newReader(ctx, f.file, f.fp, oldReaders[i])
+		if err != nil {
+			m.Errorw("Failed to create reader", zap.Error(err))
+			continue
+		}
+		readers = append(readers, r)
+	}
+	return readers
 }
 
 func (m *Manager) clearCurrentFingerprints() {
 	m.currentFps = make([]*fingerprint.Fingerprint, 0)
+	m.currentPaths = make([]string, 0)
 }
 
 // saveCurrent adds the readers from this polling interval to this list of
 // known files, then increments the generation of all tracked old readers
 // before clearing out readers that have existed for 3 generations.
-func (m *Manager) saveCurrent(readers []*Reader) {
+func (m *Manager) saveCurrent(ctx context.Context, readers []*Reader) {
 	// Add readers from the current, completed poll interval to the list of known files
 	m.knownFiles = append(m.knownFiles, readers...)
 
 	// Clear out old readers. They are sorted such that they are oldest first,
 	// so we can just find the first reader whose generation is less than our
 	// max, and keep every reader after that
-	for i := 0; i < len(m.knownFiles); i++ {
+	i := 0
+	for ; i < len(m.knownFiles); i++ {
 		reader := m.knownFiles[i]
 		if reader.generation <= 3 {
-			m.knownFiles = m.knownFiles[i:]
 			break
 		}
+		// The reader is forgotten, so its pending tokens cannot be emitted later
+		reader.flushBatch(ctx)
+		if reader.RetiredPath == "" {
+			// Retired files were closed when they were retired
+			m.fileClosed(ctx, reader)
+		}
 	}
+	m.knownFiles = m.knownFiles[i:]
 }
 
-func (m *Manager) newReader(file *os.File, fp *fingerprint.Fingerprint) (*Reader, error) {
-	// Check if the new path has the same fingerprint as an old path
-	if oldReader, ok := m.findFingerprintMatch(fp); ok {
-		return m.readerFactory.copy(oldReader, file)
+// newReader creates the reader of a file from the known reader of the same file, if any.
+// Otherwise it checks if the new path has the same fingerprint as an old path.
+func (m *Manager) newReader(ctx context.Context, file *os.File, fp *fingerprint.Fingerprint, oldReader *Reader) (*Reader, error) {
+	if oldReader == nil {
+		oldReader, _ = m.findFingerprintMatch(fp)
+	}
+	if oldReader == nil {
+		oldReader = m.findRetiredMatch(file.Name(), fp)
+	}
+	if oldReader != nil {
+		if !m.truncated(ctx, oldReader, file) {
+			r, err := m.readerFactory.copy(oldReader, file)
+			if err == nil && oldReader.RetiredPath != "" {
+				// A retired file that was modified again is read on from its offset
+				m.fileOpened(ctx, r)
+			} else if err == nil && renamed(oldReader, r) {
+				// A rotated file closes its old path and opens its new one
+				m.fileRenamed(oldReader, r)
+				m.fileClosed(ctx, oldReader)
+				m.fileOpened(ctx, r)
+			}
+			return r, err
+		}
+		// The file was rewritten, its pending tokens belong to the previous content
+		oldReader.flushBatch(ctx)
+		m.fileClosed(ctx, oldReader)
 	}
 
 	// If we don't match any previously known files, create a new reader from scratch
-	return m.readerFactory.newReader(file, fp)
+	r, err := m.readerFactory.newReader(file, fp)
+	if err == nil {
+		m.fileOpened(ctx, r)
+	}
+	return r, err
+}
+
+// truncated reports whether a file became smaller than the offset it was read to. A file
+// that was truncated and rewritten with different content does not match its fingerprint,
+// so it is read from the beginning as a new file.
+func (m *Manager) truncated(ctx context.Context, oldReader *Reader, file *os.File) bool {
+	if oldReader.compressed {
+		// The offset of a compressed file refers to its decompressed content
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil || info.Size() >= oldReader.Offset {
+		return false
+	}
+	stats.Record(ctx, mTruncations.M(1))
+	m.Infow("File was truncated, reading it from the beginning", "path", file.Name(), "offset", oldReader.Offset, "size", info.Size())
+	return true
 }
 
 func (m *Manager) findFingerprintMatch(fp *fingerprint.Fingerprint) (*Reader, bool) {
 	// Iterate backwards to match newest first
 	for i := len(m.knownFiles) - 1; i >= 0; i-- {
 		oldReader := m.knownFiles[i]
-		if fp.StartsWith(oldReader.Fingerprint) {
+		if oldReader.ArchiveMember == "" && oldReader.RetiredPath == "" && fp.StartsWith(oldReader.Fingerprint) {
 			// Remove the old reader from the list of known files. We will
 			// add it back in saveCurrent if it is still alive.
 			m.knownFiles = append(m.knownFiles[:i], m.knownFiles[i+1:]...)
@@ -314,6 +664,26 @@ func (m *Manager) findFingerprintMatch(fp *fingerprint.Fingerprint) (*Reader, bo
 	return nil, false
 }
 
+// findFileMatch returns the known reader of the same file, if the file still starts with its
+// fingerprint. The identity of a file does not change when it is renamed, so the reader of
+// a rotated file is found even if another file starts with the same content.
+func (m *Manager) findFileMatch(f *openedFile) *Reader {
+	info, err := f.file.Stat()
+	if err != nil {
+		return nil
+	}
+	// Iterate backwards to match newest first
+	for i := len(m.knownFiles) - 1; i >= 0; i-- {
+		oldReader := m.knownFiles[i]
+		if oldReader.ArchiveMember == "" && oldReader.fileInfo != nil && os.SameFile(oldReader.fileInfo, info) && f.fp.StartsWith(oldReader.Fingerprint) {
+			// The reader is added back in saveCurrent, like a fingerprint match
+			m.knownFiles = append(m.knownFiles[:i], m.knownFiles[i+1:]...)
+			return oldReader
+		}
+	}
+	return nil
+}
+
 const knownFilesKey = "knownFiles"
 
 // syncLastPollFiles syncs the most recent set of files to the database
@@ -391,6 +761,12 @@ func (m *Manager) loadLastPollFiles(ctx context.Context) error {
 			}
 		}
 
+		// A file that was partially read without skipping header lines,
+		// for example before the option was enabled, has none left to skip
+		if unsafeReader.Offset > 0 && unsafeReader.SkippedLines == 0 {
+			unsafeReader.SkippedLines = m.readerFactory.readerConfig.skipHeaderLines
+		}
+
 		m.knownFiles = append(m.knownFiles, unsafeReader)
 	}
 
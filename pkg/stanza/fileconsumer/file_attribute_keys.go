@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"fmt"
+)
+
+// AttributeKeysConfig replaces the keys of the attributes of the name and path of a file,
+// for schemas that name them differently. The attributes of an empty key keep their default key.
+type AttributeKeysConfig struct {
+	FileName         string `mapstructure:"file_name,omitempty"`
+	FilePath         string `mapstructure:"file_path,omitempty"`
+	FileNameResolved string `mapstructure:"file_name_resolved,omitempty"`
+	FilePathResolved string `mapstructure:"file_path_resolved,omitempty"`
+}
+
+// fileAttributeKeys are the keys of the attributes of the name and path of a file.
+// An empty key stands for the default key of its attribute.
+type fileAttributeKeys struct {
+	fileName         string
+	filePath         string
+	fileNameResolved string
+	filePathResolved string
+}
+
+func (c *AttributeKeysConfig) buildFileAttributeKeys() fileAttributeKeys {
+	if c == nil {
+		return fileAttributeKeys{}
+	}
+	return fileAttributeKeys{
+		fileName:         c.FileName,
+		filePath:         c.FilePath,
+		fileNameResolved: c.FileNameResolved,
+		filePathResolved: c.FilePathResolved,
+	}
+}
+
+// validate returns an error describing why the configuration is invalid, or nil if the configuration is valid.
+func (c *AttributeKeysConfig) validate() error {
+	keys := c.buildFileAttributeKeys().resolve()
+	seen := make(map[string]struct{}, 4)
+	for _, key := range []string{keys.fileName, keys.filePath, keys.fileNameResolved, keys.filePathResolved} {
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("key '%s' is used by more than one attribute", key)
+		}
+		seen[key] = struct{}{}
+	}
+	return nil
+}
+
+// resolve returns the keys, with the default key of every attribute whose key was not replaced
+func (k fileAttributeKeys) resolve() fileAttributeKeys {
+	if k.fileName == "" {
+		k.fileName = logFileName
+	}
+	if k.filePath == "" {
+		k.filePath = logFilePath
+	}
+	if k.fileNameResolved == "" {
+		k.fileNameResolved = logFileNameResolved
+	}
+	if k.filePathResolved == "" {
+		k.filePathResolved = logFilePathResolved
+	}
+	return k
+}
@@ -0,0 +1,27 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+// +build !windows
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fileID returns the inode and device number of an open file
+func fileID(file *os.File) (inode string, device string, err error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", "", err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", fmt.Errorf("unexpected file info type %T", info.Sys())
+	}
+	return strconv.FormatUint(uint64(stat.Ino), 10), strconv.FormatUint(uint64(stat.Dev), 10), nil
+}
@@ -0,0 +1,24 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+// +build windows
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fileID returns the file index and volume serial number of an open file,
+// which identify a file on Windows the way an inode and device do elsewhere
+func fileID(file *os.File) (inode string, device string, err error) {
+	var info syscall.ByHandleFileInformation
+	if err = syscall.GetFileInformationByHandle(syscall.Handle(file.Fd()), &info); err != nil {
+		return "", "", err
+	}
+	index := uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow)
+	return strconv.FormatUint(index, 10), strconv.FormatUint(uint64(info.VolumeSerialNumber), 10), nil
+}
@@ -0,0 +1,58 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+// +build !windows
+
+package fileconsumer
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileOwnerAttributes(t *testing.T) {
+	f, _ := testReaderFactory(t)
+	f.readerConfig.includeFileOwner = true
+	f.readerConfig.includeFileMode = true
+
+	temp := openTemp(t, t.TempDir())
+	require.NoError(t, temp.Chmod(0640))
+	info, err := temp.Stat()
+	require.NoError(t, err)
+	stat := info.Sys().(*syscall.Stat_t)
+	uid, gid := strconv.FormatUint(uint64(stat.Uid), 10), strconv.FormatUint(uint64(stat.Gid), 10)
+	require.Equal(t, strconv.Itoa(os.Getuid()), uid)
+
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	require.Equal(t, uid, r.FileAttributes[logFileOwnerUID])
+	require.Equal(t, gid, r.FileAttributes[logFileOwnerGID])
+	require.Equal(t, "0640", r.FileAttributes[logFileMode])
+
+	// The attributes are stable across polls
+	r2, err := f.copy(r, temp)
+	require.NoError(t, err)
+	require.Equal(t, uid, r2.FileAttributes[logFileOwnerUID])
+	require.Equal(t, gid, r2.FileAttributes[logFileOwnerGID])
+	require.Equal(t, "0640", r2.FileAttributes[logFileMode])
+
+	// A change of the mode is seen on the next poll
+	require.NoError(t, temp.Chmod(0600))
+	r3, err := f.copy(r2, temp)
+	require.NoError(t, err)
+	require.Equal(t, "0600", r3.FileAttributes[logFileMode])
+
+	// The attributes are absent when the flags are off
+	f.readerConfig.includeFileOwner = false
+	f.readerConfig.includeFileMode = false
+	r4, err := f.copy(r3, temp)
+	require.NoError(t, err)
+	require.NotContains(t, r4.FileAttributes, logFileOwnerUID)
+	require.NotContains(t, r4.FileAttributes, logFileOwnerGID)
+	require.NotContains(t, r4.FileAttributes, logFileMode)
+}
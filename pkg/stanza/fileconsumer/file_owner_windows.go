@@ -0,0 +1,38 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+// +build windows
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileOwner returns the security identifiers of the owner and primary group of an open file,
+// from its security descriptor, which stand for the user and group ids of other platforms
+func fileOwner(file *os.File, _ os.FileInfo) (uid string, gid string, err error) {
+	sd, err := windows.GetSecurityInfo(windows.Handle(file.Fd()), windows.SE_FILE_OBJECT,
+		windows.OWNER_SECURITY_INFORMATION|windows.GROUP_SECURITY_INFORMATION)
+	if err != nil {
+		return "", "", err
+	}
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return "", "", err
+	}
+	group, _, err := sd.Group()
+	if err != nil {
+		return "", "", err
+	}
+	return owner.String(), group.String(), nil
+}
+
+// fileMode is not available on Windows, where access to a file is controlled by its ACL
+// rather than by permission bits
+func fileMode(os.FileInfo) (mode string, ok bool) {
+	return "", false
+}
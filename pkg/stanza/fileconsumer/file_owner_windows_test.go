@@ -0,0 +1,45 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+// +build windows
+
+package fileconsumer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
+)
+
+func TestFileOwnerAttributesOnWindows(t *testing.T) {
+	f, _ := testReaderFactory(t)
+	f.readerConfig.includeFileOwner = true
+	f.readerConfig.includeFileMode = true
+
+	temp := openTemp(t, t.TempDir())
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+
+	// The owner and group are security identifiers, and the mode is not added
+	for _, attr := range []string{logFileOwnerUID, logFileOwnerGID} {
+		sid, ok := r.FileAttributes[attr].(string)
+		require.True(t, ok, attr)
+		_, err = windows.StringToSid(sid)
+		require.NoError(t, err, attr)
+	}
+	require.NotContains(t, r.FileAttributes, logFileMode)
+
+	// The attributes are stable across polls
+	r2, err := f.copy(r, temp)
+	require.NoError(t, err)
+	require.Equal(t, r.FileAttributes[logFileOwnerUID], r2.FileAttributes[logFileOwnerUID])
+	require.Equal(t, r.FileAttributes[logFileOwnerGID], r2.FileAttributes[logFileOwnerGID])
+
+	f.readerConfig.includeFileOwner = false
+	r3, err := f.copy(r2, temp)
+	require.NoError(t, err)
+	require.NotContains(t, r3.FileAttributes, logFileOwnerUID)
+	require.NotContains(t, r3.FileAttributes, logFileOwnerGID)
+}
@@ -5,9 +5,11 @@ package fileconsumer // import "github.com/open-telemetry/opentelemetry-collecto
 
 import (
 	"fmt"
+	"os"
 	"regexp"
 	"sort"
 	"strconv"
+	"time"
 
 	"go.opentelemetry.io/collector/confmap"
 	"go.uber.org/multierr"
@@ -20,10 +22,14 @@ const (
 	sortTypeNumeric      = "numeric"
 	sortTypeTimestamp    = "timestamp"
 	sortTypeAlphabetical = "alphabetical"
+	sortTypeMtime        = "mtime"
 )
 
+// sortRule orders files. Rules are applied in order and sort stably,
+// so files that a rule considers equal keep the order of the previous rule.
 type sortRule interface {
 	validate() error
+	usesRegex() bool
 	sort(re *regexp.Regexp, files []string) ([]string, error)
 }
 
@@ -60,6 +66,13 @@ func (sr *SortRuleImpl) Unmarshal(component *confmap.Conf) error {
 			return err
 		}
 		sr.sortRule = timestampSortRule
+	case sortTypeMtime:
+		var mtimeSortRule *MtimeSortRule
+		err := component.Unmarshal(&mtimeSortRule, confmap.WithErrorUnused())
+		if err != nil {
+			return err
+		}
+		sr.sortRule = mtimeSortRule
 	default:
 		return fmt.Errorf("invalid sort type %s", typeString)
 	}
@@ -75,12 +88,33 @@ func (f NumericSortRule) validate() error {
 }
 
 func (f *AlphabeticalSortRule) validate() error {
-	if f.RegexKey == "" {
-		return fmt.Errorf("regex key must be specified for alphabetical sort")
+	return nil
+}
+
+func (f *MtimeSortRule) validate() error {
+	if f.RegexKey != "" {
+		return fmt.Errorf("regex key cannot be specified for mtime sort")
 	}
 	return nil
 }
 
+func (f NumericSortRule) usesRegex() bool {
+	return true
+}
+
+func (f *TimestampSortRule) usesRegex() bool {
+	return true
+}
+
+// Files are sorted by their path if no regex key is specified
+func (f *AlphabeticalSortRule) usesRegex() bool {
+	return f.RegexKey != ""
+}
+
+func (f *MtimeSortRule) usesRegex() bool {
+	return false
+}
+
 func (f *TimestampSortRule) validate() error {
 	if f.RegexKey == "" {
 		return fmt.Errorf("regex key must be specified for timestamp sort")
@@ -103,7 +137,7 @@ func (f *TimestampSortRule) validate() error {
 
 func (f *NumericSortRule) sort(re *regexp.Regexp, files []string) ([]string, error) {
 	var errs error
-	sort.Slice(files, func(i, j int) bool {
+	sort.SliceStable(files, func(i, j int) bool {
 		valI, valJ, err := extractValues(re, f.RegexKey, files[i], files[j])
 		if err != nil {
 			errs = multierr.Append(errs, err)
@@ -140,7 +174,7 @@ func (f *TimestampSortRule) sort(re *regexp.Regexp, files []string) ([]string, e
 
 	var errs error
 
-	sort.Slice(files, func(i, j int) bool {
+	sort.SliceStable(files, func(i, j int) bool {
 		valI, valJ, err := extractValues(re, f.RegexKey, files[i], files[j])
 		if err != nil {
 			errs = multierr.Append(errs, err)
@@ -171,11 +205,14 @@ func (f *TimestampSortRule) sort(re *regexp.Regexp, files []string) ([]string, e
 
 func (f *AlphabeticalSortRule) sort(re *regexp.Regexp, files []string) ([]string, error) {
 	var errs error
-	sort.Slice(files, func(i, j int) bool {
-		valI, valJ, err := extractValues(re, f.RegexKey, files[i], files[j])
-		if err != nil {
-			errs = multierr.Append(errs, err)
-			return false
+	sort.SliceStable(files, func(i, j int) bool {
+		valI, valJ := files[i], files[j]
+		if f.RegexKey != "" {
+			var err error
+			if valI, valJ, err = extractValues(re, f.RegexKey, files[i], files[j]); err != nil {
+				errs = multierr.Append(errs, err)
+				return false
+			}
 		}
 
 		if f.Ascending {
@@ -187,6 +224,28 @@ func (f *AlphabeticalSortRule) sort(re *regexp.Regexp, files []string) ([]string
 	return files, errs
 }
 
+func (f *MtimeSortRule) sort(_ *regexp.Regexp, files []string) ([]string, error) {
+	var errs error
+	mtimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("stat %s: %w", file, err))
+			continue
+		}
+		mtimes[file] = info.ModTime()
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if f.Ascending {
+			return mtimes[files[i]].Before(mtimes[files[j]])
+		}
+		return mtimes[files[i]].After(mtimes[files[j]])
+	})
+
+	return files, errs
+}
+
 func extractValues(re *regexp.Regexp, reKey, file1, file2 string) (string, string, error) {
 	valI := extractValue(re, reKey, file1)
 	if valI == "" {
@@ -5,6 +5,8 @@ package fileconsumer
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,11 +18,14 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
 	"go.opentelemetry.io/collector/featuregate"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/fingerprint"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
 )
@@ -259,6 +264,48 @@ func TestFileFieldsUpdatedAfterRestart(t *testing.T) {
 	require.NoError(t, op2.Stop())
 }
 
+// TestStorageKeyPrefix tests that managers sharing a persister keep their
+// checkpoints apart with different storage key prefixes
+func TestStorageKeyPrefix(t *testing.T) {
+	t.Parallel()
+
+	persister := testutil.NewUnscopedMockPersister()
+	dirs := map[string]string{"first": t.TempDir(), "second": t.TempDir()}
+	files := map[string]*os.File{}
+	for prefix, dir := range dirs {
+		files[prefix] = openTemp(t, dir)
+		writeString(t, files[prefix], prefix+"1\n")
+	}
+	build := func(prefix string) (*Manager, chan *emitParams) {
+		cfg := NewConfig().includeDir(dirs[prefix])
+		cfg.StartAt = "beginning"
+		cfg.StorageKeyPrefix = prefix
+		return buildTestManager(t, cfg)
+	}
+
+	for _, prefix := range []string{"first", "second"} {
+		op, emitCalls := build(prefix)
+		require.NoError(t, op.Start(persister))
+		waitForToken(t, emitCalls, []byte(prefix+"1"))
+		require.NoError(t, op.Stop())
+	}
+	for _, prefix := range []string{"first", "second"} {
+		known, err := persister.Get(context.Background(), prefix+"."+knownFilesKey)
+		require.NoError(t, err)
+		require.NotEmpty(t, known)
+	}
+
+	// Each manager resumes from its own checkpoint
+	for _, prefix := range []string{"second", "first"} {
+		writeString(t, files[prefix], prefix+"2\n")
+		op, emitCalls := build(prefix)
+		require.NoError(t, op.Start(persister))
+		waitForToken(t, emitCalls, []byte(prefix+"2"))
+		expectNoTokens(t, emitCalls)
+		require.NoError(t, op.Stop())
+	}
+}
+
 // ReadExistingLogs tests that, when starting from beginning, we
 // read all the lines that are already there
 func TestReadExistingLogs(t *testing.T) {
@@ -682,6 +729,28 @@ func TestIgnoreEmptyFiles(t *testing.T) {
 	waitForTokens(t, emitCalls, [][]byte{[]byte("testlog3"), []byte("testlog4")})
 }
 
+func TestExcludeOverlappingInclude(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.Include = append(cfg.Include, filepath.Join(tempDir, "*.audit.log"))
+	cfg.Exclude = []string{"*.audit.log"}
+	cfg.StartAt = "beginning"
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+
+	writeString(t, openFile(t, filepath.Join(tempDir, "app.log")), "testlog1\n")
+	writeString(t, openFile(t, filepath.Join(tempDir, "app.audit.log")), "audit1\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog1"))
+	expectNoTokens(t, emitCalls)
+
+	// The excluded file is never opened, so no reader of it is tracked
+	require.Len(t, operator.knownFiles, 1)
+	require.Equal(t, filepath.Join(tempDir, "app.log"), operator.knownFiles[0].file.Name())
+}
+
 func TestDecodeBufferIsResized(t *testing.T) {
 	t.Parallel()
 
@@ -1354,6 +1423,65 @@ func TestDeleteAfterRead(t *testing.T) {
 	}
 }
 
+func TestDeleteAfterReadGracePeriod(t *testing.T) {
+	require.NoError(t, featuregate.GlobalRegistry().Set(allowFileDeletion.ID(), true))
+	defer func() {
+		require.NoError(t, featuregate.GlobalRegistry().Set(allowFileDeletion.ID(), false))
+	}()
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.DeleteAfterRead = true
+	cfg.DeleteGracePeriod = 200 * time.Millisecond
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "testlog1\n")
+
+	// The file is kept while it may still be written to
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog1"))
+	_, err := os.Stat(temp.Name())
+	require.NoError(t, err)
+
+	writeString(t, temp, "testlog2\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog2"))
+	_, err = os.Stat(temp.Name())
+	require.NoError(t, err)
+
+	// The file is deleted once it has not changed for the grace period
+	time.Sleep(250 * time.Millisecond)
+	operator.poll(context.Background())
+	_, err = os.Stat(temp.Name())
+	require.True(t, os.IsNotExist(err))
+	require.Empty(t, operator.knownFiles)
+}
+
+func TestDeleteAfterReadChangedFile(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	operator, emitCalls := buildTestManager(t, cfg)
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "testlog1\n")
+	r, err := operator.readerFactory.newReader(openFile(t, temp.Name()), nil)
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog1"))
+
+	// The file at the path no longer has the fingerprint of the file that was read
+	writeString(t, temp, "testlog2\n")
+	operator.deleteFile(context.Background(), r)
+	require.False(t, r.removed)
+	_, err = os.Stat(temp.Name())
+	require.NoError(t, err)
+	r.Close()
+}
+
 func TestMaxBatching(t *testing.T) {
 	t.Parallel()
 
@@ -1667,3 +1795,507 @@ func TestStalePartialFingerprintDiscarded(t *testing.T) {
 	waitForTokens(t, emitCalls, [][]byte{[]byte(content), []byte(newContent1), []byte(newContent)})
 	operator.wg.Wait()
 }
+
+func TestStartNonexistentIncludeDir(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig().includeDir(filepath.Join(t.TempDir(), "missing"))
+	cfg.StartAt = "beginning"
+	operator, _ := buildTestManager(t, cfg)
+
+	err := operator.Start(testutil.NewMockPersister("test"))
+	require.ErrorContains(t, err, "no `include` pattern matches an existing directory")
+}
+
+func TestStartWarnsUnmatchedPatterns(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.Include = append(cfg.Include, filepath.Join(tempDir, "*.missing"))
+	cfg.StartAt = "beginning"
+	operator, _ := buildTestManager(t, cfg)
+	core, observedLogs := observer.New(zap.DebugLevel)
+	operator.SugaredLogger = zap.New(core).Sugar()
+	openTemp(t, tempDir)
+
+	require.NoError(t, operator.Start(testutil.NewMockPersister("test")))
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	// Only the pattern that matches nothing is reported
+	warnings := observedLogs.FilterLevelExact(zap.WarnLevel).All()
+	require.Len(t, warnings, 1)
+	require.Equal(t, fmt.Sprintf("include pattern %q matches no files", cfg.Include[1]), warnings[0].Message)
+	require.Len(t, observedLogs.FilterMessage("Files matched at startup").All(), 1)
+}
+
+type readOnlyPersister struct {
+	operator.Persister
+}
+
+func (p readOnlyPersister) Set(context.Context, string, []byte) error {
+	return errors.New("read-only file system")
+}
+
+func TestStartReadOnlyStorage(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	operator, _ := buildTestManager(t, cfg)
+
+	err := operator.Start(readOnlyPersister{testutil.NewMockPersister("test")})
+	require.ErrorContains(t, err, "offset storage is not writable")
+}
+
+func TestStopInterruptsReadAtTokenBoundary(t *testing.T) {
+	t.Parallel()
+
+	const lineCount, stopAt = 10000, 100
+	tempDir := t.TempDir()
+	temp := openTemp(t, tempDir)
+	lineLen := len(fmt.Sprintf("line %05d\n", 0))
+	for i := 0; i < lineCount; i++ {
+		writeString(t, temp, fmt.Sprintf("line %05d\n", i))
+	}
+
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+
+	var emitted int
+	blocked := make(chan struct{})
+	operator, err := cfg.Build(testutil.Logger(t), func(ctx context.Context, _ []byte, _ map[string]any) error {
+		emitted++
+		if emitted < stopAt {
+			return nil
+		}
+		// Hold the pipeline until shutdown, as a full downstream would
+		close(blocked)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, err)
+
+	persister := testutil.NewMockPersister("test")
+	require.NoError(t, operator.Start(persister))
+
+	select {
+	case <-blocked:
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "Timed out waiting for the read to reach the stop point")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		assert.NoError(t, operator.Stop())
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		require.FailNow(t, "Timed out waiting for the read to stop")
+	}
+
+	// The interrupted token was not acknowledged, so resuming starts with it
+	operator2, emitChan := buildTestManager(t, cfg, withEmitChan(make(chan *emitParams, lineCount)))
+	operator2.persister = persister
+	require.NoError(t, operator2.loadLastPollFiles(context.Background()))
+	require.Len(t, operator2.knownFiles, 1)
+	require.Equal(t, int64((stopAt-1)*lineLen), operator2.knownFiles[0].Offset)
+
+	require.NoError(t, operator2.Start(persister))
+	defer func() {
+		require.NoError(t, operator2.Stop())
+	}()
+	waitForToken(t, emitChan, []byte(fmt.Sprintf("line %05d", stopAt-1)))
+}
+
+func TestSkipHeaderLines(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.SkipHeaderLines = 2
+	operator, emitCalls := buildTestManager(t, cfg)
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "name,value\nstring,int\nfoo,1\nbar,2\n")
+
+	require.NoError(t, operator.Start(testutil.NewMockPersister("test")))
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	waitForTokens(t, emitCalls, [][]byte{[]byte("foo,1"), []byte("bar,2")})
+	expectNoTokens(t, emitCalls)
+}
+
+func TestSkipHeaderLinesAfterRestart(t *testing.T) {
+	testCases := []struct {
+		name         string
+		skipBefore   int
+		skipAfter    int
+		beforeStop   string
+		afterRestart string
+		expected     [][]byte
+	}{
+		{
+			name:         "header_skipped",
+			skipBefore:   1,
+			skipAfter:    1,
+			beforeStop:   "header\nline1\n",
+			afterRestart: "line2\n",
+			expected:     [][]byte{[]byte("line2")},
+		},
+		{
+			name:         "header_partially_skipped",
+			skipBefore:   2,
+			skipAfter:    2,
+			beforeStop:   "header1\n",
+			afterRestart: "header2\nline1\n",
+			expected:     [][]byte{[]byte("line1")},
+		},
+		{
+			name:         "read_before_skipping_was_enabled",
+			skipBefore:   0,
+			skipAfter:    1,
+			beforeStop:   "line1\n",
+			afterRestart: "line2\n",
+			expected:     [][]byte{[]byte("line2")},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			cfg := NewConfig().includeDir(tempDir)
+			cfg.StartAt = "beginning"
+			cfg.SkipHeaderLines = tc.skipBefore
+			op1, emitCalls1 := buildTestManager(t, cfg)
+
+			temp := openTemp(t, tempDir)
+			writeString(t, temp, tc.beforeStop)
+
+			persister := testutil.NewUnscopedMockPersister()
+			op1.persister = persister
+			op1.poll(context.Background())
+			require.NoError(t, op1.Stop())
+			for len(emitCalls1) > 0 {
+				<-emitCalls1
+			}
+
+			writeString(t, temp, tc.afterRestart)
+
+			cfg.SkipHeaderLines = tc.skipAfter
+			op2, emitCalls2 := buildTestManager(t, cfg)
+			require.NoError(t, op2.Start(persister))
+			defer func() {
+				require.NoError(t, op2.Stop())
+			}()
+
+			waitForTokens(t, emitCalls2, tc.expected)
+			expectNoTokens(t, emitCalls2)
+		})
+	}
+}
+
+func TestContentHashFingerprintRestart(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.FingerprintStrategy = fingerprintStrategyContentHash
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "secret line 1\n")
+
+	persister := testutil.NewUnscopedMockPersister()
+	op1, emitCalls1 := buildTestManager(t, cfg)
+	require.NoError(t, op1.Start(persister))
+	waitForToken(t, emitCalls1, []byte("secret line 1"))
+	require.NoError(t, op1.Stop())
+
+	// Only a digest of the first bytes is stored
+	stored, err := persister.Get(context.Background(), knownFilesKey)
+	require.NoError(t, err)
+	require.NotContains(t, string(stored), base64.StdEncoding.EncodeToString([]byte("secret line ")))
+
+	writeString(t, temp, "secret line 2\n")
+
+	op2, emitCalls2 := buildTestManager(t, cfg)
+	require.NoError(t, op2.Start(persister))
+	defer func() {
+		require.NoError(t, op2.Stop())
+	}()
+	waitForToken(t, emitCalls2, []byte("secret line 2"))
+	expectNoTokens(t, emitCalls2)
+}
+
+// TestContentHashRewrittenAfterRestart tests that a file whose fingerprint was restored
+// from a digest is not read on from its offset once it is rewritten with other content
+func TestContentHashRewrittenAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.FingerprintStrategy = fingerprintStrategyContentHash
+	cfg.FingerprintSize = helper.ByteSize(len("header line 001\n"))
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "header line 001\nline 1\n")
+
+	persister := testutil.NewUnscopedMockPersister()
+	op1, emitCalls1 := buildTestManager(t, cfg)
+	require.NoError(t, op1.Start(persister))
+	waitForTokens(t, emitCalls1, [][]byte{[]byte("header line 001"), []byte("line 1")})
+	require.NoError(t, op1.Stop())
+
+	op2, emitCalls2 := buildTestManager(t, cfg)
+	op2.persister = persister
+	require.NoError(t, op2.loadLastPollFiles(context.Background()))
+	defer func() {
+		require.NoError(t, op2.Stop())
+	}()
+	op2.poll(context.Background())
+	expectNoTokens(t, emitCalls2)
+
+	// The file is rewritten in place with other content that is longer than the offset
+	require.NoError(t, temp.Truncate(0))
+	_, err := temp.WriteAt([]byte("other header 01\nline 2\nline 3\n"), 0)
+	require.NoError(t, err)
+	op2.poll(context.Background())
+	waitForTokens(t, emitCalls2, [][]byte{[]byte("other header 01"), []byte("line 2"), []byte("line 3")})
+	expectNoTokens(t, emitCalls2)
+}
+
+func TestShortFingerprintUpgraded(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.FingerprintStrategy = fingerprintStrategyContentHash
+
+	// The file starts below the fingerprint size
+	temp := openTemp(t, tempDir)
+	first := string(tokenWithLength(49))
+	writeString(t, temp, first+"\n")
+
+	persister := testutil.NewUnscopedMockPersister()
+	op1, emitCalls1 := buildTestManager(t, cfg)
+	require.NoError(t, op1.Start(persister))
+	waitForToken(t, emitCalls1, []byte(first))
+	require.NoError(t, op1.Stop())
+
+	// The file grows past the fingerprint size while the digest of its first 50 bytes is stored
+	var expected [][]byte
+	for i := 0; i < 20; i++ {
+		token := tokenWithLength(99)
+		writeString(t, temp, string(token)+"\n")
+		expected = append(expected, token)
+	}
+
+	op2, emitCalls2 := buildTestManager(t, cfg)
+	op2.persister = persister
+	require.NoError(t, op2.loadLastPollFiles(context.Background()))
+	op2.poll(context.Background())
+	waitForTokens(t, emitCalls2, expected)
+	op2.poll(context.Background())
+	expectNoTokens(t, emitCalls2)
+
+	// The stored fingerprint was upgraded to the full size
+	require.Len(t, op2.knownFiles, 1)
+	require.Equal(t, fingerprint.DefaultSize, op2.knownFiles[0].Fingerprint.Len())
+	fp, err := fingerprint.New(temp, fingerprint.DefaultSize)
+	require.NoError(t, err)
+	require.True(t, fp.StartsWith(op2.knownFiles[0].Fingerprint))
+}
+
+func TestFingerprintOffsetSharedHeader(t *testing.T) {
+	t.Parallel()
+
+	header := string(tokenWithLength(511)) + "\n"
+	testCases := []struct {
+		name   string
+		offset helper.ByteSize
+		files  int
+	}{
+		{"no_offset", 0, 1},
+		{"header_skipped", 512, 2},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			cfg := NewConfig().includeDir(tempDir)
+			cfg.StartAt = "beginning"
+			cfg.FingerprintSize = 512
+			cfg.FingerprintOffset = tc.offset
+			operator, emitCalls := buildTestManager(t, cfg)
+			operator.persister = testutil.NewMockPersister("test")
+
+			for i := 0; i < 2; i++ {
+				temp := openTemp(t, tempDir)
+				writeString(t, temp, fmt.Sprintf("%sfile %d\n", header, i))
+			}
+
+			operator.poll(context.Background())
+			defer func() {
+				require.NoError(t, operator.Stop())
+			}()
+
+			// Files with the same fingerprint are read only once
+			tokens := waitForNTokens(t, emitCalls, 2*tc.files)
+			for _, token := range tokens {
+				require.Contains(t, []string{header[:511], "file 0", "file 1"}, string(token))
+			}
+			expectNoTokens(t, emitCalls)
+		})
+	}
+}
+
+func TestFingerprintCollisionMetric(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+
+	core, observedLogs := observer.New(zap.DebugLevel)
+	operator.SugaredLogger = zap.New(core).Sugar()
+
+	for i := 0; i < 3; i++ {
+		temp := openTemp(t, tempDir)
+		writeString(t, temp, "identical content\n")
+	}
+
+	operator.poll(context.Background())
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+	waitForToken(t, emitCalls, []byte("identical content"))
+	expectNoTokens(t, emitCalls)
+
+	// Files that keep colliding are only counted once
+	operator.poll(context.Background())
+	operator.poll(context.Background())
+	expectNoTokens(t, emitCalls)
+
+	rows, err := view.RetrieveData(mFingerprintCollisions.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, float64(2), rows[0].Data.(*view.SumData).Value)
+
+	collisions := observedLogs.FilterMessage("Skipping file with the same fingerprint as another file")
+	require.Equal(t, 2, collisions.Len())
+	for _, entry := range collisions.All() {
+		require.NotEqual(t, entry.ContextMap()["path"], entry.ContextMap()["other_path"])
+	}
+}
+
+func TestTruncateThenAppend(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.FingerprintSize = helper.ByteSize(len("header line 001\n"))
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "header line 001\nline 1\nline 2\n")
+	operator.poll(context.Background())
+	waitForTokens(t, emitCalls, [][]byte{[]byte("header line 001"), []byte("line 1"), []byte("line 2")})
+
+	// The file is rewritten with the same fingerprint but less content
+	require.NoError(t, temp.Truncate(0))
+	_, err := temp.WriteAt([]byte("header line 001\nline 3\n"), 0)
+	require.NoError(t, err)
+	operator.poll(context.Background())
+	waitForTokens(t, emitCalls, [][]byte{[]byte("header line 001"), []byte("line 3")})
+
+	// Content appended after the truncation is read from the new offset
+	_, err = temp.WriteAt([]byte("line 4\n"), int64(len("header line 001\nline 3\n")))
+	require.NoError(t, err)
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("line 4"))
+
+	// A file that regrows past the old offset with different content no longer
+	// matches its fingerprint, so it is read from the beginning as a new file
+	require.NoError(t, temp.Truncate(0))
+	_, err = temp.WriteAt([]byte("other header 01\nline 5\nline 6\nline 7\n"), 0)
+	require.NoError(t, err)
+	operator.poll(context.Background())
+	waitForTokens(t, emitCalls, [][]byte{[]byte("other header 01"), []byte("line 5"), []byte("line 6"), []byte("line 7")})
+	expectNoTokens(t, emitCalls)
+
+	rows, err := view.RetrieveData(mTruncations.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, float64(1), rows[0].Data.(*view.SumData).Value)
+}
+
+func TestConsumerMetrics(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.MetricsIncludeFilePath = true
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "testlog1\n")
+	openBefore := openFiles.Load()
+
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog1"))
+	require.Equal(t, openBefore+1, openFiles.Load())
+
+	writeString(t, temp, "testlog22\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog22"))
+
+	// The counter advances by the length of each record, including its newline
+	rows, err := view.RetrieveData(mBytesConsumed.Name())
+	require.NoError(t, err)
+	var consumed float64
+	for _, row := range rows {
+		if len(row.Tags) == 1 && row.Tags[0].Value == temp.Name() {
+			consumed = row.Data.(*view.SumData).Value
+		}
+	}
+	require.Equal(t, float64(len("testlog1\ntestlog22\n")), consumed)
+
+	require.NoError(t, operator.Stop())
+	require.Equal(t, openBefore, openFiles.Load())
+	rows, err = view.RetrieveData(mOpenFiles.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, float64(openFiles.Load()), rows[0].Data.(*view.LastValueData).Value)
+}
@@ -4,7 +4,10 @@
 package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
 
 import (
+	"fmt"
+	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/bmatcuk/doublestar/v4"
 	"go.uber.org/multierr"
@@ -18,6 +21,7 @@ type MatchingCriteria struct {
 
 type OrderingCriteria struct {
 	Regex  string         `mapstructure:"regex,omitempty"`
+	TopN   int            `mapstructure:"top_n,omitempty"`
 	SortBy []SortRuleImpl `mapstructure:"sort_by,omitempty"`
 }
 
@@ -29,6 +33,10 @@ type AlphabeticalSortRule struct {
 	BaseSortRule `mapstructure:",squash"`
 }
 
+type MtimeSortRule struct {
+	BaseSortRule `mapstructure:",squash"`
+}
+
 type TimestampSortRule struct {
 	BaseSortRule `mapstructure:",squash"`
 	Layout       string `mapstructure:"layout,omitempty"`
@@ -59,8 +67,9 @@ func (f Finder) FindFiles() ([]string, error) {
 		matches, _ := doublestar.FilepathGlob(include, doublestar.WithFilesOnly()) // compile error checked in build
 	INCLUDE:
 		for _, match := range matches {
+			// A file that matches an exclude pattern is never read, whichever include pattern it matches
 			for _, exclude := range f.Exclude {
-				if itMatches, _ := doublestar.PathMatch(exclude, match); itMatches {
+				if matchExclude(exclude, match) {
 					continue INCLUDE
 				}
 			}
@@ -78,8 +87,106 @@ func (f Finder) FindFiles() ([]string, error) {
 	return f.FindCurrent(all)
 }
 
-// FindCurrent gets the current file to read from a list of files if ordering_criteria is configured
-// otherwise it returns the list of files.
+// MatchResult is a preview of the files that the matching criteria resolve to
+type MatchResult struct {
+	// Files are the files that would be read, after exclusion and ordering_criteria
+	Files []string
+	// Include lists the files that each include pattern matches
+	Include []PatternMatch
+	// Warnings describe patterns that match no files
+	Warnings []string
+}
+
+// PatternMatch is the list of files that an include pattern matches
+type PatternMatch struct {
+	Pattern string
+	// Files are the matching files that are not excluded
+	Files []string
+	// Excluded are the matching files that match an exclude pattern
+	Excluded []string
+}
+
+// DryRun resolves the include and exclude patterns against the current filesystem,
+// without opening any file, and reports the files that would be read along with
+// warnings for the patterns that match nothing. It is cheap enough to check a
+// configuration before it is used.
+func (f MatchingCriteria) DryRun() (*MatchResult, error) {
+	result := &MatchResult{}
+	excludeUsed := make([]bool, len(f.Exclude))
+	seen := make(map[string]bool)
+	var all []string
+	var errs error
+	for _, include := range f.Include {
+		matches, err := doublestar.FilepathGlob(include, doublestar.WithFilesOnly())
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("parse include glob %q: %w", include, err))
+			continue
+		}
+		pattern := PatternMatch{Pattern: include}
+		for _, match := range matches {
+			excluded := false
+			for i, exclude := range f.Exclude {
+				if matchExclude(exclude, match) {
+					excludeUsed[i] = true
+					excluded = true
+				}
+			}
+			if excluded {
+				pattern.Excluded = append(pattern.Excluded, match)
+				continue
+			}
+			pattern.Files = append(pattern.Files, match)
+			if !seen[match] {
+				seen[match] = true
+				all = append(all, match)
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("include pattern %q matches no files", include))
+		case len(pattern.Files) == 0:
+			result.Warnings = append(result.Warnings, fmt.Sprintf("every file that include pattern %q matches is excluded", include))
+		}
+		result.Include = append(result.Include, pattern)
+	}
+
+	for i, exclude := range f.Exclude {
+		if _, err := doublestar.PathMatch(exclude, "matchstring"); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("parse exclude glob %q: %w", exclude, err))
+		} else if !excludeUsed[i] {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("exclude pattern %q matches no files", exclude))
+		}
+	}
+
+	files, err := f.FindCurrent(all)
+	result.Files = files
+	return result, multierr.Append(errs, err)
+}
+
+// matchExclude reports whether a path matches an exclude pattern. A pattern without a path
+// separator matches the name of the file, and a pattern that ends with a separator matches
+// every file below the directories it matches.
+func matchExclude(exclude, path string) bool {
+	if dir := strings.TrimRight(exclude, separators); dir != exclude && dir != "" {
+		for parent := filepath.Dir(path); parent != filepath.Dir(parent) && parent != "."; parent = filepath.Dir(parent) {
+			if matchExclude(dir, parent) {
+				return true
+			}
+		}
+		return false
+	}
+	if !strings.ContainsAny(exclude, separators) {
+		path = filepath.Base(path)
+	}
+	itMatches, _ := doublestar.PathMatch(exclude, path)
+	return itMatches
+}
+
+// separators are the path separators of exclude patterns
+const separators = "/" + string(filepath.Separator)
+
+// FindCurrent gets the current files to read from a list of files if ordering_criteria is configured,
+// which are the first top_n files in sorted order. Otherwise it returns the list of files.
 //
 // Deprecated: [v0.82.0] This will be made internal in a future release, tentatively v0.83.0.
 func (f Finder) FindCurrent(files []string) ([]string, error) {
@@ -99,5 +206,12 @@ func (f Finder) FindCurrent(files []string) ([]string, error) {
 		files = sortedFiles
 	}
 
-	return []string{files[0]}, errs
+	topN := f.OrderingCriteria.TopN
+	if topN == 0 {
+		topN = 1
+	}
+	if topN < len(files) {
+		files = files[:topN]
+	}
+	return files, errs
 }
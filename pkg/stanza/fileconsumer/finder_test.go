@@ -4,9 +4,11 @@
 package fileconsumer
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -557,6 +559,134 @@ func TestFinder(t *testing.T) {
 	}
 }
 
+func TestFinderExcludePatterns(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		files    []string
+		include  []string
+		exclude  func(tempDir string) []string
+		expected []string
+	}{
+		{
+			name:    "ExcludeFileName",
+			files:   []string{"a.log", "a.audit.log", filepath.Join("b", "b.log"), filepath.Join("b", "b.audit.log")},
+			include: []string{filepath.Join("**", "*.log")},
+			exclude: func(string) []string {
+				return []string{"*.audit.log"}
+			},
+			expected: []string{"a.log", filepath.Join("b", "b.log")},
+		},
+		{
+			name:    "ExcludeDirectoryName",
+			files:   []string{"a.log", filepath.Join("debug", "b.log"), filepath.Join("c", "debug", "d", "c.log"), filepath.Join("c", "debugger", "d.log")},
+			include: []string{filepath.Join("**", "*.log")},
+			exclude: func(string) []string {
+				return []string{"debug/"}
+			},
+			expected: []string{"a.log", filepath.Join("c", "debugger", "d.log")},
+		},
+		{
+			name:    "ExcludeDirectoryPath",
+			files:   []string{"a.log", filepath.Join("b", "b.log"), filepath.Join("b", "c", "c.log"), filepath.Join("d", "b", "d.log")},
+			include: []string{filepath.Join("**", "*.log")},
+			exclude: func(tempDir string) []string {
+				return []string{filepath.Join(tempDir, "b") + "/"}
+			},
+			expected: []string{"a.log", filepath.Join("d", "b", "d.log")},
+		},
+		{
+			name:    "ExcludeDoubleStar",
+			files:   []string{"a.log", filepath.Join("b", "debug", "b.log"), filepath.Join("b", "c", "debug", "c.log")},
+			include: []string{filepath.Join("**", "*.log")},
+			exclude: func(tempDir string) []string {
+				return []string{filepath.Join(tempDir, "**", "debug", "**")}
+			},
+			expected: []string{"a.log"},
+		},
+		{
+			name:    "ExcludeWinsOverlappingIncludes",
+			files:   []string{"a.log", "a.audit.log", filepath.Join("debug", "b.log"), filepath.Join("debug", "b.audit.log")},
+			include: []string{"a.audit.log", filepath.Join("debug", "*.log"), filepath.Join("**", "*.log")},
+			exclude: func(string) []string {
+				return []string{"*.audit.log", "debug/"}
+			},
+			expected: []string{"a.log"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			files := absPath(tempDir, tc.files)
+			expected := absPath(tempDir, tc.expected)
+
+			for _, f := range files {
+				require.NoError(t, os.MkdirAll(filepath.Dir(f), 0700))
+				require.NoError(t, os.WriteFile(f, []byte(filepath.Base(f)), 0000))
+			}
+
+			finder := Finder{
+				Include: absPath(tempDir, tc.include),
+				Exclude: tc.exclude(tempDir),
+			}
+			files, err := finder.FindFiles()
+			require.NoError(t, err)
+			require.ElementsMatch(t, expected, files)
+		})
+	}
+}
+
+func TestFinderDryRun(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, f := range absPath(tempDir, []string{"a.log", filepath.Join("b", "1.log"), filepath.Join("b", "2.log"), filepath.Join("b", "3.log"), "c.audit.log"}) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(f), 0700))
+		require.NoError(t, os.WriteFile(f, []byte(filepath.Base(f)), 0600))
+	}
+
+	none := filepath.Join(tempDir, "none", "*.log")
+	one := filepath.Join(tempDir, "a.log")
+	many := filepath.Join(tempDir, "b", "*.log")
+	audit := filepath.Join(tempDir, "*.audit.log")
+	finder := Finder{
+		Include: []string{none, one, many, audit},
+		Exclude: []string{"*.audit.log", "*.tmp"},
+	}
+	result, err := finder.DryRun()
+	require.NoError(t, err)
+
+	expected := absPath(tempDir, []string{"a.log", filepath.Join("b", "1.log"), filepath.Join("b", "2.log"), filepath.Join("b", "3.log")})
+	require.Equal(t, expected, result.Files)
+	require.Equal(t, []PatternMatch{
+		{Pattern: none},
+		{Pattern: one, Files: expected[:1]},
+		{Pattern: many, Files: expected[1:]},
+		{Pattern: audit, Excluded: absPath(tempDir, []string{"c.audit.log"})},
+	}, result.Include)
+	require.Equal(t, []string{
+		fmt.Sprintf("include pattern %q matches no files", none),
+		fmt.Sprintf("every file that include pattern %q matches is excluded", audit),
+		`exclude pattern "*.tmp" matches no files`,
+	}, result.Warnings)
+
+	// The preview resolves to the same files as the consumer
+	files, err := finder.FindFiles()
+	require.NoError(t, err)
+	require.Equal(t, files, result.Files)
+}
+
+func TestFinderDryRunBadPattern(t *testing.T) {
+	tempDir := t.TempDir()
+	finder := Finder{
+		Include: []string{filepath.Join(tempDir, "[")},
+		Exclude: []string{"["},
+	}
+	result, err := finder.DryRun()
+	require.ErrorContains(t, err, "parse include glob")
+	require.ErrorContains(t, err, "parse exclude glob")
+	require.Empty(t, result.Files)
+}
+
 func absPath(tempDir string, files []string) []string {
 	absFiles := make([]string, 0, len(files))
 	for _, f := range files {
@@ -564,3 +694,73 @@ func absPath(tempDir string, files []string) []string {
 	}
 	return absFiles
 }
+
+func TestFinderMtimeOrdering(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	mtimes := map[string]time.Time{
+		"c.log": now.Add(-2 * time.Hour),
+		"a.log": now.Add(-time.Hour),
+		"d.log": now.Add(-time.Hour),
+		"b.log": now,
+	}
+
+	mtimeRule := func(ascending bool) SortRuleImpl {
+		return SortRuleImpl{&MtimeSortRule{BaseSortRule: BaseSortRule{SortType: sortTypeMtime, Ascending: ascending}}}
+	}
+	nameRule := func(ascending bool) SortRuleImpl {
+		return SortRuleImpl{&AlphabeticalSortRule{BaseSortRule: BaseSortRule{SortType: sortTypeAlphabetical, Ascending: ascending}}}
+	}
+
+	cases := []struct {
+		name     string
+		criteria OrderingCriteria
+		expected []string
+	}{
+		{
+			// Files with the same mtime keep the order in which they matched
+			name:     "Ascending",
+			criteria: OrderingCriteria{TopN: 4, SortBy: []SortRuleImpl{mtimeRule(true)}},
+			expected: []string{"c.log", "a.log", "d.log", "b.log"},
+		},
+		{
+			name:     "Descending",
+			criteria: OrderingCriteria{TopN: 4, SortBy: []SortRuleImpl{mtimeRule(false)}},
+			expected: []string{"b.log", "a.log", "d.log", "c.log"},
+		},
+		{
+			// A previous rule breaks the ties of a later rule
+			name:     "TiesByNameDescending",
+			criteria: OrderingCriteria{TopN: 4, SortBy: []SortRuleImpl{nameRule(false), mtimeRule(true)}},
+			expected: []string{"c.log", "d.log", "a.log", "b.log"},
+		},
+		{
+			name:     "TopN",
+			criteria: OrderingCriteria{TopN: 2, SortBy: []SortRuleImpl{mtimeRule(true)}},
+			expected: []string{"c.log", "a.log"},
+		},
+		{
+			name:     "DefaultTopN",
+			criteria: OrderingCriteria{SortBy: []SortRuleImpl{mtimeRule(true)}},
+			expected: []string{"c.log"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			for name, mtime := range mtimes {
+				path := filepath.Join(tempDir, name)
+				require.NoError(t, os.WriteFile(path, []byte(name), 0600))
+				require.NoError(t, os.Chtimes(path, mtime, mtime))
+			}
+
+			finder := Finder{
+				Include:          []string{filepath.Join(tempDir, "*.log")},
+				OrderingCriteria: tc.criteria,
+			}
+			files, err := finder.FindFiles()
+			require.NoError(t, err)
+			require.Equal(t, absPath(tempDir, tc.expected), files)
+		})
+	}
+}
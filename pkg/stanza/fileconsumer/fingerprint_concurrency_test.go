@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+// writeSmallFiles writes count files to dir, of which every tenth has the same content
+// and every seventh is empty
+func writeSmallFiles(tb testing.TB, dir string, count int) {
+	for i := 0; i < count; i++ {
+		content := fmt.Sprintf("file %d\n", i)
+		switch {
+		case i%10 == 0:
+			content = "identical content\n"
+		case i%7 == 0:
+			content = ""
+		}
+		require.NoError(tb, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%05d.log", i)), []byte(content), 0600))
+	}
+}
+
+func TestFingerprintConcurrency(t *testing.T) {
+	tempDir := t.TempDir()
+	writeSmallFiles(t, tempDir, 300)
+
+	// readFiles returns the names of the files that readers were created for
+	readFiles := func(concurrency int) []string {
+		cfg := NewConfig().includeDir(tempDir)
+		cfg.StartAt = "beginning"
+		cfg.FingerprintConcurrency = concurrency
+		m, err := cfg.Build(testutil.Logger(t), nopEmitFunc)
+		require.NoError(t, err)
+		require.Equal(t, concurrency, m.fingerprintConcurrency)
+		m.persister = testutil.NewMockPersister("test")
+		defer func() {
+			require.NoError(t, m.Stop())
+		}()
+
+		m.poll(context.Background())
+		names := make([]string, 0, len(m.knownFiles))
+		for _, r := range m.knownFiles {
+			names = append(names, r.FileAttributes[logFileName].(string))
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	serial := readFiles(1)
+	// The empty files and all but one of the files with identical content are not read
+	require.Len(t, serial, 300-30-(43-5)+1)
+	require.Contains(t, serial, "file00000.log")
+	for _, concurrency := range []int{2, 8, 64} {
+		require.Equal(t, serial, readFiles(concurrency), "concurrency %d", concurrency)
+	}
+}
@@ -22,9 +22,30 @@ import (
 
 const headerPipelineOutputType = "header_log_emitter"
 
+// Modes of handling the failure of the header pipeline to process a header line
+const (
+	headerParseFailureIgnore = "ignore"
+	headerParseFailureMetric = "metric"
+	headerParseFailureError  = "error"
+)
+
+// headerPersisterScope scopes the keys persisted by the operators of header pipelines,
+// so that they don't collide with the offsets of the files.
+const headerPersisterScope = "header"
+
 type HeaderConfig struct {
 	Pattern           string            `mapstructure:"pattern"`
 	MetadataOperators []operator.Config `mapstructure:"metadata_operators"`
+	// ReparseOnRotation reads the header of a rotated file that does not start with the content
+	// of the file it replaces, instead of keeping the header of the replaced file.
+	ReparseOnRotation bool `mapstructure:"reparse_on_rotation"`
+	// ParseFailureMode is what happens when a header line fails to be processed by the metadata
+	// operators: ignore logs it, metric also counts it, and error stops reading the file.
+	ParseFailureMode string `mapstructure:"parse_failure_mode"`
+	// TerminatorPattern makes the header a block of lines that starts with a line matching
+	// Pattern and ends with a line matching TerminatorPattern, such as ^$ for a blank line.
+	// The lines before the terminator are processed by the metadata operators as one entry.
+	TerminatorPattern string `mapstructure:"terminator_pattern"`
 }
 
 // validate returns an error describing why the configuration is invalid, or nil if the configuration is valid.
@@ -33,6 +54,13 @@ func (hc *HeaderConfig) validate() error {
 		return errors.New("at least one operator must be specified for `metadata_operators`")
 	}
 
+	switch hc.ParseFailureMode {
+	case "", headerParseFailureIgnore, headerParseFailureMetric, headerParseFailureError:
+	default:
+		return fmt.Errorf("invalid `parse_failure_mode` '%s', must be one of '%s', '%s' or '%s'",
+			hc.ParseFailureMode, headerParseFailureIgnore, headerParseFailureMetric, headerParseFailureError)
+	}
+
 	nopLogger := zap.NewNop().Sugar()
 	outOp := newHeaderPipelineOutput(nopLogger)
 	p, err := pipeline.Config{
@@ -74,25 +102,39 @@ func (hc *HeaderConfig) buildHeaderSettings(enc encoding.Encoding) (*headerSetti
 		return nil, fmt.Errorf("failed to compile `pattern`: %w", err)
 	}
 
+	var terminatorRegex *regexp.Regexp
+	if hc.TerminatorPattern != "" {
+		if terminatorRegex, err = regexp.Compile(hc.TerminatorPattern); err != nil {
+			return nil, fmt.Errorf("failed to compile `terminator_pattern`: %w", err)
+		}
+	}
+
 	splitFunc, err := helper.NewNewlineSplitFunc(enc, false, func(b []byte) []byte {
-		return bytes.Trim(b, "\r\n")
+		trimmed := bytes.Trim(b, "\r\n")
+		if trimmed == nil && terminatorRegex != nil {
+			// Keep blank lines as tokens, they may terminate the header
+			return b[:0]
+		}
+		return trimmed
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create split func: %w", err)
 	}
 
 	return &headerSettings{
-		matchRegex: matchRegex,
-		splitFunc:  splitFunc,
-		config:     hc,
+		matchRegex:      matchRegex,
+		terminatorRegex: terminatorRegex,
+		splitFunc:       splitFunc,
+		config:          hc,
 	}, nil
 }
 
 // headerSettings contains compiled objects defined by a HeaderConfig
 type headerSettings struct {
-	matchRegex *regexp.Regexp
-	splitFunc  bufio.SplitFunc
-	config     *HeaderConfig
+	matchRegex      *regexp.Regexp
+	terminatorRegex *regexp.Regexp
+	splitFunc       bufio.SplitFunc
+	config          *HeaderConfig
 }
 
 // headerPipelineOutput is a stanza operator that emits log entries to a channel
@@ -4,16 +4,23 @@
 package fileconsumer
 
 import (
+	"context"
+	"os"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 	"golang.org/x/text/encoding"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/input/generate"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/output/stdout"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/parser/regex"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/transformer/filter"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
 )
 
 func TestHeaderConfig_validate(t *testing.T) {
@@ -63,6 +70,19 @@ func TestHeaderConfig_validate(t *testing.T) {
 			},
 			expectedErr: "at least one operator must be specified for `metadata_operators`",
 		},
+		{
+			name: "Invalid parse failure mode",
+			conf: HeaderConfig{
+				Pattern: "^#",
+				MetadataOperators: []operator.Config{
+					{
+						Builder: regexConf,
+					},
+				},
+				ParseFailureMode: "panic",
+			},
+			expectedErr: "invalid `parse_failure_mode` 'panic', must be one of 'ignore', 'metric' or 'error'",
+		},
 		{
 			name: "Invalid operator specified",
 			conf: HeaderConfig{
@@ -162,6 +182,19 @@ func TestHeaderConfig_buildHeaderSettings(t *testing.T) {
 			},
 			expectedErr: "failed to compile `pattern`:",
 		},
+		{
+			name: "Invalid terminator pattern",
+			conf: HeaderConfig{
+				Pattern:           "^#",
+				TerminatorPattern: "(",
+				MetadataOperators: []operator.Config{
+					{
+						Builder: regexConf,
+					},
+				},
+			},
+			expectedErr: "failed to compile `terminator_pattern`:",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -177,3 +210,96 @@ func TestHeaderConfig_buildHeaderSettings(t *testing.T) {
 		})
 	}
 }
+
+const headerCounterType = "header_counter"
+
+func init() {
+	operator.Register(headerCounterType, func() operator.Builder {
+		return &headerCounterConfig{TransformerConfig: helper.NewTransformerConfig(headerCounterType, headerCounterType)}
+	})
+}
+
+// headerCounterConfig builds a stateful operator that counts the entries it processed in its
+// persister, and sets the count as the header_count attribute.
+type headerCounterConfig struct {
+	helper.TransformerConfig `mapstructure:",squash"`
+}
+
+func (c headerCounterConfig) Build(logger *zap.SugaredLogger) (operator.Operator, error) {
+	transformer, err := c.TransformerConfig.Build(logger)
+	if err != nil {
+		return nil, err
+	}
+	return &headerCounter{TransformerOperator: transformer}, nil
+}
+
+type headerCounter struct {
+	helper.TransformerOperator
+	persister operator.Persister
+	count     int
+}
+
+func (h *headerCounter) Start(persister operator.Persister) error {
+	h.persister = persister
+	data, err := persister.Get(context.Background(), "count")
+	if err != nil || data == nil {
+		return err
+	}
+	h.count, err = strconv.Atoi(string(data))
+	return err
+}
+
+func (h *headerCounter) Process(ctx context.Context, e *entry.Entry) error {
+	h.count++
+	if err := h.persister.Set(ctx, "count", []byte(strconv.Itoa(h.count))); err != nil {
+		return err
+	}
+	if e.Attributes == nil {
+		e.Attributes = map[string]any{}
+	}
+	e.Attributes["header_count"] = h.count
+	h.Write(ctx, e)
+	return nil
+}
+
+func TestHeaderPersisterSurvivesCopy(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	headerConf := &HeaderConfig{
+		Pattern:           "^#",
+		MetadataOperators: []operator.Config{{Builder: &headerCounterConfig{TransformerConfig: helper.NewTransformerConfig(headerCounterType, headerCounterType)}}},
+	}
+	require.NoError(t, headerConf.validate())
+	h, err := headerConf.buildHeaderSettings(encoding.Nop)
+	require.NoError(t, err)
+	f.headerSettings = h
+	persister := testutil.NewUnscopedMockPersister()
+	f.readerConfig.headerPersister = operator.NewScopedPersister(headerPersisterScope, persister)
+
+	temp := openTemp(t, t.TempDir())
+	_, err = temp.WriteString("#first\n")
+	require.NoError(t, err)
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	require.False(t, r.HeaderFinalized)
+	require.Equal(t, 1, r.FileAttributes["header_count"])
+
+	// The header pipeline of the copy starts from the state persisted by the original one
+	_, err = temp.WriteString("#second\naaa\n")
+	require.NoError(t, err)
+	file, err := os.Open(temp.Name())
+	require.NoError(t, err)
+	r2, err := f.copy(r, file)
+	require.NoError(t, err)
+	r.Close()
+	t.Cleanup(r2.Close)
+	r2.ReadToEnd(context.Background())
+	require.True(t, r2.HeaderFinalized)
+	require.Equal(t, 2, r2.FileAttributes["header_count"])
+	waitForTokenWithAttributes(t, emitChan, []byte("aaa"), map[string]any{"header_count": 2})
+
+	// The keys of the header operators are scoped apart from the offsets
+	data, err := persister.Get(context.Background(), headerPersisterScope+"."+headerCounterType+".count")
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), data)
+}
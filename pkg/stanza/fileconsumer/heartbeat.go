@@ -0,0 +1,64 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/util"
+)
+
+const (
+	fileHeartbeatEvent = "file.heartbeat"
+	logFileOffset      = "log.file.offset"
+)
+
+// heartbeat is when a file was last checked for a heartbeat, and its offset at that time.
+// It is handed over to the readers of the file in the following polls.
+type heartbeat struct {
+	at     time.Time
+	offset int64
+}
+
+// emitHeartbeats emits a heartbeat for the readers of this poll whose file had no new content
+// for heartbeat_interval, so that a file that is still watched can be told apart from a file
+// that is no longer watched. A reader is only checked once an interval, and a reader that
+// stopped reading its file is skipped.
+func (m *Manager) emitHeartbeats(ctx context.Context, readers []*Reader) {
+	if m.heartbeatInterval == 0 {
+		return
+	}
+	now := m.now()
+	for _, r := range readers {
+		if r.removed || r.headerFailed || r.encodingFailed {
+			continue
+		}
+		if r.heartbeat == nil {
+			// The interval of a new file starts when it is first read
+			r.heartbeat = &heartbeat{at: now, offset: r.Offset}
+			continue
+		}
+		if now.Sub(r.heartbeat.at) < m.heartbeatInterval {
+			continue
+		}
+		if r.Offset == r.heartbeat.offset {
+			r.emitHeartbeat(ctx)
+		}
+		r.heartbeat.at, r.heartbeat.offset = now, r.Offset
+	}
+}
+
+// emitHeartbeat emits a record without a body, with the attributes of the file, the heartbeat
+// event and the offset that the file was read to. The offset is left as it is.
+func (r *Reader) emitHeartbeat(ctx context.Context) {
+	attrs := util.MapCopy(r.FileAttributes)
+	attrs[LogFileEvent] = fileHeartbeatEvent
+	attrs[logFileOffset] = r.Offset
+	if err := r.emit(ctx, nil, attrs); err != nil {
+		r.Errorw("Failed to emit file heartbeat", zap.Error(err))
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func TestFileHeartbeat(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.HeartbeatInterval = time.Minute
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	now := time.Now()
+	operator.now = func() time.Time { return now }
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	idle := openTemp(t, tempDir)
+	idleName := filepath.Base(idle.Name())
+	busy := openTemp(t, tempDir)
+	writeString(t, idle, "idle1\n")
+	writeString(t, busy, "busy1\n")
+	operator.poll(context.Background())
+	waitForTokens(t, emitCalls, [][]byte{[]byte("idle1"), []byte("busy1")})
+
+	// Only the file without new content for the interval gets a heartbeat
+	now = now.Add(time.Minute)
+	writeString(t, busy, "busy2\n")
+	operator.poll(context.Background())
+	calls := map[string]*emitParams{}
+	for i := 0; i < 2; i++ {
+		call := waitForEmit(t, emitCalls)
+		calls[string(call.token)] = call
+	}
+	require.Contains(t, calls, "busy2")
+	require.Equal(t, fileHeartbeatEvent, calls[""].attrs[LogFileEvent])
+	require.Equal(t, idleName, calls[""].attrs[logFileName])
+	require.Equal(t, int64(6), calls[""].attrs[logFileOffset])
+	expectNoTokens(t, emitCalls)
+
+	// A file is checked once an interval
+	now = now.Add(30 * time.Second)
+	operator.poll(context.Background())
+	expectNoTokens(t, emitCalls)
+
+	// The heartbeat does not move the offset, new content is read from where the file was read to
+	now = now.Add(30 * time.Second)
+	operator.poll(context.Background())
+	heartbeats := map[any]any{}
+	for i := 0; i < 2; i++ {
+		call := waitForEmit(t, emitCalls)
+		require.Equal(t, fileHeartbeatEvent, call.attrs[LogFileEvent])
+		heartbeats[call.attrs[logFileName]] = call.attrs[logFileOffset]
+	}
+	require.Equal(t, map[any]any{idleName: int64(6), filepath.Base(busy.Name()): int64(12)}, heartbeats)
+	writeString(t, idle, "idle2\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("idle2"))
+	expectNoTokens(t, emitCalls)
+}
+
+func TestFileHeartbeatDisabled(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	now := time.Now()
+	operator.now = func() time.Time { return now }
+
+	writeString(t, openTemp(t, tempDir), "testlog1\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog1"))
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Hour)
+		operator.poll(context.Background())
+	}
+	expectNoTokens(t, emitCalls)
+}
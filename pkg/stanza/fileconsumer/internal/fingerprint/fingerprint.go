@@ -5,6 +5,8 @@ package fingerprint // import "github.com/open-telemetry/opentelemetry-collector
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -19,38 +21,131 @@ const MinSize = 16 // bytes
 // A file's fingerprint is the first N bytes of the file
 type Fingerprint struct {
 	FirstBytes []byte
+
+	// Offset is the position in the file at which FirstBytes start
+	Offset int64 `json:",omitempty"`
+
+	// Digest is the SHA-256 hash of the first Length bytes of FirstBytes. Content hash
+	// fingerprints are stored as a digest, so this is only set when they are restored.
+	Digest []byte `json:",omitempty"`
+	Length int    `json:",omitempty"`
+
+	contentHash bool
 }
 
 // New creates a new fingerprint from an open file
 func New(file *os.File, size int) (*Fingerprint, error) {
+	return NewAt(file, 0, size)
+}
+
+// NewAt creates a new fingerprint from the bytes of an open file that follow offset.
+// Files that are not longer than offset have an empty fingerprint.
+func NewAt(file *os.File, offset int64, size int) (*Fingerprint, error) {
 	buf := make([]byte, size)
 
-	n, err := file.ReadAt(buf, 0)
+	n, err := file.ReadAt(buf, offset)
 	if err != nil && !errors.Is(err, io.EOF) {
 		return nil, fmt.Errorf("reading fingerprint bytes: %w", err)
 	}
 
 	fp := &Fingerprint{
 		FirstBytes: buf[:n],
+		Offset:     offset,
 	}
 
 	return fp, nil
 }
 
+// NewContentHash creates a new fingerprint from an open file that is
+// stored as a SHA-256 digest of the first bytes instead of the bytes themselves.
+// It only keeps file contents out of storage, and is matched like any fingerprint.
+func NewContentHash(file *os.File, offset int64, size int) (*Fingerprint, error) {
+	fp, err := NewAt(file, offset, size)
+	if err != nil {
+		return nil, err
+	}
+	fp.contentHash = true
+	return fp, nil
+}
+
 // Copy creates a new copy of the fingerprint
 func (f Fingerprint) Copy() *Fingerprint {
 	buf := make([]byte, len(f.FirstBytes), cap(f.FirstBytes))
 	n := copy(buf, f.FirstBytes)
+	var digest []byte
+	if f.Digest != nil {
+		digest = make([]byte, len(f.Digest))
+		copy(digest, f.Digest)
+	}
 	return &Fingerprint{
-		FirstBytes: buf[:n],
+		FirstBytes:  buf[:n],
+		Offset:      f.Offset,
+		Digest:      digest,
+		Length:      f.Length,
+		contentHash: f.contentHash,
+	}
+}
+
+// MarshalJSON stores content hash fingerprints as a digest of their first bytes
+func (f Fingerprint) MarshalJSON() ([]byte, error) {
+	type fingerprint Fingerprint // avoids recursing into MarshalJSON
+	if !f.contentHash {
+		return json.Marshal(fingerprint(f))
+	}
+	stored := struct {
+		Offset int64 `json:",omitempty"`
+		Digest []byte
+		Length int
+	}{f.Offset, f.Digest, f.Length}
+	if len(f.FirstBytes) > 0 {
+		sum := sha256.Sum256(f.FirstBytes)
+		stored.Digest, stored.Length = sum[:], len(f.FirstBytes)
+	}
+	return json.Marshal(stored)
+}
+
+// UnmarshalJSON restores fingerprints stored by MarshalJSON
+func (f *Fingerprint) UnmarshalJSON(data []byte) error {
+	type fingerprint Fingerprint // avoids recursing into UnmarshalJSON
+	var stored fingerprint
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	*f = Fingerprint(stored)
+	f.contentHash = f.Digest != nil
+	return nil
+}
+
+// restored returns true if only the digest of the first bytes is known
+func (f Fingerprint) restored() bool {
+	return len(f.FirstBytes) == 0 && f.Digest != nil
+}
+
+// digestMatches returns true if the first bytes of f hash to the digest of restored
+func (f Fingerprint) digestMatches(restored *Fingerprint) bool {
+	if f.restored() {
+		return f.Length == restored.Length && bytes.Equal(f.Digest, restored.Digest)
 	}
+	if restored.Length == 0 || restored.Length > len(f.FirstBytes) {
+		return false
+	}
+	sum := sha256.Sum256(f.FirstBytes[:restored.Length])
+	return bytes.Equal(sum[:], restored.Digest)
 }
 
-// Equal returns true if the fingerprints have the same FirstBytes,
-// false otherwise. This does not compare other aspects of the fingerprints
-// because the primary purpose of a fingerprint is to convey a unique
-// identity, and only the FirstBytes field contributes to this goal.
+// Equal returns true if the fingerprints have the same FirstBytes at
+// the same Offset, false otherwise. Content hash fingerprints restored
+// from storage are compared by their digest instead of their bytes.
 func (f Fingerprint) Equal(other *Fingerprint) bool {
+	if f.Offset != other.Offset {
+		return false
+	}
+	if other.restored() {
+		return f.digestMatches(other) && (f.restored() || len(f.FirstBytes) == other.Length)
+	}
+	if f.restored() {
+		return other.digestMatches(&f) && len(other.FirstBytes) == f.Length
+	}
 	l0 := len(other.FirstBytes)
 	l1 := len(f.FirstBytes)
 	if l0 != l1 {
@@ -71,6 +166,16 @@ func (f Fingerprint) Equal(other *Fingerprint) bool {
 // a fingerprint. As the file grows, its fingerprint is updated
 // until it reaches a maximum size, as configured on the operator
 func (f Fingerprint) StartsWith(old *Fingerprint) bool {
+	if f.Offset != old.Offset {
+		return false
+	}
+	if old.restored() {
+		return f.digestMatches(old)
+	}
+	if f.restored() {
+		// Only a fingerprint of the same length can be compared to a digest
+		return len(old.FirstBytes) == f.Length && old.digestMatches(&f)
+	}
 	l0 := len(old.FirstBytes)
 	if l0 == 0 {
 		return false
@@ -81,3 +186,35 @@ func (f Fingerprint) StartsWith(old *Fingerprint) bool {
 	}
 	return bytes.Equal(old.FirstBytes[:l0], f.FirstBytes[:l0])
 }
+
+// Len returns the number of bytes that the fingerprint was taken from
+func (f Fingerprint) Len() int {
+	if f.restored() {
+		return f.Length
+	}
+	return len(f.FirstBytes)
+}
+
+// Upgrade replaces a fingerprint that was taken while the file was shorter than the
+// fingerprint size with the longer fingerprint of the grown file, if the grown file
+// starts with it. It returns true if the fingerprint was upgraded.
+func (f *Fingerprint) Upgrade(grown *Fingerprint) bool {
+	if f.Len() == 0 || grown.restored() || len(grown.FirstBytes) <= f.Len() || !grown.StartsWith(f) {
+		return false
+	}
+	f.FirstBytes = append(make([]byte, 0, len(grown.FirstBytes)), grown.FirstBytes...)
+	f.Digest, f.Length = nil, 0
+	return true
+}
+
+// Restore replaces the digest of a fingerprint restored from storage with the first bytes
+// of current, the fingerprint of the file it was matched with, if they hash to the digest.
+// Only then can the file be checked for being rewritten. It returns true if it was restored.
+func (f *Fingerprint) Restore(current *Fingerprint) bool {
+	if !f.restored() || current.restored() || f.Offset != current.Offset || !current.digestMatches(f) {
+		return false
+	}
+	f.FirstBytes = append(make([]byte, 0, f.Length), current.FirstBytes[:f.Length]...)
+	f.Digest, f.Length = nil, 0
+	return true
+}
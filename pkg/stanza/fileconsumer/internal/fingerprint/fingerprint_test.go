@@ -4,6 +4,7 @@
 package fingerprint
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"os"
@@ -277,3 +278,134 @@ func tokenWithLength(length int) []byte {
 	}
 	return b
 }
+
+func TestContentHashRoundTrip(t *testing.T) {
+	temp, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+	defer temp.Close()
+	_, err = temp.WriteString("hello world")
+	require.NoError(t, err)
+
+	fp, err := NewContentHash(temp, 0, 5)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(fp.FirstBytes))
+
+	encoded, err := json.Marshal(fp)
+	require.NoError(t, err)
+	require.NotContains(t, string(encoded), "FirstBytes")
+
+	restored := &Fingerprint{}
+	require.NoError(t, json.Unmarshal(encoded, restored))
+	require.Empty(t, restored.FirstBytes)
+	require.Equal(t, 5, restored.Length)
+
+	// Copies keep the digest, and are stored the same way
+	cp := restored.Copy()
+	require.True(t, cp.Equal(restored))
+	reencoded, err := json.Marshal(cp)
+	require.NoError(t, err)
+	require.JSONEq(t, string(encoded), string(reencoded))
+
+	require.True(t, fp.Equal(restored))
+	require.True(t, restored.Equal(fp))
+	require.True(t, fp.StartsWith(restored))
+	require.True(t, restored.StartsWith(fp))
+}
+
+func TestContentHashStartsWith(t *testing.T) {
+	restore := func(firstBytes string) *Fingerprint {
+		encoded, err := json.Marshal(&Fingerprint{FirstBytes: []byte(firstBytes), contentHash: true})
+		require.NoError(t, err)
+		fp := &Fingerprint{}
+		require.NoError(t, json.Unmarshal(encoded, fp))
+		return fp
+	}
+	hello := restore("hello")
+	helloworld := restore("helloworld")
+
+	// A file that grew since its digest was stored
+	require.True(t, (&Fingerprint{FirstBytes: []byte("helloworld")}).StartsWith(hello))
+	require.False(t, (&Fingerprint{FirstBytes: []byte("hell")}).StartsWith(hello))
+	require.False(t, (&Fingerprint{FirstBytes: []byte("jello world")}).StartsWith(hello))
+
+	// Only the length of a stored digest is known, not its bytes
+	require.True(t, hello.StartsWith(hello))
+	require.False(t, helloworld.StartsWith(hello))
+	require.False(t, hello.StartsWith(helloworld))
+	require.False(t, hello.StartsWith(restore("")))
+}
+
+func TestUpgrade(t *testing.T) {
+	short := &Fingerprint{FirstBytes: []byte("hello")}
+	grown := &Fingerprint{FirstBytes: []byte("helloworld")}
+
+	require.False(t, short.Upgrade(&Fingerprint{FirstBytes: []byte("jello world")}))
+	require.False(t, short.Upgrade(&Fingerprint{FirstBytes: []byte("hello")}))
+	require.False(t, (&Fingerprint{}).Upgrade(grown))
+	require.Equal(t, []byte("hello"), short.FirstBytes)
+
+	require.True(t, short.Upgrade(grown))
+	require.True(t, short.Equal(grown))
+	grown.FirstBytes[0] = 'j'
+	require.Equal(t, []byte("helloworld"), short.FirstBytes, "the bytes are copied")
+
+	// A stored digest is replaced with the bytes of the grown file, which are stored as a digest again
+	encoded, err := json.Marshal(&Fingerprint{FirstBytes: []byte("hello"), contentHash: true})
+	require.NoError(t, err)
+	restored := &Fingerprint{}
+	require.NoError(t, json.Unmarshal(encoded, restored))
+	require.Equal(t, 5, restored.Len())
+	require.True(t, restored.Upgrade(&Fingerprint{FirstBytes: []byte("helloworld")}))
+	require.Equal(t, 10, restored.Len())
+	encoded, err = json.Marshal(restored)
+	require.NoError(t, err)
+	require.NotContains(t, string(encoded), "FirstBytes")
+}
+
+func TestRestore(t *testing.T) {
+	encoded, err := json.Marshal(&Fingerprint{FirstBytes: []byte("hello"), contentHash: true})
+	require.NoError(t, err)
+	restored := &Fingerprint{}
+	require.NoError(t, json.Unmarshal(encoded, restored))
+
+	require.False(t, restored.Restore(&Fingerprint{FirstBytes: []byte("jello world")}))
+	require.False(t, restored.Restore(&Fingerprint{FirstBytes: []byte("hell")}))
+	require.False(t, restored.Restore(&Fingerprint{FirstBytes: []byte("helloworld"), Offset: 1}))
+	require.False(t, restored.Restore(restored.Copy()))
+	require.Empty(t, restored.FirstBytes)
+
+	// Only the bytes that were hashed are restored
+	require.True(t, restored.Restore(&Fingerprint{FirstBytes: []byte("helloworld")}))
+	require.Equal(t, []byte("hello"), restored.FirstBytes)
+	require.False(t, restored.Restore(&Fingerprint{FirstBytes: []byte("helloworld")}))
+
+	// The bytes are not kept in storage
+	encoded, err = json.Marshal(restored)
+	require.NoError(t, err)
+	require.NotContains(t, string(encoded), "FirstBytes")
+	require.False(t, (&Fingerprint{FirstBytes: []byte("jello")}).StartsWith(restored))
+}
+
+func TestNewAt(t *testing.T) {
+	temp, err := os.CreateTemp(t.TempDir(), "")
+	require.NoError(t, err)
+	defer temp.Close()
+	_, err = temp.WriteString("banner:hello world")
+	require.NoError(t, err)
+
+	fp, err := NewAt(temp, 7, 5)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(fp.FirstBytes))
+	require.Equal(t, int64(7), fp.Offset)
+	require.Equal(t, int64(7), fp.Copy().Offset)
+
+	// The same bytes at a different position identify a different file
+	other := &Fingerprint{FirstBytes: []byte("hello")}
+	require.False(t, fp.Equal(other))
+	require.False(t, fp.StartsWith(other))
+
+	// Files that end before the offset have an empty fingerprint
+	short, err := NewAt(temp, 100, 5)
+	require.NoError(t, err)
+	require.Empty(t, short.FirstBytes)
+}
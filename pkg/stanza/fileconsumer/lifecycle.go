@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/util"
+)
+
+// LogFileEvent is the attribute of the records that are emitted for the lifecycle of a file
+// with emit_on_open and emit_on_close. These records have an empty token.
+const LogFileEvent = "event"
+
+const (
+	fileOpenedEvent = "file.opened"
+	fileClosedEvent = "file.closed"
+)
+
+// ReaderObserver is notified of the lifecycle of the readers of files, so that tools and
+// tests can follow the files that are read without parsing logs. Its methods may be called
+// concurrently, from the poll loop, and must return quickly since they hold back reading.
+type ReaderObserver interface {
+	// OnReaderCreated is called when a file that was not known is first read, with the
+	// bytes of the fingerprint that it is recognized by.
+	OnReaderCreated(path string, fingerprint []byte)
+	// OnReaderClosed is called when a file is no longer tracked, with the offset it was read to.
+	OnReaderClosed(path string, finalOffset int64)
+	// OnRotationDetected is called when a known file was found at another path. The reader of
+	// the old path is then closed, and a reader of the new path is created.
+	OnRotationDetected(oldPath, newPath string)
+}
+
+// SetReaderObserver sets the observer that is notified of the lifecycle of the readers of
+// files. It must be called before the manager is started. A nil observer is not notified.
+func (m *Manager) SetReaderObserver(observer ReaderObserver) {
+	m.observer = observer
+}
+
+// fileOpened emits the open event of a reader that was built for a file that was not known
+func (m *Manager) fileOpened(ctx context.Context, r *Reader) {
+	if m.readerFactory.readerConfig.emitOnOpen {
+		r.emitFileEvent(ctx, fileOpenedEvent)
+	}
+	if m.observer != nil {
+		m.observer.OnReaderCreated(r.path(), append([]byte(nil), r.Fingerprint.FirstBytes...))
+	}
+}
+
+// fileClosed emits the close event of a reader whose file is no longer tracked
+func (m *Manager) fileClosed(ctx context.Context, r *Reader) {
+	if m.readerFactory.readerConfig.emitOnClose {
+		r.emitFileEvent(ctx, fileClosedEvent)
+	}
+	if m.observer != nil {
+		m.observer.OnReaderClosed(r.path(), r.Offset)
+	}
+}
+
+// fileRenamed notifies the observer, if any, that the file of oldReader is now the file of r
+func (m *Manager) fileRenamed(oldReader *Reader, r *Reader) {
+	if m.observer != nil {
+		m.observer.OnRotationDetected(oldReader.path(), r.path())
+	}
+}
+
+// emitFileEvent emits a record without a body, with the attributes of the file and the event
+func (r *Reader) emitFileEvent(ctx context.Context, event string) {
+	attrs := util.MapCopy(r.FileAttributes)
+	attrs[LogFileEvent] = event
+	if err := r.emit(ctx, nil, attrs); err != nil {
+		r.Errorw("Failed to emit file event", "event", event, zap.Error(err))
+	}
+}
+
+// renamed reports whether a reader was copied from the reader of a file at another path
+func renamed(oldReader *Reader, r *Reader) bool {
+	return oldReader.file != nil && r.file != nil && oldReader.file.Name() != r.file.Name()
+}
@@ -0,0 +1,208 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func waitForFileEvent(t *testing.T, c chan *emitParams, event, name string) {
+	call := <-c
+	require.Empty(t, call.token)
+	require.Equal(t, event, call.attrs[LogFileEvent])
+	require.Equal(t, name, call.attrs[logFileName])
+}
+
+func TestFileLifecycleEvents(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.EmitOnOpen = true
+	cfg.EmitOnClose = true
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	temp := openTemp(t, tempDir)
+	name := filepath.Base(temp.Name())
+	writeString(t, temp, "testlog1\n")
+	operator.poll(context.Background())
+	waitForFileEvent(t, emitCalls, fileOpenedEvent, name)
+	waitForTokenWithAttributes(t, emitCalls, []byte("testlog1"), map[string]any{logFileName: name})
+
+	// Reading new content of the file does not open it again
+	writeString(t, temp, "testlog2\n")
+	operator.poll(context.Background())
+	waitForTokenWithAttributes(t, emitCalls, []byte("testlog2"), map[string]any{logFileName: name})
+	expectNoTokens(t, emitCalls)
+
+	// The file is closed once it is no longer tracked
+	require.NoError(t, temp.Close())
+	require.NoError(t, os.Remove(temp.Name()))
+	for i := 0; i < 5; i++ {
+		operator.poll(context.Background())
+	}
+	waitForFileEvent(t, emitCalls, fileClosedEvent, name)
+	expectNoTokens(t, emitCalls)
+}
+
+func TestFileLifecycleEventsRotation(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.EmitOnOpen = true
+	cfg.EmitOnClose = true
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	path := filepath.Join(tempDir, "app.log")
+	writeString(t, openFile(t, path), "testlog1\n")
+	operator.poll(context.Background())
+	waitForFileEvent(t, emitCalls, fileOpenedEvent, "app.log")
+	waitForTokenWithAttributes(t, emitCalls, []byte("testlog1"), map[string]any{logFileName: "app.log"})
+
+	// The rotated file has the same content at another path
+	require.NoError(t, os.Rename(path, path+".1"))
+	operator.poll(context.Background())
+	waitForFileEvent(t, emitCalls, fileClosedEvent, "app.log")
+	waitForFileEvent(t, emitCalls, fileOpenedEvent, "app.log.1")
+	expectNoTokens(t, emitCalls)
+}
+
+// recordingObserver records the lifecycle of readers as strings, in the order it is notified of it
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(format string, args ...any) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) OnReaderCreated(path string, fingerprint []byte) {
+	o.record("created %s %q", filepath.Base(path), fingerprint)
+}
+
+func (o *recordingObserver) OnReaderClosed(path string, finalOffset int64) {
+	o.record("closed %s %d", filepath.Base(path), finalOffset)
+}
+
+func (o *recordingObserver) OnRotationDetected(oldPath, newPath string) {
+	o.record("rotated %s %s", filepath.Base(oldPath), filepath.Base(newPath))
+}
+
+func (o *recordingObserver) take() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	events := o.events
+	o.events = nil
+	return events
+}
+
+func TestReaderObserver(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	observer := &recordingObserver{}
+	operator.SetReaderObserver(observer)
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	path := filepath.Join(tempDir, "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("testlog1\n"), 0600))
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog1"))
+	require.Equal(t, []string{`created app.log "testlog1\n"`}, observer.take())
+
+	// Reading the file again does not create another reader
+	operator.poll(context.Background())
+	require.Empty(t, observer.take())
+
+	// The rotated file is recognized at its new path, and a new file is created at the old one
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, os.WriteFile(path, []byte("testlog2\n"), 0600))
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog2"))
+	require.ElementsMatch(t, []string{
+		`rotated app.log app.log.1`,
+		`closed app.log 9`,
+		`created app.log.1 "testlog1\n"`,
+		`created app.log "testlog2\n"`,
+	}, observer.take())
+
+	// The files are closed once they are no longer tracked
+	require.NoError(t, os.Remove(path+".1"))
+	require.NoError(t, os.Remove(path))
+	for i := 0; i < 5; i++ {
+		operator.poll(context.Background())
+	}
+	require.ElementsMatch(t, []string{`closed app.log.1 9`, `closed app.log 9`}, observer.take())
+	expectNoTokens(t, emitCalls)
+}
+
+func TestReaderObserverNil(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	operator.SetReaderObserver(nil)
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "testlog1\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog1"))
+
+	require.NoError(t, temp.Close())
+	require.NoError(t, os.Remove(temp.Name()))
+	for i := 0; i < 5; i++ {
+		operator.poll(context.Background())
+	}
+	expectNoTokens(t, emitCalls)
+}
+
+func TestFileLifecycleEventsDisabled(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "testlog1\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog1"))
+
+	require.NoError(t, temp.Close())
+	require.NoError(t, os.Remove(temp.Name()))
+	for i := 0; i < 5; i++ {
+		operator.poll(context.Background())
+	}
+	expectNoTokens(t, emitCalls)
+}
@@ -0,0 +1,44 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
+)
+
+// MaxLogSizeOverride replaces the maximum size of a log entry for the files that match a pattern
+type MaxLogSizeOverride struct {
+	Pattern    string          `mapstructure:"pattern,omitempty"`
+	MaxLogSize helper.ByteSize `mapstructure:"max_log_size,omitempty"`
+}
+
+// validate returns an error describing why the configuration is invalid, or nil if the configuration is valid.
+func (o MaxLogSizeOverride) validate() error {
+	if o.Pattern == "" {
+		return errors.New("`pattern` must be specified for each of `max_log_size_overrides`")
+	}
+	if _, err := doublestar.PathMatch(o.Pattern, "matchstring"); err != nil {
+		return fmt.Errorf("parse `max_log_size_overrides` pattern %q: %w", o.Pattern, err)
+	}
+	if o.MaxLogSize <= 0 {
+		return fmt.Errorf("`max_log_size` of pattern %q must be positive", o.Pattern)
+	}
+	return nil
+}
+
+// maxLogSizeFor returns the maximum size of a log entry of the file at path.
+// The first override whose pattern matches the path applies.
+func (c *readerConfig) maxLogSizeFor(path string) int {
+	for _, o := range c.maxLogSizeOverrides {
+		if match, _ := doublestar.PathMatch(o.Pattern, path); match {
+			return int(o.MaxLogSize)
+		}
+	}
+	return c.maxLogSize
+}
@@ -0,0 +1,135 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+var (
+	tagFilePath, _ = tag.NewKey("path")
+
+	mFingerprintCollisions = stats.Int64("fileconsumer_fingerprint_collisions", "Number of files that were not read because another file has the same fingerprint, counted once for every pair of files", stats.UnitDimensionless)
+	mFilesDeleted          = stats.Int64("fileconsumer_files_deleted", "Number of files that were deleted after they were read", stats.UnitDimensionless)
+	mTruncations           = stats.Int64("fileconsumer_truncations", "Number of files that were read again from the beginning because they were truncated", stats.UnitDimensionless)
+	mBytesConsumed         = stats.Int64("fileconsumer_bytes_consumed", "Number of bytes that were read from files and emitted", stats.UnitBytes)
+	mOpenFiles             = stats.Int64("fileconsumer_open_files", "Number of files that are held open by readers", stats.UnitDimensionless)
+	mHeaderParseFailures   = stats.Int64("fileconsumer_header_parse_failures", "Number of header lines that failed to be processed by the header metadata operators", stats.UnitDimensionless)
+	mRetiredFiles          = stats.Int64("fileconsumer_retired_files", "Number of files that were no longer read because they were not modified for max_file_age", stats.UnitDimensionless)
+	mRejectedTokens        = stats.Int64("fileconsumer_rejected_tokens", "Number of tokens that were dropped because emitting them failed with a permanent error", stats.UnitDimensionless)
+	mPollInterval          = stats.Int64("fileconsumer_poll_interval", "Interval between polls, which is increased by poll_backoff while files have no new content", stats.UnitMilliseconds)
+)
+
+// openFiles counts the file handles held by the readers of all file consumers
+var openFiles atomic.Int64
+
+// MetricViews returns the metric views for the file consumer.
+func MetricViews() []*view.View {
+	return []*view.View{
+		{
+			Name:        mFingerprintCollisions.Name(),
+			Measure:     mFingerprintCollisions,
+			Description: mFingerprintCollisions.Description(),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mFilesDeleted.Name(),
+			Measure:     mFilesDeleted,
+			Description: mFilesDeleted.Description(),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mTruncations.Name(),
+			Measure:     mTruncations,
+			Description: mTruncations.Description(),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mBytesConsumed.Name(),
+			Measure:     mBytesConsumed,
+			Description: mBytesConsumed.Description(),
+			TagKeys:     []tag.Key{tagFilePath},
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mOpenFiles.Name(),
+			Measure:     mOpenFiles,
+			Description: mOpenFiles.Description(),
+			Aggregation: view.LastValue(),
+		},
+		{
+			Name:        mHeaderParseFailures.Name(),
+			Measure:     mHeaderParseFailures,
+			Description: mHeaderParseFailures.Description(),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mRetiredFiles.Name(),
+			Measure:     mRetiredFiles,
+			Description: mRetiredFiles.Description(),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mRejectedTokens.Name(),
+			Measure:     mRejectedTokens,
+			Description: mRejectedTokens.Description(),
+			Aggregation: view.Sum(),
+		},
+		{
+			Name:        mPollInterval.Name(),
+			Measure:     mPollInterval,
+			Description: mPollInterval.Description(),
+			Aggregation: view.LastValue(),
+		},
+	}
+}
+
+func recordOpenFiles(ctx context.Context) {
+	stats.Record(ctx, mOpenFiles.M(openFiles.Load()))
+}
+
+// recordConsumed counts the bytes the reader emitted since it was at offset. The path of the
+// file is only recorded if it is enabled, since every path is a separate time series.
+func (r *Reader) recordConsumed(ctx context.Context, offset int64) {
+	consumed := r.readOffset() - offset
+	if consumed <= 0 {
+		return
+	}
+	r.consumedBytes.Add(consumed)
+	if !r.metricsIncludeFilePath {
+		stats.Record(ctx, mBytesConsumed.M(consumed))
+		return
+	}
+	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(tagFilePath, r.file.Name())}, mBytesConsumed.M(consumed))
+}
+
+// logEmitError logs the error of emitting tokens. The tokens that were rejected with a permanent
+// error, such as by json_body.strict, are dropped, and are only counted and logged at debug
+// level, since every entry of a file may be rejected.
+func (r *Reader) logEmitError(ctx context.Context, msg string, err error) {
+	var rejected int64
+	var errs error
+	for _, e := range multierr.Errors(err) {
+		if consumererror.IsPermanent(e) {
+			rejected++
+		} else {
+			errs = multierr.Append(errs, e)
+		}
+	}
+	if rejected > 0 {
+		stats.Record(ctx, mRejectedTokens.M(rejected))
+		r.Debugw("Dropped tokens that were rejected", zap.Int64("count", rejected), zap.Error(err))
+	}
+	if errs != nil {
+		r.Errorw(msg, zap.Error(errs))
+	}
+}
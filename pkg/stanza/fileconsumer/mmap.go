@@ -0,0 +1,199 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+
+	stanzaerrors "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/errors"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/scanner"
+)
+
+const (
+	readModeBuffered = "buffered"
+	readModeMmap     = "mmap"
+)
+
+// tokenScanner scans the tokens of a file from an offset, and tracks the offset of the next token
+type tokenScanner interface {
+	Scan() bool
+	Bytes() []byte
+	Pos() int64
+	Err() error
+	Error() error
+}
+
+// newScanner returns a scanner of the tokens of the file from offset. With `read_mode: mmap`,
+// the tokens of regular files are scanned from a mapping of the file. Named pipes and
+// compressed files are always read into a buffer.
+func (r *Reader) newScanner(offset int64) tokenScanner {
+	if r.readMode == readModeMmap && r.fifo == nil && !r.compressed {
+		return newMappedScanner(r, offset)
+	}
+	return scanner.New(r, r.maxLogSize, scanner.DefaultBufferSize, offset, r.splitFunc)
+}
+
+// releaseScanner releases the mapping of a scanner of a mapped file
+func (r *Reader) releaseScanner(s tokenScanner) {
+	if m, ok := s.(*mappedScanner); ok {
+		if err := m.close(); err != nil {
+			r.Debugw("Problem unmapping file", zap.Error(err))
+		}
+	}
+}
+
+// mappedScanner runs the split func over a mapping of the file, instead of reading the file into
+// a buffer. The file is mapped again from the current position when it grew past the mapping.
+// Tokens are copied out of the mapping, so that a file that is truncated while a token is
+// processed does not fault, and tokens are limited to maxLogSize like with the buffered scanner.
+type mappedScanner struct {
+	r          *Reader
+	splitFunc  bufio.SplitFunc
+	maxLogSize int
+
+	// data is mapped from start, a multiple of the page size at or before pos
+	data  []byte
+	start int64
+	pos   int64
+	atEOF bool
+	token []byte
+	err   error
+}
+
+func newMappedScanner(r *Reader, offset int64) *mappedScanner {
+	return &mappedScanner{
+		r:          r,
+		splitFunc:  r.splitFunc,
+		maxLogSize: r.maxLogSize,
+		start:      offset,
+		pos:        offset,
+		token:      make([]byte, 0, scanner.DefaultBufferSize),
+	}
+}
+
+// Scan advances to the next token, which is then available through Bytes
+func (s *mappedScanner) Scan() (ok bool) {
+	// A file that was truncated faults on access to the pages that were removed
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if p := recover(); p != nil {
+			if _, fault := p.(interface{ Addr() uintptr }); !fault {
+				panic(p)
+			}
+			s.err = fmt.Errorf("mapped file was truncated: %v", p)
+			ok = false
+		}
+	}()
+
+	for s.err == nil {
+		data := s.data[s.pos-s.start:]
+		atEOF := false
+		if len(data) > s.maxLogSize {
+			data = data[:s.maxLogSize]
+		} else if s.atEOF {
+			atEOF = true
+		}
+
+		advance, token, err := s.splitFunc(data, atEOF)
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if advance == 0 && token == nil && len(data) >= s.maxLogSize {
+			// No token fits into max_log_size, it is truncated
+			advance, token = s.maxLogSize, data[:s.maxLogSize]
+		} else if len(token) > s.maxLogSize {
+			advance, token = s.maxLogSize, token[:s.maxLogSize]
+		}
+		if advance < 0 || advance > len(data) {
+			s.err = bufio.ErrAdvanceTooFar
+			return false
+		}
+
+		s.pos += int64(advance)
+		if token != nil {
+			s.token = append(s.token[:0], token...)
+			return true
+		}
+		if advance > 0 {
+			continue
+		}
+		if atEOF {
+			return false
+		}
+		// The split func needs more data than is mapped
+		if !s.grow() {
+			s.atEOF = true
+		}
+	}
+	return false
+}
+
+// grow maps the file again from the current position if it grew past the end of the mapping.
+// It returns false if there was nothing more to map.
+func (s *mappedScanner) grow() bool {
+	info, err := s.r.file.Stat()
+	if err != nil {
+		s.err = err
+		return false
+	}
+	end := s.start + int64(len(s.data))
+	if info.Size() <= end {
+		return false
+	}
+
+	start := s.pos - s.pos%int64(os.Getpagesize())
+	data, err := mapFile(s.r.file, start, int(info.Size()-start))
+	if err != nil {
+		s.err = fmt.Errorf("map file: %w", err)
+		return false
+	}
+	// The bytes that were not mapped before are read for the first time
+	s.r.extendFingerprint(end-s.r.Fingerprint.Offset, data[end-start:])
+
+	if err = s.close(); err != nil {
+		s.r.Debugw("Problem unmapping file", zap.Error(err))
+	}
+	s.data, s.start = data, start
+	return true
+}
+
+// close releases the mapping
+func (s *mappedScanner) close() error {
+	if s.data == nil {
+		return nil
+	}
+	data := s.data
+	s.data = nil
+	s.start = s.pos
+	return unmapFile(data)
+}
+
+// Bytes returns the most recent token, which is overwritten by the next call to Scan
+func (s *mappedScanner) Bytes() []byte {
+	return s.token
+}
+
+// Pos returns the offset of the file after the most recent token
+func (s *mappedScanner) Pos() int64 {
+	return s.pos
+}
+
+// Err returns the error that stopped the scanner, if any
+func (s *mappedScanner) Err() error {
+	return s.err
+}
+
+// Error returns the error that stopped the scanner like the buffered scanner describes it
+func (s *mappedScanner) Error() error {
+	if s.err != nil {
+		return stanzaerrors.Wrap(s.err, "scanner error")
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+// +build !windows
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapSupported reports whether files can be read with `read_mode: mmap`
+const mmapSupported = true
+
+// mapFile maps size bytes of file from offset, which must be a multiple of the page size, read only
+func mapFile(file *os.File, offset int64, size int) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), offset, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// unmapFile releases a mapping made by mapFile
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
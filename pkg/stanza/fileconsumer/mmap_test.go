@@ -0,0 +1,156 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+// +build !windows
+
+package fileconsumer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/fingerprint"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func TestBuildReadModeMmap(t *testing.T) {
+	cfg := NewConfig().includeDir(t.TempDir())
+	cfg.ReadMode = readModeMmap
+	m, err := cfg.Build(testutil.Logger(t), nopEmitFunc)
+	require.NoError(t, err)
+	require.Equal(t, readModeMmap, m.readerFactory.readerConfig.readMode)
+}
+
+// collectTokens emits the tokens of the readers of the factory into a slice, which
+// holds more tokens than the channel of testReaderFactory
+func collectTokens(f *readerFactory) *[][]byte {
+	tokens := &[][]byte{}
+	f.readerConfig.emit = func(_ context.Context, token []byte, _ map[string]any) error {
+		*tokens = append(*tokens, append([]byte{}, token...))
+		return nil
+	}
+	return tokens
+}
+
+// readAll reads a file to its end with the read mode, and returns the tokens and the reader
+func readAll(t *testing.T, path, readMode string, maxLogSize int) ([][]byte, *Reader) {
+	f, _ := testReaderFactory(t)
+	f.readerConfig.readMode = readMode
+	f.readerConfig.maxLogSize = maxLogSize
+	tokens := collectTokens(f)
+
+	r, err := f.newReaderBuilder().withFile(openFile(t, path)).build()
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+	r.ReadToEnd(context.Background())
+	return *tokens, r
+}
+
+func TestMmapMatchesBuffered(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{"Lines", "aaa\nbbb\n\nccc\n"},
+		{"PartialLine", "aaa\nbbb"},
+		{"LongLine", "aaa\n" + strings.Repeat("b", 100) + "\nccc\n"},
+		{"LongPartialLine", "aaa\n" + strings.Repeat("b", 100)},
+		{"SeveralPages", strings.Repeat(fmt.Sprintf("%063d\n", 0), 3*os.Getpagesize()/64+1)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			temp := openTemp(t, t.TempDir())
+			writeString(t, temp, tc.content)
+
+			buffered, bufferedReader := readAll(t, temp.Name(), readModeBuffered, 64)
+			mapped, mappedReader := readAll(t, temp.Name(), readModeMmap, 64)
+			require.Equal(t, buffered, mapped)
+			require.Equal(t, bufferedReader.Offset, mappedReader.Offset)
+			require.Equal(t, bufferedReader.Fingerprint, mappedReader.Fingerprint)
+		})
+	}
+}
+
+func TestMmapGrowingFile(t *testing.T) {
+	f, _ := testReaderFactory(t)
+	f.readerConfig.readMode = readModeMmap
+	tokens := collectTokens(f)
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, "aaa\nbb")
+	r, err := f.newReaderBuilder().withFile(openFile(t, temp.Name())).build()
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+
+	r.ReadToEnd(context.Background())
+	require.Equal(t, [][]byte{[]byte("aaa")}, *tokens)
+	require.Equal(t, int64(len("aaa\n")), r.Offset)
+
+	// The file is mapped again from the partial line as it grows past the page it started on
+	*tokens = nil
+	line := strings.Repeat("c", 99)
+	lines := 2 * os.Getpagesize() / 100
+	writeString(t, temp, "b\n"+strings.Repeat(line+"\n", lines))
+	r.ReadToEnd(context.Background())
+	require.Len(t, *tokens, 1+lines)
+	require.Equal(t, []byte("bbb"), (*tokens)[0])
+	for _, token := range (*tokens)[1:] {
+		require.Equal(t, []byte(line), token)
+	}
+
+	info, err := temp.Stat()
+	require.NoError(t, err)
+	require.Equal(t, info.Size(), r.Offset)
+	fp, err := fingerprint.New(temp, fingerprint.DefaultSize)
+	require.NoError(t, err)
+	require.Equal(t, fp, r.Fingerprint)
+}
+
+func TestMmapTruncatedWhileMapped(t *testing.T) {
+	f, _ := testReaderFactory(t)
+	f.readerConfig.readMode = readModeMmap
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, "aaa\n"+strings.Repeat("b", 3*os.Getpagesize())+"\n")
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+
+	s := newMappedScanner(r, 0)
+	t.Cleanup(func() { require.NoError(t, s.close()) })
+	require.True(t, s.Scan())
+	require.Equal(t, []byte("aaa"), s.Bytes())
+
+	// The pages of the mapping past the end of the file fault, which stops the scanner
+	require.NoError(t, temp.Truncate(0))
+	require.False(t, s.Scan())
+	require.ErrorContains(t, s.Error(), "mapped file was truncated")
+}
+
+func BenchmarkReadMode(b *testing.B) {
+	temp := openTemp(b, b.TempDir())
+	line := string(tokenWithLength(99)) + "\n"
+	writeString(b, temp, strings.Repeat(line, 100000))
+
+	for _, readMode := range []string{readModeBuffered, readModeMmap} {
+		b.Run(readMode, func(b *testing.B) {
+			f, _ := testReaderFactory(b)
+			f.readerConfig.readMode = readMode
+			f.readerConfig.emit = nopEmitFunc
+			file := openFile(b, temp.Name())
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r, err := f.newReaderBuilder().withFile(file).build()
+				require.NoError(b, err)
+				r.ReadToEnd(context.Background())
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+// +build windows
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapSupported reports whether files can be read with `read_mode: mmap`
+const mmapSupported = false
+
+// mapFile is not supported, files are read into a buffer on Windows
+func mapFile(*os.File, int64, int) ([]byte, error) {
+	return nil, errors.New("memory mapped files are not supported on windows")
+}
+
+func unmapFile([]byte) error {
+	return nil
+}
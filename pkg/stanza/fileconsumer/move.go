@@ -0,0 +1,97 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// maxMoveSuffix limits the numeric suffixes tried for a name that is taken in the destination
+const maxMoveSuffix = 1000
+
+// moveFile moves a file that was consumed into the move destination
+func (m *Manager) moveFile(ctx context.Context, r *Reader) {
+	if !m.consumed(ctx, r) {
+		return
+	}
+	path := r.file.Name()
+	dest, err := moveToDir(path, m.moveDestination)
+	if err != nil {
+		m.Errorw("Failed to move file", "path", path, zap.Error(err))
+		// Keep tracking the file, so that it is not read again from its start
+		r.removed = false
+		return
+	}
+	m.Debugw("Moved file after it was read", "path", path, "destination", dest)
+}
+
+// moveToDir moves a file into dir under its base name. If the name is taken, a numeric
+// suffix is appended to it. Files on another device are copied, then removed.
+func moveToDir(path string, dir string) (string, error) {
+	dest, err := availablePath(dir, filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(path, dest)
+	if err == nil {
+		return dest, nil
+	}
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) || !isCrossDevice(linkErr.Err) {
+		return "", err
+	}
+	if err = copyFile(path, dest); err != nil {
+		return "", err
+	}
+	return dest, os.Remove(path)
+}
+
+// availablePath returns a path in dir for name that does not refer to an existing file
+func availablePath(dir string, name string) (string, error) {
+	dest := filepath.Join(dir, name)
+	for i := 1; i <= maxMoveSuffix; i++ {
+		if _, err := os.Lstat(dest); errors.Is(err, os.ErrNotExist) {
+			return dest, nil
+		} else if err != nil {
+			return "", err
+		}
+		dest = filepath.Join(dir, name+"."+strconv.Itoa(i))
+	}
+	return "", fmt.Errorf("no available name for %s in %s", name, dir)
+}
+
+// copyFile copies the content of a file to a new file, which is removed if the copy fails
+func copyFile(src string, dest string) (err error) {
+	in, err := os.Open(src) // #nosec - operator must read in files defined by user
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(dest)
+		}
+	}()
+
+	if _, err = io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Sync()
+}
@@ -0,0 +1,17 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+// +build !windows
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDevice returns true if a rename failed because the destination is on another device
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
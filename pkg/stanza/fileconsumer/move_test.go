@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func TestMoveAfterRead(t *testing.T) {
+	tempDir := t.TempDir()
+	destDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.MoveAfterRead = true
+	cfg.MoveDestination = destDir
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+
+	// A file of the same name was moved before
+	require.NoError(t, os.WriteFile(filepath.Join(destDir, "app.log"), []byte("old\n"), 0600))
+
+	path := filepath.Join(tempDir, "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("testlog\n"), 0600))
+
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog"))
+
+	_, err := os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+	moved, err := os.ReadFile(filepath.Join(destDir, "app.log.1"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("testlog\n"), moved)
+	require.Empty(t, operator.knownFiles)
+}
+
+func TestMoveAfterReadFailure(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.MoveAfterRead = true
+	cfg.MoveDestination = filepath.Join(tempDir, "missing")
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "testlog\n")
+
+	// A file that could not be moved is not read again
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog"))
+	operator.poll(context.Background())
+	expectNoTokens(t, emitCalls)
+	_, err := os.Stat(temp.Name())
+	require.NoError(t, err)
+}
+
+func TestMoveToDirCopy(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(src, []byte("testlog\n"), 0600))
+	dest := filepath.Join(t.TempDir(), "app.log")
+
+	// The fallback for renames across devices
+	require.NoError(t, copyFile(src, dest))
+	copied, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	require.Equal(t, []byte("testlog\n"), copied)
+
+	// An existing file is never overwritten
+	require.Error(t, copyFile(src, dest))
+	copied, err = os.ReadFile(dest)
+	require.NoError(t, err)
+	require.Equal(t, []byte("testlog\n"), copied)
+}
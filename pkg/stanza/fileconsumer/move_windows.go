@@ -0,0 +1,20 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+// +build windows
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errorNotSameDevice is the ERROR_NOT_SAME_DEVICE system error code
+const errorNotSameDevice syscall.Errno = 17
+
+// isCrossDevice returns true if a rename failed because the destination is on another volume
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
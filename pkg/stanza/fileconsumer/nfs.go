@@ -0,0 +1,124 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/fingerprint"
+)
+
+const (
+	defaultNFSReopenInterval     = time.Second
+	defaultNFSStaleHandleRetries = 3
+)
+
+// NFSConfig relaxes the consistency assumptions of the reader for files on network file systems
+type NFSConfig struct {
+	ReopenInterval     time.Duration `mapstructure:"reopen_interval,omitempty"`
+	StaleHandleRetries int           `mapstructure:"stale_handle_retries,omitempty"`
+}
+
+// validate returns an error describing why the configuration is invalid, or nil if the configuration is valid.
+func (c *NFSConfig) validate() error {
+	if c.ReopenInterval < 0 {
+		return errors.New("`reopen_interval` must not be negative")
+	}
+	if c.StaleHandleRetries < 0 {
+		return errors.New("`stale_handle_retries` must not be negative")
+	}
+	return nil
+}
+
+func (c *NFSConfig) buildNFSSettings() *nfsSettings {
+	s := &nfsSettings{
+		reopenInterval:     c.ReopenInterval,
+		staleHandleRetries: c.StaleHandleRetries,
+	}
+	if s.reopenInterval == 0 {
+		s.reopenInterval = defaultNFSReopenInterval
+	}
+	if s.staleHandleRetries == 0 {
+		s.staleHandleRetries = defaultNFSStaleHandleRetries
+	}
+	return s
+}
+
+type nfsSettings struct {
+	reopenInterval     time.Duration
+	staleHandleRetries int
+}
+
+// fileRead reads from an open file. It is a variable so tests can simulate server side errors.
+var fileRead = (*os.File).Read
+
+func isStaleFileHandle(err error) bool {
+	return errors.Is(err, syscall.ESTALE)
+}
+
+// recoverStaleHandle reopens the file if err reports a stale file handle and
+// the retry budget allows it. It returns true if reading can resume at r.Offset.
+func (r *Reader) recoverStaleHandle(err error, attempts *int) bool {
+	if r.nfs == nil || !isStaleFileHandle(err) || *attempts >= r.nfs.staleHandleRetries {
+		return false
+	}
+	*attempts++
+	r.Warnw("Stale file handle, reopening file", zap.Int("attempt", *attempts), zap.Error(err))
+	if reopenErr := r.reopen(); reopenErr != nil {
+		r.Errorw("Failed to reopen file", zap.Error(reopenErr))
+		return false
+	}
+	return true
+}
+
+// reopenExpired reopens the file if the current handle is older than the reopen interval,
+// so that the client revalidates cached attributes and observes recently appended data.
+// It returns true if the handle was replaced.
+func (r *Reader) reopenExpired() bool {
+	if r.nfs == nil || time.Since(r.openedAt) < r.nfs.reopenInterval {
+		return false
+	}
+	if err := r.reopen(); err != nil {
+		r.Debugw("Failed to reopen file", zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// reopen replaces the file handle with a new one for the same path, or reopens the file
+// of an evicted reader. The new handle is only kept if it still refers to the file
+// identified by the reader's fingerprint.
+func (r *Reader) reopen() error {
+	file, err := os.Open(r.file.Name()) // #nosec - operator must read in files defined by user
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+
+	fp, err := fingerprint.NewAt(file, r.Fingerprint.Offset, r.fingerprintSize)
+	if err == nil && len(r.Fingerprint.FirstBytes) > 0 && !fp.StartsWith(r.Fingerprint) {
+		err = errors.New("file was replaced")
+	}
+	if err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			r.Debugw("Problem closing reopened file", zap.Error(closeErr))
+		}
+		return err
+	}
+
+	if r.evicted {
+		r.evicted = false
+		openFiles.Add(1)
+	} else if closeErr := r.file.Close(); closeErr != nil {
+		r.Debugw("Problem closing stale file handle", zap.Error(closeErr))
+	}
+	r.file = file
+	r.openedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// staleFileRead makes the reads selected by failOn report a stale file handle.
+func staleFileRead(t *testing.T, failOn func(call int) bool) {
+	var calls int
+	fileRead = func(f *os.File, b []byte) (int, error) {
+		calls++
+		if failOn(calls) {
+			return 0, &os.PathError{Op: "read", Path: f.Name(), Err: syscall.ESTALE}
+		}
+		return f.Read(b)
+	}
+	t.Cleanup(func() { fileRead = (*os.File).Read })
+}
+
+func nfsTestContent(lines int) (string, []string) {
+	var sb strings.Builder
+	expected := make([]string, 0, lines)
+	for i := 0; i < lines; i++ {
+		line := fmt.Sprintf("log line number %05d", i)
+		expected = append(expected, line)
+		sb.WriteString(line + "\n")
+	}
+	return sb.String(), expected
+}
+
+func TestNFSStaleFileHandleRecovery(t *testing.T) {
+	// Large enough to need several reads from the file
+	content, expected := nfsTestContent(5000)
+
+	f, emitChan := testReaderFactory(t)
+	f.readerConfig.nfs = (&NFSConfig{}).buildNFSSettings()
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, content)
+
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	defer r.Close()
+
+	staleFileRead(t, func(call int) bool { return call == 2 || call == 5 })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.ReadToEnd(context.Background())
+	}()
+
+	for _, line := range expected {
+		require.Equal(t, []byte(line), readToken(t, emitChan))
+	}
+	<-done
+	expectNoTokens(t, emitChan)
+	require.True(t, r.eof)
+	require.Equal(t, int64(len(content)), r.Offset)
+}
+
+func TestNFSStaleFileHandleRetriesExhausted(t *testing.T) {
+	content, expected := nfsTestContent(5)
+
+	f, emitChan := testReaderFactory(t)
+	f.readerConfig.nfs = (&NFSConfig{StaleHandleRetries: 2}).buildNFSSettings()
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, content)
+
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	defer r.Close()
+
+	staleFileRead(t, func(int) bool { return true })
+	r.ReadToEnd(context.Background())
+	expectNoTokens(t, emitChan)
+	require.False(t, r.eof)
+	require.Equal(t, int64(0), r.Offset)
+
+	// Once the server recovers, reading resumes without losing data
+	fileRead = (*os.File).Read
+	r.ReadToEnd(context.Background())
+	for _, line := range expected {
+		require.Equal(t, []byte(line), readToken(t, emitChan))
+	}
+	require.True(t, r.eof)
+}
+
+func TestNFSStaleFileHandleWithoutNFS(t *testing.T) {
+	content, _ := nfsTestContent(5)
+
+	f, emitChan := testReaderFactory(t)
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, content)
+
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	defer r.Close()
+
+	staleFileRead(t, func(call int) bool { return call == 1 })
+	r.ReadToEnd(context.Background())
+	expectNoTokens(t, emitChan)
+	require.False(t, r.eof)
+}
+
+func TestNFSReopenReplacedFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows does not allow removing an open file")
+	}
+	tempDir := t.TempDir()
+
+	f, emitChan := testReaderFactory(t)
+	f.readerConfig.nfs = (&NFSConfig{ReopenInterval: time.Nanosecond}).buildNFSSettings()
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "original content\n")
+
+	fp, err := f.newFingerprint(temp)
+	require.NoError(t, err)
+	r, err := f.newReaderBuilder().withFile(temp).withFingerprint(fp).build()
+	require.NoError(t, err)
+	defer r.Close()
+
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("original content"), readToken(t, emitChan))
+
+	// The path now refers to a different file, which must not be read through the old reader
+	require.NoError(t, os.Remove(temp.Name()))
+	require.NoError(t, os.WriteFile(temp.Name(), []byte("replaced content\n"), 0600))
+
+	file := r.file
+	require.Error(t, r.reopen())
+	require.Same(t, file, r.file)
+	r.ReadToEnd(context.Background())
+	expectNoTokens(t, emitChan)
+}
@@ -0,0 +1,101 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opencensus.io/stats"
+)
+
+const (
+	defaultPollBackoffIdlePolls  = 5
+	defaultPollBackoffMultiplier = 2
+)
+
+// PollBackoffConfig increases the interval between polls while no file has new content
+type PollBackoffConfig struct {
+	// IdlePolls is the number of consecutive polls without new content after which the interval is increased
+	IdlePolls int `mapstructure:"idle_polls,omitempty"`
+	// Multiplier is the factor by which the interval is increased
+	Multiplier float64 `mapstructure:"multiplier,omitempty"`
+	// MaxPollInterval is the interval up to which the interval is increased
+	MaxPollInterval time.Duration `mapstructure:"max_poll_interval,omitempty"`
+}
+
+// validate returns an error describing why the configuration is invalid, or nil if the configuration is valid.
+func (c *PollBackoffConfig) validate(pollInterval time.Duration) error {
+	if c.IdlePolls < 0 {
+		return errors.New("`idle_polls` must not be negative")
+	}
+	if c.Multiplier != 0 && c.Multiplier <= 1 {
+		return errors.New("`multiplier` must be greater than 1")
+	}
+	if c.MaxPollInterval < pollInterval {
+		return errors.New("`max_poll_interval` must not be less than `poll_interval`")
+	}
+	return nil
+}
+
+func (c *PollBackoffConfig) buildPollBackoff(pollInterval time.Duration) *pollBackoff {
+	b := &pollBackoff{
+		baseInterval: pollInterval,
+		maxInterval:  c.MaxPollInterval,
+		multiplier:   c.Multiplier,
+		idlePolls:    c.IdlePolls,
+		interval:     pollInterval,
+	}
+	if b.multiplier == 0 {
+		b.multiplier = defaultPollBackoffMultiplier
+	}
+	if b.idlePolls == 0 {
+		b.idlePolls = defaultPollBackoffIdlePolls
+	}
+	return b
+}
+
+// pollBackoff tracks the interval between polls. The interval is multiplied after every
+// idlePolls consecutive polls that read nothing, up to the max interval, and returns to
+// the base interval as soon as a poll reads new content.
+type pollBackoff struct {
+	baseInterval time.Duration
+	maxInterval  time.Duration
+	multiplier   float64
+	idlePolls    int
+
+	idle     int
+	interval time.Duration
+}
+
+// next returns the interval until the next poll, after a poll that read consumed bytes
+func (b *pollBackoff) next(consumed int64) time.Duration {
+	if consumed > 0 {
+		b.idle = 0
+		b.interval = b.baseInterval
+		return b.interval
+	}
+	b.idle++
+	if b.idle >= b.idlePolls {
+		b.idle = 0
+		b.interval = time.Duration(float64(b.interval) * b.multiplier)
+		if b.interval > b.maxInterval {
+			b.interval = b.maxInterval
+		}
+	}
+	return b.interval
+}
+
+// nextPollInterval returns the interval until the next poll, from the bytes that were read
+// from all files since the previous poll
+func (m *Manager) nextPollInterval(ctx context.Context) time.Duration {
+	consumed := m.readerFactory.readerConfig.consumedBytes.Swap(0)
+	interval := m.pollInterval
+	if m.pollBackoff != nil {
+		interval = m.pollBackoff.next(consumed)
+	}
+	stats.Record(ctx, mPollInterval.M(interval.Milliseconds()))
+	return interval
+}
@@ -0,0 +1,74 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func TestPollBackoff(t *testing.T) {
+	cfg := &PollBackoffConfig{IdlePolls: 2, Multiplier: 3, MaxPollInterval: 20 * time.Second}
+	b := cfg.buildPollBackoff(time.Second)
+
+	// The interval is multiplied after every two idle polls, up to the max interval
+	expected := []time.Duration{time.Second, 3 * time.Second, 3 * time.Second, 9 * time.Second, 9 * time.Second, 20 * time.Second, 20 * time.Second, 20 * time.Second}
+	for _, interval := range expected {
+		require.Equal(t, interval, b.next(0))
+	}
+
+	// Any content resets the interval, and the count of idle polls
+	require.Equal(t, time.Second, b.next(1))
+	require.Equal(t, time.Second, b.next(0))
+	require.Equal(t, 3*time.Second, b.next(0))
+}
+
+func TestPollBackoffActivity(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.PollInterval = time.Second
+	cfg.PollBackoff = &PollBackoffConfig{IdlePolls: 1, MaxPollInterval: 4 * time.Second}
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "testlog1\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog1"))
+	require.Equal(t, time.Second, operator.nextPollInterval(context.Background()))
+
+	// Files without new content back off the interval
+	for _, interval := range []time.Duration{2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		operator.poll(context.Background())
+		require.Equal(t, interval, operator.nextPollInterval(context.Background()))
+	}
+
+	// New content in any file resets the interval
+	writeString(t, openTemp(t, tempDir), "testlog2\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("testlog2"))
+	require.Equal(t, time.Second, operator.nextPollInterval(context.Background()))
+}
+
+func TestPollBackoffDisabled(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.PollInterval = time.Second
+	operator, _ := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+
+	for i := 0; i < 10; i++ {
+		operator.poll(context.Background())
+		require.Equal(t, time.Second, operator.nextPollInterval(context.Background()))
+	}
+}
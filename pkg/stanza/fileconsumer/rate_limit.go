@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
+)
+
+// RateLimitConfig limits the throughput at which files are read
+type RateLimitConfig struct {
+	// PerFile is the number of bytes per second that are read from each file
+	PerFile helper.ByteSize `mapstructure:"per_file,omitempty"`
+}
+
+// validate returns an error describing why the configuration is invalid, or nil if the configuration is valid.
+func (c *RateLimitConfig) validate() error {
+	if c.PerFile <= 0 {
+		return errors.New("`per_file` must be positive")
+	}
+	return nil
+}
+
+// rateLimiter is a token bucket of the bytes that may be read from a file. The budget refills
+// at the rate, up to one second worth of bytes. A reader that spent its budget stops reading
+// until the next poll, instead of waiting, so that the other files are read in the meantime.
+type rateLimiter struct {
+	rate   float64
+	budget float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(bytesPerSecond),
+		budget: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// allow refills the budget for the time since it was last refilled, and reports whether
+// there is budget left. A nil rateLimiter always allows reading.
+func (l *rateLimiter) allow() bool {
+	if l == nil {
+		return true
+	}
+	now := time.Now()
+	l.budget = math.Min(l.rate, l.budget+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	return l.budget > 0
+}
+
+// take spends n bytes of the budget. A token that is larger than the budget leaves it in debt,
+// which is paid off before the file is read further.
+func (l *rateLimiter) take(n int64) {
+	if l != nil {
+		l.budget -= float64(n)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(100)
+	require.True(t, l.allow())
+	l.take(150)
+	require.False(t, l.allow())
+
+	// The debt is paid off as the budget refills
+	l.last = l.last.Add(-time.Second)
+	require.True(t, l.allow())
+	l.last = l.last.Add(-time.Hour)
+	require.True(t, l.allow())
+	require.Equal(t, float64(100), l.budget)
+
+	var unlimited *rateLimiter
+	unlimited.take(150)
+	require.True(t, unlimited.allow())
+}
+
+func TestRateLimitPerFile(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.RateLimit = &RateLimitConfig{PerFile: 1000}
+	operator, emitCalls := buildTestManager(t, cfg, withEmitChan(make(chan *emitParams, 200)))
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	// A burst of 1900 bytes takes more than one poll to read at 1000 bytes per second
+	var burst strings.Builder
+	for i := 0; i < 100; i++ {
+		burst.WriteString(fmt.Sprintf("log line %09d\n", i))
+	}
+	noisy := openTemp(t, tempDir)
+	writeString(t, noisy, burst.String())
+	quiet := openTemp(t, tempDir)
+	writeString(t, quiet, "quiet\n")
+
+	// The other file is read while the noisy one waits for its budget
+	operator.poll(context.Background())
+	first := len(emitCalls)
+	require.Greater(t, first, 1)
+	require.Less(t, first, 101)
+	var quietRead bool
+	for i := 0; i < first; i++ {
+		if string((<-emitCalls).token) == "quiet" {
+			quietRead = true
+		}
+	}
+	require.True(t, quietRead)
+
+	read := first
+	polls := 1
+	require.Eventually(t, func() bool {
+		operator.poll(context.Background())
+		polls++
+		read += len(emitCalls)
+		for len(emitCalls) > 0 {
+			<-emitCalls
+		}
+		return read == 101
+	}, 5*time.Second, 100*time.Millisecond)
+	require.Greater(t, polls, 2)
+}
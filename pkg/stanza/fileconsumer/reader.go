@@ -6,27 +6,66 @@ package fileconsumer // import "github.com/open-telemetry/opentelemetry-collecto
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"go.opencensus.io/stats"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.uber.org/zap"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/emit"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/fingerprint"
-	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/scanner"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/pipeline"
 )
 
 type readerConfig struct {
-	fingerprintSize         int
-	maxLogSize              int
-	emit                    emit.Callback
-	includeFileName         bool
-	includeFilePath         bool
-	includeFileNameResolved bool
-	includeFilePathResolved bool
+	fingerprintSize          int
+	fingerprintStrategy      string
+	fingerprintOffset        int64
+	maxLogSize               int
+	maxLogSizeOverrides      []MaxLogSizeOverride
+	emit                     emit.Callback
+	includeFileName          bool
+	includeFilePath          bool
+	includeFileNameResolved  bool
+	includeFilePathResolved  bool
+	resolveSymlinksOnWindows bool
+	includeFileInode         bool
+	includeFileSize          bool
+	includeFileModTime       bool
+	includeFileOwner         bool
+	includeFileMode          bool
+	includeRecordOffset      bool
+	encodingErrorMode        string
+	decompression            string
+	nfs                      *nfsSettings
+	batchSettings            *batchSettings
+	emitBatch                emit.BatchCallback
+	skipHeaderLines          int
+	allowFIFO                bool
+	metricsIncludeFilePath   bool
+	attributesFromPath       *regexp.Regexp
+	atLeastOnce              bool
+	emitOnOpen               bool
+	emitOnClose              bool
+	rateLimitPerFile         int
+	readMode                 string
+	onDeleteWhileReading     string
+	preserveRecordBytes      bool
+	fileAttributeKeys        fileAttributeKeys
+	// consumedBytes counts the bytes read from all files, it is reset after each poll
+	consumedBytes atomic.Int64
+	// headerPersister is the persister of the operators of header pipelines, scoped apart
+	// from the offsets. It is set once the Manager is started.
+	headerPersister operator.Persister
 }
 
 // Reader manages a single file
@@ -38,21 +77,81 @@ type Reader struct {
 	lineSplitFunc bufio.SplitFunc
 	splitFunc     bufio.SplitFunc
 	encoding      helper.Encoding
+	// strictDecoder decodes tokens in place of encoding with an encoding_error_mode other than replace
+	strictDecoder *strictDecoder
 	processFunc   emit.Callback
 
-	Fingerprint    *fingerprint.Fingerprint
+	// maxLogSize replaces the default of the readerConfig with the size that applies to the file
+	maxLogSize int
+
+	Fingerprint *fingerprint.Fingerprint
+	// Offset is the number of bytes of the file content that were consumed, before they are
+	// decoded. It advances by the bytes that the split func consumed, whatever the length of
+	// the records once decoded, so that a reader resumes at the same record with any encoding.
 	Offset         int64
 	generation     int
 	file           *os.File
+	fileInfo       os.FileInfo
+	openedAt       time.Time
 	FileAttributes map[string]any
 	eof            bool
 
 	HeaderFinalized bool
 	recreateScanner bool
+	SkippedLines    int
+
+	// CompressedOffset is the size of a compressed file that was read to its end.
+	// The Offset of a compressed file counts bytes of the decompressed content.
+	CompressedOffset int64
+	compressed       bool
+	decompressor     *decompressor
+
+	fifo *fifoBuffer
+
+	// DetectedEncoding is the encoding of a file read with the auto encoding,
+	// as detected from its byte order mark when the file was first read
+	DetectedEncoding string `json:",omitempty"`
+
+	// HeaderLines are the lines of a header block that were read before its terminator
+	HeaderLines []string `json:",omitempty"`
+
+	// ArchiveMember is the name of the member of an archive that is read from the file,
+	// with `archive_mode: members`. The file is the archive.
+	ArchiveMember string `json:",omitempty"`
+
+	// DeferredPath is the path of a file that was read from its end without a fingerprint,
+	// until content is appended to it
+	DeferredPath string `json:",omitempty"`
+
+	// RetiredPath is the path of a file that was not modified for max_file_age. The reader
+	// keeps the offset of the file without holding it open, until the file is modified again.
+	RetiredPath string `json:",omitempty"`
+
+	batch *tokenBatch
+
+	// lastChange is when the reader last read new content of the file
+	lastChange time.Time
+	removed    bool
+	evicted    bool
 
 	headerSettings       *headerSettings
 	headerPipeline       pipeline.Pipeline
 	headerPipelineOutput *headerPipelineOutput
+	// headerParseFailureMode is the mode of the header settings, and headerFailed is set
+	// once a header line failed to be processed in the error mode.
+	headerParseFailureMode string
+	headerFailed           bool
+	// headerTerminated is set once the terminator of a header block was read
+	headerTerminated bool
+	// rateLimiter limits the throughput at which the file is read, it is handed over to the
+	// readers of the file in the following polls.
+	rateLimiter *rateLimiter
+	// heartbeat tracks the content of the file for heartbeat_interval, it is handed over to
+	// the readers of the file in the following polls.
+	heartbeat *heartbeat
+	// encodingFailed is set once a token was invalid in the configured encoding, with the
+	// stop-file encoding_error_mode. The file is no longer read from that token on.
+	encodingFailed bool
 }
 
 // offsetToEnd sets the starting offset
@@ -62,91 +161,344 @@ func (r *Reader) offsetToEnd() error {
 		return fmt.Errorf("stat: %w", err)
 	}
 	r.Offset = info.Size()
+	// Header lines are only skipped when a file is read from its start
+	r.SkippedLines = r.skipHeaderLines
 	return nil
 }
 
+// detectEncoding detects the encoding of a file from its byte order mark and returns
+// the length of the mark. The encoding of an empty file is detected once it has content.
+func (r *Reader) detectEncoding(file *os.File) int {
+	buf := make([]byte, 3)
+	n, _ := file.ReadAt(buf, 0)
+	if n == 0 {
+		return 0
+	}
+	var bomLength int
+	r.DetectedEncoding, bomLength = helper.DetectBOM(buf[:n])
+	return bomLength
+}
+
+// updateFileInfoAttributes sets the attributes that describe the current size and
+// modification time of the file, so that they are refreshed every poll cycle
+func (r *Reader) updateFileInfoAttributes() {
+	if !r.includeFileSize {
+		delete(r.FileAttributes, logFileSize)
+	}
+	if !r.includeFileModTime {
+		delete(r.FileAttributes, logFileModTime)
+	}
+	if !r.includeFileSize && !r.includeFileModTime {
+		return
+	}
+	info, err := r.file.Stat()
+	if err != nil {
+		r.Errorw("Failed to stat file", zap.Error(err))
+		return
+	}
+	if r.includeFileSize {
+		r.FileAttributes[logFileSize] = info.Size()
+	}
+	if r.includeFileModTime {
+		r.FileAttributes[logFileModTime] = info.ModTime().Format(time.RFC3339)
+	}
+}
+
+// recordAttributes returns the attributes of a record that occupied the bytes of the file
+// from offset up to end. The range is added to a copy of the file attributes, since
+// every record has its own.
+func (r *Reader) recordAttributes(offset, end int64) map[string]any {
+	if !r.includeRecordOffset {
+		return r.FileAttributes
+	}
+	attrs := make(map[string]any, len(r.FileAttributes)+2)
+	for k, v := range r.FileAttributes {
+		attrs[k] = v
+	}
+	attrs[logFileRecordOffset] = offset
+	attrs[logFileRecordLength] = end - offset
+	return attrs
+}
+
+// trackChange records the time of a read that advanced the offset or the fingerprint
+func (r *Reader) trackChange(offset int64, fingerprintLength int) {
+	if r.readOffset() != offset || len(r.Fingerprint.FirstBytes) != fingerprintLength {
+		r.lastChange = time.Now()
+	}
+}
+
+// rewritten reports whether the open file no longer starts with the reader's fingerprint,
+// which happens when a file is truncated and rewritten in place
+func (r *Reader) rewritten() bool {
+	if r.file == nil || r.evicted || r.fifo != nil || len(r.Fingerprint.FirstBytes) == 0 {
+		return false
+	}
+	fp, err := fingerprint.NewAt(r.file, r.Fingerprint.Offset, r.fingerprintSize)
+	return err == nil && !fp.StartsWith(r.Fingerprint)
+}
+
 // ReadToEnd will read until the end of the file
 func (r *Reader) ReadToEnd(ctx context.Context) {
-	if _, err := r.file.Seek(r.Offset, 0); err != nil {
-		r.Errorw("Failed to seek", zap.Error(err))
+	if r.headerFailed || r.encodingFailed {
+		return
+	}
+	if r.evicted {
+		if err := r.reopen(); err != nil {
+			r.Debugw("Failed to reopen evicted file", zap.Error(err))
+			return
+		}
+	}
+	if r.deletedWhileReading() {
+		return
+	}
+	r.updateFileInfoAttributes()
+	defer r.trackChange(r.readOffset(), len(r.Fingerprint.FirstBytes))
+	defer r.recordConsumed(ctx, r.readOffset())
+
+	var compressedSize int64
+	if r.compressed {
+		var complete bool
+		if compressedSize, complete = r.compressedSize(); complete {
+			r.eof = true
+			return
+		}
+	}
+
+	var staleAttempts int
+	var reopened bool
+	for {
+		if err := r.seek(); err != nil {
+			if r.recoverStaleHandle(err, &staleAttempts) {
+				continue
+			}
+			r.Errorw("Failed to seek", zap.Error(err))
+			return
+		}
+		if r.readToEnd(ctx, &staleAttempts) {
+			continue
+		}
+		if r.compressed {
+			if r.eof {
+				r.markCompressedReadComplete(compressedSize)
+			}
+			r.decompressor = nil
+		}
+		// On network file systems a handle may not observe recently appended data
+		if r.eof && !reopened && r.fifo == nil && r.reopenExpired() {
+			reopened = true
+			continue
+		}
+		if r.eof && r.batchExpired() {
+			r.flushBatch(ctx)
+		}
 		return
 	}
+}
+
+// seek positions the file, or the decompressed content of a compressed file, at the read offset
+func (r *Reader) seek() error {
+	if r.fifo != nil {
+		r.fifo.pos = r.readOffset()
+		return nil
+	}
+	if r.compressed {
+		return r.openDecompressor()
+	}
+	_, err := r.file.Seek(r.readOffset(), 0)
+	return err
+}
+
+// readToEnd scans the file from the current position. It returns true if the
+// file handle was replaced after a stale handle error and scanning must resume.
+func (r *Reader) readToEnd(ctx context.Context, staleAttempts *int) bool {
+	// A batch that could not be emitted is retried before the file is read further
+	if r.batchFull() && !r.flushBatch(ctx) {
+		return false
+	}
 
-	s := scanner.New(r, r.maxLogSize, scanner.DefaultBufferSize, r.Offset, r.splitFunc)
+	s := r.newScanner(r.readOffset())
+	defer func() { r.releaseScanner(s) }()
 
 	// Iterate over the tokenized file, emitting entries as we go
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return false
 		default:
 		}
 
+		if !r.rateLimiter.allow() {
+			// The file is read further on a later poll, once its budget refilled
+			r.eof = false
+			return false
+		}
+
+		pos := s.Pos()
 		ok := s.Scan()
+		r.rateLimiter.take(s.Pos() - pos)
 		if !ok {
 			r.eof = true
 			if err := s.Error(); err != nil {
 				// If Scan returned an error then we are not guaranteed to be at the end of the file
 				r.eof = false
+				if r.recoverStaleHandle(s.Err(), staleAttempts) {
+					return true
+				}
 				r.Errorw("Failed during scan", zap.Error(err))
 			}
-			break
+			return false
 		}
 
-		token, err := r.encoding.Decode(s.Bytes())
+		if r.SkippedLines < r.skipHeaderLines {
+			// Discard a header line without decoding it
+			r.SkippedLines++
+			r.advance(s.Pos())
+			continue
+		}
+
+		token, err := r.decode(s.Bytes())
 		if err != nil {
+			if r.encodingFailed {
+				r.eof = false
+				r.Errorw("Invalid byte sequence in the configured encoding, the file is no longer read",
+					zap.Int64("offset", r.readOffset()), zap.Error(err))
+				return false
+			}
 			r.Errorw("decode: %w", zap.Error(err))
-		} else if err = r.processFunc(ctx, token, r.FileAttributes); err != nil {
-			r.Errorw("process: %w", zap.Error(err))
+		} else if r.batching() {
+			r.appendToBatch(token, pos, s.Pos())
+		} else if err = r.processFunc(ctx, token, r.recordAttributes(pos, s.Pos())); err != nil {
+			if r.headerFailed {
+				r.eof = false
+				r.Errorw("Failed to process header, the file is no longer read", zap.Error(err))
+				return false
+			}
+			if ctx.Err() != nil {
+				// Interrupted by shutdown. Keep the offset at the start of
+				// this token so that it is read again on the next start.
+				return false
+			}
+			if r.atLeastOnce && !consumererror.IsPermanent(err) {
+				// Keep the offset at the start of this token and stop reading
+				// the file, the token is emitted again on the next poll. A token
+				// that was rejected permanently would fail again, and is dropped.
+				r.eof = false
+				r.Warnw("Failed to emit, the file is read again from this entry on the next poll", zap.Error(err))
+				return false
+			}
+			r.logEmitError(ctx, "process: %w", err)
 		}
 
 		if r.recreateScanner {
 			r.recreateScanner = false
+			if r.headerTerminated {
+				// The terminator of the header is not read again as part of the body
+				r.headerTerminated = false
+				r.advance(s.Pos())
+			}
 			// recreate the scanner with the log-line's split func.
 			// We do not use the updated offset from the scanner,
 			// as the log line we just read could be multiline, and would be
 			// split differently with the new splitter.
-			if _, err := r.file.Seek(r.Offset, 0); err != nil {
+			if err := r.seek(); err != nil {
 				r.Errorw("Failed to seek post-header", zap.Error(err))
-				return
+				return false
 			}
 
-			s = scanner.New(r, r.maxLogSize, scanner.DefaultBufferSize, r.Offset, r.splitFunc)
+			r.releaseScanner(s)
+			s = r.newScanner(r.Offset)
 		}
 
-		r.Offset = s.Pos()
+		r.advance(s.Pos())
+		if r.batchFull() && !r.flushBatch(ctx) {
+			return false
+		}
 	}
 }
 
+// decode converts a token to UTF-8, applying the encoding_error_mode to the byte sequences
+// that are invalid in the configured encoding
+func (r *Reader) decode(token []byte) ([]byte, error) {
+	if r.strictDecoder == nil {
+		return r.encoding.Decode(token)
+	}
+	decoded, err := r.strictDecoder.Decode(token)
+	if errors.Is(err, errInvalidInput) && r.encodingErrorMode == encodingErrorModeStopFile {
+		r.encodingFailed = true
+	}
+	return decoded, err
+}
+
 // consumeHeaderLine checks if the given token is a line of the header, and consumes it if it is.
 // The return value dictates whether the given line was a header line or not.
 // If false is returned, the full header can be assumed to be read.
 func (r *Reader) consumeHeaderLine(ctx context.Context, token []byte, _ map[string]any) error {
+	if r.headerSettings.terminatorRegex != nil {
+		return r.consumeHeaderBlockLine(ctx, token)
+	}
 	if !r.headerSettings.matchRegex.Match(token) {
-		// Finalize and cleanup the pipeline
-		r.HeaderFinalized = true
-
-		// Stop and drop the header pipeline.
-		if err := r.headerPipeline.Stop(); err != nil {
-			return fmt.Errorf("stop header pipeline: %w", err)
-		}
-		r.headerPipeline = nil
-		r.headerPipelineOutput = nil
+		return r.finalizeHeader()
+	}
+	return r.processHeader(ctx, string(token))
+}
 
-		// Use the line split func instead of the header split func
-		r.splitFunc = r.lineSplitFunc
-		r.processFunc = r.emit
-		// Mark that we should recreate the scanner, since we changed the split function
-		r.recreateScanner = true
+// consumeHeaderBlockLine accumulates the lines of a header block, from a line that matches the
+// pattern to its terminator, and processes them once the terminator is read.
+func (r *Reader) consumeHeaderBlockLine(ctx context.Context, token []byte) error {
+	if len(r.HeaderLines) == 0 && !r.headerSettings.matchRegex.Match(token) {
+		// The file does not start with a header
+		return r.finalizeHeader()
+	}
+	if !r.headerSettings.terminatorRegex.Match(token) {
+		r.HeaderLines = append(r.HeaderLines, string(token))
 		return nil
 	}
 
+	block := strings.Join(r.HeaderLines, "\n")
+	r.HeaderLines = nil
+	if err := r.processHeader(ctx, block); err != nil {
+		return err
+	}
+	// The terminator is part of the header, the body starts after it
+	r.headerTerminated = true
+	return r.finalizeHeader()
+}
+
+// finalizeHeader stops the header pipeline, and reads the rest of the file as its body.
+func (r *Reader) finalizeHeader() error {
+	// Finalize and cleanup the pipeline
+	r.HeaderFinalized = true
+
+	// Stop and drop the header pipeline.
+	if err := r.headerPipeline.Stop(); err != nil {
+		return fmt.Errorf("stop header pipeline: %w", err)
+	}
+	r.headerPipeline = nil
+	r.headerPipelineOutput = nil
+
+	// Use the line split func instead of the header split func
+	r.splitFunc = r.lineSplitFunc
+	r.processFunc = r.emit
+	// Mark that we should recreate the scanner, since we changed the split function
+	r.recreateScanner = true
+	return nil
+}
+
+// processHeader processes a header through the header pipeline, and adds the resulting
+// attributes to the attributes of the file.
+func (r *Reader) processHeader(ctx context.Context, header string) error {
 	firstOperator := r.headerPipeline.Operators()[0]
 
 	newEntry := entry.New()
-	newEntry.Body = string(token)
+	newEntry.Body = header
 
 	if err := firstOperator.Process(ctx, newEntry); err != nil {
+		switch r.headerParseFailureMode {
+		case headerParseFailureMetric:
+			stats.Record(ctx, mHeaderParseFailures.M(1))
+		case headerParseFailureError:
+			r.headerFailed = true
+		}
 		return fmt.Errorf("process header entry: %w", err)
 	}
 
@@ -164,8 +516,10 @@ func (r *Reader) consumeHeaderLine(ctx context.Context, token []byte, _ map[stri
 
 // Close will close the file
 func (r *Reader) Close() {
-	if r.file != nil {
-		if err := r.file.Close(); err != nil {
+	if r.file != nil && !r.evicted {
+		if err := r.file.Close(); err == nil {
+			openFiles.Add(-1)
+		} else if !errors.Is(err, os.ErrClosed) {
 			r.Debugw("Problem closing reader", zap.Error(err))
 		}
 	}
@@ -179,21 +533,43 @@ func (r *Reader) Close() {
 
 // Read from the file and update the fingerprint if necessary
 func (r *Reader) Read(dst []byte) (int, error) {
+	// Named pipes have no fingerprint
+	if r.fifo != nil {
+		return r.readFIFO(dst)
+	}
+	// The fingerprint of a compressed file is made of its compressed bytes
+	if r.decompressor != nil {
+		return r.decompressor.Read(dst)
+	}
+	// Position of the read relative to the start of the fingerprint
+	pos := r.readOffset() - r.Fingerprint.Offset
+	n, err := fileRead(r.file, dst)
+	r.extendFingerprint(pos, dst[:n])
+	return n, err
+}
+
+// extendFingerprint adds the bytes of data, read at pos relative to the start of the
+// fingerprint, to the fingerprint
+func (r *Reader) extendFingerprint(pos int64, data []byte) {
 	// Skip if fingerprint is already built
 	// or if fingerprint is behind Offset
-	if len(r.Fingerprint.FirstBytes) == r.fingerprintSize || int(r.Offset) > len(r.Fingerprint.FirstBytes) {
-		return r.file.Read(dst)
+	if len(r.Fingerprint.FirstBytes) == r.fingerprintSize || pos > int64(len(r.Fingerprint.FirstBytes)) {
+		return
 	}
-	n, err := r.file.Read(dst)
-	appendCount := min0(n, r.fingerprintSize-int(r.Offset))
+	// Bytes read before the start of the fingerprint do not contribute to it
+	var skip int
+	if pos < 0 {
+		skip = min0(len(data), int(-pos))
+		pos = 0
+	}
+	appendCount := min0(len(data)-skip, r.fingerprintSize-int(pos))
 	// return for n == 0 or r.Offset >= r.fileInput.fingerprintSize
 	if appendCount == 0 {
-		return n, err
+		return
 	}
 
 	// for appendCount==0, the following code would add `0` to fingerprint
-	r.Fingerprint.FirstBytes = append(r.Fingerprint.FirstBytes[:r.Offset], dst[:appendCount]...)
-	return n, err
+	r.Fingerprint.FirstBytes = append(r.Fingerprint.FirstBytes[:pos], data[skip:skip+appendCount]...)
 }
 
 func min0(a, b int) int {
@@ -5,16 +5,20 @@ package fileconsumer // import "github.com/open-telemetry/opentelemetry-collecto
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"go.opentelemetry.io/collector/extension/experimental/storage"
 	"go.uber.org/zap"
+	"golang.org/x/text/encoding"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/fingerprint"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/util"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/pipeline"
 )
@@ -35,34 +39,145 @@ func (f *readerFactory) newReader(file *os.File, fp *fingerprint.Fingerprint) (*
 		build()
 }
 
-// copy creates a deep copy of a Reader
+// copy creates a deep copy of a Reader. With header.reparse_on_rotation, a file that does not
+// start with the content of the old one is read from scratch instead, along with its header.
 func (f *readerFactory) copy(old *Reader, newFile *os.File) (*Reader, error) {
+	if f.headerSettings != nil && f.headerSettings.config.ReparseOnRotation {
+		fp, err := f.newFingerprint(newFile)
+		if err != nil {
+			return nil, err
+		}
+		if !fp.StartsWith(old.Fingerprint) {
+			return f.newReaderBuilder().
+				withFile(newFile).
+				withFingerprint(fp).
+				withBatch(old.takeBatch()).
+				build()
+		}
+	}
 	return f.newReaderBuilder().
 		withFile(newFile).
-		withFingerprint(old.Fingerprint.Copy()).
+		withFingerprint(f.upgradeFingerprint(old, newFile)).
 		withOffset(old.Offset).
 		withSplitterFunc(old.lineSplitFunc).
+		withMaxLogSize(old.maxLogSize).
 		withFileAttributes(util.MapCopy(old.FileAttributes)).
 		withHeaderFinalized(old.HeaderFinalized).
+		withHeaderLines(append([]string(nil), old.HeaderLines...)).
+		withSkippedLines(old.SkippedLines).
+		withCompressedOffset(old.CompressedOffset).
+		withDetectedEncoding(old.DetectedEncoding).
+		withLastChange(old.lastChange).
+		withBatch(old.takeBatch()).
+		withRateLimiter(old.rateLimiter).
+		withHeartbeat(old.heartbeat).
+		withArchiveMember(old.ArchiveMember).
 		build()
 }
 
+// ErrFingerprintMismatch is returned when a file was replaced since it was read
+var ErrFingerprintMismatch = errors.New("file does not match the fingerprint")
+
+// newReaderAt creates a reader for the file at path that resumes at offset, regardless of
+// start_at. ErrFingerprintMismatch is returned if the file no longer starts with fp.
+func (f *readerFactory) newReaderAt(path string, offset int64, fp *fingerprint.Fingerprint) (*Reader, error) {
+	file, err := os.Open(path) // #nosec - operator must read in files defined by user
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	// The reader takes the fingerprint of the file, which starts with fp
+	current, err := f.newFingerprint(file)
+	if err == nil && fp.Len() > 0 && !current.StartsWith(fp) {
+		err = ErrFingerprintMismatch
+	}
+	if err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			f.Debugw("Problem closing file", zap.Error(closeErr))
+		}
+		return nil, err
+	}
+
+	resume := *f
+	resume.fromBeginning = true
+	r, err := resume.newReaderBuilder().
+		withFile(file).
+		withFingerprint(current).
+		withOffset(offset).
+		build()
+	if err != nil {
+		if closeErr := file.Close(); closeErr != nil {
+			f.Debugw("Problem closing file", zap.Error(closeErr))
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
 func (f *readerFactory) unsafeReader() (*Reader, error) {
 	return f.newReaderBuilder().build()
 }
 
 func (f *readerFactory) newFingerprint(file *os.File) (*fingerprint.Fingerprint, error) {
-	return fingerprint.New(file, f.readerConfig.fingerprintSize)
+	if f.readerConfig.fingerprintStrategy == fingerprintStrategyContentHash {
+		return fingerprint.NewContentHash(file, f.readerConfig.fingerprintOffset, f.readerConfig.fingerprintSize)
+	}
+	return fingerprint.NewAt(file, f.readerConfig.fingerprintOffset, f.readerConfig.fingerprintSize)
+}
+
+// upgradeFingerprint returns a copy of the fingerprint of the old reader. A fingerprint
+// that was taken while the file was shorter than the fingerprint size is replaced with
+// the fingerprint of the file once it grew, so that the longer fingerprint is stored.
+// A content hash fingerprint restored from storage gets back the first bytes it was
+// hashed from, so that the file can be checked for being rewritten.
+func (f *readerFactory) upgradeFingerprint(old *Reader, newFile *os.File) *fingerprint.Fingerprint {
+	fp := old.Fingerprint.Copy()
+	if fp.Len() == 0 || (fp.Len() >= f.readerConfig.fingerprintSize && len(fp.FirstBytes) > 0) {
+		return fp
+	}
+	grown, err := f.newFingerprint(newFile)
+	if err != nil {
+		f.Debugw("Failed to upgrade fingerprint", "path", newFile.Name(), zap.Error(err))
+		return fp
+	}
+	fp.Restore(grown)
+	fp.Upgrade(grown)
+	return fp
+}
+
+// buildSplitFunc builds a split func for content of the detected encoding, if any
+func (f *readerFactory) buildSplitFunc(detectedEncoding string, maxLogSize int) (bufio.SplitFunc, error) {
+	factory := f.splitterFactory
+	if multiline, ok := factory.(*multilineSplitterFactory); ok && detectedEncoding != "" {
+		splitter := multiline.SplitterConfig
+		splitter.EncodingConfig = helper.EncodingConfig{Encoding: detectedEncoding}
+		factory = newMultilineSplitterFactory(splitter)
+	}
+	splitFunc, err := factory.Build(maxLogSize)
+	if err != nil || !f.readerConfig.preserveRecordBytes {
+		return splitFunc, err
+	}
+	return preserveRecordBytes(splitFunc), nil
 }
 
 type readerBuilder struct {
 	*readerFactory
-	file            *os.File
-	fp              *fingerprint.Fingerprint
-	offset          int64
-	splitFunc       bufio.SplitFunc
-	headerFinalized bool
-	fileAttributes  map[string]any
+	file             *os.File
+	fp               *fingerprint.Fingerprint
+	offset           int64
+	splitFunc        bufio.SplitFunc
+	maxLogSize       int
+	headerFinalized  bool
+	headerLines      []string
+	fileAttributes   map[string]any
+	skippedLines     int
+	compressedOffset int64
+	detectedEncoding string
+	lastChange       time.Time
+	batch            *tokenBatch
+	rateLimiter      *rateLimiter
+	heartbeat        *heartbeat
+	archiveMember    string
 }
 
 func (f *readerFactory) newReaderBuilder() *readerBuilder {
@@ -74,6 +189,13 @@ func (b *readerBuilder) withSplitterFunc(s bufio.SplitFunc) *readerBuilder {
 	return b
 }
 
+// withMaxLogSize keeps the maximum size of a log entry that applied when the file was first read,
+// since a rotated file may no longer match the pattern of its override
+func (b *readerBuilder) withMaxLogSize(maxLogSize int) *readerBuilder {
+	b.maxLogSize = maxLogSize
+	return b
+}
+
 func (b *readerBuilder) withFile(f *os.File) *readerBuilder {
 	b.file = f
 	return b
@@ -94,6 +216,51 @@ func (b *readerBuilder) withHeaderFinalized(finalized bool) *readerBuilder {
 	return b
 }
 
+func (b *readerBuilder) withHeaderLines(lines []string) *readerBuilder {
+	b.headerLines = lines
+	return b
+}
+
+func (b *readerBuilder) withSkippedLines(skipped int) *readerBuilder {
+	b.skippedLines = skipped
+	return b
+}
+
+func (b *readerBuilder) withCompressedOffset(offset int64) *readerBuilder {
+	b.compressedOffset = offset
+	return b
+}
+
+func (b *readerBuilder) withDetectedEncoding(enc string) *readerBuilder {
+	b.detectedEncoding = enc
+	return b
+}
+
+func (b *readerBuilder) withLastChange(t time.Time) *readerBuilder {
+	b.lastChange = t
+	return b
+}
+
+func (b *readerBuilder) withBatch(batch *tokenBatch) *readerBuilder {
+	b.batch = batch
+	return b
+}
+
+func (b *readerBuilder) withRateLimiter(l *rateLimiter) *readerBuilder {
+	b.rateLimiter = l
+	return b
+}
+
+func (b *readerBuilder) withHeartbeat(h *heartbeat) *readerBuilder {
+	b.heartbeat = h
+	return b
+}
+
+func (b *readerBuilder) withArchiveMember(member string) *readerBuilder {
+	b.archiveMember = member
+	return b
+}
+
 func (b *readerBuilder) withFileAttributes(attrs map[string]any) *readerBuilder {
 	b.fileAttributes = attrs
 	return b
@@ -101,26 +268,67 @@ func (b *readerBuilder) withFileAttributes(attrs map[string]any) *readerBuilder
 
 func (b *readerBuilder) build() (r *Reader, err error) {
 	r = &Reader{
-		readerConfig:    b.readerConfig,
-		Offset:          b.offset,
-		headerSettings:  b.headerSettings,
-		HeaderFinalized: b.headerFinalized,
-		FileAttributes:  b.fileAttributes,
+		readerConfig:     b.readerConfig,
+		Offset:           b.offset,
+		headerSettings:   b.headerSettings,
+		HeaderFinalized:  b.headerFinalized,
+		HeaderLines:      b.headerLines,
+		SkippedLines:     b.skippedLines,
+		CompressedOffset: b.compressedOffset,
+		FileAttributes:   b.fileAttributes,
+		batch:            b.batch,
+		lastChange:       b.lastChange,
+		maxLogSize:       b.maxLogSize,
+		rateLimiter:      b.rateLimiter,
+		heartbeat:        b.heartbeat,
+		ArchiveMember:    b.archiveMember,
+	}
+	if r.rateLimiter == nil && b.readerConfig.rateLimitPerFile > 0 {
+		r.rateLimiter = newRateLimiter(b.readerConfig.rateLimitPerFile)
+	}
+	if r.lastChange.IsZero() {
+		r.lastChange = time.Now()
+	}
+	if r.maxLogSize == 0 {
+		r.maxLogSize = b.readerConfig.maxLogSize
+		if b.file != nil {
+			r.maxLogSize = b.readerConfig.maxLogSizeFor(b.file.Name())
+		}
+	}
+
+	// The encoding of a file is detected once, when it is first read
+	var bomLength int
+	encodingConfig := b.encodingConfig
+	if helper.IsAuto(encodingConfig.Encoding) {
+		r.DetectedEncoding = b.detectedEncoding
+		if r.DetectedEncoding == "" && b.file != nil {
+			bomLength = r.detectEncoding(b.file)
+		}
+		if r.DetectedEncoding != "" {
+			encodingConfig.Encoding = r.DetectedEncoding
+		}
 	}
 
-	if b.splitFunc != nil {
+	if b.splitFunc != nil && r.DetectedEncoding == b.detectedEncoding {
 		r.lineSplitFunc = b.splitFunc
 	} else {
-		r.lineSplitFunc, err = b.splitterFactory.Build(b.readerConfig.maxLogSize)
+		r.lineSplitFunc, err = b.buildSplitFunc(r.DetectedEncoding, r.maxLogSize)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	r.encoding, err = b.encodingConfig.Build()
+	r.encoding, err = encodingConfig.Build()
 	if err != nil {
 		return nil, err
 	}
+	switch b.readerConfig.encodingErrorMode {
+	case encodingErrorModeDropBytes, encodingErrorModeSkipToken, encodingErrorModeStopFile:
+		// The nop encoding performs no validation
+		if r.encoding.Encoding != encoding.Nop {
+			r.strictDecoder = newStrictDecoder(r.encoding.Encoding, b.readerConfig.encodingErrorMode == encodingErrorModeDropBytes)
+		}
+	}
 
 	if b.headerSettings == nil || b.headerFinalized {
 		r.splitFunc = r.lineSplitFunc
@@ -129,6 +337,7 @@ func (b *readerBuilder) build() (r *Reader, err error) {
 		// We are reading the header. Use the header split func
 		r.splitFunc = b.headerSettings.splitFunc
 		r.processFunc = r.consumeHeaderLine
+		r.headerParseFailureMode = b.headerSettings.config.ParseFailureMode
 
 		// Create the header pipeline
 		r.headerPipelineOutput = newHeaderPipelineOutput(b.SugaredLogger)
@@ -139,7 +348,11 @@ func (b *readerBuilder) build() (r *Reader, err error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to build pipeline: %w", err)
 		}
-		if err = r.headerPipeline.Start(storage.NewNopClient()); err != nil {
+		var persister operator.Persister = storage.NewNopClient()
+		if b.readerConfig.headerPersister != nil {
+			persister = b.readerConfig.headerPersister
+		}
+		if err = r.headerPipeline.Start(persister); err != nil {
 			return nil, fmt.Errorf("failed to start header pipeline: %w", err)
 		}
 	}
@@ -150,7 +363,13 @@ func (b *readerBuilder) build() (r *Reader, err error) {
 	}
 
 	r.file = b.file
-	r.SugaredLogger = b.SugaredLogger.With("path", b.file.Name())
+	r.openedAt = time.Now()
+	openFiles.Add(1)
+	if info, statErr := b.file.Stat(); statErr == nil {
+		// The identity of the file recognizes it after it was renamed
+		r.fileInfo = info
+	}
+	r.SugaredLogger = b.SugaredLogger.With("path", r.path())
 	r.FileAttributes = b.fileAttributes
 
 	// Resolve file name and path attributes
@@ -163,39 +382,117 @@ func (b *readerBuilder) build() (r *Reader, err error) {
 		if err != nil {
 			b.Errorf("resolve symlinks: %w", err)
 		}
+	} else if b.readerConfig.resolveSymlinksOnWindows {
+		if evaluated, evalErr := filepath.EvalSymlinks(b.file.Name()); evalErr != nil {
+			b.Debugw("Failed to resolve symlinks, using the file name", zap.Error(evalErr))
+		} else {
+			resolved = evaluated
+		}
 	}
 	abs, err := filepath.Abs(resolved)
 	if err != nil {
 		b.Errorf("resolve abs: %w", err)
 	}
 
+	name, path := filepath.Base(b.file.Name()), b.file.Name()
+	nameResolved, pathResolved := filepath.Base(abs), abs
+	if r.ArchiveMember != "" {
+		name, path = archiveMemberPath(name, r.ArchiveMember), archiveMemberPath(path, r.ArchiveMember)
+		nameResolved, pathResolved = archiveMemberPath(nameResolved, r.ArchiveMember), archiveMemberPath(pathResolved, r.ArchiveMember)
+	}
+	keys := b.readerConfig.fileAttributeKeys.resolve()
 	if b.readerConfig.includeFileName {
-		r.FileAttributes[logFileName] = filepath.Base(b.file.Name())
-	} else if r.FileAttributes[logFileName] != nil {
-		delete(r.FileAttributes, logFileName)
+		r.FileAttributes[keys.fileName] = name
+	} else if r.FileAttributes[keys.fileName] != nil {
+		delete(r.FileAttributes, keys.fileName)
 	}
 	if b.readerConfig.includeFilePath {
-		r.FileAttributes[logFilePath] = b.file.Name()
-	} else if r.FileAttributes[logFilePath] != nil {
-		delete(r.FileAttributes, logFilePath)
+		r.FileAttributes[keys.filePath] = path
+	} else if r.FileAttributes[keys.filePath] != nil {
+		delete(r.FileAttributes, keys.filePath)
 	}
 	if b.readerConfig.includeFileNameResolved {
-		r.FileAttributes[logFileNameResolved] = filepath.Base(abs)
-	} else if r.FileAttributes[logFileNameResolved] != nil {
-		delete(r.FileAttributes, logFileNameResolved)
+		r.FileAttributes[keys.fileNameResolved] = nameResolved
+	} else if r.FileAttributes[keys.fileNameResolved] != nil {
+		delete(r.FileAttributes, keys.fileNameResolved)
 	}
 	if b.readerConfig.includeFilePathResolved {
-		r.FileAttributes[logFilePathResolved] = abs
-	} else if r.FileAttributes[logFilePathResolved] != nil {
-		delete(r.FileAttributes, logFilePathResolved)
+		r.FileAttributes[keys.filePathResolved] = pathResolved
+	} else if r.FileAttributes[keys.filePathResolved] != nil {
+		delete(r.FileAttributes, keys.filePathResolved)
+	}
+	// Each named group of the pattern becomes an attribute, files that do not match do not get them
+	if re := b.readerConfig.attributesFromPath; re != nil {
+		// A renamed file keeps none of the attributes of its previous path
+		for _, name := range re.SubexpNames() {
+			delete(r.FileAttributes, "log.file."+name)
+		}
+		if match := re.FindStringSubmatch(abs); match != nil {
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					r.FileAttributes["log.file."+name] = match[i]
+				}
+			}
+		}
 	}
+	if !b.readerConfig.includeFileInode {
+		delete(r.FileAttributes, logFileInode)
+		delete(r.FileAttributes, logFileDevice)
+	} else if r.FileAttributes[logFileInode] == nil {
+		// The identity of a file does not change, so copies of a reader keep it
+		inode, device, idErr := fileID(b.file)
+		if idErr != nil {
+			b.Errorf("resolve file id: %w", idErr)
+		} else {
+			r.FileAttributes[logFileInode] = inode
+			r.FileAttributes[logFileDevice] = device
+		}
+	}
+	// The owner and mode are taken from every build, so that a change of ownership is seen on the next poll
+	if !b.readerConfig.includeFileOwner {
+		delete(r.FileAttributes, logFileOwnerUID)
+		delete(r.FileAttributes, logFileOwnerGID)
+	} else if r.fileInfo != nil {
+		uid, gid, ownerErr := fileOwner(b.file, r.fileInfo)
+		if ownerErr != nil {
+			b.Errorf("resolve file owner: %w", ownerErr)
+		} else {
+			r.FileAttributes[logFileOwnerUID] = uid
+			r.FileAttributes[logFileOwnerGID] = gid
+		}
+	}
+	if !b.readerConfig.includeFileMode {
+		delete(r.FileAttributes, logFileMode)
+	} else if r.fileInfo != nil {
+		if mode, ok := fileMode(r.fileInfo); ok {
+			r.FileAttributes[logFileMode] = mode
+		}
+	}
+	r.updateFileInfoAttributes()
 
-	if !b.fromBeginning {
+	// Named pipes can neither be fingerprinted nor positioned, their content is read as it arrives
+	if b.readerConfig.allowFIFO {
+		if info, statErr := b.file.Stat(); statErr == nil && info.Mode()&os.ModeNamedPipe != 0 {
+			r.fifo = &fifoBuffer{}
+			r.Fingerprint = &fingerprint.Fingerprint{}
+			return r, nil
+		}
+	}
+
+	// Compressed files are always read from the beginning, since the end of
+	// their compressed content does not tell the end of their decompressed content
+	r.compressed = r.ArchiveMember != "" || isCompressed(b.file, b.readerConfig.decompression)
+	if !b.fromBeginning && !r.compressed {
 		if err = r.offsetToEnd(); err != nil {
 			return nil, err
 		}
 	}
 
+	// The byte order mark is not part of the first token
+	if r.Offset < int64(bomLength) {
+		r.Offset = int64(bomLength)
+	}
+
 	if b.fp != nil {
 		r.Fingerprint = b.fp
 		return r, nil
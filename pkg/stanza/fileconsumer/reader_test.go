@@ -7,11 +7,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/fingerprint"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
@@ -119,6 +127,46 @@ func TestTokenizationTooLong(t *testing.T) {
 	}
 }
 
+func TestMaxLogSizeOverride(t *testing.T) {
+	tempDir := t.TempDir()
+	f, emitChan := testReaderFactory(t)
+	f.readerConfig.maxLogSize = 10
+	f.readerConfig.maxLogSizeOverrides = []MaxLogSizeOverride{
+		{Pattern: filepath.Join(tempDir, "*.json"), MaxLogSize: 20},
+		{Pattern: filepath.Join(tempDir, "dump*"), MaxLogSize: 5},
+	}
+
+	logFile := openFile(t, filepath.Join(tempDir, "app.log"))
+	writeString(t, logFile, "aaaaaaaaaaaaaaaaaaaaaa\n")
+	r, err := f.newReaderBuilder().withFile(logFile).build()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("aaaaaaaaaa"), readToken(t, emitChan))
+	require.Equal(t, []byte("aaaaaaaaaa"), readToken(t, emitChan))
+	require.Equal(t, []byte("aa"), readToken(t, emitChan))
+
+	// The first matching pattern applies
+	dumpPath := filepath.Join(tempDir, "dump.json")
+	dumpFile := openFile(t, dumpPath)
+	writeString(t, dumpFile, "bbbbbbbbbbbbbbbbbbbbbb\n")
+	r, err = f.newReaderBuilder().withFile(dumpFile).build()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("bbbbbbbbbbbbbbbbbbbb"), readToken(t, emitChan))
+	require.Equal(t, []byte("bb"), readToken(t, emitChan))
+
+	// A rotated file keeps the size of the pattern it matched
+	r.Close()
+	rotatedPath := filepath.Join(tempDir, "rotated.1")
+	require.NoError(t, os.Rename(dumpPath, rotatedPath))
+	appendFile(t, rotatedPath, []byte("cccccccccccccccccccccc\n"))
+	r, err = f.copy(r, openFile(t, rotatedPath))
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("cccccccccccccccccccc"), readToken(t, emitChan))
+	require.Equal(t, []byte("cc"), readToken(t, emitChan))
+}
+
 func TestTokenizationTooLongWithLineStartPattern(t *testing.T) {
 	fileContent := []byte("aaa2023-01-01aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa 2023-01-01 2 2023-01-01")
 	expected := [][]byte{
@@ -156,6 +204,113 @@ func TestTokenizationTooLongWithLineStartPattern(t *testing.T) {
 	}
 }
 
+func TestForceFlushPartialLine(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	splitterConfig := helper.NewSplitterConfig()
+	splitterConfig.Flusher.Period = 100 * time.Millisecond
+	f.splitterFactory = newMultilineSplitterFactory(splitterConfig)
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, "aaa\nbbb")
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+
+	// The partial line is held until no new data arrives for the flush period
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("aaa"), readToken(t, emitChan))
+	expectNoTokens(t, emitChan)
+	time.Sleep(2 * splitterConfig.Flusher.Period)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("bbb"), readToken(t, emitChan))
+
+	// The rest of the line is emitted without the flushed part
+	writeString(t, temp, "ccc\nddd\n")
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("ccc"), readToken(t, emitChan))
+	require.Equal(t, []byte("ddd"), readToken(t, emitChan))
+	expectNoTokens(t, emitChan)
+}
+
+func TestPreserveRecordBytes(t *testing.T) {
+	stackTrace := "2023-01-01 ERROR failed\r\n\tat com.example.Foo(Foo.java:1)\n  at com.example.Bar(Bar.java:2)  \n"
+	next := "2023-01-01 INFO done\n"
+	lineStart := helper.MultilineConfig{LineStartPattern: `\d+-\d+-\d+`}
+
+	testCases := []struct {
+		name       string
+		multiline  helper.MultilineConfig
+		encoding   string
+		maxLogSize int
+		content    string
+		expected   []string
+	}{
+		{
+			name:      "StackTrace",
+			multiline: lineStart,
+			content:   stackTrace + next + "2023-01-02",
+			expected:  []string{stackTrace, next},
+		},
+		{
+			name:     "Newline",
+			content:  "  a\r\n\nb \n",
+			expected: []string{"  a\r\n", "\n", "b \n"},
+		},
+		{
+			name:     "Encoding",
+			encoding: "utf-16le",
+			content:  "  a\r\n\nb \n",
+			expected: []string{"  a\r\n", "\n", "b \n"},
+		},
+		{
+			name:       "MaxLogSize",
+			multiline:  lineStart,
+			maxLogSize: 40,
+			content:    stackTrace + next + "2023-01-02",
+			expected:   []string{stackTrace[:40], stackTrace[40:80], stackTrace[80:], next},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, emitChan := testReaderFactory(t)
+			splitterConfig := helper.NewSplitterConfig()
+			splitterConfig.Multiline = tc.multiline
+			if tc.encoding != "" {
+				splitterConfig.EncodingConfig = helper.EncodingConfig{Encoding: tc.encoding}
+			}
+			f.splitterFactory = newMultilineSplitterFactory(splitterConfig)
+			f.encodingConfig = splitterConfig.EncodingConfig
+			f.readerConfig.preserveRecordBytes = true
+			if tc.maxLogSize > 0 {
+				f.readerConfig.maxLogSize = tc.maxLogSize
+			}
+
+			content := []byte(tc.content)
+			if tc.encoding != "" {
+				enc, err := splitterConfig.EncodingConfig.Build()
+				require.NoError(t, err)
+				content, err = enc.Encoding.NewEncoder().Bytes(content)
+				require.NoError(t, err)
+			}
+			temp := openTemp(t, t.TempDir())
+			_, err := temp.Write(content)
+			require.NoError(t, err)
+
+			r, err := f.newReaderBuilder().withFile(temp).build()
+			require.NoError(t, err)
+			t.Cleanup(r.Close)
+			r.ReadToEnd(context.Background())
+
+			// Every record is the exact slice of the source that it was read from
+			for _, expected := range tc.expected {
+				require.Equal(t, []byte(expected), readToken(t, emitChan))
+			}
+			expectNoTokens(t, emitChan)
+		})
+	}
+}
+
 func TestHeaderFingerprintIncluded(t *testing.T) {
 	fileContent := []byte("#header-line\naaa\n")
 
@@ -196,7 +351,224 @@ func TestHeaderFingerprintIncluded(t *testing.T) {
 	require.Equal(t, []byte("#header-line\naaa\n"), r.Fingerprint.FirstBytes)
 }
 
-func testReaderFactory(t *testing.T) (*readerFactory, chan *emitParams) {
+func TestHeaderReparseOnRotation(t *testing.T) {
+	for _, reparse := range []bool{false, true} {
+		t.Run(fmt.Sprintf("reparse_on_rotation=%t", reparse), func(t *testing.T) {
+			f, emitChan := testReaderFactory(t)
+			regexConf := regex.NewConfig()
+			regexConf.Regex = "^#(?P<header>.*)"
+			headerConf := &HeaderConfig{
+				Pattern:           "^#",
+				MetadataOperators: []operator.Config{{Builder: regexConf}},
+				ReparseOnRotation: reparse,
+			}
+			h, err := headerConf.buildHeaderSettings(encoding.Nop)
+			require.NoError(t, err)
+			f.headerSettings = h
+
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, "app.log")
+			logFile := openFile(t, path)
+			writeString(t, logFile, "#first\naaa\n")
+			r, err := f.newReaderBuilder().withFile(logFile).build()
+			require.NoError(t, err)
+			r.ReadToEnd(context.Background())
+			waitForTokenWithAttributes(t, emitChan, []byte("aaa"), map[string]any{"header": "first"})
+
+			// Content appended to the same file keeps the header
+			writeString(t, logFile, "bbb\n")
+			r.Close()
+			r, err = f.copy(r, openFile(t, path))
+			require.NoError(t, err)
+			require.True(t, r.HeaderFinalized)
+			r.ReadToEnd(context.Background())
+			waitForTokenWithAttributes(t, emitChan, []byte("bbb"), map[string]any{"header": "first"})
+
+			// A new file with a header of its own replaces the file
+			r.Close()
+			require.NoError(t, os.Rename(path, filepath.Join(tempDir, "app.log.1")))
+			rotated := openFile(t, path)
+			writeString(t, rotated, "#second\nccc\n")
+			r, err = f.copy(r, openFile(t, path))
+			require.NoError(t, err)
+			t.Cleanup(r.Close)
+			r.ReadToEnd(context.Background())
+			if reparse {
+				waitForTokenWithAttributes(t, emitChan, []byte("ccc"), map[string]any{"header": "second"})
+			} else {
+				expectNoTokens(t, emitChan)
+			}
+		})
+	}
+}
+
+func TestHeaderParseFailureMode(t *testing.T) {
+	for _, mode := range []string{"", headerParseFailureIgnore, headerParseFailureMetric, headerParseFailureError} {
+		t.Run(mode, func(t *testing.T) {
+			views := MetricViews()
+			require.NoError(t, view.Register(views...))
+			t.Cleanup(func() { view.Unregister(views...) })
+
+			f, emitChan := testReaderFactory(t)
+			regexConf := regex.NewConfig()
+			regexConf.Regex = "^#(?P<header>[a-z]+)$"
+			headerConf := &HeaderConfig{
+				Pattern:           "^#",
+				MetadataOperators: []operator.Config{{Builder: regexConf}},
+				ParseFailureMode:  mode,
+			}
+			require.NoError(t, headerConf.validate())
+			h, err := headerConf.buildHeaderSettings(encoding.Nop)
+			require.NoError(t, err)
+			f.headerSettings = h
+
+			temp := openTemp(t, t.TempDir())
+			writeString(t, temp, "#first\n#123\naaa\n")
+			r, err := f.newReaderBuilder().withFile(temp).build()
+			require.NoError(t, err)
+			t.Cleanup(r.Close)
+			r.ReadToEnd(context.Background())
+
+			if mode == headerParseFailureError {
+				// The file is no longer read from the malformed header line on
+				r.ReadToEnd(context.Background())
+				expectNoTokens(t, emitChan)
+				require.Equal(t, int64(len("#first\n")), r.Offset)
+				return
+			}
+
+			// The malformed header line is skipped
+			waitForTokenWithAttributes(t, emitChan, []byte("aaa"), map[string]any{"header": "first"})
+			rows, err := view.RetrieveData(mHeaderParseFailures.Name())
+			require.NoError(t, err)
+			if mode == headerParseFailureMetric {
+				require.Len(t, rows, 1)
+				require.Equal(t, float64(1), rows[0].Data.(*view.SumData).Value)
+			} else {
+				require.Empty(t, rows)
+			}
+		})
+	}
+}
+
+func TestHeaderTerminatorPattern(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	regexConf := regex.NewConfig()
+	regexConf.Regex = `^#format=(?P<format>\w+)\n#version=(?P<version>\w+)\n#host=(?P<host>\w+)\n#owner=(?P<owner>\w+)$`
+	headerConf := &HeaderConfig{
+		Pattern:           "^#",
+		MetadataOperators: []operator.Config{{Builder: regexConf}},
+		TerminatorPattern: "^$",
+	}
+	require.NoError(t, headerConf.validate())
+	h, err := headerConf.buildHeaderSettings(encoding.Nop)
+	require.NoError(t, err)
+	f.headerSettings = h
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	logFile := openFile(t, path)
+	writeString(t, logFile, "#format=csv\n#version=2\n")
+	r, err := f.newReaderBuilder().withFile(logFile).build()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	expectNoTokens(t, emitChan)
+	require.Equal(t, []string{"#format=csv", "#version=2"}, r.HeaderLines)
+
+	// The rest of the header block is read on a later poll
+	writeString(t, logFile, "#host=a\n#owner=b\n\naaa\nbbb\n")
+	r.Close()
+	r, err = f.copy(r, openFile(t, path))
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+	r.ReadToEnd(context.Background())
+
+	attrs := map[string]any{"format": "csv", "version": "2", "host": "a", "owner": "b"}
+	waitForTokenWithAttributes(t, emitChan, []byte("aaa"), attrs)
+	waitForTokenWithAttributes(t, emitChan, []byte("bbb"), attrs)
+	expectNoTokens(t, emitChan)
+	require.True(t, r.HeaderFinalized)
+	require.Empty(t, r.HeaderLines)
+}
+
+func TestLengthPrefixFraming(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	f.splitterFactory = newLengthPrefixSplitterFactory()
+
+	frames := lengthPrefixed([]byte("aaa"), []byte("multi\nline"), []byte("ccc"))
+	temp := openTemp(t, t.TempDir())
+	_, err := temp.Write(frames[:len(frames)-2])
+	require.NoError(t, err)
+
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("aaa"), readToken(t, emitChan))
+	require.Equal(t, []byte("multi\nline"), readToken(t, emitChan))
+	expectNoTokens(t, emitChan)
+
+	// The partial frame is read once it is complete
+	_, err = temp.Write(frames[len(frames)-2:])
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("ccc"), readToken(t, emitChan))
+	require.Equal(t, int64(len(frames)), r.Offset)
+}
+
+func TestFingerprintOffsetGrows(t *testing.T) {
+	f, _ := testReaderFactory(t)
+	f.readerConfig.fingerprintSize = 16
+	f.readerConfig.fingerprintOffset = 8
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, "banner\n")
+
+	fp, err := f.newFingerprint(temp)
+	require.NoError(t, err)
+	require.Empty(t, fp.FirstBytes)
+	r, err := f.newReaderBuilder().withFile(temp).withFingerprint(fp).build()
+	require.NoError(t, err)
+	defer r.Close()
+
+	// The fingerprint grows with the bytes that follow the offset only
+	writeString(t, temp, "-first\n")
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("first\n"), r.Fingerprint.FirstBytes)
+
+	writeString(t, temp, "second line\n")
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("first\nsecond lin"), r.Fingerprint.FirstBytes)
+	require.Equal(t, int64(8), r.Fingerprint.Offset)
+}
+
+func TestFingerprintUpgradedOnCopy(t *testing.T) {
+	f, _ := testReaderFactory(t)
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, string(tokenWithLength(49))+"\n")
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, 50, r.Fingerprint.Len())
+
+	// A copy made once the file grew past the fingerprint size stores the longer fingerprint
+	writeString(t, temp, string(tokenWithLength(1499))+"\n")
+	r2, err := f.copy(r, openFile(t, temp.Name()))
+	require.NoError(t, err)
+	defer r2.Close()
+	fp, err := fingerprint.New(temp, fingerprint.DefaultSize)
+	require.NoError(t, err)
+	require.Equal(t, fp, r2.Fingerprint)
+	require.Equal(t, int64(50), r2.Offset)
+
+	// A file that no longer starts with the fingerprint keeps it
+	r3, err := f.copy(r, openFile(t, filepath.Join(t.TempDir(), "other.log")))
+	require.NoError(t, err)
+	defer r3.Close()
+	require.Equal(t, r.Fingerprint, r3.Fingerprint)
+}
+
+func testReaderFactory(t testing.TB) (*readerFactory, chan *emitParams) {
 	emitChan := make(chan *emitParams, 100)
 	splitterConfig := helper.NewSplitterConfig()
 	return &readerFactory{
@@ -272,3 +644,477 @@ func TestEncodingDecode(t *testing.T) {
 	assert.Empty(t, decodedReader.FileAttributes[logFileNameResolved])
 	assert.Empty(t, decodedReader.FileAttributes[logFilePathResolved])
 }
+
+func TestEncodingErrorMode(t *testing.T) {
+	// U+FFFD in the file content is valid, and is kept in every mode
+	fileContent := append([]byte{'a', 0xc5, 'b', '\n', 'c', '\n', 0xff, 0xfe, '\n'}, "d\uFFFD\n"...)
+
+	testCases := []struct {
+		mode     string
+		expected [][]byte
+	}{
+		{
+			encodingErrorModeReplace,
+			[][]byte{
+				[]byte("a�b"),
+				[]byte("c"),
+				[]byte("��"),
+				[]byte("d�"),
+			},
+		},
+		{
+			encodingErrorModeDropBytes,
+			[][]byte{
+				[]byte("ab"),
+				[]byte("c"),
+				[]byte(""),
+				[]byte("d�"),
+			},
+		},
+		{
+			encodingErrorModeSkipToken,
+			[][]byte{
+				[]byte("c"),
+				[]byte("d�"),
+			},
+		},
+		{
+			encodingErrorModeStopFile,
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			f, emitChan := testReaderFactory(t)
+			f.readerConfig.encodingErrorMode = tc.mode
+
+			temp := openTemp(t, t.TempDir())
+			_, err := temp.Write(fileContent)
+			require.NoError(t, err)
+
+			r, err := f.newReaderBuilder().withFile(temp).build()
+			require.NoError(t, err)
+
+			r.ReadToEnd(context.Background())
+
+			for _, expected := range tc.expected {
+				require.Equal(t, expected, readToken(t, emitChan))
+			}
+			select {
+			case call := <-emitChan:
+				require.FailNow(t, "Received unexpected token", "Token: %q", call.token)
+			default:
+			}
+			if tc.mode == encodingErrorModeStopFile {
+				// The file is no longer read from the invalid token on
+				r.ReadToEnd(context.Background())
+				expectNoTokens(t, emitChan)
+				require.Equal(t, int64(0), r.Offset)
+				return
+			}
+			require.Equal(t, int64(len(fileContent)), r.Offset)
+		})
+	}
+}
+
+func TestAutoEncoding(t *testing.T) {
+	utf16le := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder()
+	utf16be := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder()
+
+	testCases := []struct {
+		name     string
+		bom      []byte
+		encoder  *encoding.Encoder
+		expected string
+	}{
+		{"utf-8 bom", []byte{0xef, 0xbb, 0xbf}, unicode.UTF8.NewEncoder(), "utf-8"},
+		{"utf-16le bom", []byte{0xff, 0xfe}, utf16le, "utf-16le"},
+		{"utf-16be bom", []byte{0xfe, 0xff}, utf16be, "utf-16be"},
+		{"no bom", nil, unicode.UTF8.NewEncoder(), "utf-8"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, emitChan := testReaderFactory(t)
+			splitterConfig := helper.NewSplitterConfig()
+			splitterConfig.EncodingConfig.Encoding = "auto"
+			f.splitterFactory = newMultilineSplitterFactory(splitterConfig)
+			f.encodingConfig = splitterConfig.EncodingConfig
+
+			encode := func(s string) []byte {
+				b, err := tc.encoder.Bytes([]byte(s))
+				require.NoError(t, err)
+				return b
+			}
+
+			temp := openTemp(t, t.TempDir())
+			_, err := temp.Write(append(append([]byte{}, tc.bom...), encode("line 1\nline 2\n")...))
+			require.NoError(t, err)
+
+			r, err := f.newReaderBuilder().withFile(temp).build()
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, r.DetectedEncoding)
+
+			r.ReadToEnd(context.Background())
+			require.Equal(t, []byte("line 1"), readToken(t, emitChan))
+			require.Equal(t, []byte("line 2"), readToken(t, emitChan))
+
+			// The detected encoding is kept by readers of the same file
+			_, err = temp.Write(encode("line 3\n"))
+			require.NoError(t, err)
+			r2, err := f.copy(r, temp)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, r2.DetectedEncoding)
+
+			r2.ReadToEnd(context.Background())
+			require.Equal(t, []byte("line 3"), readToken(t, emitChan))
+			expectNoTokens(t, emitChan)
+		})
+	}
+}
+
+func TestAutoEncodingEmptyFile(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	f.encodingConfig = helper.EncodingConfig{Encoding: "auto"}
+
+	temp := openTemp(t, t.TempDir())
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	require.Empty(t, r.DetectedEncoding)
+
+	// The encoding is detected once the file has content
+	_, err = temp.Write([]byte{0xef, 0xbb, 0xbf, 'a', '\n'})
+	require.NoError(t, err)
+	r2, err := f.copy(r, temp)
+	require.NoError(t, err)
+	require.Equal(t, "utf-8", r2.DetectedEncoding)
+
+	r2.ReadToEnd(context.Background())
+	require.Equal(t, []byte("a"), readToken(t, emitChan))
+}
+
+func TestUTF16OffsetResume(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	splitterConfig := helper.NewSplitterConfig()
+	splitterConfig.EncodingConfig.Encoding = "utf-16le"
+	f.splitterFactory = newMultilineSplitterFactory(splitterConfig)
+	f.encodingConfig = splitterConfig.EncodingConfig
+	f.readerConfig.fingerprintSize = 16
+
+	utf16le := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder()
+	encode := func(s string) []byte {
+		b, err := utf16le.Bytes([]byte(s))
+		require.NoError(t, err)
+		return b
+	}
+
+	// The records have characters of two and four bytes in utf-16, and of one to four bytes in utf-8
+	first := encode("héllo\n€ 1\n𝄞 clef\n")
+	temp := openTemp(t, t.TempDir())
+	_, err := temp.Write(first)
+	require.NoError(t, err)
+
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("héllo"), readToken(t, emitChan))
+	require.Equal(t, []byte("€ 1"), readToken(t, emitChan))
+	require.Equal(t, []byte("𝄞 clef"), readToken(t, emitChan))
+
+	// The offset and the fingerprint count the bytes of the file, not of the decoded records
+	require.Equal(t, int64(len(first)), r.Offset)
+	require.Equal(t, first[:16], r.Fingerprint.FirstBytes)
+
+	// A partial record is not consumed
+	second := encode("naïve\npart")
+	_, err = temp.Write(second)
+	require.NoError(t, err)
+	r2, err := f.copy(r, temp)
+	require.NoError(t, err)
+	require.Equal(t, r.Offset, r2.Offset)
+	r2.ReadToEnd(context.Background())
+	require.Equal(t, []byte("naïve"), readToken(t, emitChan))
+	expectNoTokens(t, emitChan)
+	require.Equal(t, int64(len(first)+len(encode("naïve\n"))), r2.Offset)
+
+	// The next reader resumes exactly at the start of the partial record
+	_, err = temp.Write(encode("ial ☃\n"))
+	require.NoError(t, err)
+	r3, err := f.copy(r2, temp)
+	require.NoError(t, err)
+	r3.ReadToEnd(context.Background())
+	require.Equal(t, []byte("partial ☃"), readToken(t, emitChan))
+	expectNoTokens(t, emitChan)
+	require.Equal(t, int64(len(first)+len(second)+len(encode("ial ☃\n"))), r3.Offset)
+}
+
+func TestFileAttributeKeys(t *testing.T) {
+	temp := openTemp(t, t.TempDir())
+
+	testCases := []struct {
+		name     string
+		keys     fileAttributeKeys
+		expected []string
+	}{
+		{
+			name:     "Default",
+			expected: []string{logFileName, logFilePath, logFileNameResolved, logFilePathResolved},
+		},
+		{
+			name:     "Custom",
+			keys:     fileAttributeKeys{fileName: "source.file", filePath: "source.path"},
+			expected: []string{"source.file", "source.path", logFileNameResolved, logFilePathResolved},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, _ := testReaderFactory(t)
+			f.readerConfig.includeFileName = true
+			f.readerConfig.includeFilePath = true
+			f.readerConfig.includeFileNameResolved = true
+			f.readerConfig.includeFilePathResolved = true
+			f.readerConfig.fileAttributeKeys = tc.keys
+
+			r, err := f.newReaderBuilder().withFile(temp).build()
+			require.NoError(t, err)
+			keys := make([]string, 0, len(r.FileAttributes))
+			for key := range r.FileAttributes {
+				keys = append(keys, key)
+			}
+			require.ElementsMatch(t, tc.expected, keys)
+			require.Equal(t, filepath.Base(temp.Name()), r.FileAttributes[tc.expected[0]])
+			require.Equal(t, temp.Name(), r.FileAttributes[tc.expected[1]])
+
+			// The attributes under the replaced keys are removed when their flags are off
+			f.readerConfig.includeFileName = false
+			f.readerConfig.includeFilePath = false
+			f.readerConfig.includeFileNameResolved = false
+			f.readerConfig.includeFilePathResolved = false
+			r2, err := f.copy(r, temp)
+			require.NoError(t, err)
+			require.Empty(t, r2.FileAttributes)
+		})
+	}
+}
+
+func TestFileInodeAttributes(t *testing.T) {
+	f, _ := testReaderFactory(t)
+	f.readerConfig.includeFileInode = true
+
+	temp := openTemp(t, t.TempDir())
+	inode, device, err := fileID(temp)
+	require.NoError(t, err)
+
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	require.Equal(t, inode, r.FileAttributes[logFileInode])
+	require.Equal(t, device, r.FileAttributes[logFileDevice])
+
+	// Copies of the reader carry the file identity forward
+	r2, err := f.copy(r, temp)
+	require.NoError(t, err)
+	require.Equal(t, inode, r2.FileAttributes[logFileInode])
+	require.Equal(t, device, r2.FileAttributes[logFileDevice])
+
+	// The attributes are absent when the flag is off
+	f.readerConfig.includeFileInode = false
+	r3, err := f.copy(r2, temp)
+	require.NoError(t, err)
+	require.NotContains(t, r3.FileAttributes, logFileInode)
+	require.NotContains(t, r3.FileAttributes, logFileDevice)
+}
+
+func TestFileInfoAttributes(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	f.readerConfig.includeFileSize = true
+	f.readerConfig.includeFileModTime = true
+
+	temp := openTemp(t, t.TempDir())
+	_, err := temp.Write([]byte("line 1\n"))
+	require.NoError(t, err)
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(temp.Name(), past, past))
+
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	call := <-emitChan
+	require.Equal(t, []byte("line 1"), call.token)
+	require.Equal(t, int64(7), call.attrs[logFileSize])
+	require.Equal(t, past.Format(time.RFC3339), call.attrs[logFileModTime])
+
+	// The attributes are refreshed on the next poll after the file grows
+	_, err = temp.Write([]byte("line 2\n"))
+	require.NoError(t, err)
+	info, err := temp.Stat()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+	call = <-emitChan
+	require.Equal(t, []byte("line 2"), call.token)
+	require.Equal(t, int64(14), call.attrs[logFileSize])
+	require.Equal(t, info.ModTime().Format(time.RFC3339), call.attrs[logFileModTime])
+
+	// The attributes are absent when the options are off
+	f.readerConfig.includeFileSize = false
+	f.readerConfig.includeFileModTime = false
+	r2, err := f.copy(r, temp)
+	require.NoError(t, err)
+	require.NotContains(t, r2.FileAttributes, logFileSize)
+	require.NotContains(t, r2.FileAttributes, logFileModTime)
+}
+
+func TestRecordOffset(t *testing.T) {
+	testCases := []struct {
+		name  string
+		batch *BatchConfig
+	}{
+		{"Records", nil},
+		{"Batched", &BatchConfig{MaxTokens: 2, FlushInterval: time.Hour}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, emitChan := testReaderFactory(t)
+			f.readerConfig.includeRecordOffset = true
+			if tc.batch != nil {
+				f.readerConfig.batchSettings = tc.batch.buildBatchSettings()
+			}
+
+			temp := openTemp(t, t.TempDir())
+			writeString(t, temp, "line 1\n\nlonger line 3\r\nline 4\n")
+			r, err := f.newReaderBuilder().withFile(temp).build()
+			require.NoError(t, err)
+			r.ReadToEnd(context.Background())
+			require.True(t, r.flushBatch(context.Background()))
+
+			// The records are contiguous, and cover the file up to the offset
+			var end int64
+			for len(emitChan) > 0 {
+				call := <-emitChan
+				require.Equal(t, end, call.attrs[logFileRecordOffset], "record %q", call.token)
+				length, ok := call.attrs[logFileRecordLength].(int64)
+				require.True(t, ok)
+				require.GreaterOrEqual(t, length, int64(len(call.token)))
+				end += length
+			}
+			require.Equal(t, r.Offset, end)
+			require.Equal(t, int64(len("line 1\n\nlonger line 3\r\nline 4\n")), end)
+
+			// The range is not kept in the attributes of the file
+			require.NotContains(t, r.FileAttributes, logFileRecordOffset)
+			require.NotContains(t, r.FileAttributes, logFileRecordLength)
+		})
+	}
+}
+
+func TestRecordOffsetOff(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, "line 1\n")
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	r.ReadToEnd(context.Background())
+
+	call := <-emitChan
+	require.NotContains(t, call.attrs, logFileRecordOffset)
+	require.NotContains(t, call.attrs, logFileRecordLength)
+}
+
+func TestNewReaderAt(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "end"
+	operator, emitCalls := buildTestManager(t, cfg)
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "line 1\nline 2\n")
+
+	// The reader resumes at the offset regardless of start_at, in a file that grew since
+	// its fingerprint was taken
+	r, err := operator.NewReaderAt(temp.Name(), int64(len("line 1\n")), []byte("line 1\n"))
+	require.NoError(t, err)
+	defer r.Close()
+	require.Equal(t, []byte("line 1\nline 2\n"), r.Fingerprint.FirstBytes)
+	r.ReadToEnd(context.Background())
+	waitForToken(t, emitCalls, []byte("line 2"))
+	expectNoTokens(t, emitCalls)
+}
+
+func TestNewReaderAtFingerprintMismatch(t *testing.T) {
+	tempDir := t.TempDir()
+	operator, _ := buildTestManager(t, NewConfig().includeDir(tempDir))
+
+	// The file was replaced by one with different content
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "other\n")
+
+	r, err := operator.NewReaderAt(temp.Name(), int64(len("line 1\n")), []byte("line 1\n"))
+	require.ErrorIs(t, err, ErrFingerprintMismatch)
+	require.Nil(t, r)
+}
+
+func TestAttributesFromPath(t *testing.T) {
+	tempDir := t.TempDir()
+	f, _ := testReaderFactory(t)
+	f.readerConfig.attributesFromPath = regexp.MustCompile(`(?P<ns>[^_/\\]+)_(?P<pod>[^/\\]+)[/\\](?P<container>[^/\\]+)[/\\][^/\\]+\.log$`)
+
+	podDir := filepath.Join(tempDir, "default_web-0", "nginx")
+	require.NoError(t, os.MkdirAll(podDir, 0700))
+	r, err := f.newReaderBuilder().withFile(openFile(t, filepath.Join(podDir, "0.log"))).build()
+	require.NoError(t, err)
+	require.Equal(t, "default", r.FileAttributes["log.file.ns"])
+	require.Equal(t, "web-0", r.FileAttributes["log.file.pod"])
+	require.Equal(t, "nginx", r.FileAttributes["log.file.container"])
+
+	// A file moved to a path that does not match keeps none of the attributes of its previous path
+	moved, err := f.copy(r, openFile(t, filepath.Join(tempDir, "moved.log")))
+	require.NoError(t, err)
+	require.NotContains(t, moved.FileAttributes, "log.file.ns")
+	require.NotContains(t, moved.FileAttributes, "log.file.pod")
+	require.NotContains(t, moved.FileAttributes, "log.file.container")
+
+	// Files that do not match do not get the attributes
+	r, err = f.newReaderBuilder().withFile(openFile(t, filepath.Join(tempDir, "app.log"))).build()
+	require.NoError(t, err)
+	require.NotContains(t, r.FileAttributes, "log.file.ns")
+	require.NotContains(t, r.FileAttributes, "log.file.pod")
+	require.NotContains(t, r.FileAttributes, "log.file.container")
+}
+
+func TestAtLeastOnce(t *testing.T) {
+	f, emitChan := testReaderFactory(t)
+	f.readerConfig.atLeastOnce = true
+	sinkErr := errors.New("sink is full")
+	var failing bool
+	emitToChan := f.readerConfig.emit
+	f.readerConfig.emit = func(ctx context.Context, token []byte, attrs map[string]any) error {
+		if failing && string(token) == "line 2" {
+			return sinkErr
+		}
+		return emitToChan(ctx, token, attrs)
+	}
+
+	temp := openTemp(t, t.TempDir())
+	writeString(t, temp, "line 1\nline 2\nline 3\n")
+	r, err := f.newReaderBuilder().withFile(temp).build()
+	require.NoError(t, err)
+	defer r.Close()
+
+	// The offset does not advance past the token that could not be emitted
+	failing = true
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("line 1"), readToken(t, emitChan))
+	expectNoTokens(t, emitChan)
+	require.Equal(t, int64(len("line 1\n")), r.Offset)
+	require.False(t, r.eof)
+
+	// Reading resumes at that token once the sink accepts it
+	failing = false
+	r.ReadToEnd(context.Background())
+	require.Equal(t, []byte("line 2"), readToken(t, emitChan))
+	require.Equal(t, []byte("line 3"), readToken(t, emitChan))
+	require.Equal(t, int64(len("line 1\nline 2\nline 3\n")), r.Offset)
+	require.True(t, r.eof)
+}
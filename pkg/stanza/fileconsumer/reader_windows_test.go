@@ -0,0 +1,57 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+// +build windows
+
+package fileconsumer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSymlinksOnWindows(t *testing.T) {
+	tempDir := t.TempDir()
+	targetDir := filepath.Join(tempDir, "target")
+	require.NoError(t, os.Mkdir(targetDir, 0700))
+	targetPath := filepath.Join(targetDir, "app.log")
+	require.NoError(t, os.WriteFile(targetPath, []byte("testlog\n"), 0600))
+
+	junction := filepath.Join(tempDir, "junction")
+	out, err := exec.Command("cmd", "/c", "mklink", "/J", junction, targetDir).CombinedOutput()
+	require.NoError(t, err, string(out))
+	junctionPath := filepath.Join(junction, "app.log")
+
+	resolvedTarget, err := filepath.EvalSymlinks(targetPath)
+	require.NoError(t, err)
+	unresolved, err := filepath.Abs(junctionPath)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		resolve  bool
+		expected string
+	}{
+		{"disabled", false, unresolved},
+		{"enabled", true, resolvedTarget},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, _ := testReaderFactory(t)
+			f.readerConfig.includeFileNameResolved = true
+			f.readerConfig.includeFilePathResolved = true
+			f.readerConfig.resolveSymlinksOnWindows = tc.resolve
+
+			r, err := f.newReaderBuilder().withFile(openFile(t, junctionPath)).build()
+			require.NoError(t, err)
+			defer r.Close()
+			require.Equal(t, tc.expected, r.FileAttributes[logFilePathResolved])
+			require.Equal(t, filepath.Base(tc.expected), r.FileAttributes[logFileNameResolved])
+		})
+	}
+}
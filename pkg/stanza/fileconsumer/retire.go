@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/fingerprint"
+)
+
+// stale reports whether the file at path was not modified for max_file_age
+func (m *Manager) stale(path string) bool {
+	if m.maxFileAge == 0 || (m.archiveMode == archiveModeMembers && isArchive(path)) {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > m.maxFileAge
+}
+
+// retire returns the reader of a stale file without reading it. The reader is kept among the
+// known files with its offset while the file stays stale, and its file is not held open. A
+// file that is not known yet is opened once, and retired at its end. The second result is
+// true if the file was not retired before, so that its reader still has to be closed.
+func (m *Manager) retire(ctx context.Context, path string) (*Reader, bool) {
+	if r := m.findPathMatch(path); r != nil {
+		r.generation = 0
+		if r.RetiredPath != "" {
+			return r, false
+		}
+		r.RetiredPath = path
+		return r, true
+	}
+
+	f := m.openFile(ctx, path)
+	if f == nil {
+		return nil, false
+	}
+	oldReader := m.findFileMatch(f)
+	if oldReader == nil {
+		oldReader, _ = m.findFingerprintMatch(f.fp)
+	}
+	var r *Reader
+	var err error
+	if oldReader != nil {
+		r, err = m.readerFactory.copy(oldReader, f.file)
+	} else if r, err = m.readerFactory.newReader(f.file, f.fp); err == nil && !r.compressed {
+		err = r.offsetToEnd()
+	}
+	if err != nil {
+		m.Errorw("Failed to create reader", zap.Error(err))
+		if closeErr := f.file.Close(); closeErr != nil {
+			m.Errorf("problem closing file %s", f.file.Name())
+		}
+		return nil, false
+	}
+	r.RetiredPath = path
+	return r, true
+}
+
+// closeRetired closes the readers of the files that were retired this poll. Their pending
+// tokens are emitted, since the files are only read again once they are modified.
+func (m *Manager) closeRetired(ctx context.Context, readers []*Reader) {
+	for _, r := range readers {
+		r.flushBatch(ctx)
+		r.evict()
+		stats.Record(ctx, mRetiredFiles.M(1))
+		m.Debugw("Retired file that was not modified for max_file_age", "path", r.RetiredPath)
+	}
+}
+
+// findPathMatch removes the newest known reader of the file at path from the known files,
+// and returns it. Readers of archive members and of files without a fingerprint are not
+// matched by their path.
+func (m *Manager) findPathMatch(path string) *Reader {
+	for i := len(m.knownFiles) - 1; i >= 0; i-- {
+		r := m.knownFiles[i]
+		if r.RetiredPath == path ||
+			(r.RetiredPath == "" && r.ArchiveMember == "" && r.DeferredPath == "" && r.file != nil && r.file.Name() == path) {
+			m.knownFiles = append(m.knownFiles[:i], m.knownFiles[i+1:]...)
+			return r
+		}
+	}
+	return nil
+}
+
+// findRetiredMatch removes the reader of the retired file at path from the known files, if the
+// file still starts with its fingerprint, and returns it. A retired reader is only matched to
+// the file at its path, since it is kept for as long as the file exists.
+func (m *Manager) findRetiredMatch(path string, fp *fingerprint.Fingerprint) *Reader {
+	for i := len(m.knownFiles) - 1; i >= 0; i-- {
+		r := m.knownFiles[i]
+		if r.RetiredPath == path && fp.StartsWith(r.Fingerprint) {
+			m.knownFiles = append(m.knownFiles[:i], m.knownFiles[i+1:]...)
+			return r
+		}
+	}
+	return nil
+}
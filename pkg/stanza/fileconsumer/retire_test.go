@@ -0,0 +1,148 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+// age sets the modification time of the file to before max_file_age
+func age(t *testing.T, file *os.File) {
+	past := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(file.Name(), past, past))
+}
+
+// touch sets the modification time of the file to now
+func touch(t *testing.T, file *os.File) {
+	now := time.Now()
+	require.NoError(t, os.Chtimes(file.Name(), now, now))
+}
+
+func TestMaxFileAge(t *testing.T) {
+	views := MetricViews()
+	require.NoError(t, view.Register(views...))
+	t.Cleanup(func() { view.Unregister(views...) })
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.MaxFileAge = time.Hour
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	// The file is adopted while it is modified
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "line 1\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("line 1"))
+
+	// Once it is stale, its reader is retired and its file is closed, for as long as it stays stale
+	age(t, temp)
+	operator.poll(context.Background())
+	writeString(t, temp, "line 2\n")
+	age(t, temp)
+	for i := 0; i < 5; i++ {
+		operator.poll(context.Background())
+	}
+	expectNoTokens(t, emitCalls)
+	require.Len(t, operator.knownFiles, 1)
+	retired := operator.knownFiles[0]
+	require.Equal(t, temp.Name(), retired.RetiredPath)
+	require.True(t, retired.evicted)
+	require.Equal(t, int64(len("line 1\n")), retired.Offset)
+
+	rows, err := view.RetrieveData(mRetiredFiles.Name())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, float64(1), rows[0].Data.(*view.SumData).Value)
+
+	// The file is adopted again at its offset once it is modified
+	touch(t, temp)
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("line 2"))
+	expectNoTokens(t, emitCalls)
+	require.Len(t, operator.knownFiles, 1)
+	require.Empty(t, operator.knownFiles[0].RetiredPath)
+	require.False(t, operator.knownFiles[0].evicted)
+
+	// And retired again once it is stale again
+	age(t, temp)
+	operator.poll(context.Background())
+	require.Equal(t, temp.Name(), operator.knownFiles[0].RetiredPath)
+	rows, err = view.RetrieveData(mRetiredFiles.Name())
+	require.NoError(t, err)
+	require.Equal(t, float64(2), rows[0].Data.(*view.SumData).Value)
+}
+
+func TestMaxFileAgeRestart(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.MaxFileAge = time.Hour
+	persister := testutil.NewMockPersister("test")
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "line 1\n")
+	op1, emitCalls1 := buildTestManager(t, cfg)
+	op1.persister = persister
+	op1.poll(context.Background())
+	waitForToken(t, emitCalls1, []byte("line 1"))
+	age(t, temp)
+	op1.poll(context.Background())
+	require.NoError(t, op1.Stop())
+
+	// The retired reader is restored, and the stale file is not read
+	writeString(t, temp, "line 2\n")
+	age(t, temp)
+	op2, emitCalls2 := buildTestManager(t, cfg)
+	op2.persister = persister
+	require.NoError(t, op2.loadLastPollFiles(context.Background()))
+	for i := 0; i < 5; i++ {
+		op2.poll(context.Background())
+	}
+	expectNoTokens(t, emitCalls2)
+
+	touch(t, temp)
+	op2.poll(context.Background())
+	waitForToken(t, emitCalls2, []byte("line 2"))
+	expectNoTokens(t, emitCalls2)
+	require.NoError(t, op2.Stop())
+}
+
+func TestMaxFileAgeNewStaleFile(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.MaxFileAge = time.Hour
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	// A file that is stale when it is found is retired at its end
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "old line\n")
+	age(t, temp)
+	operator.poll(context.Background())
+	expectNoTokens(t, emitCalls)
+	require.Len(t, operator.knownFiles, 1)
+	require.Equal(t, int64(len("old line\n")), operator.knownFiles[0].Offset)
+
+	writeString(t, temp, "new line\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("new line"))
+	expectNoTokens(t, emitCalls)
+}
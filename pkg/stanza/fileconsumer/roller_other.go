@@ -25,10 +25,21 @@ func (r *detectLostFiles) readLostFiles(ctx context.Context, readers []*Reader)
 OUTER:
 	for _, oldReader := range r.oldReaders {
 		for _, reader := range readers {
-			if reader.Fingerprint.StartsWith(oldReader.Fingerprint) {
+			if reader.Fingerprint.StartsWith(oldReader.Fingerprint) ||
+				(oldReader.DeferredPath != "" && reader.file.Name() == oldReader.DeferredPath) {
 				continue OUTER
 			}
 		}
+		if oldReader.rewritten() {
+			// The content at the reader's offset is not a continuation of what it read
+			oldReader.flushBatch(ctx)
+			continue
+		}
+		if oldReader.deletedWhileReading() {
+			// The file is not drained with on_delete_while_reading close-immediately
+			oldReader.flushBatch(ctx)
+			continue
+		}
 		lostReaders = append(lostReaders, oldReader)
 	}
 
@@ -38,6 +49,8 @@ OUTER:
 		go func(r *Reader) {
 			defer lostWG.Done()
 			r.ReadToEnd(ctx)
+			// The file will not be read again, emit what is left of its batch
+			r.flushBatch(ctx)
 		}(reader)
 	}
 	lostWG.Wait()
@@ -585,3 +585,93 @@ func TestFileMovedWhileOff_BigFiles(t *testing.T) {
 	require.NoError(t, operator.Start(persister))
 	waitForToken(t, emitCalls, log2)
 }
+
+// TestRenameRotation follows the sequence of logrotate's create mode: the file is renamed,
+// the application writes to it until it reopens its path, and a new file is created at the path
+func TestRenameRotation(t *testing.T) {
+	if runtime.GOOS == windowsOS {
+		t.Skip("Moving files while open is unsupported on Windows")
+	}
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		firstLine string
+		newLine   string
+	}{
+		{"DifferentContent", "testlog1", "newlog1"},
+		// The new file starts with the content of the rotated file that was read, so
+		// that both match the fingerprint of the reader of the rotated file
+		{"SamePrefix", "header", "header"},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			cfg := NewConfig().includeDir(tempDir)
+			cfg.StartAt = "beginning"
+			operator, emitCalls := buildTestManager(t, cfg)
+			operator.persister = testutil.NewMockPersister("test")
+			defer func() {
+				require.NoError(t, operator.Stop())
+			}()
+
+			path := filepath.Join(tempDir, "app.log")
+			file := openFile(t, path)
+			writeString(t, file, tc.firstLine+"\n")
+			operator.poll(context.Background())
+			waitForTokenWithAttributes(t, emitCalls, []byte(tc.firstLine), map[string]any{logFileName: "app.log"})
+
+			// The rotated file is still written to, and read, after it was renamed
+			writeString(t, file, "testlog2\n")
+			require.NoError(t, os.Rename(path, path+".1"))
+			writeString(t, file, "testlog3\n")
+			writeString(t, openFile(t, path), tc.newLine+"\nnewlog2\n")
+
+			operator.poll(context.Background())
+			operator.poll(context.Background())
+			tokens := make([]string, 0, 4)
+			for i := 0; i < 4; i++ {
+				call := <-emitCalls
+				tokens = append(tokens, fmt.Sprintf("%s@%s", call.token, call.attrs[logFileName]))
+			}
+			require.ElementsMatch(t, []string{
+				"testlog2@app.log.1",
+				"testlog3@app.log.1",
+				tc.newLine + "@app.log",
+				"newlog2@app.log",
+			}, tokens)
+			expectNoTokens(t, emitCalls)
+		})
+	}
+}
+
+func TestRenameRotationAttributesFromPath(t *testing.T) {
+	if runtime.GOOS == windowsOS {
+		t.Skip("Moving files while open is unsupported on Windows")
+	}
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = "beginning"
+	cfg.AttributesFromPath = `app-(?P<shard>\d+)\.log$`
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	path := filepath.Join(tempDir, "app-1.log")
+	file := openFile(t, path)
+	writeString(t, file, "testlog1\n")
+	operator.poll(context.Background())
+	waitForTokenWithAttributes(t, emitCalls, []byte("testlog1"), map[string]any{logFileName: "app-1.log", "log.file.shard": "1"})
+
+	// The rotated path does not match the pattern, so the file has no shard anymore
+	require.NoError(t, os.Rename(path, path+".1"))
+	writeString(t, file, "testlog2\n")
+	operator.poll(context.Background())
+	waitForTokenWithAttributes(t, emitCalls, []byte("testlog2"), map[string]any{logFileName: "app-1.log.1"})
+	expectNoTokens(t, emitCalls)
+}
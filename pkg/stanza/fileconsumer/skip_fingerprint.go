@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+
+import (
+	"context"
+	"os"
+
+	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/fingerprint"
+)
+
+// startAtEndSkipFingerprint starts reading files at their end like start_at end, without
+// reading the fingerprints of the files that exist at startup until content is appended to them
+const startAtEndSkipFingerprint = "end-skip-fingerprint"
+
+// makeDeferredReader creates a reader for a file that exists at startup without reading its
+// fingerprint. Until content is appended to the file, the reader identifies it by its path and
+// size, after which it takes the fingerprint of the file. It returns false if the file is not,
+// or no longer, identified this way.
+func (m *Manager) makeDeferredReader(ctx context.Context, path string) (*Reader, bool) {
+	oldReader := m.findDeferredReader(path)
+	if oldReader == nil && m.readerFactory.fromBeginning {
+		// Only files that exist at startup are read without a fingerprint
+		return nil, false
+	}
+
+	m.logNewPath(path)
+	file, err := os.Open(path) // #nosec - operator must read in files defined by user
+	if err != nil {
+		m.Debugw("Failed to open file", zap.Error(err))
+		return nil, true
+	}
+	info, err := file.Stat()
+	if err != nil || (oldReader != nil && info.Size() < oldReader.Offset) {
+		// The file was replaced, it is identified by its fingerprint from now on
+		if closeErr := file.Close(); closeErr != nil {
+			m.Errorf("problem closing file %s", file.Name())
+		}
+		return nil, false
+	}
+
+	var r *Reader
+	switch {
+	case oldReader == nil:
+		r, err = m.readerFactory.newReaderBuilder().
+			withFile(file).
+			withFingerprint(&fingerprint.Fingerprint{Offset: m.readerFactory.readerConfig.fingerprintOffset}).
+			build()
+		if err == nil {
+			r.DeferredPath = path
+			m.fileOpened(ctx, r)
+		}
+	case info.Size() == oldReader.Offset:
+		if r, err = m.readerFactory.copy(oldReader, file); err == nil {
+			r.DeferredPath = path
+		}
+	default:
+		// Content was appended, the reader upgrades to the fingerprint of the file
+		var fp *fingerprint.Fingerprint
+		if fp, err = m.readerFactory.newFingerprint(file); err == nil {
+			if r, err = m.readerFactory.copy(oldReader, file); err == nil {
+				r.Fingerprint = fp
+				m.currentFps = append(m.currentFps, fp)
+				m.currentPaths = append(m.currentPaths, path)
+			}
+		}
+	}
+	if err != nil {
+		m.Errorw("Failed to create reader", zap.Error(err))
+		if closeErr := file.Close(); closeErr != nil {
+			m.Errorf("problem closing file %s", file.Name())
+		}
+		return nil, true
+	}
+	return r, true
+}
+
+// findDeferredReader returns the newest known reader of the file at path,
+// if that reader identifies the file by its path
+func (m *Manager) findDeferredReader(path string) *Reader {
+	for i := len(m.knownFiles) - 1; i >= 0; i-- {
+		r := m.knownFiles[i]
+		if r.DeferredPath == path {
+			return r
+		}
+		if r.file != nil && r.file.Name() == path {
+			return nil
+		}
+	}
+	return nil
+}
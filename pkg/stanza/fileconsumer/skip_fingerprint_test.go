@@ -0,0 +1,92 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fileconsumer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+)
+
+func TestStartAtEndSkipFingerprint(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = startAtEndSkipFingerprint
+	operator, emitCalls := buildTestManager(t, cfg)
+	operator.persister = testutil.NewMockPersister("test")
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	existing := openTemp(t, tempDir)
+	writeString(t, existing, "historic1\nhistoric2\n")
+
+	// The existing file is read from its end without a fingerprint
+	operator.poll(context.Background())
+	expectNoTokens(t, emitCalls)
+	reader := operator.knownFiles[len(operator.knownFiles)-1]
+	require.Empty(t, reader.Fingerprint.FirstBytes)
+	require.Equal(t, existing.Name(), reader.DeferredPath)
+
+	operator.poll(context.Background())
+	expectNoTokens(t, emitCalls)
+
+	// A file created after startup is read from its beginning
+	created := openTemp(t, tempDir)
+	writeString(t, created, "created1\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("created1"))
+	expectNoTokens(t, emitCalls)
+
+	// The reader takes the fingerprint of the file once content is appended
+	writeString(t, existing, "new1\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("new1"))
+	expectNoTokens(t, emitCalls)
+	for _, r := range operator.knownFiles[len(operator.knownFiles)-2:] {
+		if r.file.Name() == existing.Name() {
+			require.Equal(t, []byte("historic1\nhistoric2\nnew1\n"), r.Fingerprint.FirstBytes)
+			require.Empty(t, r.DeferredPath)
+		}
+	}
+
+	writeString(t, existing, "new2\n")
+	operator.poll(context.Background())
+	waitForToken(t, emitCalls, []byte("new2"))
+	expectNoTokens(t, emitCalls)
+}
+
+func TestStartAtEndSkipFingerprintRestart(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := NewConfig().includeDir(tempDir)
+	cfg.StartAt = startAtEndSkipFingerprint
+
+	existing := openTemp(t, tempDir)
+	writeString(t, existing, "historic1\n")
+
+	persister := testutil.NewUnscopedMockPersister()
+	op1, emitCalls1 := buildTestManager(t, cfg)
+	op1.persister = persister
+	op1.poll(context.Background())
+	expectNoTokens(t, emitCalls1)
+	require.NoError(t, op1.Stop())
+
+	// The restored reader still identifies the file by its path
+	op2, emitCalls2 := buildTestManager(t, cfg)
+	op2.persister = persister
+	require.NoError(t, op2.loadLastPollFiles(context.Background()))
+	defer func() {
+		require.NoError(t, op2.Stop())
+	}()
+	op2.poll(context.Background())
+	expectNoTokens(t, emitCalls2)
+
+	writeString(t, existing, "new1\n")
+	op2.poll(context.Background())
+	waitForToken(t, emitCalls2, []byte("new1"))
+	expectNoTokens(t, emitCalls2)
+}
@@ -5,6 +5,8 @@ package fileconsumer // import "github.com/open-telemetry/opentelemetry-collecto
 
 import (
 	"bufio"
+	"encoding/binary"
+	"fmt"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
 )
@@ -63,3 +65,50 @@ func (factory *customizeSplitterFactory) Build(_ int) (bufio.SplitFunc, error) {
 	}
 	return factory.Splitter, nil
 }
+
+// preserveRecordBytes returns the tokens of splitFunc as the exact bytes of the file that they
+// were split from, such as the newlines between and after the lines of a multiline record,
+// instead of the trimmed tokens. The bytes that are skipped without a token are left out.
+func preserveRecordBytes(splitFunc bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		advance, token, err = splitFunc(data, atEOF)
+		if token != nil {
+			token = data[:advance]
+		}
+		return
+	}
+}
+
+// lengthPrefixSize is the size of the big-endian length that precedes each frame
+const lengthPrefixSize = 4
+
+type lengthPrefixSplitterFactory struct{}
+
+var _ splitterFactory = (*lengthPrefixSplitterFactory)(nil)
+
+func newLengthPrefixSplitterFactory() *lengthPrefixSplitterFactory {
+	return &lengthPrefixSplitterFactory{}
+}
+
+// Build builds a split func for frames of a 4-byte big-endian length followed by that many
+// bytes of payload. The tokens are the payloads. A frame that is larger than maxLogSize,
+// including its length, is an error.
+func (factory *lengthPrefixSplitterFactory) Build(maxLogSize int) (bufio.SplitFunc, error) {
+	if maxLogSize <= lengthPrefixSize {
+		return nil, fmt.Errorf("max log size must be greater than the %d bytes of a length prefix", lengthPrefixSize)
+	}
+	return func(data []byte, _ bool) (advance int, token []byte, err error) {
+		if len(data) < lengthPrefixSize {
+			// Request more data, a partial frame is read once it is complete
+			return 0, nil, nil
+		}
+		size := lengthPrefixSize + int64(binary.BigEndian.Uint32(data))
+		if size > int64(maxLogSize) {
+			return 0, nil, fmt.Errorf("frame of %d bytes is larger than the max log size of %d bytes", size, maxLogSize)
+		}
+		if int64(len(data)) < size {
+			return 0, nil, nil
+		}
+		return int(size), data[lengthPrefixSize:size], nil
+	}, nil
+}
@@ -5,10 +5,15 @@ package fileconsumer // import "github.com/open-telemetry/opentelemetry-collecto
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/binary"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/internal/scanner"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
 )
 
@@ -133,3 +138,78 @@ func Test_newCustomizeSplitterFactory(t *testing.T) {
 		})
 	assert.NotNil(t, splitter)
 }
+
+func lengthPrefixed(payloads ...[]byte) []byte {
+	var frames []byte
+	for _, payload := range payloads {
+		frames = binary.BigEndian.AppendUint32(frames, uint32(len(payload)))
+		frames = append(frames, payload...)
+	}
+	return frames
+}
+
+func Test_lengthPrefixSplitterFactory_Build(t *testing.T) {
+	payloads := [][]byte{[]byte("a"), {}, []byte("hello\nworld"), bytes.Repeat([]byte{0x00, 0xff}, 150)}
+	frames := lengthPrefixed(payloads...)
+	maxLogSize := 512
+	oversized := lengthPrefixed(make([]byte, maxLogSize-lengthPrefixSize+1))
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantTokens [][]byte
+		wantPos    int
+		wantErr    bool
+	}{
+		{
+			name:       "complete frames",
+			data:       frames,
+			wantTokens: payloads,
+			wantPos:    len(frames),
+		},
+		{
+			name:       "partial length",
+			data:       append(append([]byte{}, frames...), 0x00, 0x00),
+			wantTokens: payloads,
+			wantPos:    len(frames),
+		},
+		{
+			name:       "partial payload",
+			data:       append(append([]byte{}, frames...), lengthPrefixed([]byte("cut off"))[:8]...),
+			wantTokens: payloads,
+			wantPos:    len(frames),
+		},
+		{
+			name:       "frame larger than max log size",
+			data:       append(lengthPrefixed([]byte("a")), oversized...),
+			wantTokens: [][]byte{[]byte("a")},
+			wantPos:    len(lengthPrefixed([]byte("a"))),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splitFunc, err := newLengthPrefixSplitterFactory().Build(maxLogSize)
+			require.NoError(t, err)
+
+			// Read a byte at a time into a small buffer, so that frames are split across reads
+			s := scanner.New(iotest.OneByteReader(bytes.NewReader(tt.data)), maxLogSize, 8, 0, splitFunc)
+			var tokens [][]byte
+			for s.Scan() {
+				tokens = append(tokens, append([]byte{}, s.Bytes()...))
+			}
+			if tt.wantErr {
+				assert.Error(t, s.Error())
+			} else {
+				assert.NoError(t, s.Error())
+			}
+			assert.Equal(t, tt.wantTokens, tokens)
+			assert.Equal(t, int64(tt.wantPos), s.Pos())
+		})
+	}
+}
+
+func Test_lengthPrefixSplitterFactory_BuildMaxLogSize(t *testing.T) {
+	_, err := newLengthPrefixSplitterFactory().Build(lengthPrefixSize)
+	assert.Error(t, err)
+}
@@ -4,6 +4,7 @@
 package helper // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"strings"
@@ -71,6 +72,35 @@ var encodingOverrides = map[string]encoding.Encoding{
 	"us-ascii": unicode.UTF8,
 	"nop":      encoding.Nop,
 	"":         unicode.UTF8,
+	// auto is decoded as utf-8 unless a byte order mark is detected with DetectBOM
+	autoEncoding: unicode.UTF8,
+}
+
+const autoEncoding = "auto"
+
+var byteOrderMarks = []struct {
+	encoding string
+	bom      []byte
+}{
+	{"utf-8", []byte{0xef, 0xbb, 0xbf}},
+	{"utf-16le", []byte{0xff, 0xfe}},
+	{"utf-16be", []byte{0xfe, 0xff}},
+}
+
+// DetectBOM returns the encoding indicated by the byte order mark at the
+// start of b and the length of the mark, or "utf-8" and 0 if there is none
+func DetectBOM(b []byte) (string, int) {
+	for _, m := range byteOrderMarks {
+		if bytes.HasPrefix(b, m.bom) {
+			return m.encoding, len(m.bom)
+		}
+	}
+	return "utf-8", 0
+}
+
+// IsAuto returns true if the encoding is detected from the byte order mark of the input
+func IsAuto(enc string) bool {
+	return strings.EqualFold(enc, autoEncoding)
 }
 
 func lookupEncoding(enc string) (encoding.Encoding, error) {
@@ -0,0 +1,46 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectBOM(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    []byte
+		expected string
+		length   int
+	}{
+		{"utf-8", []byte{0xef, 0xbb, 0xbf, 'a'}, "utf-8", 3},
+		{"utf-16le", []byte{0xff, 0xfe, 'a', 0x00}, "utf-16le", 2},
+		{"utf-16be", []byte{0xfe, 0xff, 0x00, 'a'}, "utf-16be", 2},
+		{"none", []byte("abc"), "utf-8", 0},
+		{"partial", []byte{0xef, 0xbb}, "utf-8", 0},
+		{"empty", []byte{}, "utf-8", 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			enc, n := DetectBOM(tc.input)
+			require.Equal(t, tc.expected, enc)
+			require.Equal(t, tc.length, n)
+		})
+	}
+}
+
+func TestBuildAutoEncoding(t *testing.T) {
+	require.True(t, IsAuto("auto"))
+	require.True(t, IsAuto("AUTO"))
+	require.False(t, IsAuto("utf-8"))
+
+	// Without a detected byte order mark, auto decodes utf-8
+	enc, err := EncodingConfig{Encoding: "auto"}.Build()
+	require.NoError(t, err)
+	decoded, err := enc.Decode([]byte("héllo"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("héllo"), decoded)
+}
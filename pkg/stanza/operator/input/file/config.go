@@ -4,6 +4,9 @@
 package file // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/input/file"
 
 import (
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
 	"go.uber.org/zap"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
@@ -34,6 +37,13 @@ func NewConfigWithID(operatorID string) *Config {
 type Config struct {
 	helper.InputConfig  `mapstructure:",squash"`
 	fileconsumer.Config `mapstructure:",squash"`
+	JSONBody            *JSONBodyConfig `mapstructure:"json_body,omitempty"`
+}
+
+// JSONBodyConfig enables emitting tokens that are JSON objects as structured bodies
+type JSONBodyConfig struct {
+	// Strict drops tokens that are not a JSON object instead of emitting them as string bodies
+	Strict bool `mapstructure:"strict,omitempty"`
 }
 
 // Build will build a file input operator from the supplied configuration
@@ -43,23 +53,33 @@ func (c Config) Build(logger *zap.SugaredLogger) (operator.Operator, error) {
 		return nil, err
 	}
 
-	var toBody toBodyFunc = func(token []byte) interface{} {
-		return string(token)
+	var toBody toBodyFunc = func(token []byte) (interface{}, error) {
+		return string(token), nil
 	}
 	if helper.IsNop(c.Config.Splitter.EncodingConfig.Encoding) {
-		toBody = func(token []byte) interface{} {
+		if c.JSONBody != nil {
+			return nil, fmt.Errorf("`json_body` cannot be used with the `nop` encoding")
+		}
+		toBody = func(token []byte) (interface{}, error) {
 			copied := make([]byte, len(token))
 			copy(copied, token)
-			return copied
+			return copied, nil
 		}
 	}
+	if c.JSONBody != nil {
+		toBody = jsonBody(jsoniter.ConfigFastest, c.JSONBody.Strict)
+	}
 
 	input := &Input{
 		InputOperator: inputOperator,
 		toBody:        toBody,
 	}
 
-	input.fileConsumer, err = c.Config.Build(logger, input.emit)
+	if c.Config.Batch != nil {
+		input.fileConsumer, err = c.Config.BuildWithBatchEmit(logger, input.emitBatch)
+	} else {
+		input.fileConsumer, err = c.Config.Build(logger, input.emit)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -425,6 +425,15 @@ func TestUnmarshal(t *testing.T) {
 					return cfg
 				}(),
 			},
+			{
+				Name:      "json_body_strict",
+				ExpectErr: false,
+				Expect: func() *Config {
+					cfg := NewConfig()
+					cfg.JSONBody = &JSONBodyConfig{Strict: true}
+					return cfg
+				}(),
+			},
 		},
 	}.Run(t)
 }
@@ -7,13 +7,37 @@ import (
 	"context"
 	"fmt"
 
+	jsoniter "github.com/json-iterator/go"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/multierr"
+
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
 )
 
-type toBodyFunc func([]byte) interface{}
+type toBodyFunc func([]byte) (interface{}, error)
+
+// jsonBody returns a toBodyFunc that emits tokens holding a JSON object as map bodies.
+// Other tokens are emitted as string bodies, or rejected with a permanent error if strict
+// is set, so that they are not emitted again with at_least_once.
+func jsonBody(json jsoniter.API, strict bool) toBodyFunc {
+	return func(token []byte) (interface{}, error) {
+		var body map[string]interface{}
+		err := json.Unmarshal(token, &body)
+		if err == nil && body == nil {
+			err = fmt.Errorf("token is not a JSON object")
+		}
+		if err == nil {
+			return body, nil
+		}
+		if strict {
+			return nil, consumererror.NewPermanent(fmt.Errorf("parse JSON body: %w", err))
+		}
+		return string(token), nil
+	}
+}
 
 // Input is an operator that monitors files for entries
 type Input struct {
@@ -35,20 +59,67 @@ func (f *Input) Stop() error {
 }
 
 func (f *Input) emit(ctx context.Context, token []byte, attrs map[string]any) error {
+	var body interface{}
 	if len(token) == 0 {
-		return nil
+		if _, ok := attrs[fileconsumer.LogFileEvent]; !ok {
+			return nil
+		}
+		// Events of the lifecycle of a file are emitted without a body
+	} else {
+		var err error
+		if body, err = f.toBody(token); err != nil {
+			return err
+		}
 	}
 
-	ent, err := f.NewEntry(f.toBody(token))
+	ent, err := f.NewEntry(body)
 	if err != nil {
 		return fmt.Errorf("create entry: %w", err)
 	}
 
+	f.setAttributes(ent, attrs)
+	f.Write(ctx, ent)
+	return nil
+}
+
+// emitBatch writes a single entry whose body holds the bodies of all tokens in the batch.
+// Tokens that are rejected are left out of it, and returned as permanent errors, so that the
+// batch is not emitted again with at_least_once, and the file consumer counts them.
+func (f *Input) emitBatch(ctx context.Context, tokens [][]byte, attrs map[string]any) error {
+	bodies := make([]any, 0, len(tokens))
+	var errs error
+	for _, token := range tokens {
+		if len(token) == 0 {
+			continue
+		}
+		body, err := f.toBody(token)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		bodies = append(bodies, body)
+	}
+	if len(bodies) == 0 {
+		if _, ok := attrs[fileconsumer.LogFileEvent]; !ok {
+			return errs
+		}
+		return multierr.Append(errs, f.emit(ctx, nil, attrs))
+	}
+
+	ent, err := f.NewEntry(bodies)
+	if err != nil {
+		return multierr.Append(errs, fmt.Errorf("create entry: %w", err))
+	}
+
+	f.setAttributes(ent, attrs)
+	f.Write(ctx, ent)
+	return errs
+}
+
+func (f *Input) setAttributes(ent *entry.Entry, attrs map[string]any) {
 	for k, v := range attrs {
 		if err := ent.Set(entry.NewAttributeField(k), v); err != nil {
 			f.Errorf("set attribute: %w", err)
 		}
 	}
-	f.Write(ctx, ent)
-	return nil
 }
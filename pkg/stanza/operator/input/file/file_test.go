@@ -11,8 +11,13 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
 )
 
@@ -238,3 +243,172 @@ func TestSkipEmpty(t *testing.T) {
 	waitForMessage(t, logReceived, "testlog1")
 	waitForMessage(t, logReceived, "testlog2")
 }
+
+// TestEmitOnOpen tests that the open event of a file is emitted as an entry without a body
+func TestEmitOnOpen(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *Config) {
+		cfg.EmitOnOpen = true
+	})
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "testlog1\n")
+
+	require.NoError(t, operator.Start(testutil.NewMockPersister("test")))
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	opened := waitForOne(t, logReceived)
+	require.Nil(t, opened.Body)
+	require.Equal(t, "file.opened", opened.Attributes[fileconsumer.LogFileEvent])
+	require.Equal(t, filepath.Base(temp.Name()), opened.Attributes["log.file.name"])
+	waitForMessage(t, logReceived, "testlog1")
+}
+
+// TestJSONBody tests that tokens holding a JSON object are emitted as map bodies
+func TestJSONBody(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *Config) {
+		cfg.JSONBody = &JSONBodyConfig{}
+	})
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, `{"message":"hello","count":2}`+"\n"+`{"message":`+"\n"+`["not","an","object"]`+"\n")
+
+	require.NoError(t, operator.Start(testutil.NewMockPersister("test")))
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	require.Equal(t, map[string]interface{}{"message": "hello", "count": float64(2)}, waitForOne(t, logReceived).Body)
+	waitForMessage(t, logReceived, `{"message":`)
+	waitForMessage(t, logReceived, `["not","an","object"]`)
+}
+
+// TestJSONBodyStrict tests that tokens which are not a JSON object are dropped in strict mode
+func TestJSONBodyStrict(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *Config) {
+		cfg.JSONBody = &JSONBodyConfig{Strict: true}
+	})
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, `{"message":`+"\n"+`{"message":"hello"}`+"\n"+"plain text\n")
+
+	require.NoError(t, operator.Start(testutil.NewMockPersister("test")))
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	require.Equal(t, map[string]interface{}{"message": "hello"}, waitForOne(t, logReceived).Body)
+	expectNoMessages(t, logReceived)
+}
+
+// TestJSONBodyStrictAtLeastOnce tests that tokens rejected in strict mode are dropped with
+// at_least_once, rather than being emitted again on every poll
+func TestJSONBodyStrictAtLeastOnce(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *Config) {
+		cfg.JSONBody = &JSONBodyConfig{Strict: true}
+		cfg.AtLeastOnce = true
+	})
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "plain text\n"+`{"message":"hello"}`+"\n")
+
+	require.NoError(t, operator.Start(testutil.NewMockPersister("test")))
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	require.Equal(t, map[string]interface{}{"message": "hello"}, waitForOne(t, logReceived).Body)
+	expectNoMessagesUntil(t, logReceived, time.Second)
+}
+
+// TestJSONBodyStrictAtLeastOnceBatch tests that a batch with tokens rejected in strict mode
+// is emitted once with at_least_once, without them, and that the file is read past a batch
+// whose every token was rejected
+func TestJSONBodyStrictAtLeastOnceBatch(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *Config) {
+		cfg.JSONBody = &JSONBodyConfig{Strict: true}
+		cfg.AtLeastOnce = true
+		cfg.Batch = &fileconsumer.BatchConfig{MaxTokens: 2}
+	})
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, `{"n":1}`+"\nplain\ntext\nonly\n"+`{"n":2}`+"\n"+`{"n":3}`+"\n")
+
+	require.NoError(t, operator.Start(testutil.NewMockPersister("test")))
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	require.Equal(t, []any{map[string]interface{}{"n": float64(1)}}, waitForOne(t, logReceived).Body)
+	require.Equal(t, []any{map[string]interface{}{"n": float64(2)}, map[string]interface{}{"n": float64(3)}}, waitForOne(t, logReceived).Body)
+	expectNoMessagesUntil(t, logReceived, time.Second)
+}
+
+// TestJSONBodyStrictRejectedCounted tests that tokens rejected in strict mode are counted and
+// logged at debug level, rather than logging an error for every one of them
+func TestJSONBodyStrictRejectedCounted(t *testing.T) {
+	for _, batch := range []*fileconsumer.BatchConfig{nil, {MaxTokens: 2}} {
+		views := fileconsumer.MetricViews()
+		require.NoError(t, view.Register(views...))
+
+		tempDir := t.TempDir()
+		cfg := newDefaultConfig(tempDir)
+		cfg.JSONBody = &JSONBodyConfig{Strict: true}
+		cfg.Batch = batch
+		core, observedLogs := observer.New(zap.DebugLevel)
+		op, err := cfg.Build(zap.New(core).Sugar())
+		require.NoError(t, err)
+		fakeOutput := testutil.NewFakeOutput(t)
+		require.NoError(t, op.SetOutputs([]operator.Operator{fakeOutput}))
+
+		temp := openTemp(t, tempDir)
+		writeString(t, temp, "plain\ntext\n"+`{"n":1}`+"\nonly\n")
+
+		require.NoError(t, op.Start(testutil.NewMockPersister("test")))
+		waitForOne(t, fakeOutput.Received)
+		require.Eventually(t, func() bool {
+			rows, err := view.RetrieveData("fileconsumer_rejected_tokens")
+			require.NoError(t, err)
+			return len(rows) == 1 && rows[0].Data.(*view.SumData).Value == 3
+		}, time.Second, 10*time.Millisecond)
+		require.NoError(t, op.Stop())
+		view.Unregister(views...)
+
+		require.Zero(t, observedLogs.FilterLevelExact(zap.ErrorLevel).Len())
+		require.NotZero(t, observedLogs.FilterMessage("Dropped tokens that were rejected").Len())
+	}
+}
+
+// TestBatch tests that each batch of tokens is emitted as a single entry
+func TestBatch(t *testing.T) {
+	t.Parallel()
+	operator, logReceived, tempDir := newTestFileOperator(t, func(cfg *Config) {
+		cfg.Batch = &fileconsumer.BatchConfig{MaxTokens: 2}
+	})
+
+	temp := openTemp(t, tempDir)
+	writeString(t, temp, "one\ntwo\nthree\n")
+
+	require.NoError(t, operator.Start(testutil.NewMockPersister("test")))
+	defer func() {
+		require.NoError(t, operator.Stop())
+	}()
+
+	require.Equal(t, []any{"one", "two"}, waitForOne(t, logReceived).Body)
+	require.Equal(t, []any{"three"}, waitForOne(t, logReceived).Body)
+	expectNoMessages(t, logReceived)
+}
+
+func TestJSONBodyNopEncoding(t *testing.T) {
+	cfg := newDefaultConfig(t.TempDir())
+	cfg.JSONBody = &JSONBodyConfig{}
+	cfg.Splitter.EncodingConfig.Encoding = "nop"
+	_, err := cfg.Build(testutil.Logger(t))
+	require.ErrorContains(t, err, "`json_body` cannot be used with the `nop` encoding")
+}
@@ -331,7 +331,71 @@ func Test_createLabelSet(t *testing.T) {
 	// run tests
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.ElementsMatch(t, tt.want, createAttributes(tt.resource, tt.orig, tt.externalLabels, tt.extras...))
+			assert.ElementsMatch(t, tt.want, createAttributes(tt.resource, tt.orig, Settings{ExternalLabels: tt.externalLabels}, tt.extras...))
+		})
+	}
+}
+
+// Test_createLabelSetExternalLabelsOverride checks external labels replace colliding series labels when
+// ExternalLabelsOverride is set, and that the labels are sorted by name.
+func Test_createLabelSetExternalLabelsOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		override bool
+		want     []prompb.Label
+	}{
+		{
+			"series_wins",
+			false,
+			getPromLabels(label11, value11, label12, value12, label31, value31),
+		},
+		{
+			"external_wins",
+			true,
+			getPromLabels(label11, value41, label12, value12, label31, value31),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := Settings{ExternalLabels: exlbs2, ExternalLabelsOverride: tt.override}
+			got := createAttributes(pcommon.NewResource(), lbs1, settings, label31, value31)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// Test_createLabelSetPassthroughLabelNames checks dotted attribute names are only sanitized when
+// PassthroughLabelNames is not set.
+func Test_createLabelSetPassthroughLabelNames(t *testing.T) {
+	attrs := pcommon.NewMap()
+	attrs.PutStr("http.method", "GET")
+	attrs.PutStr("net.peer.name", "localhost")
+
+	tests := []struct {
+		name        string
+		passthrough bool
+		want        []prompb.Label
+	}{
+		{
+			"legacy_underscore",
+			false,
+			getPromLabels("__name__", "requests", "http_method", "GET", "net_peer_name", "localhost", "service.tier", "db"),
+		},
+		{
+			"utf8_passthrough",
+			true,
+			getPromLabels("__name__", "requests", "http.method", "GET", "net.peer.name", "localhost", "service.tier", "db"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := Settings{
+				// External labels are sanitized by their owner
+				ExternalLabels:        map[string]string{"service.tier": "db"},
+				PassthroughLabelNames: tt.passthrough,
+			}
+			got := createAttributes(pcommon.NewResource(), attrs, settings, model.MetricNameLabel, "requests")
+			assert.Equal(t, tt.want, got)
 		})
 	}
 }
@@ -349,7 +413,7 @@ func BenchmarkCreateAttributes(b *testing.B) {
 	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		createAttributes(r, m, ext)
+		createAttributes(r, m, Settings{ExternalLabels: ext})
 	}
 }
 
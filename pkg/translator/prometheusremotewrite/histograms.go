@@ -26,7 +26,7 @@ func addSingleExponentialHistogramDataPoint(
 	labels := createAttributes(
 		resource,
 		pt.Attributes(),
-		settings.ExternalLabels,
+		settings,
 		model.MetricNameLabel, metric,
 	)
 
@@ -6,13 +6,12 @@ package prometheusremotewrite // import "github.com/open-telemetry/opentelemetry
 import (
 	"errors"
 	"fmt"
+	"regexp"
 
 	"github.com/prometheus/prometheus/prompb"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.uber.org/multierr"
-
-	prometheustranslator "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/prometheus"
 )
 
 type Settings struct {
@@ -21,6 +20,43 @@ type Settings struct {
 	DisableTargetInfo   bool
 	ExportCreatedMetric bool
 	AddMetricSuffixes   bool
+
+	// ExternalLabelsOverride makes external labels replace the labels of series that have
+	// the same name, rather than be replaced by them.
+	ExternalLabelsOverride bool
+
+	// PassthroughLabelNames leaves label names untouched, instead of replacing the characters
+	// that are invalid in Prometheus 2.x with underscores, for endpoints accepting UTF-8 names.
+	PassthroughLabelNames bool
+
+	// SkipMetricTypes holds the OTLP metric types that are dropped before conversion.
+	SkipMetricTypes map[pmetric.MetricType]bool
+
+	// MetricNameRules rename metrics on conversion. The first rule matching the
+	// OTLP metric name sets the exported name, other metrics keep the default name.
+	MetricNameRules []MetricNameRule
+}
+
+// MetricNameRule maps an OTLP metric name to the name of the exported Prometheus metric.
+type MetricNameRule struct {
+	// Name matches the OTLP metric name exactly. Ignored if Regex is set.
+	Name string
+	// Regex matches the OTLP metric name. Target may reference its capture groups.
+	Regex *regexp.Regexp
+	// Target is the exported metric name, before forbidden characters are replaced.
+	Target string
+}
+
+// rename returns the target name for metricName, if the rule matches.
+func (r MetricNameRule) rename(metricName string) (string, bool) {
+	if r.Regex == nil {
+		return r.Target, metricName == r.Name
+	}
+	match := r.Regex.FindStringSubmatchIndex(metricName)
+	if match == nil {
+		return "", false
+	}
+	return string(r.Regex.ExpandString(nil, r.Target, metricName, match)), true
 }
 
 // FromMetrics converts pmetric.Metrics to prometheus remote write format.
@@ -42,6 +78,9 @@ func FromMetrics(md pmetric.Metrics, settings Settings) (tsMap map[string]*promp
 			// TODO: decide if instrumentation library information should be exported as labels
 			for k := 0; k < metricSlice.Len(); k++ {
 				metric := metricSlice.At(k)
+				if settings.SkipMetricTypes[metric.Type()] {
+					continue
+				}
 				mostRecentTimestamp = maxTimestamp(mostRecentTimestamp, mostRecentTimestampInMetric(metric))
 
 				if !isValidAggregationTemporality(metric) {
@@ -80,7 +119,7 @@ func FromMetrics(md pmetric.Metrics, settings Settings) (tsMap map[string]*promp
 					if dataPoints.Len() == 0 {
 						errs = multierr.Append(errs, fmt.Errorf("empty data points. %s is dropped", metric.Name()))
 					}
-					name := prometheustranslator.BuildCompliantName(metric, settings.Namespace, settings.AddMetricSuffixes)
+					name := buildMetricName(metric, settings)
 					for x := 0; x < dataPoints.Len(); x++ {
 						errs = multierr.Append(
 							errs,
@@ -0,0 +1,125 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewrite
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestFromMetricsSkipMetricTypes(t *testing.T) {
+	l := pcommon.NewMap()
+	quantiles := pmetric.NewSummaryDataPointValueAtQuantileSlice()
+	quantiles.AppendEmpty().SetValue(1)
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	getIntGaugeMetric("gauge", l, 1, time1).MoveTo(metrics.AppendEmpty())
+	getIntSumMetric("counter", l, pmetric.AggregationTemporalityCumulative, 1, time1).MoveTo(metrics.AppendEmpty())
+	getHistogramMetric("histogram", l, pmetric.AggregationTemporalityCumulative, time1, 1, 1, []float64{1}, []uint64{1, 0}).MoveTo(metrics.AppendEmpty())
+	getSummaryMetric("summary", l, time1, 1, 1, quantiles).MoveTo(metrics.AppendEmpty())
+
+	expHist := metrics.AppendEmpty()
+	expHist.SetName("exphistogram")
+	expHist.SetEmptyExponentialHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	dp := expHist.ExponentialHistogram().DataPoints().AppendEmpty()
+	dp.SetCount(1)
+	dp.SetSum(1)
+	dp.Positive().BucketCounts().FromRaw([]uint64{1})
+	dp.SetTimestamp(pcommon.Timestamp(time1))
+
+	allTypes := []pmetric.MetricType{
+		pmetric.MetricTypeGauge,
+		pmetric.MetricTypeSum,
+		pmetric.MetricTypeHistogram,
+		pmetric.MetricTypeExponentialHistogram,
+		pmetric.MetricTypeSummary,
+	}
+
+	// metricTypeOf maps a converted series name back to the OTLP metric type it originates from.
+	metricTypeOf := func(name string) pmetric.MetricType {
+		switch {
+		case strings.HasPrefix(name, "gauge"):
+			return pmetric.MetricTypeGauge
+		case strings.HasPrefix(name, "counter"):
+			return pmetric.MetricTypeSum
+		case strings.HasPrefix(name, "histogram"):
+			return pmetric.MetricTypeHistogram
+		case strings.HasPrefix(name, "exphistogram"):
+			return pmetric.MetricTypeExponentialHistogram
+		case strings.HasPrefix(name, "summary"):
+			return pmetric.MetricTypeSummary
+		}
+		return pmetric.MetricTypeEmpty
+	}
+
+	for _, skipped := range allTypes {
+		t.Run(skipped.String(), func(t *testing.T) {
+			settings := Settings{
+				DisableTargetInfo: true,
+				SkipMetricTypes:   map[pmetric.MetricType]bool{skipped: true},
+			}
+			tsMap, err := FromMetrics(md, settings)
+			require.NoError(t, err)
+
+			seen := map[pmetric.MetricType]bool{}
+			for _, ts := range tsMap {
+				for _, lbl := range ts.Labels {
+					if lbl.Name == model.MetricNameLabel {
+						seen[metricTypeOf(lbl.Value)] = true
+					}
+				}
+			}
+
+			for _, mt := range allTypes {
+				assert.Equal(t, mt != skipped, seen[mt], "unexpected conversion result for %s", mt)
+			}
+		})
+	}
+}
+
+func TestFromMetricsMetricNameRules(t *testing.T) {
+	l := pcommon.NewMap()
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	getHistogramMetric("http.server.duration", l, pmetric.AggregationTemporalityCumulative, time1, 1, 1, []float64{1}, []uint64{1, 0}).MoveTo(metrics.AppendEmpty())
+	getIntGaugeMetric("rpc.client.inflight", l, 1, time1).MoveTo(metrics.AppendEmpty())
+	getIntGaugeMetric("system.memory.usage", l, 1, time1).MoveTo(metrics.AppendEmpty())
+
+	settings := Settings{
+		DisableTargetInfo: true,
+		MetricNameRules: []MetricNameRule{
+			{Name: "http.server.duration", Target: "http_request_duration_seconds"},
+			{Regex: regexp.MustCompile(`^rpc\.(\w+)\.inflight$`), Target: "rpc_${1}.requests_in_flight"},
+			// Never reached, the exact rule above matches first
+			{Regex: regexp.MustCompile(`^http\.`), Target: "shadowed"},
+		},
+	}
+	tsMap, err := FromMetrics(md, settings)
+	require.NoError(t, err)
+
+	var names []string
+	for _, ts := range tsMap {
+		for _, lbl := range ts.Labels {
+			if lbl.Name == model.MetricNameLabel {
+				names = append(names, lbl.Value)
+			}
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"http_request_duration_seconds_sum",
+		"http_request_duration_seconds_count",
+		"http_request_duration_seconds_bucket",
+		"http_request_duration_seconds_bucket",
+		"rpc_client_requests_in_flight",
+		"system_memory_usage",
+	}, names)
+}
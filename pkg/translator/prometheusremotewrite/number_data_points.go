@@ -11,8 +11,6 @@ import (
 	"github.com/prometheus/prometheus/prompb"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
-
-	prometheustranslator "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/prometheus"
 )
 
 // addSingleSumNumberDataPoint converts the Gauge metric data point to a
@@ -25,11 +23,11 @@ func addSingleGaugeNumberDataPoint(
 	settings Settings,
 	series map[string]*prompb.TimeSeries,
 ) {
-	name := prometheustranslator.BuildCompliantName(metric, settings.Namespace, settings.AddMetricSuffixes)
+	name := buildMetricName(metric, settings)
 	labels := createAttributes(
 		resource,
 		pt.Attributes(),
-		settings.ExternalLabels,
+		settings,
 		model.MetricNameLabel, name,
 	)
 	sample := &prompb.Sample{
@@ -58,11 +56,11 @@ func addSingleSumNumberDataPoint(
 	settings Settings,
 	series map[string]*prompb.TimeSeries,
 ) {
-	name := prometheustranslator.BuildCompliantName(metric, settings.Namespace, settings.AddMetricSuffixes)
+	name := buildMetricName(metric, settings)
 	labels := createAttributes(
 		resource,
 		pt.Attributes(),
-		settings.ExternalLabels,
+		settings,
 		model.MetricNameLabel, name,
 	)
 	sample := &prompb.Sample{
@@ -92,7 +90,7 @@ func addSingleSumNumberDataPoint(
 			createdLabels := createAttributes(
 				resource,
 				pt.Attributes(),
-				settings.ExternalLabels,
+				settings,
 				nameStr,
 				name+createdSuffix,
 			)
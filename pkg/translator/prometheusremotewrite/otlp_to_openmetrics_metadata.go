@@ -0,0 +1,59 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewrite // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/translator/prometheusremotewrite"
+
+import (
+	"github.com/prometheus/prometheus/prompb"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func otelMetricTypeToPromMetricType(metric pmetric.Metric) prompb.MetricMetadata_MetricType {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		return prompb.MetricMetadata_GAUGE
+	case pmetric.MetricTypeSum:
+		if metric.Sum().IsMonotonic() {
+			return prompb.MetricMetadata_COUNTER
+		}
+		return prompb.MetricMetadata_GAUGE
+	case pmetric.MetricTypeHistogram, pmetric.MetricTypeExponentialHistogram:
+		return prompb.MetricMetadata_HISTOGRAM
+	case pmetric.MetricTypeSummary:
+		return prompb.MetricMetadata_SUMMARY
+	}
+	return prompb.MetricMetadata_UNKNOWN
+}
+
+// OtelMetricsToMetadata returns the type, help and unit of every metric family that
+// FromMetrics converts md to, once per metric family name.
+func OtelMetricsToMetadata(md pmetric.Metrics, settings Settings) []prompb.MetricMetadata {
+	var metadata []prompb.MetricMetadata
+	seen := make(map[string]bool)
+
+	resourceMetricsSlice := md.ResourceMetrics()
+	for i := 0; i < resourceMetricsSlice.Len(); i++ {
+		scopeMetricsSlice := resourceMetricsSlice.At(i).ScopeMetrics()
+		for j := 0; j < scopeMetricsSlice.Len(); j++ {
+			metricSlice := scopeMetricsSlice.At(j).Metrics()
+			for k := 0; k < metricSlice.Len(); k++ {
+				metric := metricSlice.At(k)
+				if settings.SkipMetricTypes[metric.Type()] || !isValidAggregationTemporality(metric) {
+					continue
+				}
+				name := buildMetricName(metric, settings)
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				metadata = append(metadata, prompb.MetricMetadata{
+					Type:             otelMetricTypeToPromMetricType(metric),
+					MetricFamilyName: name,
+					Help:             metric.Description(),
+					Unit:             metric.Unit(),
+				})
+			}
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,58 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheusremotewrite
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestOtelMetricsToMetadata(t *testing.T) {
+	l := pcommon.NewMap()
+	md := pmetric.NewMetrics()
+	for i := 0; i < 2; i++ {
+		// Every resource holds the same metrics, their metadata is returned once
+		metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+
+		histogram := getHistogramMetric("http.server.duration", l, pmetric.AggregationTemporalityCumulative, time1, 1, 1, []float64{1}, []uint64{1, 0})
+		histogram.SetDescription("Duration of HTTP server requests.")
+		histogram.SetUnit("ms")
+		histogram.MoveTo(metrics.AppendEmpty())
+
+		counter := getIntSumMetric("http.server.requests", l, pmetric.AggregationTemporalityCumulative, 1, time1)
+		counter.Sum().SetIsMonotonic(true)
+		counter.SetDescription("Number of HTTP server requests.")
+		counter.MoveTo(metrics.AppendEmpty())
+
+		getIntGaugeMetric("system.memory.usage", l, 1, time1).MoveTo(metrics.AppendEmpty())
+
+		// Not converted, so without metadata
+		getIntSumMetric("delta.sum", l, pmetric.AggregationTemporalityDelta, 1, time1).MoveTo(metrics.AppendEmpty())
+	}
+
+	metadata := OtelMetricsToMetadata(md, Settings{
+		MetricNameRules: []MetricNameRule{{Name: "http.server.duration", Target: "http_request_duration"}},
+	})
+	assert.Equal(t, []prompb.MetricMetadata{
+		{
+			Type:             prompb.MetricMetadata_HISTOGRAM,
+			MetricFamilyName: "http_request_duration",
+			Help:             "Duration of HTTP server requests.",
+			Unit:             "ms",
+		},
+		{
+			Type:             prompb.MetricMetadata_COUNTER,
+			MetricFamilyName: "http_server_requests",
+			Help:             "Number of HTTP server requests.",
+		},
+		{
+			Type:             prompb.MetricMetadata_GAUGE,
+			MetricFamilyName: "system_memory_usage",
+		},
+	}, metadata)
+}
@@ -125,6 +125,13 @@ func testdataConfigYamlAsMap() *Config {
 			FingerprintSize:         1000,
 			MaxLogSize:              1024 * 1024,
 			MaxConcurrentFiles:      1024,
+			EncodingErrorMode:       "replace",
+			FingerprintStrategy:     "prefix",
+			Decompression:           "none",
+			Framing:                 "delimited",
+			ReadMode:                "buffered",
+			ArchiveMode:             "none",
+			OnDeleteWhileReading:    "finish-inode",
 			MatchingCriteria: fileconsumer.MatchingCriteria{
 				Include: []string{"/var/log/*.log"},
 				Exclude: []string{"/var/log/example.log"},